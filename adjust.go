@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 公司行動類型
+const (
+	actionDividend  = "除權息"
+	actionReduction = "減資"
+	actionSplit     = "面額變更"
+)
+
+// 還原權息價格的來源
+const (
+	adjustSourceActions  = "除權息資料"
+	adjustSourceAdjClose = "Yahoo還原收盤價"
+)
+
+// CorporateAction 影響股價連續性的公司行動 (現金股利、股票股利、減資、面額變更)
+// 以停止買賣前收盤價及恢復買賣參考價換算調整係數，股利金額及配股比例已反映在參考價中
+type CorporateAction struct {
+	Date   time.Time `json:"date"` // 除權息或恢復買賣日
+	Kind   string    `json:"kind"`
+	Before float64   `json:"before"` // 前一交易日收盤價
+	After  float64   `json:"after"`  // 參考價
+	Note   string    `json:"note,omitempty"`
+}
+
+// Factor 該日之前價格的調整係數
+func (a CorporateAction) Factor() float64 {
+	if a.Before <= 0 || a.After <= 0 {
+		return 1
+	}
+	return a.After / a.Before
+}
+
+// changesShares 是否改變流通股數 (減資、面額變更)，成交量須隨之調整；除權息只影響價格
+func (a CorporateAction) changesShares() bool {
+	return a.Kind == actionReduction || a.Kind == actionSplit
+}
+
+// AdjustBars 以公司行動向前還原K棒 (最新價格不變，之前的價格依序乘上調整係數)
+// 成交量只依減資及面額變更反向調整，使前後的成交股數可比較；K棒須為未還原的原始價格 (官方行情)
+func AdjustBars(bars []Bar, actions []CorporateAction) []Bar {
+	sorted := make([]CorporateAction, len(actions))
+	copy(sorted, actions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.After(sorted[j].Date) })
+
+	adjusted := make([]Bar, len(bars))
+	factor, volumeFactor := 1.0, 1.0
+	next := 0
+	for i := len(bars) - 1; i >= 0; i-- {
+		day := startOfDay(bars[i].Time)
+		for next < len(sorted) && startOfDay(sorted[next].Date).After(day) {
+			factor *= sorted[next].Factor()
+			if sorted[next].changesShares() {
+				volumeFactor *= sorted[next].Factor()
+			}
+			next++
+		}
+		adjusted[i] = scaleBar(bars[i], factor, volumeFactor)
+	}
+	return adjusted
+}
+
+// AdjustBarsByAdjClose 以來源提供的還原收盤價比例調整K棒 (Yahoo 行情)
+// Yahoo 收盤價已反映分割，還原收盤價只另外反映股利，成交量不調整
+func AdjustBarsByAdjClose(bars []Bar) []Bar {
+	adjusted := make([]Bar, len(bars))
+	for i, bar := range bars {
+		factor := 1.0
+		if bar.Close > 0 && bar.AdjClose > 0 {
+			factor = bar.AdjClose / bar.Close
+		}
+		adjusted[i] = scaleBar(bar, factor, 1)
+	}
+	return adjusted
+}
+
+// scaleBar 依價格調整係數換算K棒價格，成交量除以股數調整係數
+func scaleBar(bar Bar, factor, volumeFactor float64) Bar {
+	if factor > 0 && factor != 1 {
+		bar.Open *= factor
+		bar.High *= factor
+		bar.Low *= factor
+		bar.Close *= factor
+	}
+	bar.AdjClose = bar.Close
+	if volumeFactor > 0 && volumeFactor != 1 {
+		bar.Volume = int64(float64(bar.Volume) / volumeFactor)
+	}
+	return bar
+}
+
+// FetchCorporateActions 從FinMind取得期間內的除權息、減資及面額變更
+func (s *StockScreener) FetchCorporateActions(ctx context.Context, code string, start, end time.Time) ([]CorporateAction, error) {
+	var actions []CorporateAction
+
+	dividends, err := s.finmind.DividendResults(ctx, code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dividends {
+		actions = appendAction(actions, d.Date, actionDividend, d.BeforePrice, d.AfterPrice,
+			fmt.Sprintf("%s %.2f", d.DividendType, d.Dividend))
+	}
+
+	reductions, err := s.finmind.CapitalReductions(ctx, code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reductions {
+		actions = appendAction(actions, r.Date, actionReduction, r.LastClose, r.ReferencePrice, r.Reason)
+	}
+
+	splits, err := s.finmind.SplitPrices(ctx, code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, sp := range splits {
+		actions = appendAction(actions, sp.Date, actionSplit, sp.BeforePrice, sp.AfterPrice, sp.Type)
+	}
+
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Date.Before(actions[j].Date) })
+	return actions, nil
+}
+
+// appendAction 加入有效的公司行動 (日期可解析且前後價格皆大於零)
+func appendAction(actions []CorporateAction, date, kind string, before, after float64, note string) []CorporateAction {
+	t, err := time.ParseInLocation("2006-01-02", date, taipeiLocation)
+	if err != nil || before <= 0 || after <= 0 {
+		return actions
+	}
+	return append(actions, CorporateAction{Date: t, Kind: kind, Before: before, After: after, Note: note})
+}
+
+// adjustPrices 建立還原權息K棒
+// Yahoo 行情直接使用其還原收盤價，不另外呼叫API；官方行情無還原收盤價，改依FinMind公司行動資料還原
+func (s *StockScreener) adjustPrices(ctx context.Context, stock *StockData) {
+	stock.adjBars, stock.actions, stock.AdjustSource = nil, nil, ""
+	if len(stock.bars) == 0 {
+		return
+	}
+
+	if stock.PriceSource == priceSourceYahoo {
+		stock.adjBars = AdjustBarsByAdjClose(stock.bars)
+		stock.AdjustSource = adjustSourceAdjClose
+		return
+	}
+
+	actions, err := s.FetchCorporateActions(ctx, stock.Code, stock.bars[0].Time, taipeiNow())
+	if err != nil {
+		s.logger.Warn("無法取得除權息資料，技術指標未還原權息", logKeyStock, stock.Code, "error", err)
+		s.summary.markDegraded(stock.Code, "除權息資料失敗，技術指標未還原權息")
+		return
+	}
+	stock.actions = actions
+	stock.adjBars = AdjustBars(stock.bars, actions)
+	stock.AdjustSource = adjustSourceActions
+	s.logger.Debug("還原權息", logKeyStock, stock.Code, "actions", len(actions))
+}
+
+// loadCorporateActions 取得期間內的公司行動供單一股票顯示 (以還原收盤價還原時篩選流程不會取得)
+func (s *StockScreener) loadCorporateActions(ctx context.Context, stock *StockData) {
+	if stock.AdjustSource != adjustSourceAdjClose || stock.actions != nil || len(stock.bars) == 0 {
+		return
+	}
+	actions, err := s.FetchCorporateActions(ctx, stock.Code, stock.bars[0].Time, taipeiNow())
+	if err != nil {
+		s.logger.Warn("無法取得除權息資料", logKeyStock, stock.Code, "error", err)
+		return
+	}
+	stock.actions = actions
+}
+
+// indicatorBars 技術指標使用的K棒 (依設定使用還原或原始價格)，只取設定資料區間內的K棒
+func (s *StockScreener) indicatorBars(stock *StockData) []Bar {
+	return chartWindow(s.historyBars(stock), s.chart)
+}
+
+// historyBars 完整價格歷史 (依設定使用還原或原始價格)，可能長於設定資料區間 (見 fetchOptions)
+func (s *StockScreener) historyBars(stock *StockData) []Bar {
+	if s.chart.Adjusted && len(stock.adjBars) > 0 {
+		return stock.adjBars
+	}
+	return stock.bars
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// barsWithVolume 每根K棒成交量皆為 volume 的日K棒
+func barsWithVolume(volume int64, closes ...float64) []Bar {
+	bars := dailyBars(closes...)
+	for i := range bars {
+		bars[i].Volume = volume
+	}
+	return bars
+}
+
+func TestAdjustBars(t *testing.T) {
+	days := dailyBars(make([]float64, 4)...)
+	action := func(i int, kind string, before, after float64) CorporateAction {
+		return CorporateAction{Date: days[i].Time, Kind: kind, Before: before, After: after}
+	}
+	// 除息前一日開盤價 98.3 較接近參考價換算值 97，仍為原始價格須還原
+	lowOpen := barsWithVolume(1000, 100, 100, 97, 98)
+	lowOpen[1].Open = 98.3
+
+	tests := []struct {
+		name        string
+		bars        []Bar
+		actions     []CorporateAction
+		wantCloses  []float64
+		wantVolumes []int64
+		wantOpens   []float64 // nil 表示與收盤價相同
+	}{
+		{"無公司行動", barsWithVolume(1000, 100, 101, 102), nil,
+			[]float64{100, 101, 102}, []int64{1000, 1000, 1000}, nil},
+		{"現金股利5元", barsWithVolume(1000, 100, 100, 95, 96),
+			[]CorporateAction{action(2, actionDividend, 100, 95)},
+			[]float64{95, 95, 95, 96}, []int64{1000, 1000, 1000, 1000}, nil},
+		{"面額變更一拆四", barsWithVolume(1000, 400, 400, 100, 101),
+			[]CorporateAction{action(2, actionSplit, 400, 100)},
+			[]float64{100, 100, 100, 101}, []int64{4000, 4000, 1000, 1000}, nil},
+		{"多次事件累乘", barsWithVolume(1000, 200, 190, 95, 95),
+			[]CorporateAction{action(1, actionDividend, 200, 190), action(2, actionSplit, 190, 95)},
+			[]float64{95, 95, 95, 95}, []int64{2000, 2000, 1000, 1000}, nil},
+		{"減資五成", barsWithVolume(1000, 20, 20, 40, 41),
+			[]CorporateAction{action(2, actionReduction, 20, 40)},
+			[]float64{40, 40, 40, 41}, []int64{500, 500, 1000, 1000}, nil},
+		{"開盤價不同於收盤價", lowOpen,
+			[]CorporateAction{action(2, actionDividend, 100, 97)},
+			[]float64{97, 97, 97, 98}, []int64{1000, 1000, 1000, 1000}, []float64{97, 98.3 * 0.97, 97, 98}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(tt.bars)
+			wantOpens := tt.wantOpens
+			if wantOpens == nil {
+				wantOpens = tt.wantCloses
+			}
+			adjusted := AdjustBars(tt.bars, tt.actions)
+			if len(adjusted) != len(tt.bars) {
+				t.Fatalf("len = %d, want %d", len(adjusted), len(tt.bars))
+			}
+			for i, bar := range adjusted {
+				if !approxEqual(bar.Close, tt.wantCloses[i], 1e-9) || !approxEqual(bar.Open, wantOpens[i], 1e-9) ||
+					bar.AdjClose != bar.Close || bar.Volume != tt.wantVolumes[i] {
+					t.Errorf("bar %d = %+v, want open %v close %v volume %d", i, bar, wantOpens[i], tt.wantCloses[i], tt.wantVolumes[i])
+				}
+			}
+			if !slices.Equal(tt.bars, original) {
+				t.Errorf("原始K棒被修改: %+v", tt.bars)
+			}
+		})
+	}
+}
+
+func TestAdjustBarsByAdjClose(t *testing.T) {
+	bars := barsWithVolume(1000, 100, 100, 95)
+	bars[0].AdjClose, bars[1].AdjClose = 95, 95
+	adjusted := AdjustBarsByAdjClose(bars)
+
+	for i, want := range []float64{95, 95, 95} {
+		// 還原收盤價只反映股利，成交量不調整
+		if !approxEqual(adjusted[i].Close, want, 1e-9) || !approxEqual(adjusted[i].High, want, 1e-9) || adjusted[i].Volume != 1000 {
+			t.Errorf("bar %d = %+v, want %v", i, adjusted[i], want)
+		}
+	}
+	if bars[0].Close != 100 {
+		t.Errorf("原始K棒被修改: %+v", bars[0])
+	}
+}
+
+func TestAdjustPricesUsesYahooAdjClose(t *testing.T) {
+	// 未設定 FinMind 客戶端: Yahoo 行情不應取得公司行動資料
+	s := &StockScreener{logger: slog.New(slog.DiscardHandler)}
+	bars := dailyBars(100, 100, 95)
+	bars[0].AdjClose, bars[1].AdjClose = 95, 95
+	stock := &StockData{Code: "2330", PriceSource: priceSourceYahoo, bars: bars}
+
+	s.adjustPrices(context.Background(), stock)
+	if stock.AdjustSource != adjustSourceAdjClose || len(stock.adjBars) != 3 || stock.adjBars[0].Close != 95 {
+		t.Errorf("AdjustSource = %q, adjBars = %+v", stock.AdjustSource, stock.adjBars)
+	}
+}
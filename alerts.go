@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 警示規則與狀態檔
+const (
+	defaultAlertRulesFile = "alert_rules.json"
+	defaultAlertStateFile = "alert_state.json"
+)
+
+// 警示規則類型
+const (
+	AlertPriceCrossMA60  = "price_cross_ma60"  // 股價穿越MA60
+	AlertKCrossD         = "k_cross_d"         // K值穿越D值
+	AlertRSIBelow        = "rsi_below"         // RSI低於門檻
+	AlertRevenueYoYAbove = "revenue_yoy_above" // 月營收年增率高於門檻
+)
+
+// 交叉方向
+const (
+	CrossUp   = "up"
+	CrossDown = "down"
+)
+
+// AlertRule 自選股警示規則
+type AlertRule struct {
+	ID        string  `json:"id,omitempty"`
+	Code      string  `json:"code"`
+	Type      string  `json:"type"`
+	Direction string  `json:"direction,omitempty"` // 交叉類規則: up / down，空白表示雙向
+	Threshold float64 `json:"threshold,omitempty"` // 門檻類規則使用
+}
+
+// key 規則識別碼，未指定ID時由規則內容產生
+func (r AlertRule) key() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return fmt.Sprintf("%s:%s:%s:%g", r.Code, r.Type, r.Direction, r.Threshold)
+}
+
+// AlertRuleState 規則上次評估的狀態
+type AlertRuleState struct {
+	Condition   bool               `json:"condition"`
+	Values      map[string]float64 `json:"values"`
+	EvaluatedAt time.Time          `json:"evaluated_at"`
+	LastFiredAt time.Time          `json:"last_fired_at,omitempty"`
+}
+
+// AlertEvent 觸發的警示
+type AlertEvent struct {
+	RuleID  string    `json:"rule_id"`
+	Code    string    `json:"code"`
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+	FiredAt time.Time `json:"fired_at"`
+}
+
+// AlertEngine 警示規則評估器
+type AlertEngine struct {
+	rules     []AlertRule
+	state     map[string]*AlertRuleState
+	statePath string
+}
+
+// LoadAlertEngine 載入警示規則及上次評估狀態，規則檔不存在時回傳 nil
+func LoadAlertEngine(rulesPath, statePath string) (*AlertEngine, error) {
+	data, err := os.ReadFile(rulesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []AlertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析警示規則失敗: %v", err)
+	}
+
+	for _, rule := range rules {
+		switch rule.Type {
+		case AlertPriceCrossMA60, AlertKCrossD:
+			if rule.Direction != "" && rule.Direction != CrossUp && rule.Direction != CrossDown {
+				return nil, fmt.Errorf("警示規則 %s 方向錯誤: %s", rule.key(), rule.Direction)
+			}
+		case AlertRSIBelow, AlertRevenueYoYAbove:
+		default:
+			return nil, fmt.Errorf("未知的警示規則類型: %s", rule.Type)
+		}
+	}
+
+	engine := &AlertEngine{
+		rules:     rules,
+		state:     make(map[string]*AlertRuleState),
+		statePath: statePath,
+	}
+
+	data, err = os.ReadFile(statePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &engine.state); err != nil {
+			return nil, fmt.Errorf("解析警示狀態失敗: %v", err)
+		}
+	}
+
+	return engine, nil
+}
+
+// Codes 取得自選股清單
+func (e *AlertEngine) Codes() []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, rule := range e.rules {
+		if !seen[rule.Code] {
+			seen[rule.Code] = true
+			codes = append(codes, rule.Code)
+		}
+	}
+	return codes
+}
+
+// Evaluate 以最新資料評估該股票的所有規則，條件由不成立轉為成立時觸發一次
+func (e *AlertEngine) Evaluate(stock *StockData) []AlertEvent {
+	var events []AlertEvent
+	now := taipeiNow()
+
+	for _, rule := range e.rules {
+		if rule.Code != stock.Code {
+			continue
+		}
+
+		condition, values, ok := evaluateAlertCondition(rule, stock)
+		if !ok {
+			continue
+		}
+
+		key := rule.key()
+		prev, hasPrev := e.state[key]
+		state := &AlertRuleState{
+			Condition:   condition,
+			Values:      values,
+			EvaluatedAt: now,
+		}
+		if hasPrev {
+			state.LastFiredAt = prev.LastFiredAt
+		}
+
+		if fired, message := alertTriggered(rule, prev, state); fired {
+			state.LastFiredAt = now
+			events = append(events, AlertEvent{
+				RuleID:  key,
+				Code:    stock.Code,
+				Name:    stock.Name,
+				Type:    rule.Type,
+				Message: message,
+				FiredAt: now,
+			})
+		}
+
+		e.state[key] = state
+	}
+
+	return events
+}
+
+// evaluateAlertCondition 計算規則條件，資料不足時 ok 為 false
+func evaluateAlertCondition(rule AlertRule, stock *StockData) (condition bool, values map[string]float64, ok bool) {
+	switch rule.Type {
+	case AlertPriceCrossMA60:
+		if stock.Price <= 0 || stock.MA60 <= 0 {
+			return false, nil, false
+		}
+		return stock.Price > stock.MA60, map[string]float64{"price": stock.Price, "ma60": stock.MA60}, true
+	case AlertKCrossD:
+		if stock.KValue == 0 && stock.DValue == 0 {
+			return false, nil, false
+		}
+		return stock.KValue > stock.DValue, map[string]float64{"k": stock.KValue, "d": stock.DValue}, true
+	case AlertRSIBelow:
+		if stock.RSI == 0 {
+			return false, nil, false
+		}
+		return stock.RSI < rule.Threshold, map[string]float64{"rsi": stock.RSI}, true
+	case AlertRevenueYoYAbove:
+		if stock.MonthlyRevenueMonth == "" {
+			return false, nil, false
+		}
+		return stock.MonthlyRevenueYoY > rule.Threshold, map[string]float64{"revenue_yoy": stock.MonthlyRevenueYoY}, true
+	}
+	return false, nil, false
+}
+
+// alertTriggered 比對前後狀態判斷是否觸發警示
+func alertTriggered(rule AlertRule, prev, curr *AlertRuleState) (bool, string) {
+	switch rule.Type {
+	case AlertPriceCrossMA60, AlertKCrossD:
+		// 交叉類規則需有前次狀態才能判斷穿越
+		if prev == nil || prev.Condition == curr.Condition {
+			return false, ""
+		}
+		direction := CrossDown
+		if curr.Condition {
+			direction = CrossUp
+		}
+		if rule.Direction != "" && rule.Direction != direction {
+			return false, ""
+		}
+		return true, crossMessage(rule.Type, direction, curr.Values)
+	case AlertRSIBelow:
+		if !curr.Condition || (prev != nil && prev.Condition) {
+			return false, ""
+		}
+		return true, fmt.Sprintf("RSI %.1f 低於 %.1f", curr.Values["rsi"], rule.Threshold)
+	case AlertRevenueYoYAbove:
+		if !curr.Condition || (prev != nil && prev.Condition) {
+			return false, ""
+		}
+		return true, fmt.Sprintf("月營收年增率 %.1f%% 高於 %.1f%%", curr.Values["revenue_yoy"], rule.Threshold)
+	}
+	return false, ""
+}
+
+// crossMessage 產生交叉警示訊息
+func crossMessage(ruleType, direction string, values map[string]float64) string {
+	action := "跌破"
+	if direction == CrossUp {
+		action = "突破"
+	}
+
+	if ruleType == AlertPriceCrossMA60 {
+		return fmt.Sprintf("股價 %.2f %s MA60 %.2f", values["price"], action, values["ma60"])
+	}
+	if direction == CrossUp {
+		return fmt.Sprintf("K值 %.1f 黃金交叉 D值 %.1f", values["k"], values["d"])
+	}
+	return fmt.Sprintf("K值 %.1f 死亡交叉 D值 %.1f", values["k"], values["d"])
+}
+
+// Save 儲存規則評估狀態
+func (e *AlertEngine) Save() error {
+	data, err := json.MarshalIndent(e.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(e.statePath, data, 0644)
+}
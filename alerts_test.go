@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testAlertEngine 於暫存目錄寫入規則檔並載入，回傳引擎及狀態檔路徑
+func testAlertEngine(t *testing.T, rules string) (*AlertEngine, string) {
+	t.Helper()
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, defaultAlertRulesFile)
+	statePath := filepath.Join(dir, defaultAlertStateFile)
+	if err := os.WriteFile(rulesPath, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := LoadAlertEngine(rulesPath, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, statePath
+}
+
+// firedRules 觸發警示的規則識別碼
+func firedRules(events []AlertEvent) []string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.RuleID)
+	}
+	return ids
+}
+
+func TestAlertCrossFiresOnce(t *testing.T) {
+	engine, _ := testAlertEngine(t, `[
+		{"id": "up", "code": "2330", "type": "price_cross_ma60", "direction": "up"},
+		{"id": "both", "code": "2330", "type": "price_cross_ma60"}
+	]`)
+
+	steps := []struct {
+		name  string
+		price float64
+		want  []string
+	}{
+		{"首次評估無前次狀態", 95, nil},
+		{"突破MA60", 105, []string{"up", "both"}},
+		{"維持在MA60之上不重複觸發", 110, nil},
+		{"仍在MA60之上", 101, nil},
+		{"跌破MA60僅雙向規則觸發", 95, []string{"both"}},
+		{"再次突破重新觸發", 103, []string{"up", "both"}},
+	}
+	for _, step := range steps {
+		events := engine.Evaluate(&StockData{Code: "2330", Name: "台積電", Price: step.price, MA60: 100})
+		if got := firedRules(events); !slices.Equal(got, step.want) {
+			t.Errorf("%s: fired = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	// 其他股票及資料不足不影響狀態
+	if events := engine.Evaluate(&StockData{Code: "2317", Price: 200, MA60: 100}); len(events) != 0 {
+		t.Errorf("其他股票觸發 %v", firedRules(events))
+	}
+	if events := engine.Evaluate(&StockData{Code: "2330", Price: 90}); len(events) != 0 || !engine.state["up"].Condition {
+		t.Errorf("缺少MA60時 fired = %v, condition = %v", firedRules(events), engine.state["up"].Condition)
+	}
+}
+
+func TestAlertThresholdRearms(t *testing.T) {
+	engine, _ := testAlertEngine(t, `[{"code": "2330", "type": "rsi_below", "threshold": 30}]`)
+
+	steps := []struct {
+		rsi  float64
+		want int
+	}{
+		{25, 1}, // 門檻類規則首次評估即成立也觸發
+		{20, 0},
+		{28, 0},
+		{45, 0},
+		{29, 1},
+	}
+	for i, step := range steps {
+		events := engine.Evaluate(&StockData{Code: "2330", RSI: step.rsi})
+		if len(events) != step.want {
+			t.Errorf("step %d RSI %v: events = %+v, want %d", i, step.rsi, events, step.want)
+		}
+		if step.want == 1 && events[0].RuleID != "2330:rsi_below::30" {
+			t.Errorf("RuleID = %s", events[0].RuleID)
+		}
+	}
+}
+
+func TestAlertStateRoundTrip(t *testing.T) {
+	rules := `[{"id": "kd", "code": "2330", "type": "k_cross_d", "direction": "up"}]`
+	engine, statePath := testAlertEngine(t, rules)
+	engine.Evaluate(&StockData{Code: "2330", KValue: 20, DValue: 30})
+	if events := engine.Evaluate(&StockData{Code: "2330", KValue: 35, DValue: 30}); len(events) != 1 {
+		t.Fatalf("黃金交叉 events = %+v, want 1", events)
+	}
+	firedAt := engine.state["kd"].LastFiredAt
+	if err := engine.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadAlertEngine(filepath.Join(filepath.Dir(statePath), defaultAlertRulesFile), statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := reloaded.state["kd"]
+	if state == nil || !state.Condition || state.Values["k"] != 35 || state.Values["d"] != 30 || !state.LastFiredAt.Equal(firedAt) {
+		t.Fatalf("state = %+v, want condition true k 35 d 30 fired %v", state, firedAt)
+	}
+
+	// 重新載入後條件仍成立不重複觸發，死叉後再金叉才觸發
+	if events := reloaded.Evaluate(&StockData{Code: "2330", KValue: 40, DValue: 30}); len(events) != 0 {
+		t.Errorf("重新載入後 events = %+v, want none", events)
+	}
+	reloaded.Evaluate(&StockData{Code: "2330", KValue: 25, DValue: 30})
+	if events := reloaded.Evaluate(&StockData{Code: "2330", KValue: 35, DValue: 30}); len(events) != 1 {
+		t.Errorf("再次黃金交叉 events = %+v, want 1", events)
+	}
+}
+
+func TestLoadAlertEngine(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := LoadAlertEngine(filepath.Join(dir, "missing.json"), filepath.Join(dir, "state.json"))
+	if engine != nil || err != nil {
+		t.Errorf("規則檔不存在: engine = %v, err = %v", engine, err)
+	}
+
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"未知類型", `[{"code": "2330", "type": "volume_spike"}]`},
+		{"方向錯誤", `[{"code": "2330", "type": "k_cross_d", "direction": "sideways"}]`},
+		{"格式錯誤", `{"code": "2330"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesPath := filepath.Join(dir, tt.name+".json")
+			if err := os.WriteFile(rulesPath, []byte(tt.rules), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadAlertEngine(rulesPath, filepath.Join(dir, "state.json")); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+
+	engine, _ = testAlertEngine(t, `[{"code": "2330", "type": "rsi_below", "threshold": 30},
+		{"code": "2317", "type": "rsi_below", "threshold": 30}, {"code": "2330", "type": "k_cross_d"}]`)
+	if codes := engine.Codes(); !slices.Equal(codes, []string{"2330", "2317"}) {
+		t.Errorf("Codes = %v, want [2330 2317]", codes)
+	}
+}
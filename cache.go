@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCacheDir 個股資料快取目錄
+const defaultCacheDir = ".cache"
+
+// cacheEntry 快取的個股資料，以交易日區分新舊
+type cacheEntry struct {
+	TradingDay string            `json:"trading_day"`
+	Chart      ChartOptions      `json:"chart"` // 價格歷史區間不同時視為未快取
+	FetchedAt  time.Time         `json:"fetched_at"`
+	Stock      *StockData        `json:"stock"`
+	Bars       []Bar             `json:"bars,omitempty"`
+	AdjBars    []Bar             `json:"adj_bars,omitempty"`
+	Actions    []CorporateAction `json:"actions,omitempty"`
+	Closes     []float64         `json:"closes"`
+	ROESteps   []ROEStep         `json:"roe_steps,omitempty"`
+}
+
+// DataCache 以交易日為單位的個股資料快取
+type DataCache struct {
+	dir string
+}
+
+// NewDataCache 建立資料快取
+func NewDataCache(dir string) *DataCache {
+	return &DataCache{dir: dir}
+}
+
+// path 快取檔路徑
+func (c *DataCache) path(code string) string {
+	return filepath.Join(c.dir, code+".json")
+}
+
+// Load 讀取指定交易日及價格歷史區間的快取資料，沒有或過期時 ok 為 false
+func (c *DataCache) Load(code, tradingDay string, chart ChartOptions) (*StockData, bool) {
+	data, err := os.ReadFile(c.path(code))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Stock == nil {
+		return nil, false
+	}
+	if entry.TradingDay != tradingDay || entry.Chart != chart {
+		return nil, false
+	}
+
+	entry.Stock.bars = entry.Bars
+	entry.Stock.adjBars = entry.AdjBars
+	entry.Stock.actions = entry.Actions
+	entry.Stock.closes = entry.Closes
+	entry.Stock.roeSteps = entry.ROESteps
+	return entry.Stock, true
+}
+
+// LoadDay 讀取指定交易日及價格歷史區間的所有快取資料 (依代碼排序)
+func (c *DataCache) LoadDay(tradingDay string, chart ChartOptions) []*StockData {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil
+	}
+	sort.Strings(files)
+
+	var stocks []*StockData
+	for _, file := range files {
+		code := strings.TrimSuffix(filepath.Base(file), ".json")
+		if stock, ok := c.Load(code, tradingDay, chart); ok {
+			stocks = append(stocks, stock)
+		}
+	}
+	return stocks
+}
+
+// Store 寫入快取資料
+func (c *DataCache) Store(stock *StockData, tradingDay string, chart ChartOptions) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cacheEntry{
+		TradingDay: tradingDay,
+		Chart:      chart,
+		FetchedAt:  taipeiNow(),
+		Stock:      stock,
+		Bars:       stock.bars,
+		AdjBars:    stock.adjBars,
+		Actions:    stock.actions,
+		Closes:     stock.closes,
+		ROESteps:   stock.roeSteps,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 先寫入暫存檔再更名，避免中斷時留下不完整的快取
+	tmp := c.path(stock.Code) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(stock.Code)); err != nil {
+		return fmt.Errorf("寫入快取失敗: %v", err)
+	}
+	return nil
+}
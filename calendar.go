@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultClosuresFile 臨時休市(颱風假等)設定檔
+const defaultClosuresFile = "market_closures.json"
+
+// twseHolidayScheduleURL 證交所OpenAPI當年度市場開休市日期
+const twseHolidayScheduleURL = "https://openapi.twse.com.tw/v1/holidaySchedule/holidaySchedule"
+
+// 證交所盤後資料(BWIBBU_d 等)約於收盤後公布，在此時間之前視為當日資料尚未可用
+const (
+	dailyDataReadyHour   = 16
+	dailyDataReadyMinute = 0
+)
+
+// taipeiLocation 台北時區，系統未安裝時區資料時使用固定 UTC+8
+var taipeiLocation = loadTaipeiLocation()
+
+func loadTaipeiLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		return time.FixedZone("Asia/Taipei", 8*60*60)
+	}
+	return loc
+}
+
+// taipeiNow 取得台北時間的現在時刻
+func taipeiNow() time.Time {
+	return time.Now().In(taipeiLocation)
+}
+
+// twseHolidays 證交所公告之休市日 (不含週末)，未列出的年度須由證交所OpenAPI取得
+var twseHolidays = map[string]string{
+	// 2025年
+	"2025-01-01": "中華民國開國紀念日",
+	"2025-01-23": "農曆春節前無交易日",
+	"2025-01-24": "農曆春節前無交易日",
+	"2025-01-27": "農曆春節",
+	"2025-01-28": "農曆除夕",
+	"2025-01-29": "農曆春節",
+	"2025-01-30": "農曆春節",
+	"2025-01-31": "農曆春節",
+	"2025-02-28": "和平紀念日",
+	"2025-04-03": "兒童節補假",
+	"2025-04-04": "兒童節及民族掃墓節",
+	"2025-05-01": "勞動節",
+	"2025-05-30": "端午節補假",
+	"2025-09-29": "教師節補假",
+	"2025-10-06": "中秋節",
+	"2025-10-10": "國慶日",
+	"2025-10-24": "臺灣光復暨金門古寧頭大捷紀念日補假",
+	"2025-12-25": "行憲紀念日",
+	// 2026年
+	"2026-01-01": "中華民國開國紀念日",
+	"2026-02-12": "農曆春節前無交易日",
+	"2026-02-13": "農曆春節前無交易日",
+	"2026-02-16": "農曆除夕",
+	"2026-02-17": "農曆春節",
+	"2026-02-18": "農曆春節",
+	"2026-02-19": "農曆春節",
+	"2026-02-20": "農曆春節補假",
+	"2026-02-27": "和平紀念日補假",
+	"2026-04-03": "兒童節補假",
+	"2026-04-06": "民族掃墓節補假",
+	"2026-05-01": "勞動節",
+	"2026-06-19": "端午節",
+	"2026-09-25": "中秋節",
+	"2026-09-28": "教師節",
+	"2026-10-09": "國慶日補假",
+	"2026-10-26": "臺灣光復暨金門古寧頭大捷紀念日補假",
+	"2026-12-25": "行憲紀念日",
+}
+
+// MarketClosure 休市日設定
+type MarketClosure struct {
+	Date   string `json:"date"` // 格式 2006-01-02
+	Reason string `json:"reason"`
+}
+
+// TradingCalendar 台股交易日曆，只能判斷已取得完整休市日程的年度
+type TradingCalendar struct {
+	holidays    map[string]string
+	years       map[int]bool // 已取得完整休市日程的年度
+	weekdayOnly map[int]bool // 無休市日程、僅排除週末的年度
+}
+
+// NewTradingCalendar 建立內建證交所休市日的交易日曆
+func NewTradingCalendar() *TradingCalendar {
+	c := &TradingCalendar{holidays: make(map[string]string, len(twseHolidays)), years: make(map[int]bool),
+		weekdayOnly: make(map[int]bool)}
+	for date, reason := range twseHolidays {
+		c.holidays[date] = reason
+		year, _ := strconv.Atoi(date[:4])
+		c.years[year] = true
+	}
+	return c
+}
+
+// AddYear 加入一個年度的完整休市日程
+func (c *TradingCalendar) AddYear(year int, closures []MarketClosure) {
+	for _, closure := range closures {
+		c.holidays[closure.Date] = closure.Reason
+	}
+	c.years[year] = true
+}
+
+// AddWeekdayYear 無法取得休市日程時以平日為交易日，國定假日會被誤判為交易日
+func (c *TradingCalendar) AddWeekdayYear(year int) {
+	c.years[year] = true
+	c.weekdayOnly[year] = true
+}
+
+// WeekdayOnly 該年度是否僅排除週末
+func (c *TradingCalendar) WeekdayOnly(year int) bool {
+	return c.weekdayOnly[year]
+}
+
+// Covers 是否已取得該年度的完整休市日程
+func (c *TradingCalendar) Covers(year int) bool {
+	return c.years[year]
+}
+
+// checkYear 年度未涵蓋時回傳錯誤，避免將休市日誤判為交易日
+func (c *TradingCalendar) checkYear(t time.Time) error {
+	year := t.In(taipeiLocation).Year()
+	if c.years[year] {
+		return nil
+	}
+	years := make([]int, 0, len(c.years))
+	for y := range c.years {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+	return fmt.Errorf("交易日曆未涵蓋 %d 年 (已涵蓋: %v)，請確認可連線證交所OpenAPI或提供 %s", year, years,
+		holidayScheduleFile(defaultCacheDir, year))
+}
+
+// LoadClosures 從JSON檔載入額外休市日 (如颱風停止交易)
+func (c *TradingCalendar) LoadClosures(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var closures []MarketClosure
+	if err := json.Unmarshal(data, &closures); err != nil {
+		return fmt.Errorf("解析休市日設定失敗: %v", err)
+	}
+
+	for _, closure := range closures {
+		if _, err := time.ParseInLocation("2006-01-02", closure.Date, taipeiLocation); err != nil {
+			return fmt.Errorf("休市日格式錯誤 %q: %v", closure.Date, err)
+		}
+		c.holidays[closure.Date] = closure.Reason
+	}
+
+	return nil
+}
+
+// IsTradingDay 判斷指定日期(以台北時間計)是否為交易日，年度未涵蓋時回傳錯誤
+func (c *TradingCalendar) IsTradingDay(t time.Time) (bool, error) {
+	reason, err := c.ClosureReason(t)
+	return reason == "", err
+}
+
+// ClosureReason 取得休市原因，交易日回傳空字串；年度未涵蓋時回傳錯誤
+func (c *TradingCalendar) ClosureReason(t time.Time) (string, error) {
+	t = t.In(taipeiLocation)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return "週末", nil
+	}
+	if err := c.checkYear(t); err != nil {
+		return "", err
+	}
+	return c.holidays[t.Format("2006-01-02")], nil
+}
+
+// PreviousTradingDay 取得指定日期之前(不含當日)最近的交易日
+func (c *TradingCalendar) PreviousTradingDay(t time.Time) (time.Time, error) {
+	day := startOfDay(t.In(taipeiLocation)).AddDate(0, 0, -1)
+	for {
+		open, err := c.IsTradingDay(day)
+		if err != nil || open {
+			return day, err
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+}
+
+// LatestTradingDay 取得在指定時刻已公布盤後資料的最近交易日
+func (c *TradingCalendar) LatestTradingDay(now time.Time) (time.Time, error) {
+	now = now.In(taipeiLocation)
+	today := startOfDay(now)
+	readyAt := today.Add(dailyDataReadyHour*time.Hour + dailyDataReadyMinute*time.Minute)
+
+	open, err := c.IsTradingDay(today)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if open && !now.Before(readyAt) {
+		return today, nil
+	}
+	return c.PreviousTradingDay(today)
+}
+
+// holidayScheduleFile 證交所休市日程的快取檔路徑
+func holidayScheduleFile(dir string, year int) string {
+	return filepath.Join(dir, fmt.Sprintf("holidays_%d.json", year))
+}
+
+// parseHolidaySchedule 解析證交所OpenAPI的開休市日期 (民國日期，如 1150101)
+// 「開始交易」、「最後交易」等交易日公告不列為休市日
+func parseHolidaySchedule(r io.Reader) (int, []MarketClosure, error) {
+	var rows []struct {
+		Name string `json:"Name"`
+		Date string `json:"Date"`
+	}
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return 0, nil, fmt.Errorf("解析休市日程失敗: %v", err)
+	}
+
+	year := 0
+	var closures []MarketClosure
+	for _, row := range rows {
+		date := strings.TrimSpace(row.Date)
+		if len(date) != 7 {
+			return 0, nil, fmt.Errorf("無效的民國日期: %q", row.Date)
+		}
+		day, err := parseROCDate(date[:3] + "/" + date[3:5] + "/" + date[5:])
+		if err != nil {
+			return 0, nil, err
+		}
+		year = day.Year()
+		if strings.Contains(row.Name, "開始交易") || strings.Contains(row.Name, "最後交易") {
+			continue
+		}
+		closures = append(closures, MarketClosure{Date: day.Format("2006-01-02"), Reason: strings.TrimSpace(row.Name)})
+	}
+	if year == 0 {
+		return 0, nil, fmt.Errorf("休市日程無資料")
+	}
+	return year, closures, nil
+}
+
+// loadHolidaySchedule 交易日曆未涵蓋今年或去年時，依序從快取檔及證交所OpenAPI取得休市日程，每次執行只嘗試一次
+// 仍無法涵蓋的年度改為僅排除週末，記錄一次警告並標示本次執行資料不完整，避免每檔股票都因交易日曆失敗
+func (s *StockScreener) loadHolidaySchedule(ctx context.Context) {
+	if s.holidaysLoaded {
+		return
+	}
+	s.holidaysLoaded = true
+
+	year := taipeiNow().Year()
+	s.fetchHolidaySchedule(ctx, year)
+
+	var missing []int
+	for _, y := range []int{year - 1, year} {
+		if !s.calendar.Covers(y) {
+			s.calendar.AddWeekdayYear(y)
+			missing = append(missing, y)
+		}
+	}
+	if len(missing) > 0 {
+		s.logger.Warn("交易日曆未涵蓋，改為僅排除週末", "years", missing)
+		s.summary.markRunDegraded(fmt.Sprintf("交易日曆未涵蓋 %v 年，僅排除週末", missing))
+	}
+}
+
+// fetchHolidaySchedule 從快取檔及證交所OpenAPI取得今年及去年的休市日程
+// 證交所只提供當年度日程，取得後寫入快取供隔年年初回溯使用
+func (s *StockScreener) fetchHolidaySchedule(ctx context.Context, year int) {
+	for _, y := range []int{year - 1, year} {
+		if s.calendar.Covers(y) {
+			continue
+		}
+		data, err := os.ReadFile(holidayScheduleFile(s.cache.dir, y))
+		if err != nil {
+			continue
+		}
+		var closures []MarketClosure
+		if err := json.Unmarshal(data, &closures); err != nil {
+			s.logger.Warn("無法讀取休市日程快取", "year", y, "error", err)
+			continue
+		}
+		s.calendar.AddYear(y, closures)
+	}
+	if s.calendar.Covers(year) {
+		return
+	}
+
+	resp, err := s.get(ctx, twseHolidayScheduleURL)
+	if err != nil {
+		s.logger.Warn("無法取得休市日程", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		s.logger.Warn("無法取得休市日程", "error", fmt.Errorf("TWSE OpenAPI 返回錯誤狀態碼: %d", resp.StatusCode))
+		return
+	}
+	y, closures, err := parseHolidaySchedule(resp.Body)
+	if err != nil {
+		s.logger.Warn("無法取得休市日程", "error", err)
+		return
+	}
+	s.calendar.AddYear(y, closures)
+
+	data, err := json.MarshalIndent(closures, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(s.cache.dir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(holidayScheduleFile(s.cache.dir, y), data, 0644)
+	}
+	if err != nil {
+		s.logger.Warn("無法寫入休市日程快取", "year", y, "error", err)
+	}
+	s.logger.Debug("休市日程", "year", y, "closures", len(closures))
+}
+
+// startOfDay 取得同一時區當日零時
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatestTradingDay(t *testing.T) {
+	c := NewTradingCalendar()
+	at := func(date string, hour int) time.Time {
+		day, err := time.ParseInLocation("2006-01-02", date, taipeiLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return day.Add(time.Duration(hour) * time.Hour)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"交易日收盤資料已公布", at("2026-03-04", 17), "2026-03-04"},
+		{"交易日盤後資料未公布", at("2026-03-04", 10), "2026-03-03"},
+		{"週一盤前跳過228補假回溯至週四", at("2026-03-02", 9), "2026-02-26"},
+		{"週末", at("2026-03-07", 12), "2026-03-06"},
+		{"春節連假", at("2026-02-20", 18), "2026-02-11"},
+		{"跨年回溯", at("2026-01-01", 18), "2025-12-31"},
+		{"台北時區換算", at("2026-03-04", 16).UTC(), "2026-03-04"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.LatestTradingDay(tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format("2006-01-02") != tt.want {
+				t.Errorf("LatestTradingDay(%v) = %s, want %s", tt.now, got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestTradingDayUncoveredYear(t *testing.T) {
+	c := NewTradingCalendar()
+	now := time.Date(2027, 3, 3, 18, 0, 0, 0, taipeiLocation)
+	if _, err := c.LatestTradingDay(now); err == nil {
+		t.Fatal("未涵蓋的年度應回傳錯誤")
+	}
+	// 週末不需休市日程即可判斷
+	if open, err := c.IsTradingDay(time.Date(2027, 3, 6, 0, 0, 0, 0, taipeiLocation)); err != nil || open {
+		t.Errorf("IsTradingDay(週六) = %v, %v", open, err)
+	}
+	// 年初回溯至未涵蓋的前一年
+	c.AddYear(2027, []MarketClosure{{Date: "2027-01-01", Reason: "開國紀念日"}})
+	if _, err := c.LatestTradingDay(time.Date(2027, 1, 4, 9, 0, 0, 0, taipeiLocation)); err != nil {
+		t.Errorf("2026年已內建，不應失敗: %v", err)
+	}
+	if got, err := c.LatestTradingDay(now); err != nil || got.Format("2006-01-02") != "2027-03-03" {
+		t.Errorf("LatestTradingDay = %v, %v", got, err)
+	}
+}
+
+func TestWeekdayOnlyFallback(t *testing.T) {
+	var requests int
+	s := &StockScreener{
+		logger: slog.New(slog.DiscardHandler),
+		client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return statusResponse(http.StatusServiceUnavailable), nil
+		})},
+		calendar: &TradingCalendar{holidays: make(map[string]string), years: make(map[int]bool), weekdayOnly: make(map[int]bool)},
+		cache:    NewDataCache(t.TempDir()),
+		summary:  NewRunSummary(),
+	}
+
+	// 無法取得休市日程時改為僅排除週末，整次執行只警告一次
+	for i := 0; i < 3; i++ {
+		day, err := s.latestTradingDate(context.Background())
+		if err != nil {
+			t.Fatalf("latestTradingDate: %v", err)
+		}
+		if d, _ := time.ParseInLocation("20060102", day, taipeiLocation); d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			t.Errorf("交易日 %s 不應為週末", day)
+		}
+	}
+	year := taipeiNow().Year()
+	if !s.calendar.WeekdayOnly(year) || !s.calendar.WeekdayOnly(year-1) {
+		t.Errorf("WeekdayOnly(%d, %d) = false, want true", year-1, year)
+	}
+	if requests != 1 || len(s.summary.RunDegraded) != 1 {
+		t.Errorf("requests = %d, RunDegraded = %v, want 1 and 1", requests, s.summary.RunDegraded)
+	}
+}
+
+func TestParseHolidaySchedule(t *testing.T) {
+	body := `[
+		{"Name":"中華民國開國紀念日","Date":"1160101","Weekday":"五","Description":"依規定放假1日。"},
+		{"Name":"國曆新年開始交易日","Date":"1160104","Weekday":"一","Description":"國曆新年開始交易。"},
+		{"Name":"農曆春節前最後交易日","Date":"1160203","Weekday":"三","Description":""},
+		{"Name":"農曆春節前無交易日","Date":"1160204","Weekday":"四","Description":"僅辦理結算交割作業。"}
+	]`
+	year, closures, err := parseHolidaySchedule(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if year != 2027 {
+		t.Errorf("year = %d, want 2027", year)
+	}
+	want := []MarketClosure{
+		{Date: "2027-01-01", Reason: "中華民國開國紀念日"},
+		{Date: "2027-02-04", Reason: "農曆春節前無交易日"},
+	}
+	if len(closures) != len(want) {
+		t.Fatalf("closures = %v, want %v", closures, want)
+	}
+	for i := range want {
+		if closures[i] != want[i] {
+			t.Errorf("closures[%d] = %v, want %v", i, closures[i], want[i])
+		}
+	}
+
+	if _, _, err := parseHolidaySchedule(strings.NewReader(`[{"Name":"x","Date":"2027-01-01"}]`)); err == nil {
+		t.Error("非民國日期應回傳錯誤")
+	}
+}
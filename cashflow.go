@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stock/finmind"
+)
+
+// 現金流量表項目 (FinMind type)
+var (
+	operatingCashFlowTypes = []string{"CashFlowsFromOperatingActivities", "NetCashInflowFromOperatingActivities"}
+	capexTypes             = []string{"PropertyAndPlantAndEquipment", "AcquisitionOfPropertyPlantAndEquipment"}
+	depreciationTypes      = []string{"Depreciation", "DepreciationExpense"}
+)
+
+// cashFlowHistoryYears 現金流量表回溯年數 (滾動計算，財務評分需8個單季；跨年度還原單季數值需同年度前一季累計值)
+const cashFlowHistoryYears = 3
+
+// isCashFlowItem 依 type 或中文科目名稱判斷現金流量表項目
+func isCashFlowItem(item finmind.FinancialStatement, types []string, originPrefix string) bool {
+	for _, t := range types {
+		if item.Type == t {
+			return true
+		}
+	}
+	return strings.HasPrefix(item.OriginName, originPrefix)
+}
+
+// quarterlyFromYTD 將年初至今累計數值還原為單季數值
+// 第一季即為單季；其餘季度須有同年度前一季累計數值，否則略過
+func quarterlyFromYTD(ytd map[string]float64) map[string]float64 {
+	quarterly := make(map[string]float64, len(ytd))
+	for date, value := range ytd {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			continue
+		}
+		if t.Month() <= time.March {
+			quarterly[date] = value
+			continue
+		}
+		prevEnd := time.Date(t.Year(), t.Month()-2, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+		if prev, ok := ytd[prevEnd.Format("2006-01-02")]; ok {
+			quarterly[date] = value - prev
+		}
+	}
+	return quarterly
+}
+
+// consecutiveNegative 由最近一季往前連續為負值的季數
+func consecutiveNegative(quarterly map[string]float64) int {
+	dates := make([]string, 0, len(quarterly))
+	for date := range quarterly {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	count := 0
+	for _, date := range dates {
+		if quarterly[date] >= 0 {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// fetchCashFlow 取得現金流量表，計算近四季營業現金流、資本支出、自由現金流及盈餘品質指標
+// 需在損益表 (近四季淨利) 及資產負債表 (股數、總資產) 解析後呼叫
+func (s *StockScreener) fetchCashFlow(ctx context.Context, stock *StockData) error {
+	now := taipeiNow()
+	statements, err := s.finmind.CashFlowsStatement(ctx, stock.Code, now.AddDate(-cashFlowHistoryYears, 0, 0), now)
+	if err != nil {
+		return err
+	}
+
+	// 現金流量表為年初至今累計數值
+	ocfYTD := make(map[string]float64)
+	capexYTD := make(map[string]float64)
+	ytdByType := make(map[string]map[string]float64)
+	for _, item := range statements {
+		if ytdByType[item.Type] == nil {
+			ytdByType[item.Type] = make(map[string]float64)
+		}
+		ytdByType[item.Type][item.Date] = item.Value
+
+		switch {
+		case isCashFlowItem(item, operatingCashFlowTypes, "營業活動之淨現金流入"):
+			ocfYTD[item.Date] = item.Value
+		case isCashFlowItem(item, capexTypes, "取得不動產、廠房及設備"):
+			capexYTD[item.Date] = math.Abs(item.Value)
+		}
+	}
+
+	// 保留各科目單季數值供財務評分使用
+	if stock.statements != nil {
+		for itemType, ytd := range ytdByType {
+			for date, value := range quarterlyFromYTD(ytd) {
+				record(stock.statements.cashFlow, date, itemType, value)
+			}
+		}
+	}
+
+	ocf := quarterlyFromYTD(ocfYTD)
+	capex := quarterlyFromYTD(capexYTD)
+	fcf := make(map[string]float64, len(ocf))
+	for date, value := range ocf {
+		fcf[date] = value - capex[date]
+	}
+
+	ocfTTM, ok := trailingFourQuarters(ocf)
+	if !ok {
+		return fmt.Errorf("營業現金流量季度不足 (%d季)", len(ocf))
+	}
+	fcfTTM, _ := trailingFourQuarters(fcf)
+
+	stock.OperatingCashFlow = ocfTTM
+	stock.FreeCashFlow = fcfTTM
+	stock.CapitalExpenditure = ocfTTM - fcfTTM
+	stock.NegativeFCFQuarters = consecutiveNegative(fcf)
+
+	stock.OCFToNetIncome, stock.AccrualsRatio = 0, 0
+	if stock.netIncomeTTM > 0 {
+		stock.OCFToNetIncome = ocfTTM / stock.netIncomeTTM
+	}
+	if stock.totalAssets > 0 && stock.netIncomeTTM != 0 {
+		stock.AccrualsRatio = (stock.netIncomeTTM - ocfTTM) / stock.totalAssets * 100
+	}
+	if stock.shares > 0 {
+		stock.FCFPerShare = fcfTTM / stock.shares
+	}
+
+	s.logger.Debug("現金流量", logKeyStock, stock.Code, "ocf", ocfTTM, "capex", stock.CapitalExpenditure,
+		"fcf", fcfTTM, "ocf_to_ni", stock.OCFToNetIncome, "accruals", stock.AccrualsRatio,
+		"negative_fcf_quarters", stock.NegativeFCFQuarters)
+	return nil
+}
+
+// evaluateCashFlowQuality 盈餘品質規則 (第二階段)，無現金流量資料時不列入判定
+func (s *StockScreener) evaluateCashFlowQuality(stock *StockData) []RuleVerdict {
+	names := []string{"營業現金流/淨利", "應計比率", "自由現金流殖利率", "自由現金流連續為負"}
+	if stock.OperatingCashFlow == 0 {
+		rules := make([]RuleVerdict, len(names))
+		for i, name := range names {
+			rules[i] = skipRule(name, "無現金流量資料")
+		}
+		return rules
+	}
+
+	c := s.criteria
+	return []RuleVerdict{
+		gradeRule(names[0], fmt.Sprintf("%.2f", stock.OCFToNetIncome),
+			stock.OCFToNetIncome >= c.MinOCFToNetIncome, stock.OCFToNetIncome >= c.MinOCFToNetIncome/2,
+			"獲利有現金支撐", "現金轉換偏低", "獲利缺乏現金支撐",
+			fmt.Sprintf("營業現金流/淨利偏低 %.2f", stock.OCFToNetIncome)),
+		gradeRule(names[1], fmt.Sprintf("%.1f%%", stock.AccrualsRatio),
+			stock.AccrualsRatio <= c.MaxAccrualsRatio, stock.AccrualsRatio <= c.MaxAccrualsRatio*1.5,
+			"正常", "偏高", "過高",
+			fmt.Sprintf("應計比率過高 %.1f%%", stock.AccrualsRatio)),
+		gradeRule(names[2], fmt.Sprintf("%.2f%%", stock.FCFYield),
+			stock.FCFYield >= c.MinFCFYield, stock.FCFYield > 0,
+			"達標", "偏低", "自由現金流為負",
+			fmt.Sprintf("自由現金流殖利率不足 %.2f%%", stock.FCFYield)),
+		gradeRule(names[3], fmt.Sprintf("%d季", stock.NegativeFCFQuarters),
+			stock.NegativeFCFQuarters == 0, stock.NegativeFCFQuarters <= c.MaxNegativeFCFQuarters,
+			"無", "短期為負", "長期為負",
+			fmt.Sprintf("自由現金流連續%d季為負", stock.NegativeFCFQuarters)),
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuarterlyFromYTD(t *testing.T) {
+	ytd := map[string]float64{
+		"2024-09-30": 90, // 缺少同年度前一季，無法還原
+		"2024-12-31": 130,
+		"2025-03-31": 40,
+		"2025-06-30": 70,
+		"2025-09-30": 120,
+		"2025-12-31": 150,
+	}
+	want := map[string]float64{
+		"2024-12-31": 40,
+		"2025-03-31": 40,
+		"2025-06-30": 30,
+		"2025-09-30": 50,
+		"2025-12-31": 30,
+	}
+	if got := quarterlyFromYTD(ytd); !reflect.DeepEqual(got, want) {
+		t.Errorf("quarterlyFromYTD = %v, want %v", got, want)
+	}
+}
+
+func TestConsecutiveNegative(t *testing.T) {
+	quarterly := map[string]float64{"2025-03-31": -1, "2025-06-30": 5, "2025-09-30": -2, "2025-12-31": -3}
+	if got := consecutiveNegative(quarterly); got != 2 {
+		t.Errorf("consecutiveNegative = %d, want 2", got)
+	}
+}
+
+func TestEvaluateCashFlowQuality(t *testing.T) {
+	s := testScreener(t, "default")
+
+	missing := newStageResult(2, "盈餘品質", 4, s.evaluateCashFlowQuality(&StockData{}))
+	if missing.TotalChecks != 0 || missing.PassCount != 0 {
+		t.Errorf("無現金流量資料: pass %d / total %d, want 0 / 0", missing.PassCount, missing.TotalChecks)
+	}
+
+	stock := &StockData{OperatingCashFlow: 100, OCFToNetIncome: 0.5, AccrualsRatio: 12, FCFYield: -1, NegativeFCFQuarters: 3}
+	want := []string{verdictWarn, verdictWarn, verdictFail, verdictFail}
+	for i, rule := range s.evaluateCashFlowQuality(stock) {
+		if rule.Status != want[i] {
+			t.Errorf("%s = %s, want %s", rule.Rule, rule.Status, want[i])
+		}
+	}
+}
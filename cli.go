@@ -0,0 +1,833 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cliUsage 指令說明
+const cliUsage = `台股篩選系統
+
+用法:
+  stock [screen] [flags]          執行篩選 (未指定指令時的預設行為)
+  stock inspect <code> [flags]    單一股票深入分析
+  stock fetch [flags]             預先取得資料並寫入快取
+  stock indicators <code>         顯示單一股票技術指標
+  stock runs list                 列出歷次篩選結果
+  stock runs diff [old] [new]     比較兩次篩選結果 (預設為最近兩次)
+  stock positions list            列出持股帳本中的持股
+  stock positions buy|sell <code> <shares> <price> [flags]
+                                  新增買賣紀錄至持股帳本
+
+結束代碼:
+  0 成功 (含部分資料使用預設值) | 1 全部失敗 | 2 參數錯誤 | 3 部分股票失敗或執行中斷
+
+執行中按 Ctrl-C 可中斷並保留已完成的結果，再按一次強制結束
+
+執行 "stock <指令> -h" 查看各指令參數
+`
+
+// runCLI 解析指令並執行，回傳結束代碼
+func runCLI(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runScreen(args)
+	}
+
+	switch args[0] {
+	case "screen":
+		return runScreen(args[1:])
+	case "inspect":
+		return runInspect(args[1:])
+	case "fetch":
+		return runFetch(args[1:])
+	case "indicators":
+		return runIndicators(args[1:])
+	case "runs":
+		return runRuns(args[1:])
+	case "positions":
+		return runPositions(args[1:])
+	case "help":
+		fmt.Print(cliUsage)
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的指令: %s\n\n%s", args[0], cliUsage)
+	return exitUsage
+}
+
+// errUsage 參數錯誤，錯誤訊息已輸出至標準錯誤
+var errUsage = errors.New("參數錯誤")
+
+// usageExitCode 參數解析失敗的結束代碼，-h/--help 顯示說明後視為成功
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// parseArgs 解析參數，允許位置參數與旗標交錯 (如 inspect 2330 --profile strict)
+// "--" 之後的參數一律視為位置參數 (如負數或以 - 開頭的代碼)
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// logFlags 診斷日誌參數 (日誌輸出至標準錯誤，報告輸出至標準輸出)
+type logFlags struct {
+	level string
+	json  bool
+	trace string
+}
+
+func (o *logFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.level, "log-level", "info", "日誌等級: debug, info, warn, error")
+	fs.BoolVar(&o.json, "log-json", false, "以JSON格式輸出日誌")
+	fs.StringVar(&o.trace, "trace", "", "以逗號分隔的股票代碼，輸出這些股票的除錯日誌")
+}
+
+// setup 依參數設定預設日誌
+func (o *logFlags) setup() error {
+	level, err := ParseLogLevel(o.level)
+	if err != nil {
+		return fmt.Errorf("無效的日誌等級 %q: %v", o.level, err)
+	}
+	slog.SetDefault(NewLogger(os.Stderr, LogOptions{
+		Level: level,
+		JSON:  o.json,
+		Trace: splitCodes(o.trace),
+	}))
+	return nil
+}
+
+// parseCommand 解析指令參數並設定日誌
+// 旗標錯誤已由 FlagSet 輸出，回傳的錯誤以 usageExitCode 換算結束代碼
+func parseCommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	var logs logFlags
+	logs.register(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := logs.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// timeoutFlags 執行時限參數
+type timeoutFlags struct {
+	timeout        time.Duration
+	requestTimeout time.Duration
+}
+
+func (o *timeoutFlags) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.timeout, "timeout", 0, "整體執行時限 (如 30m)，逾時後保留已完成的結果，0 表示不限")
+	fs.DurationVar(&o.requestTimeout, "request-timeout", defaultAttemptTimeout, "單次HTTP請求時限 (不含重試)")
+}
+
+// context 建立可由 Ctrl-C 或 SIGTERM 中斷並套用整體時限的context
+func (o *timeoutFlags) context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// 收到第一次中斷後恢復預設處理，再按一次 Ctrl-C 即強制結束
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if o.timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// apply 設定篩選器的單次請求時限
+func (o *timeoutFlags) apply(s *StockScreener) {
+	if t, ok := s.client.Transport.(*RetryTransport); ok {
+		t.AttemptTimeout = o.requestTimeout
+	}
+}
+
+// priceOptions 價格歷史參數
+type priceOptions struct {
+	chart     ChartOptions
+	source    string
+	tolerance float64
+	raw       bool
+}
+
+func (o *priceOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.chart.Range, "range", defaultChartRange, "價格歷史區間: "+strings.Join(chartRanges, ", "))
+	fs.StringVar(&o.chart.Interval, "interval", defaultChartInterval, "K棒週期: 1d (日線), 1wk (週線), 1mo (月線)")
+	fs.StringVar(&o.source, "price-source", priceSourceYahoo, "優先使用的價格來源: yahoo, official (證交所/櫃買中心)，失敗時改用另一來源")
+	fs.Float64Var(&o.tolerance, "price-check", 0, "與另一價格來源比對收盤價的容許差異 (%)，0 表示不比對")
+	fs.BoolVar(&o.raw, "raw-prices", false, "技術指標使用未還原權息的原始價格")
+}
+
+// apply 檢查參數並設定篩選器的價格來源
+func (o *priceOptions) apply(s *StockScreener) error {
+	if err := o.chart.Validate(); err != nil {
+		return err
+	}
+	sources, err := NewPriceSources(s.client, o.source)
+	if err != nil {
+		return err
+	}
+	if o.tolerance < 0 {
+		return fmt.Errorf("價格比對容許差異不可為負數: %v", o.tolerance)
+	}
+
+	s.chart = o.chart
+	s.chart.Adjusted = !o.raw
+	s.priceSources = sources
+	s.priceTolerance = o.tolerance
+	return nil
+}
+
+// screenerOptions 各指令共用的篩選器參數
+type screenerOptions struct {
+	profile string
+	refresh bool
+	price   priceOptions
+}
+
+func (o *screenerOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.profile, "profile", "default", "篩選條件組合: default, strict, relaxed 或JSON檔路徑")
+	fs.BoolVar(&o.refresh, "refresh", false, "忽略快取，重新取得資料")
+	o.price.register(fs)
+}
+
+// newScreener 依參數建立篩選器
+func (o *screenerOptions) newScreener() (*StockScreener, error) {
+	criteria, err := LoadCriteriaProfile(o.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	screener := NewStockScreener()
+	screener.criteria = criteria
+	screener.refresh = o.refresh
+	if err := o.price.apply(screener); err != nil {
+		return nil, err
+	}
+	return screener, nil
+}
+
+// universeOptions 股票清單參數
+type universeOptions struct {
+	codes    string
+	universe string
+}
+
+func (o *universeOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.codes, "codes", "", "以逗號分隔的股票代碼，指定時忽略 --universe")
+	fs.StringVar(&o.universe, "universe", "default", "股票清單: default, watchlist (自選股及持股), twse (全部上市) 或代碼清單檔路徑")
+}
+
+// resolve 取得要分析的股票代碼
+func (o *universeOptions) resolve(ctx context.Context, s *StockScreener) ([]string, error) {
+	if o.codes != "" {
+		return splitCodes(o.codes), nil
+	}
+
+	switch o.universe {
+	case "default":
+		codes, err := s.FetchStockList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// 加入警示規則中的自選股
+		return mergeCodes(codes, s.WatchlistCodes()), nil
+	case "watchlist":
+		ledger, err := LoadLedger(defaultLedgerFile)
+		if err != nil {
+			return nil, err
+		}
+		holdings, err := ledger.Codes()
+		if err != nil {
+			return nil, err
+		}
+		return mergeCodes(s.WatchlistCodes(), holdings), nil
+	case "twse":
+		return s.FetchListedStocks(ctx)
+	}
+
+	return readCodesFile(o.universe)
+}
+
+// splitCodes 解析逗號分隔的股票代碼
+func splitCodes(text string) []string {
+	var codes []string
+	for _, code := range strings.Split(text, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return mergeCodes(codes, nil)
+}
+
+// readCodesFile 讀取代碼清單檔，每行一個代碼，# 之後為註解
+func readCodesFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取股票清單 %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	var codes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		codes = append(codes, splitCodes(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mergeCodes(codes, nil), nil
+}
+
+// screenFlags screen 指令參數
+type screenFlags struct {
+	opts      screenerOptions
+	universe  universeOptions
+	timeouts  timeoutFlags
+	format    string
+	out       string
+	capital   float64
+	weighting string
+	oddLot    bool
+}
+
+// parseScreenArgs 解析 screen 指令參數
+func parseScreenArgs(args []string) (*screenFlags, error) {
+	f := &screenFlags{}
+	fs := flag.NewFlagSet("screen", flag.ContinueOnError)
+	f.opts.register(fs)
+	f.universe.register(fs)
+	f.timeouts.register(fs)
+	fs.StringVar(&f.format, "format", FormatJSON, "報告格式: text, json, csv, markdown, html, xlsx")
+	fs.StringVar(&f.out, "out", "", "報告輸出路徑，\"-\" 表示標準輸出 (預設依時間戳記命名)")
+	fs.Float64Var(&f.capital, "capital", defaultPortfolioCapital, "投組建議的投入資金")
+	fs.StringVar(&f.weighting, "weighting", WeightEqual, "投組權重方式: equal, score, inverse_vol, risk_parity")
+	fs.BoolVar(&f.oddLot, "odd-lot", false, "投組建議允許以零股補足")
+	if _, err := parseCommand(fs, args); err != nil {
+		return nil, err
+	}
+	if _, err := NewReportRenderer(f.format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, errUsage
+	}
+	return f, nil
+}
+
+// runScreen 執行篩選
+func runScreen(args []string) int {
+	flags, err := parseScreenArgs(args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+
+	slog.Info("啟動台股篩選系統")
+
+	ctx, cancel := flags.timeouts.context()
+	defer cancel()
+
+	// 建立篩選器
+	screener, err := flags.opts.newScreener()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	flags.timeouts.apply(screener)
+
+	// 設定通知
+	notifiers, err := NotifiersFromEnv(newNotifyClient())
+	if err != nil {
+		slog.Error("通知設定錯誤", "error", err)
+		return exitUsage
+	}
+	for _, n := range notifiers {
+		screener.AddNotifier(n)
+	}
+	screener.notifyTopN = notifyTopNFromEnv()
+
+	// 取得股票清單
+	stockList, err := flags.universe.resolve(ctx, screener)
+	if err != nil {
+		slog.Error("無法取得股票清單", "error", err)
+		return exitTotalFailure
+	}
+
+	slog.Info("準備篩選", "count", len(stockList))
+
+	// 執行篩選，中斷或逾時時仍輸出已完成的結果
+	qualifiedStocks, err := screener.ScreenStocks(ctx, stockList)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("篩選過程發生錯誤", "error", err)
+			return exitTotalFailure
+		}
+		slog.Warn("篩選中斷，輸出已完成的結果", "error", err, "qualified", len(qualifiedStocks))
+	}
+
+	// 報告輸出至標準輸出時，不另外輸出主控台報告
+	console := flags.out != "-"
+
+	// 產生報告
+	if console {
+		screener.GenerateReport(qualifiedStocks)
+	}
+
+	// 儲存結果 (JSON結果檔供下次比對新進榜，中斷時另存為部分結果)
+	timestamp := taipeiNow().Format("20060102_150405")
+	filename := resultsFilename(timestamp, ctx.Err() != nil)
+	if err := screener.SaveResults(qualifiedStocks, filename); err != nil {
+		slog.Warn("無法儲存結果", "error", err)
+	} else {
+		slog.Info("結果已儲存", "file", filename)
+	}
+
+	// 輸出指定格式報告
+	if flags.format != FormatJSON || flags.out != "" {
+		reportFile := flags.out
+		if reportFile == "" {
+			reportFile = fmt.Sprintf("screening_report_%s.%s", timestamp, reportExtension(flags.format))
+		}
+		if err := screener.WriteReport(qualifiedStocks, flags.format, reportFile); err != nil {
+			slog.Warn("無法輸出報告", "error", err)
+		} else if console {
+			slog.Info("報告已輸出", "file", reportFile)
+		}
+	}
+
+	// 產生買進建議
+	builder := NewPortfolioBuilder(flags.capital)
+	builder.Scheme = flags.weighting
+	builder.Constraints.AllowOddLot = flags.oddLot
+	if console {
+		printBuyAdvice(qualifiedStocks, builder)
+	}
+
+	// 持股停損停利監控 (已中斷時略過)
+	if ctx.Err() == nil {
+		monitorHoldings(ctx, screener, console)
+	}
+
+	if console {
+		screener.summary.Print()
+	}
+	return screener.summary.ExitCode()
+}
+
+// printBuyAdvice 輸出買進建議及投組建構建議
+func printBuyAdvice(qualifiedStocks []*StockData, builder *PortfolioBuilder) {
+	fmt.Println("\n========== 買進建議 ==========")
+	if len(qualifiedStocks) == 0 {
+		fmt.Println("目前沒有符合所有條件的股票")
+		fmt.Println("建議：")
+		fmt.Println("1. 放寬部分篩選條件")
+		fmt.Println("2. 等待市場回檔再執行篩選")
+		fmt.Println("3. 考慮ETF作為替代選擇")
+		return
+	}
+
+	fmt.Println("【優先考慮】評分最高的前3檔:")
+	for i := 0; i < len(qualifiedStocks) && i < 3; i++ {
+		stock := qualifiedStocks[i]
+		fmt.Printf("%d. %s (%s) - 評分: %.1f\n",
+			i+1, stock.Name, stock.Code, stock.Score)
+	}
+
+	// 投組建構建議
+	if plan, err := builder.Build(qualifiedStocks); err != nil {
+		slog.Warn("無法產生投組建議", "error", err)
+	} else {
+		PrintPortfolioPlan(plan)
+	}
+
+	rules := DefaultPositionRules()
+	fmt.Println("\n【進場策略】")
+	fmt.Println("1. 分3批進場，每批間隔1-2週")
+	fmt.Printf("2. 設定停損點在買進價-%.0f%%\n", rules.StopLossPct)
+	fmt.Printf("3. 獲利%.0f%%可先出場%.0f%%\n", rules.TakeProfitPct, rules.TakeProfitSellPct)
+	fmt.Println("4. 每週檢視技術指標變化")
+}
+
+// monitorHoldings 評估持股帳本的停損停利條件，console 為 false 時只更新帳本不輸出
+func monitorHoldings(ctx context.Context, screener *StockScreener, console bool) {
+	ledger, err := LoadLedger(defaultLedgerFile)
+	if err != nil {
+		slog.Warn("無法讀取持股帳本", "error", err)
+		return
+	}
+	holdingCodes, err := ledger.Codes()
+	if err != nil {
+		slog.Warn("持股帳本資料錯誤", "error", err)
+		return
+	}
+	if len(holdingCodes) == 0 {
+		return
+	}
+
+	report, err := ledger.MonitorPositions(screener.FetchPrices(ctx, holdingCodes), DefaultPositionRules())
+	if err != nil {
+		slog.Warn("持股監控失敗", "error", err)
+		return
+	}
+	if console {
+		PrintPositionReport(report)
+	}
+	if err := ledger.Save(); err != nil {
+		slog.Warn("無法儲存持股帳本", "error", err)
+	}
+}
+
+// runInspect 單一股票深入分析
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	var opts screenerOptions
+	var timeouts timeoutFlags
+	opts.register(fs)
+	timeouts.register(fs)
+	format := fs.String("format", FormatText, "輸出格式: text, json")
+	out := fs.String("out", "-", "輸出路徑，\"-\" 表示標準輸出")
+	positional, err := parseCommand(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "用法: stock inspect <code> [flags]")
+		return exitUsage
+	}
+	if *format != FormatText && *format != FormatJSON {
+		fmt.Fprintf(os.Stderr, "inspect 不支援的格式: %s\n", *format)
+		return exitUsage
+	}
+
+	screener, err := opts.newScreener()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	timeouts.apply(screener)
+
+	ctx, cancel := timeouts.context()
+	defer cancel()
+
+	inspection, err := screener.Inspect(ctx, positional[0])
+	if err != nil {
+		slog.Error("無法取得資料", logKeyStock, positional[0], "error", err)
+		return exitTotalFailure
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			slog.Error("無法建立輸出檔", "error", err)
+			return exitTotalFailure
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == FormatJSON {
+		if err := WriteInspectionJSON(w, inspection); err != nil {
+			slog.Error("無法輸出分析結果", "error", err)
+			return exitTotalFailure
+		}
+	} else {
+		PrintInspection(w, inspection)
+	}
+
+	return screener.summary.ExitCode()
+}
+
+// runFetch 預先取得資料並寫入快取
+func runFetch(args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	var universe universeOptions
+	var timeouts timeoutFlags
+	var price priceOptions
+	universe.register(fs)
+	timeouts.register(fs)
+	price.register(fs)
+	if _, err := parseCommand(fs, args); err != nil {
+		return usageExitCode(err)
+	}
+
+	ctx, cancel := timeouts.context()
+	defer cancel()
+
+	screener := NewStockScreener()
+	screener.refresh = true
+	if err := price.apply(screener); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	timeouts.apply(screener)
+
+	codes, err := universe.resolve(ctx, screener)
+	if err != nil {
+		slog.Error("無法取得股票清單", "error", err)
+		return exitTotalFailure
+	}
+
+	// 已寫入快取的資料保留，中斷後再次執行可接續
+	for i, code := range codes {
+		if ctx.Err() != nil {
+			screener.summary.markInterrupted(ctx.Err(), i, len(codes))
+			break
+		}
+		slog.Info("取得資料", logKeyStock, code, "progress", fmt.Sprintf("%d/%d", i+1, len(codes)))
+		if _, _, err := screener.LoadStock(ctx, code); err != nil {
+			slog.Warn("無法取得資料", logKeyStock, code, "error", err)
+		}
+	}
+
+	screener.summary.Print()
+	return screener.summary.ExitCode()
+}
+
+// runIndicators 顯示單一股票技術指標
+func runIndicators(args []string) int {
+	fs := flag.NewFlagSet("indicators", flag.ContinueOnError)
+	var timeouts timeoutFlags
+	var price priceOptions
+	timeouts.register(fs)
+	price.register(fs)
+	positional, err := parseCommand(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "用法: stock indicators <code>")
+		return exitUsage
+	}
+
+	ctx, cancel := timeouts.context()
+	defer cancel()
+
+	screener := NewStockScreener()
+	if err := price.apply(screener); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	timeouts.apply(screener)
+	stock := &StockData{Code: positional[0]}
+	if err := screener.FetchTechnicalData(ctx, stock); err != nil {
+		slog.Error("無法取得技術資料", logKeyStock, stock.Code, "error", err)
+		return exitTotalFailure
+	}
+	screener.loadCorporateActions(ctx, stock)
+
+	fmt.Printf("\n========== %s 技術指標 ==========\n", stock.Code)
+	fmt.Printf("現價: %.2f (%s)\n", stock.Price, stock.PriceSource)
+	fmt.Printf("MA60: %.2f\n", stock.MA60)
+	fmt.Printf("K值: %.2f | D值: %.2f\n", stock.KValue, stock.DValue)
+	fmt.Printf("RSI(14): %.2f\n", stock.RSI)
+	fmt.Printf("年化波動率: %.2f%%\n", stock.Volatility*100)
+	printRisk(os.Stdout, stock)
+	printMomentum(os.Stdout, stock)
+	printAdjustment(os.Stdout, stock)
+	fmt.Printf("平均成交量(%d根): %d\n", avgVolumeBars, stock.AvgVolume)
+	if n := len(stock.bars); n > 0 {
+		fmt.Printf("K棒: %d 根 (%s ~ %s, %s)\n", n, stock.bars[0].Time.Format("2006-01-02"),
+			stock.bars[n-1].Time.Format("2006-01-02"), price.chart.Interval)
+	}
+
+	return exitOK
+}
+
+// runRuns 歷次篩選結果管理
+func runRuns(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: stock runs list | stock runs diff [old] [new]")
+		return exitUsage
+	}
+
+	files, err := ListRuns()
+	if err != nil {
+		slog.Error("無法列出篩選結果", "error", err)
+		return exitTotalFailure
+	}
+
+	switch args[0] {
+	case "list":
+		if len(files) == 0 {
+			fmt.Println("尚無篩選結果")
+			return exitOK
+		}
+		for _, file := range files {
+			record, err := LoadRun(file)
+			if err != nil {
+				slog.Warn("無法讀取篩選結果", "error", err)
+				continue
+			}
+			top := "-"
+			if len(record.Stocks) > 0 {
+				top = fmt.Sprintf("%s %s (%.1f)", record.Stocks[0].Code, record.Stocks[0].Name, record.Stocks[0].Score)
+			}
+			fmt.Printf("%s  %s  %3d 檔  最高分: %s\n",
+				record.Time.Format("2006-01-02 15:04:05"), file, len(record.Stocks), top)
+		}
+		return exitOK
+
+	case "diff":
+		var olderFile, newerFile string
+		switch len(args) {
+		case 1:
+			if len(files) < 2 {
+				fmt.Fprintln(os.Stderr, "至少需要兩次篩選結果才能比較")
+				return exitUsage
+			}
+			olderFile, newerFile = files[len(files)-2], files[len(files)-1]
+		case 3:
+			olderFile, newerFile = args[1], args[2]
+		default:
+			fmt.Fprintln(os.Stderr, "用法: stock runs diff [old] [new]")
+			return exitUsage
+		}
+
+		older, err := LoadRun(olderFile)
+		if err != nil {
+			slog.Error("無法讀取篩選結果", "error", err)
+			return exitTotalFailure
+		}
+		newer, err := LoadRun(newerFile)
+		if err != nil {
+			slog.Error("無法讀取篩選結果", "error", err)
+			return exitTotalFailure
+		}
+		PrintRunDiff(older, newer, DiffRuns(older, newer))
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的 runs 指令: %s\n", args[0])
+	return exitUsage
+}
+
+// runPositions 持股帳本管理
+func runPositions(args []string) int {
+	const usage = "用法: stock positions list | stock positions buy|sell <code> <shares> <price> [--date YYYY-MM-DD] [--name 名稱] [--fee 元] [--tax 元]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("positions "+args[0], flag.ContinueOnError)
+	file := fs.String("file", defaultLedgerFile, "持股帳本檔案")
+	date := fs.String("date", taipeiNow().Format("2006-01-02"), "交易日期 (YYYY-MM-DD)")
+	name := fs.String("name", "", "股票名稱")
+	fee := fs.Float64("fee", 0, "實際手續費 (未指定時依費率計算，可指定0)")
+	tax := fs.Float64("tax", 0, "實際證券交易稅 (未指定時依稅率計算)")
+	positional, err := parseCommand(fs, args[1:])
+	if err != nil {
+		return usageExitCode(err)
+	}
+
+	ledger, err := LoadLedger(*file)
+	if err != nil {
+		slog.Error("無法讀取持股帳本", "error", err)
+		return exitTotalFailure
+	}
+
+	switch args[0] {
+	case "list":
+		holdings, err := ledger.Holdings()
+		if err != nil {
+			slog.Error("持股帳本資料錯誤", "error", err)
+			return exitTotalFailure
+		}
+		codes, _ := ledger.Codes()
+		if len(codes) == 0 {
+			fmt.Println("目前沒有持股")
+			return exitOK
+		}
+		for _, code := range codes {
+			h := holdings[code]
+			fmt.Printf("%s %s: %d 股 | 均價 %.2f | 持有成本 %.0f 元 | 建倉日 %s | 已實現損益 %.0f 元\n",
+				h.Code, h.Name, h.Shares, h.AvgCost, h.CostBasis, h.FirstBuy, h.RealizedPnL)
+		}
+		return exitOK
+
+	case SideBuy, SideSell:
+		if len(positional) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			return exitUsage
+		}
+		shares, err := strconv.Atoi(positional[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "股數格式錯誤: %s\n", positional[1])
+			return exitUsage
+		}
+		price, err := strconv.ParseFloat(positional[2], 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "價格格式錯誤: %s\n", positional[2])
+			return exitUsage
+		}
+
+		tx := Transaction{
+			Date:   *date,
+			Code:   positional[0],
+			Name:   *name,
+			Side:   args[0],
+			Shares: shares,
+			Price:  price,
+		}
+		// 只有明確指定時才記錄手續費及交易稅，否則依費率計算
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "fee":
+				tx.Fee = fee
+			case "tax":
+				tx.Tax = tax
+			}
+		})
+
+		if err := ledger.AddTransaction(tx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		if err := ledger.Save(); err != nil {
+			slog.Error("無法儲存持股帳本", "error", err)
+			return exitTotalFailure
+		}
+		fmt.Printf("已新增 %s %s %d 股 @ %.2f (%s)\n", tx.Date, tx.Code, tx.Shares, tx.Price, tx.Side)
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的 positions 指令: %s\n", args[0])
+	return exitUsage
+}
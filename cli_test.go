@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// keepDefaultLogger 還原 parseCommand 設定的預設日誌
+func keepDefaultLogger(t *testing.T) {
+	t.Helper()
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+}
+
+func TestParseScreenArgs(t *testing.T) {
+	keepDefaultLogger(t)
+	tests := []struct {
+		name     string
+		args     []string
+		ok       bool
+		codes    string
+		universe string
+		profile  string
+		format   string
+		out      string
+	}{
+		{"預設值", nil, true, "", "default", "default", FormatJSON, ""},
+		{"指定代碼", []string{"--codes", "2330,2454"}, true, "2330,2454", "default", "default", FormatJSON, ""},
+		{"股票清單及條件組合", []string{"--universe", "twse", "--profile", "strict"}, true, "", "twse", "strict", FormatJSON, ""},
+		{"報告格式及輸出", []string{"--format", "html", "--out", "report.html"}, true, "", "default", "default", FormatHTML, "report.html"},
+		{"標準輸出", []string{"-format=csv", "-out=-"}, true, "", "default", "default", FormatCSV, "-"},
+		{"不支援的格式", []string{"--format", "pdf"}, false, "", "", "", "", ""},
+		{"未知的旗標", []string{"--bogus"}, false, "", "", "", "", ""},
+		{"無效的日誌等級", []string{"--log-level", "loud"}, false, "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parseScreenArgs(tt.args)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil {
+				return
+			}
+			got := []string{flags.universe.codes, flags.universe.universe, flags.opts.profile, flags.format, flags.out}
+			want := []string{tt.codes, tt.universe, tt.profile, tt.format, tt.out}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("codes, universe, profile, format, out = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseArgsInterleaved(t *testing.T) {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	profile := fs.String("profile", "default", "")
+	format := fs.String("format", FormatText, "")
+
+	positional, err := parseArgs(fs, []string{"2330", "--profile", "strict", "2454", "--format", "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2330", "2454"}; !reflect.DeepEqual(positional, want) {
+		t.Errorf("positional = %v, want %v", positional, want)
+	}
+	if *profile != "strict" || *format != FormatJSON {
+		t.Errorf("profile = %q, format = %q", *profile, *format)
+	}
+}
+
+func TestParseArgsDoubleDash(t *testing.T) {
+	fs := flag.NewFlagSet("positions sell", flag.ContinueOnError)
+	fee := fs.Float64("fee", 0, "")
+
+	positional, err := parseArgs(fs, []string{"2330", "--fee", "20", "--", "-100", "--tax", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2330", "-100", "--tax", "5"}; !reflect.DeepEqual(positional, want) {
+		t.Errorf("positional = %q, want %q", positional, want)
+	}
+	if *fee != 20 {
+		t.Errorf("fee = %v, want 20", *fee)
+	}
+}
+
+func TestRunCLIHelp(t *testing.T) {
+	keepDefaultLogger(t)
+	for _, args := range [][]string{{"-h"}, {"--help"}, {"screen", "-h"}, {"inspect", "--help"}, {"help"}} {
+		if got := runCLI(args); got != exitOK {
+			t.Errorf("runCLI(%q) = %d, want %d", args, got, exitOK)
+		}
+	}
+}
+
+func TestRunCLIUsageErrors(t *testing.T) {
+	keepDefaultLogger(t)
+	t.Chdir(t.TempDir())
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"未知的指令", []string{"bogus"}},
+		{"inspect 缺少代碼", []string{"inspect"}},
+		{"inspect 不支援的格式", []string{"inspect", "2330", "--format", "html"}},
+		{"indicators 缺少代碼", []string{"indicators"}},
+		{"runs 缺少子指令", []string{"runs"}},
+		{"未知的 runs 指令", []string{"runs", "bogus"}},
+		{"runs diff 不足兩次結果", []string{"runs", "diff"}},
+		{"screen 未知的旗標", []string{"screen", "--bogus"}},
+		{"預設指令未知的旗標", []string{"--bogus"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runCLI(tt.args); got != exitUsage {
+				t.Errorf("runCLI(%q) = %d, want %d", tt.args, got, exitUsage)
+			}
+		})
+	}
+}
+
+func TestReadCodesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codes.txt")
+	content := "# 自選股\n2330\n2454, 2317  # 同一行多檔\n\n  0050\n2330\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := readCodesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2330", "2454", "2317", "0050"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes = %v, want %v", codes, want)
+	}
+
+	if _, err := readCodesFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("檔案不存在時應回傳錯誤")
+	}
+}
+
+func TestUniverseResolveCodesAndFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codes.txt")
+	if err := os.WriteFile(path, []byte("2603\n2609\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts universeOptions
+		want []string
+	}{
+		{"代碼優先於股票清單", universeOptions{codes: " 2330,,2454,2330 ", universe: path}, []string{"2330", "2454"}},
+		{"代碼清單檔", universeOptions{universe: path}, []string{"2603", "2609"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, err := tt.opts.resolve(context.Background(), &StockScreener{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(codes, tt.want) {
+				t.Errorf("codes = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	older := &RunRecord{Stocks: []*StockData{{Code: "A", Score: 80}, {Code: "B", Score: 70}, {Code: "C", Score: 60}}}
+	newer := &RunRecord{Stocks: []*StockData{{Code: "B", Score: 85}, {Code: "D", Score: 75}, {Code: "A", Score: 65}}}
+
+	diff := DiffRuns(older, newer)
+	codes := func(stocks []*StockData) []string {
+		var result []string
+		for _, stock := range stocks {
+			result = append(result, stock.Code)
+		}
+		return result
+	}
+	if got := codes(diff.Added); !reflect.DeepEqual(got, []string{"D"}) {
+		t.Errorf("Added = %v, want [D]", got)
+	}
+	if got := codes(diff.Removed); !reflect.DeepEqual(got, []string{"C"}) {
+		t.Errorf("Removed = %v, want [C]", got)
+	}
+	want := []ScoreChange{
+		{Code: "B", OldScore: 70, NewScore: 85, OldRank: 2, NewRank: 1},
+		{Code: "A", OldScore: 80, NewScore: 65, OldRank: 1, NewRank: 3},
+	}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("Changed = %+v, want %+v", diff.Changed, want)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary func(r *RunSummary)
+		want    int
+	}{
+		{"全部成功", func(r *RunSummary) { r.Attempted, r.Succeeded = 3, 3 }, exitOK},
+		{"未分析任何股票", func(r *RunSummary) {}, exitOK},
+		{"部分失敗", func(r *RunSummary) {
+			r.Attempted, r.Succeeded = 3, 2
+			r.markFailed("2330", errors.New("逾時"))
+		}, exitPartialFailure},
+		{"資料不完整不影響結束代碼", func(r *RunSummary) {
+			r.Attempted, r.Succeeded = 1, 1
+			r.markDegraded("2330", "ROE使用行業估算值")
+			r.markRunDegraded("交易日曆未涵蓋 [2027] 年，僅排除週末")
+		}, exitOK},
+		{"部分失敗且資料不完整", func(r *RunSummary) {
+			r.Attempted, r.Succeeded = 2, 1
+			r.markFailed("2454", errors.New("逾時"))
+			r.markDegraded("2330", "ROE使用行業估算值")
+		}, exitPartialFailure},
+		{"執行中斷", func(r *RunSummary) {
+			r.Attempted, r.Succeeded = 1, 1
+			r.markInterrupted(context.Canceled, 1, 3)
+		}, exitPartialFailure},
+		{"全部失敗", func(r *RunSummary) {
+			r.Attempted = 2
+			r.markFailed("2330", errors.New("逾時"))
+			r.markFailed("2454", errors.New("逾時"))
+		}, exitTotalFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRunSummary()
+			tt.summary(r)
+			if got := r.ExitCode(); got != tt.want {
+				t.Errorf("ExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
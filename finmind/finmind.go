@@ -0,0 +1,335 @@
+// Package finmind FinMind API客戶端: 財報、月營收、除權息及估值資料集的分頁查詢與額度用盡重試
+package finmind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FinMind API設定
+const (
+	apiURL              = "https://api.finmindtrade.com/api/v4/data"
+	tokenEnv            = "FINMIND_TOKEN"
+	defaultConfigFile   = "finmind.json"
+	defaultPageSpan     = 3 // 每次請求涵蓋的年數
+	defaultQuotaRetries = 2
+	defaultQuotaBackoff = 30 * time.Second
+	defaultQuotaReset   = time.Hour // FinMind 以小時計算請求額度
+	logKeyStock         = "stock"   // 與主程式相同的股票代碼日誌鍵
+)
+
+// FinMind 資料集
+const (
+	datasetFinancialStatements = "TaiwanStockFinancialStatements"
+	datasetBalanceSheet        = "TaiwanStockBalanceSheet"
+	datasetCashFlowsStatement  = "TaiwanStockCashFlowsStatement"
+	datasetMonthRevenue        = "TaiwanStockMonthRevenue"
+	datasetDividendResult      = "TaiwanStockDividendResult"
+	datasetCapitalReduction    = "TaiwanStockCapitalReductionReferencePrice"
+	datasetSplitPrice          = "TaiwanStockSplitPrice"
+	datasetPER                 = "TaiwanStockPER"
+)
+
+// ErrQuota FinMind請求次數已達上限
+var ErrQuota = errors.New("FinMind 請求次數已達上限")
+
+// Error FinMind API回傳的錯誤
+type Error struct {
+	Dataset string
+	Status  int
+	Msg     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("FinMind %s 錯誤 (status %d): %s", e.Dataset, e.Status, e.Msg)
+}
+
+// FinancialStatement 財務報表結構 (綜合損益表、資產負債表、現金流量表)
+type FinancialStatement struct {
+	Date       string  `json:"date"`
+	StockID    string  `json:"stock_id"`
+	Type       string  `json:"type"`
+	Value      float64 `json:"value"`
+	OriginName string  `json:"origin_name"`
+}
+
+// MonthRevenue 月營收資料
+type MonthRevenue struct {
+	Date         string  `json:"date"`
+	StockID      string  `json:"stock_id"`
+	Revenue      float64 `json:"revenue"`
+	RevenueMonth int     `json:"revenue_month"`
+	RevenueYear  int     `json:"revenue_year"`
+}
+
+// DividendResult 除權息結果 (前後參考價)
+type DividendResult struct {
+	Date         string  `json:"date"`
+	StockID      string  `json:"stock_id"`
+	BeforePrice  float64 `json:"before_price"`             // 除權息前收盤價
+	AfterPrice   float64 `json:"after_price"`              // 除權息參考價
+	Dividend     float64 `json:"stock_and_cache_dividend"` // 權值+息值
+	DividendType string  `json:"stock_or_cache_dividend"`  // 權、息或權息
+}
+
+// CapitalReduction 減資恢復買賣參考價
+type CapitalReduction struct {
+	Date           string  `json:"date"`
+	StockID        string  `json:"stock_id"`
+	LastClose      float64 `json:"ClosingPriceonTheLastTradingDay"` // 停止買賣前收盤價
+	ReferencePrice float64 `json:"PostReductionReferencePrice"`     // 恢復買賣參考價
+	Reason         string  `json:"ReasonforCapitalReduction"`
+}
+
+// SplitPrice 面額變更 (分割) 前後參考價
+type SplitPrice struct {
+	Date        string  `json:"date"`
+	StockID     string  `json:"stock_id"`
+	Type        string  `json:"type"`
+	BeforePrice float64 `json:"before_price"`
+	AfterPrice  float64 `json:"after_price"`
+}
+
+// StockPER 每日本益比、股價淨值比及殖利率
+type StockPER struct {
+	Date          string  `json:"date"`
+	StockID       string  `json:"stock_id"`
+	DividendYield float64 `json:"dividend_yield"`
+	PER           float64 `json:"PER"`
+	PBR           float64 `json:"PBR"`
+}
+
+// Response FinMind API響應結構
+type Response[T any] struct {
+	Msg    string `json:"msg"`
+	Status int    `json:"status"`
+	Data   []T    `json:"data"`
+}
+
+// Config FinMind設定檔
+type Config struct {
+	Token string `json:"token"`
+}
+
+// TokenFromEnv 取得API token，優先使用環境變數，其次為設定檔
+func TokenFromEnv() string {
+	if token := strings.TrimSpace(os.Getenv(tokenEnv)); token != "" {
+		return token
+	}
+
+	data, err := os.ReadFile(defaultConfigFile)
+	if err != nil {
+		return ""
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		slog.Warn("無法解析FinMind設定檔", "file", defaultConfigFile, "error", err)
+		return ""
+	}
+	return strings.TrimSpace(config.Token)
+}
+
+// Client FinMind API客戶端
+type Client struct {
+	client       *http.Client
+	baseURL      string
+	token        string
+	logger       *slog.Logger
+	pageSpan     int           // 分頁年數，長區間依此拆成多次請求
+	quotaRetries int           // 額度用盡時的重試次數
+	quotaBackoff time.Duration // 額度用盡時的初始等待時間，每次加倍
+	quotaReset   time.Duration // 重試用盡後視為額度用盡的時間
+
+	mu             sync.Mutex
+	exhaustedUntil time.Time // 額度用盡至此時間前，所有請求直接失敗
+}
+
+// NewClient 建立FinMind客戶端，token 為空時以匿名額度存取
+func NewClient(client *http.Client, token string) *Client {
+	return &Client{
+		client:       client,
+		baseURL:      apiURL,
+		token:        token,
+		logger:       slog.Default(),
+		pageSpan:     defaultPageSpan,
+		quotaRetries: defaultQuotaRetries,
+		quotaBackoff: defaultQuotaBackoff,
+		quotaReset:   defaultQuotaReset,
+	}
+}
+
+// quotaExhausted 額度用盡的截止時間，未用盡時 ok 為 false
+func (c *Client) quotaExhausted(now time.Time) (until time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.exhaustedUntil, now.Before(c.exhaustedUntil)
+}
+
+// markQuotaExhausted 重試用盡後記錄額度用盡，回傳是否為首次記錄
+func (c *Client) markQuotaExhausted(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.exhaustedUntil) {
+		return false
+	}
+	c.exhaustedUntil = now.Add(c.quotaReset)
+	return true
+}
+
+// FinancialStatements 取得綜合損益表
+func (c *Client) FinancialStatements(ctx context.Context, code string, start, end time.Time) ([]FinancialStatement, error) {
+	return query[FinancialStatement](ctx, c, datasetFinancialStatements, code, start, end)
+}
+
+// BalanceSheet 取得資產負債表
+func (c *Client) BalanceSheet(ctx context.Context, code string, start, end time.Time) ([]FinancialStatement, error) {
+	return query[FinancialStatement](ctx, c, datasetBalanceSheet, code, start, end)
+}
+
+// CashFlowsStatement 取得現金流量表 (年初至今累計數值)
+func (c *Client) CashFlowsStatement(ctx context.Context, code string, start, end time.Time) ([]FinancialStatement, error) {
+	return query[FinancialStatement](ctx, c, datasetCashFlowsStatement, code, start, end)
+}
+
+// MonthRevenue 取得月營收
+func (c *Client) MonthRevenue(ctx context.Context, code string, start, end time.Time) ([]MonthRevenue, error) {
+	return query[MonthRevenue](ctx, c, datasetMonthRevenue, code, start, end)
+}
+
+// DividendResults 取得除權息結果
+func (c *Client) DividendResults(ctx context.Context, code string, start, end time.Time) ([]DividendResult, error) {
+	return query[DividendResult](ctx, c, datasetDividendResult, code, start, end)
+}
+
+// CapitalReductions 取得減資恢復買賣參考價
+func (c *Client) CapitalReductions(ctx context.Context, code string, start, end time.Time) ([]CapitalReduction, error) {
+	return query[CapitalReduction](ctx, c, datasetCapitalReduction, code, start, end)
+}
+
+// SplitPrices 取得面額變更前後參考價
+func (c *Client) SplitPrices(ctx context.Context, code string, start, end time.Time) ([]SplitPrice, error) {
+	return query[SplitPrice](ctx, c, datasetSplitPrice, code, start, end)
+}
+
+// PER 取得每日本益比、股價淨值比及殖利率
+func (c *Client) PER(ctx context.Context, code string, start, end time.Time) ([]StockPER, error) {
+	return query[StockPER](ctx, c, datasetPER, code, start, end)
+}
+
+// query 依日期區間分頁查詢資料集
+func query[T any](ctx context.Context, c *Client, dataset, code string, start, end time.Time) ([]T, error) {
+	var result []T
+	for pageStart := start; !pageStart.After(end); {
+		pageEnd := pageStart.AddDate(c.pageSpan, 0, -1)
+		// 區間恰為整數年時訖日會多出一天，併入本頁以免再送一次單日請求
+		if !pageStart.AddDate(c.pageSpan, 0, 0).Before(end) {
+			pageEnd = end
+		}
+
+		data, err := get[T](ctx, c, dataset, code, pageStart, pageEnd)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data...)
+		if !pageEnd.Before(end) {
+			break
+		}
+
+		pageStart = pageEnd.AddDate(0, 0, 1)
+	}
+	return result, nil
+}
+
+// get 送出單次查詢，額度用盡時等待後重試
+// 重試用盡後整個客戶端視為額度用盡，quotaReset 內的後續請求直接失敗，不再逐一等待退避
+func get[T any](ctx context.Context, c *Client, dataset, code string, start, end time.Time) ([]T, error) {
+	query := url.Values{}
+	query.Set("dataset", dataset)
+	query.Set("data_id", code)
+	query.Set("start_date", start.Format("2006-01-02"))
+	query.Set("end_date", end.Format("2006-01-02"))
+	requestURL := c.baseURL + "?" + query.Encode()
+
+	backoff := c.quotaBackoff
+	for attempt := 0; ; attempt++ {
+		if until, ok := c.quotaExhausted(time.Now()); ok {
+			return nil, fmt.Errorf("%w: 至 %s 前不再請求", ErrQuota, until.Format("15:04"))
+		}
+		data, err := do[T](ctx, c, dataset, requestURL)
+		if !errors.Is(err, ErrQuota) {
+			return data, err
+		}
+		if attempt >= c.quotaRetries {
+			if c.markQuotaExhausted(time.Now()) {
+				c.logger.Warn("FinMind 額度用盡，暫停請求", "dataset", dataset, logKeyStock, code,
+					"reset", c.quotaReset, "authenticated", c.token != "")
+			}
+			return data, err
+		}
+
+		c.logger.Warn("FinMind 額度用盡，稍後重試", "dataset", dataset, logKeyStock, code,
+			"wait", backoff, "authenticated", c.token != "")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// do 送出請求並解析回應
+func do[T any](ctx context.Context, c *Client, dataset, requestURL string) ([]T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("FinMind %s 請求失敗: %v", dataset, err)
+	}
+	defer resp.Body.Close()
+
+	// 錯誤回應可能無法解析，先判斷狀態再回報解析錯誤
+	var response Response[T]
+	decodeErr := json.NewDecoder(resp.Body).Decode(&response)
+
+	status := resp.StatusCode
+	if response.Status != 0 {
+		status = response.Status
+	}
+	if isQuotaError(status, response.Msg) {
+		return nil, fmt.Errorf("%w: %s", ErrQuota, response.Msg)
+	}
+	if resp.StatusCode != http.StatusOK || status != http.StatusOK {
+		msg := response.Msg
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return nil, &Error{Dataset: dataset, Status: status, Msg: msg}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("解析 FinMind %s 回應失敗: %v", dataset, decodeErr)
+	}
+
+	return response.Data, nil
+}
+
+// isQuotaError 判斷是否為額度用盡 (FinMind 回傳 402 及 "upper limit" 訊息)
+// 429 已由 HTTP 傳輸層依 Retry-After 重試，不再重複退避
+func isQuotaError(status int, msg string) bool {
+	return status == http.StatusPaymentRequired || strings.Contains(strings.ToLower(msg), "upper limit")
+}
@@ -0,0 +1,254 @@
+package finmind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClient 建立指向測試伺服器的客戶端，額度重試間隔縮短為 1ms
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.Client(), "test-token")
+	c.baseURL = srv.URL
+	c.logger = slog.New(slog.DiscardHandler)
+	c.quotaBackoff = time.Millisecond
+	return c
+}
+
+// sequence 依序回傳狀態碼及內容，超出後重複最後一個
+type sequence struct {
+	mu        sync.Mutex
+	responses []response
+	calls     int
+}
+
+type response struct {
+	status int
+	body   string
+}
+
+func (s *sequence) handler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := s.responses[min(s.calls, len(s.responses)-1)]
+	s.calls++
+	s.mu.Unlock()
+
+	w.WriteHeader(resp.status)
+	fmt.Fprint(w, resp.body)
+}
+
+const (
+	okBody    = `{"msg":"success","status":200,"data":[{"date":"2024-03-31","stock_id":"2330","type":"Revenue","value":100}]}`
+	limitBody = `{"msg":"Requests reach the upper limit. https://finmindtrade.com/","status":402}`
+)
+
+func TestQuotaBackoff(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		responses []response
+		wantCalls int
+		wantQuota bool
+		wantRows  int
+	}{
+		{"402後成功", []response{{http.StatusPaymentRequired, limitBody}, {http.StatusOK, okBody}}, 2, false, 1},
+		{"狀態200但訊息為upper limit", []response{
+			{http.StatusOK, `{"msg":"Requests reach the upper limit","status":200,"data":[]}`},
+			{http.StatusOK, okBody},
+		}, 2, false, 1},
+		{"回應內容status 402", []response{{http.StatusOK, limitBody}, {http.StatusOK, okBody}}, 2, false, 1},
+		{"重試用盡", []response{{http.StatusPaymentRequired, limitBody}}, defaultQuotaRetries + 1, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := &sequence{responses: tt.responses}
+			c := testClient(t, seq.handler)
+
+			rows, err := c.FinancialStatements(context.Background(), "2330", start, end)
+			if seq.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", seq.calls, tt.wantCalls)
+			}
+			if errors.Is(err, ErrQuota) != tt.wantQuota {
+				t.Errorf("err = %v, want quota %v", err, tt.wantQuota)
+			}
+			if !tt.wantQuota && err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != tt.wantRows {
+				t.Errorf("rows = %+v, want %d", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestQuotaExhaustedFailsFast(t *testing.T) {
+	seq := &sequence{responses: []response{{http.StatusPaymentRequired, limitBody}}}
+	c := testClient(t, seq.handler)
+	start, end := time.Now().AddDate(0, -1, 0), time.Now()
+
+	if _, err := c.MonthRevenue(context.Background(), "2330", start, end); !errors.Is(err, ErrQuota) {
+		t.Fatalf("err = %v, want ErrQuota", err)
+	}
+	if seq.calls != defaultQuotaRetries+1 {
+		t.Fatalf("calls = %d, want %d", seq.calls, defaultQuotaRetries+1)
+	}
+
+	// 重試用盡後其他股票的請求直接失敗，不再送出請求或等待退避
+	for _, code := range []string{"2454", "2317"} {
+		if _, err := c.PER(context.Background(), code, start, end); !errors.Is(err, ErrQuota) {
+			t.Errorf("%s: err = %v, want ErrQuota", code, err)
+		}
+	}
+	if seq.calls != defaultQuotaRetries+1 {
+		t.Errorf("額度用盡期間不應送出請求，calls = %d", seq.calls)
+	}
+
+	// 額度重置後恢復請求
+	c.mu.Lock()
+	c.exhaustedUntil = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+	seq.mu.Lock()
+	seq.responses = []response{{http.StatusOK, okBody}}
+	seq.mu.Unlock()
+	if _, err := c.MonthRevenue(context.Background(), "2330", start, end); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIErrorNotRetried(t *testing.T) {
+	seq := &sequence{responses: []response{{http.StatusBadRequest, `{"msg":"dataset not found","status":400}`}}}
+	c := testClient(t, seq.handler)
+
+	_, err := c.PER(context.Background(), "2330", time.Now().AddDate(0, -1, 0), time.Now())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.Dataset != datasetPER {
+		t.Fatalf("err = %v, want *Error 400", err)
+	}
+	if seq.calls != 1 {
+		t.Errorf("calls = %d, want 1", seq.calls)
+	}
+}
+
+func TestTooManyRequestsNotRetried(t *testing.T) {
+	// 429 由 HTTP 傳輸層重試，客戶端不再以額度退避重複重試
+	seq := &sequence{responses: []response{{http.StatusTooManyRequests, ""}, {http.StatusOK, okBody}}}
+	c := testClient(t, seq.handler)
+
+	_, err := c.MonthRevenue(context.Background(), "2330", time.Now().AddDate(0, -1, 0), time.Now())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests || errors.Is(err, ErrQuota) {
+		t.Fatalf("err = %v, want *Error 429", err)
+	}
+	if seq.calls != 1 {
+		t.Errorf("calls = %d, want 1", seq.calls)
+	}
+}
+
+func TestQuotaBackoffCancelled(t *testing.T) {
+	seq := &sequence{responses: []response{{http.StatusPaymentRequired, limitBody}}}
+	c := testClient(t, seq.handler)
+	c.quotaBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.MonthRevenue(ctx, "2330", time.Now().AddDate(0, -1, 0), time.Now())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if seq.calls != 1 {
+		t.Errorf("calls = %d, want 1", seq.calls)
+	}
+}
+
+func TestDatePaging(t *testing.T) {
+	var mu sync.Mutex
+	var pages [][2]string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("dataset") != datasetFinancialStatements || q.Get("data_id") != "2330" {
+			t.Errorf("query = %v", q)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		mu.Lock()
+		pages = append(pages, [2]string{q.Get("start_date"), q.Get("end_date")})
+		mu.Unlock()
+		fmt.Fprintf(w, `{"msg":"success","status":200,"data":[{"date":%q,"stock_id":"2330","type":"Revenue","value":1}]}`,
+			q.Get("end_date"))
+	})
+
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	rows, err := c.FinancialStatements(context.Background(), "2330", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][2]string{
+		{"2015-01-01", "2017-12-31"},
+		{"2018-01-01", "2020-12-31"},
+		{"2021-01-01", "2023-12-31"},
+		{"2024-01-01", "2024-06-30"},
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("pages = %v, want %v", pages, want)
+	}
+	for i := range want {
+		if pages[i] != want[i] {
+			t.Errorf("page %d = %v, want %v", i, pages[i], want[i])
+		}
+		if rows[i].Date != want[i][1] {
+			t.Errorf("row %d = %+v, 應依分頁順序合併", i, rows[i])
+		}
+	}
+}
+
+func TestDatePagingAlignedWindow(t *testing.T) {
+	var mu sync.Mutex
+	var pages [][2]string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		pages = append(pages, [2]string{q.Get("start_date"), q.Get("end_date")})
+		mu.Unlock()
+		fmt.Fprint(w, okBody)
+	})
+
+	// 與 main.go 相同的三年區間：起訖同月同日
+	end := time.Date(2024, 6, 30, 15, 4, 5, 0, time.Local)
+	start := end.AddDate(-3, 0, 0)
+	if _, err := c.FinancialStatements(context.Background(), "2330", start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [2]string{"2021-06-30", "2024-06-30"}
+	if len(pages) != 1 || pages[0] != want {
+		t.Errorf("pages = %v, want [%v]", pages, want)
+	}
+}
+
+func TestTokenFromEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(tokenEnv, "  env-token ")
+	if got := TokenFromEnv(); got != "env-token" {
+		t.Errorf("token = %q, want env-token", got)
+	}
+
+	t.Setenv(tokenEnv, "")
+	if got := TokenFromEnv(); got != "" {
+		t.Errorf("無環境變數及設定檔時 token = %q, want empty", got)
+	}
+}
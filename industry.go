@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 上市、上櫃公司基本資料 (含產業別代碼)
+const (
+	twseCompanyProfileURL = "https://openapi.twse.com.tw/v1/opendata/t187ap03_L"
+	tpexCompanyProfileURL = "https://www.tpex.org.tw/openapi/v1/mopsfe_t187ap03_O"
+)
+
+// industrySource 公司基本資料來源及欄位名稱
+type industrySource struct {
+	Market      string
+	URL         string
+	CodeKey     string
+	NameKey     string
+	IndustryKey string
+}
+
+// industrySources 產業分類來源: 證交所上市公司及櫃買中心上櫃公司 (兩者產業別代碼相同)
+var industrySources = []industrySource{
+	{Market: "上市", URL: twseCompanyProfileURL, CodeKey: "公司代號", NameKey: "公司簡稱", IndustryKey: "產業別"},
+	{Market: "上櫃", URL: tpexCompanyProfileURL, CodeKey: "SecuritiesCompanyCode", NameKey: "CompanyAbbreviation", IndustryKey: "SecuritiesIndustryCode"},
+}
+
+// minPeerGroupSize 計算產業中位數所需的最少同業股票數 (含自身)
+const minPeerGroupSize = 3
+
+// twseIndustryNames 證交所產業別代碼 -> 名稱
+var twseIndustryNames = map[string]string{
+	"01": "水泥工業",
+	"02": "食品工業",
+	"03": "塑膠工業",
+	"04": "紡織纖維",
+	"05": "電機機械",
+	"06": "電器電纜",
+	"08": "玻璃陶瓷",
+	"09": "造紙工業",
+	"10": "鋼鐵工業",
+	"11": "橡膠工業",
+	"12": "汽車工業",
+	"14": "建材營造業",
+	"15": "航運業",
+	"16": "觀光餐旅",
+	"17": "金融保險業",
+	"18": "貿易百貨業",
+	"19": "綜合",
+	"20": "其他業",
+	"21": "化學工業",
+	"22": "生技醫療業",
+	"23": "油電燃氣業",
+	"24": "半導體業",
+	"25": "電腦及週邊設備業",
+	"26": "光電業",
+	"27": "通信網路業",
+	"28": "電子零組件業",
+	"29": "電子通路業",
+	"30": "資訊服務業",
+	"31": "其他電子業",
+	"32": "文化創意業",
+	"33": "農業科技業",
+	"34": "電子商務",
+	"35": "綠能環保",
+	"36": "數位雲端",
+	"37": "運動休閒",
+	"38": "居家生活",
+	"91": "存託憑證",
+}
+
+// industryBaselineROE 各產業的ROE基準 (%)，供無財報資料時估算，未列出的產業為 defaultBaselineROE
+var industryBaselineROE = map[string]float64{
+	"半導體業":     15.0,
+	"電腦及週邊設備業": 12.0,
+	"光電業":      8.0,
+	"通信網路業":    12.0,
+	"電子零組件業":   12.0,
+	"電子通路業":    10.0,
+	"資訊服務業":    14.0,
+	"其他電子業":    12.0,
+	"金融保險業":    8.0,
+	"航運業":      6.0,
+	"食品工業":     10.0,
+	"水泥工業":     6.0,
+	"鋼鐵工業":     6.0,
+	"建材營造業":    9.0,
+	"生技醫療業":    7.0,
+}
+
+// defaultBaselineROE 未分類或未列出產業的ROE基準 (%)
+const defaultBaselineROE = 10.0
+
+// loadIndustries 從證交所及櫃買中心OpenAPI取得上市、上櫃公司產業別，每次執行只取得一次
+// 任一來源失敗時記錄警告並以其餘來源繼續 (未分類的股票同業比較時歸入 "未分類")
+func (s *StockScreener) loadIndustries(ctx context.Context) {
+	if s.industries != nil {
+		return
+	}
+	s.industries = make(map[string]string)
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+
+	for _, source := range industrySources {
+		if err := s.loadIndustrySource(ctx, source); err != nil {
+			s.logger.Warn("無法取得產業分類", "market", source.Market, "error", err)
+		}
+	}
+	s.logger.Debug("產業分類", "companies", len(s.industries))
+}
+
+// loadIndustrySource 取得單一來源的公司產業別及簡稱
+func (s *StockScreener) loadIndustrySource(ctx context.Context, source industrySource) error {
+	resp, err := s.get(ctx, source.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("OpenAPI 返回錯誤狀態碼: %d", resp.StatusCode)
+	}
+
+	var rows []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return fmt.Errorf("解析產業分類失敗: %v", err)
+	}
+
+	for _, row := range rows {
+		code := profileField(row, source.CodeKey)
+		if code == "" {
+			continue
+		}
+		industry := profileField(row, source.IndustryKey)
+		if name, ok := twseIndustryNames[industry]; ok {
+			industry = name
+		}
+		s.industries[code] = industry
+		if name := profileField(row, source.NameKey); name != "" {
+			if _, ok := s.names[code]; !ok {
+				s.names[code] = name
+			}
+		}
+	}
+	return nil
+}
+
+// profileField 公司基本資料的文字欄位，缺少或非文字時為空字串
+func profileField(row map[string]any, key string) string {
+	text, _ := row[key].(string)
+	return strings.TrimSpace(text)
+}
+
+// industryFor 股票的上市櫃產業別名稱，興櫃或無法取得時為空字串
+func (s *StockScreener) industryFor(ctx context.Context, code string) string {
+	s.loadIndustries(ctx)
+	return s.industries[code]
+}
+
+// calculateMargins 由近四季損益表計算毛利率、營業利益率及稅後淨利率 (%)
+// 營收不足四季時維持原值
+func calculateMargins(stock *StockData) {
+	h := stock.statements
+	if h == nil {
+		return
+	}
+	revenue, ok := h.ttm(h.income, 0, "Revenue")
+	if !ok || revenue <= 0 {
+		return
+	}
+	if gp, ok := h.ttm(h.income, 0, "GrossProfit"); ok {
+		stock.GrossMargin = gp / revenue * 100
+	}
+	if op, ok := h.ttm(h.income, 0, "OperatingIncome"); ok {
+		stock.OperatingMargin = op / revenue * 100
+	}
+	if ni, ok := h.ttm(h.income, 0, "IncomeAfterTaxes"); ok {
+		stock.NetMargin = ni / revenue * 100
+	}
+}
+
+// peerMetric 同業比較的指標，ok 為 false 表示資料不足，不列入中位數
+type peerMetric struct {
+	Field string
+	Label string
+	Value func(*StockData) (float64, bool)
+}
+
+// peerMetrics 同業比較指標
+var peerMetrics = []peerMetric{
+	{"roe", "ROE", func(s *StockData) (float64, bool) { return s.ROE, true }},
+	{"gross_margin", "毛利率", func(s *StockData) (float64, bool) { return s.GrossMargin, s.GrossMargin != 0 }},
+	{"operating_margin", "營業利益率", func(s *StockData) (float64, bool) { return s.OperatingMargin, s.OperatingMargin != 0 }},
+	{"pe", "本益比", func(s *StockData) (float64, bool) { return s.PE, s.PE > 0 }},
+	{"revenue_growth", "營收成長", func(s *StockData) (float64, bool) { return s.RevenueGrowth, true }},
+	{"eps_growth", "EPS增長", func(s *StockData) (float64, bool) { return s.EPSGrowth, true }},
+}
+
+// PeerMetric 單一指標與同業中位數的比較
+type PeerMetric struct {
+	Value  float64 `json:"value"`
+	Median float64 `json:"median"` // 產業中位數
+	Diff   float64 `json:"diff"`   // 與產業中位數的差距 (Value - Median)
+	Peers  int     `json:"peers"`  // 有資料的同業股票數 (含自身)
+}
+
+// PeerComparison 股票相對同產業股票的比較
+type PeerComparison struct {
+	Industry string                `json:"industry"`
+	Peers    int                   `json:"peers"` // 本次股票池中的同業股票數 (含自身)
+	Metrics  map[string]PeerMetric `json:"metrics"`
+}
+
+// metric 指標的同業比較，無資料時 ok 為 false
+func (p *PeerComparison) metric(field string) (PeerMetric, bool) {
+	if p == nil {
+		return PeerMetric{}, false
+	}
+	m, ok := p.Metrics[field]
+	return m, ok
+}
+
+// median 數值的中位數
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return quantile(sorted, 0.5)
+}
+
+// buildPeerGroups 依證交所產業別建立同業群組，計算各指標的產業中位數及個股差距
+// 未分類的股票不列入；同業有資料的股票不足 minPeerGroupSize 檔的指標不計算
+func (s *StockScreener) buildPeerGroups(universe []*StockData) {
+	groups := make(map[string][]*StockData)
+	for _, stock := range universe {
+		stock.Peers = nil
+		if stock.Industry != "" {
+			groups[stock.Industry] = append(groups[stock.Industry], stock)
+		}
+	}
+
+	for industry, members := range groups {
+		for _, stock := range members {
+			stock.Peers = &PeerComparison{Industry: industry, Peers: len(members), Metrics: make(map[string]PeerMetric)}
+		}
+
+		for _, metric := range peerMetrics {
+			var withData []*StockData
+			var values []float64
+			for _, stock := range members {
+				if v, ok := metric.Value(stock); ok {
+					withData = append(withData, stock)
+					values = append(values, v)
+				}
+			}
+			if len(values) < minPeerGroupSize {
+				continue
+			}
+
+			med := median(values)
+			for i, stock := range withData {
+				stock.Peers.Metrics[metric.Field] = PeerMetric{Value: values[i], Median: med, Diff: values[i] - med, Peers: len(values)}
+			}
+			s.logger.Debug("產業中位數", "industry", industry, "metric", metric.Field, "median", med, "peers", len(values))
+		}
+	}
+}
+
+// evaluateROE 第二階段ROE規則
+// 設定 ROEAboveIndustryMedian 且有產業中位數時，以高於產業中位數為通過，高於 MinROE 為尚可；否則達 GoodROE 為通過，達 MinROE 為尚可
+func (s *StockScreener) evaluateROE(stock *StockData) RuleVerdict {
+	if peer, ok := stock.Peers.metric("roe"); ok && s.criteria.ROEAboveIndustryMedian {
+		return gradeRule("ROE vs 產業中位數",
+			fmt.Sprintf("%.1f%% vs %.1f%% (%s, %d檔)", stock.ROE, peer.Median, stock.Peers.Industry, peer.Peers),
+			stock.ROE >= peer.Median, stock.ROE >= s.criteria.MinROE, "優於同業", "低於同業", "偏低",
+			fmt.Sprintf("ROE低於產業中位數 %.1f%% (<%.1f%%)", stock.ROE, peer.Median))
+	}
+	return gradeRule("ROE", fmt.Sprintf("%.1f%%", stock.ROE),
+		stock.ROE >= s.criteria.GoodROE, stock.ROE >= s.criteria.MinROE, "優秀", "良好", "偏低",
+		fmt.Sprintf("ROE偏低 %.1f%% (<%.1f%%)", stock.ROE, s.criteria.MinROE))
+}
+
+// peerText 同業比較文字 (如 半導體業 12檔 | ROE +3.2 | 毛利率 -1.5)
+func peerText(stock *StockData) string {
+	if stock.Peers == nil {
+		return "-"
+	}
+	parts := []string{fmt.Sprintf("%s %d檔", stock.Peers.Industry, stock.Peers.Peers)}
+	for _, metric := range peerMetrics {
+		if m, ok := stock.Peers.Metrics[metric.Field]; ok {
+			parts = append(parts, fmt.Sprintf("%s %+.1f", metric.Label, m.Diff))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// peerDiffCell 指標與產業中位數差距的表格欄位值，無資料時為 "-"
+func peerDiffCell(stock *StockData, field string) interface{} {
+	if m, ok := stock.Peers.metric(field); ok {
+		return m.Diff
+	}
+	return "-"
+}
+
+// industryLabel 產業顯示名稱，未分類時為 "未分類"
+func industryLabel(industry string) string {
+	if industry == "" {
+		return "未分類"
+	}
+	return industry
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadIndustriesListedAndOTC(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/twse", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"公司代號":"2330","公司簡稱":"台積電","產業別":"24"},{"公司代號":"2882","公司簡稱":"國泰金","產業別":"17"}]`)
+	})
+	mux.HandleFunc("/tpex", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"SecuritiesCompanyCode":"5483","CompanyAbbreviation":"中美晶","SecuritiesIndustryCode":"24"},{"SecuritiesCompanyCode":"6488","CompanyAbbreviation":"環球晶","SecuritiesIndustryCode":"24"}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	original := industrySources
+	defer func() { industrySources = original }()
+	industrySources = []industrySource{
+		{Market: "上市", URL: server.URL + "/twse", CodeKey: "公司代號", NameKey: "公司簡稱", IndustryKey: "產業別"},
+		{Market: "上櫃", URL: server.URL + "/tpex", CodeKey: "SecuritiesCompanyCode", NameKey: "CompanyAbbreviation", IndustryKey: "SecuritiesIndustryCode"},
+		{Market: "失敗", URL: server.URL + "/missing", CodeKey: "code"},
+	}
+
+	s := &StockScreener{client: server.Client(), logger: slog.New(slog.DiscardHandler)}
+	ctx := context.Background()
+	tests := []struct {
+		code, industry, name string
+	}{
+		{"2330", "半導體業", "台積電"},
+		{"2882", "金融保險業", "國泰金"},
+		{"5483", "半導體業", "中美晶"},
+		{"6488", "半導體業", "環球晶"},
+		{"9999", "", ""},
+	}
+	for _, tt := range tests {
+		if got := s.industryFor(ctx, tt.code); got != tt.industry {
+			t.Errorf("industryFor(%s) = %q, want %q", tt.code, got, tt.industry)
+		}
+		if got := s.names[tt.code]; got != tt.name {
+			t.Errorf("names[%s] = %q, want %q", tt.code, got, tt.name)
+		}
+	}
+}
+
+func TestIndustryOfUnclassified(t *testing.T) {
+	tests := []struct {
+		stock StockData
+		want  string
+	}{
+		{StockData{Code: "2330", Industry: "半導體業"}, "半導體業"},
+		{StockData{Code: "2330"}, "未分類"},
+		{StockData{Code: "7799"}, "未分類"},
+	}
+	for _, tt := range tests {
+		if got := industryOf(&tt.stock); got != tt.want {
+			t.Errorf("industryOf(%s) = %q, want %q", tt.stock.Code, got, tt.want)
+		}
+	}
+}
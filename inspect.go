@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// inspectQuarters 深入分析顯示的季度數
+const inspectQuarters = 8
+
+// QuarterFinancials 單季財務資料
+type QuarterFinancials struct {
+	Date      string  `json:"date"`
+	EPS       float64 `json:"eps"`
+	Revenue   float64 `json:"revenue"`
+	NetIncome float64 `json:"net_income"`
+	Equity    float64 `json:"equity"`
+	DebtRatio float64 `json:"debt_ratio"`
+}
+
+// Inspection 單一股票深入分析結果
+type Inspection struct {
+	Stock     *StockData          `json:"stock"`
+	Quarters  []QuarterFinancials `json:"quarters"`
+	ROESteps  []ROEStep           `json:"roe_steps"`
+	ROESource string              `json:"roe_source"`
+	Actions   []CorporateAction   `json:"corporate_actions,omitempty"`
+	Stages    []StageResult       `json:"stages"`
+	Qualified bool                `json:"qualified"`
+	Universe  int                 `json:"universe"` // 比較基準股票數 (含自身)
+}
+
+// Inspect 取得單一股票的完整分析資料
+func (s *StockScreener) Inspect(ctx context.Context, code string) (*Inspection, error) {
+	stock, _, err := s.LoadStock(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	s.loadCorporateActions(ctx, stock)
+
+	quarters, err := s.FetchQuarterlyFinancials(ctx, code, inspectQuarters)
+	if err != nil {
+		s.logger.Warn("無法取得季度財務資料", logKeyStock, code, "error", err)
+		s.summary.markDegraded(code, "季度財務資料失敗")
+	}
+
+	// 與 screen 相同，先依比較基準判定市場狀態、同業比較、相對強弱評等及橫向排名，再判定各階段
+	universe := s.inspectUniverse(ctx, stock)
+	s.detectRegime(ctx, universe)
+	s.buildPeerGroups(universe)
+	s.rateRelativeStrength(universe)
+	s.rankUniverse(universe)
+
+	stages := s.EvaluateStages(stock)
+	qualified := qualifies(stages)
+	if qualified {
+		s.scoreStocks([]*StockData{stock}, universe)
+	}
+
+	return &Inspection{
+		Stock:     stock,
+		Quarters:  quarters,
+		ROESteps:  stock.roeSteps,
+		ROESource: roeSource(stock.roeSteps),
+		Actions:   stock.actions,
+		Stages:    stages,
+		Qualified: qualified,
+		Universe:  len(universe),
+	}, nil
+}
+
+// inspectUniverse 以當日快取的股票 (screen 或 fetch 已取得者) 作為比較基準，並加入分析的股票
+func (s *StockScreener) inspectUniverse(ctx context.Context, stock *StockData) []*StockData {
+	universe := []*StockData{stock}
+	tradingDay, err := s.latestTradingDate(ctx)
+	if err != nil {
+		return universe
+	}
+
+	for _, peer := range s.cache.LoadDay(tradingDay, s.chart) {
+		if peer.Code == stock.Code {
+			continue
+		}
+		if peer.Industry == "" {
+			peer.Industry = s.industryFor(ctx, peer.Code)
+		}
+		s.calculateIntrinsicValue(peer)
+		universe = append(universe, peer)
+	}
+	s.logger.Debug("比較基準", logKeyStock, stock.Code, "universe", len(universe))
+	return universe
+}
+
+// roeSource 最終採用的ROE計算方法
+func roeSource(steps []ROEStep) string {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].Error == "" {
+			return steps[i].Method
+		}
+	}
+	return roeMethodDefault
+}
+
+// FetchQuarterlyFinancials 從FinMind取得最近數季的EPS、營收、淨利、權益及負債比 (由新到舊)
+func (s *StockScreener) FetchQuarterlyFinancials(ctx context.Context, code string, quarters int) ([]QuarterFinancials, error) {
+	// 多取一年以涵蓋財報公布延遲
+	now := taipeiNow()
+	startDate := now.AddDate(-(quarters/4 + 1), 0, 0)
+
+	byDate := make(map[string]*QuarterFinancials)
+	quarter := func(date string) *QuarterFinancials {
+		if q, ok := byDate[date]; ok {
+			return q
+		}
+		q := &QuarterFinancials{Date: date}
+		byDate[date] = q
+		return q
+	}
+
+	statements, err := s.finmind.FinancialStatements(ctx, code, startDate, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range statements {
+		switch item.Type {
+		case "EPS":
+			quarter(item.Date).EPS = item.Value
+		case "Revenue":
+			quarter(item.Date).Revenue = item.Value
+		case "IncomeAfterTaxes":
+			quarter(item.Date).NetIncome = item.Value
+		}
+	}
+
+	balance, err := s.finmind.BalanceSheet(ctx, code, startDate, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range balance {
+		switch item.Type {
+		case "Equity":
+			quarter(item.Date).Equity = item.Value
+		case "Liabilities_per":
+			quarter(item.Date).DebtRatio = item.Value
+		}
+	}
+
+	dates := make([]string, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	if len(dates) > quarters {
+		dates = dates[:quarters]
+	}
+
+	result := make([]QuarterFinancials, 0, len(dates))
+	for _, date := range dates {
+		result = append(result, *byDate[date])
+	}
+	return result, nil
+}
+
+// WriteInspectionJSON 以JSON輸出深入分析結果
+func WriteInspectionJSON(w io.Writer, ins *Inspection) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ins)
+}
+
+// PrintInspection 以終端機格式輸出深入分析結果
+func PrintInspection(w io.Writer, ins *Inspection) {
+	stock := ins.Stock
+
+	fmt.Fprintf(w, "\n========== %s %s 深入分析 ==========\n", stock.Code, stock.Name)
+	if stock.Industry != "" {
+		fmt.Fprintf(w, "產業: %s\n", stock.Industry)
+	}
+	if ins.Universe < 2 {
+		fmt.Fprintln(w, "比較基準: 無當日快取的其他股票 (請先執行 screen 或 fetch)，同業比較、相對強弱評等、橫向排名及跨股票正規化的評分因子無法計算")
+	} else {
+		fmt.Fprintf(w, "比較基準: 當日快取 %d 檔\n", ins.Universe)
+	}
+
+	// 季度財務資料
+	fmt.Fprintf(w, "\n【近%d季財務資料】\n", inspectQuarters)
+	if len(ins.Quarters) == 0 {
+		fmt.Fprintln(w, "無季度財務資料")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "季度\tEPS\t營收(億)\t淨利(億)\t股東權益(億)\t負債比\t")
+		for _, q := range ins.Quarters {
+			fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.1f%%\t\n",
+				q.Date, q.EPS, q.Revenue/1e8, q.NetIncome/1e8, q.Equity/1e8, q.DebtRatio)
+		}
+		tw.Flush()
+	}
+
+	// ROE計算過程
+	fmt.Fprintln(w, "\n【ROE計算過程】")
+	for i, step := range ins.ROESteps {
+		if step.Error != "" {
+			fmt.Fprintf(w, "%d. %s ❌ %s\n", i+1, step.Method, step.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%d. %s ✅ ROE=%.2f%%", i+1, step.Method, step.ROE)
+		if step.Detail != "" {
+			fmt.Fprintf(w, " (%s)", step.Detail)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "採用: %s, ROE=%.2f%%\n", ins.ROESource, stock.ROE)
+	fmt.Fprintf(w, "毛利率: %.1f%% | 營業利益率: %.1f%% | 淨利率: %.1f%% (近四季)\n",
+		stock.GrossMargin, stock.OperatingMargin, stock.NetMargin)
+
+	// 估值
+	fmt.Fprintln(w, "\n【估值】")
+	fmt.Fprintf(w, "本益比: %.1f | 淨值比: %.2f | 殖利率: %.2f%% | PEG: %.2f | 盈餘殖利率: %.2f%%\n",
+		stock.PE, stock.PB, stock.DividendYield, stock.PEG, stock.EarningsYield)
+	printValuationBand(w, "本益比", stock.PEBand)
+	printValuationBand(w, "淨值比", stock.PBBand)
+	printValuationBand(w, "殖利率", stock.YieldBand)
+	printValuationBand(w, "PEG", stock.PEGBand)
+	printValuationBand(w, "盈餘殖利率", stock.EarningsYieldBand)
+
+	// 現金流量
+	fmt.Fprintln(w, "\n【現金流量 (近四季)】")
+	if stock.OperatingCashFlow != 0 {
+		fmt.Fprintf(w, "營業現金流: %.0f | 資本支出: %.0f | 自由現金流: %.0f\n",
+			stock.OperatingCashFlow, stock.CapitalExpenditure, stock.FreeCashFlow)
+		fmt.Fprintf(w, "營業現金流/淨利: %.2f | 應計比率: %.1f%% | 自由現金流殖利率: %.2f%% | 連續為負: %d季\n",
+			stock.OCFToNetIncome, stock.AccrualsRatio, stock.FCFYield, stock.NegativeFCFQuarters)
+	} else {
+		fmt.Fprintln(w, "無現金流量資料")
+	}
+
+	// 財務評分
+	fmt.Fprintln(w, "\n【財務評分】")
+	printCompositeScore(w, "Piotroski F-Score", stock.PiotroskiF, "%.0f", "")
+	printCompositeScore(w, "Altman Z-Score", stock.AltmanZ, "%.2f",
+		altmanZone(stock.AltmanZ, altmanZDistress, altmanZSafe))
+	printCompositeScore(w, "Altman Z''(EM)", stock.AltmanZEM, "%.2f",
+		altmanZone(stock.AltmanZEM, altmanZEMDistress, altmanZEMSafe))
+	printCompositeScore(w, "Beneish M-Score", stock.BeneishM, "%.2f", "")
+
+	// 內在價值
+	fmt.Fprintln(w, "\n【內在價值】")
+	fmt.Fprintf(w, "近四季EPS: %.2f | 每股淨值: %.2f | 每股自由現金流: %.2f\n",
+		stock.EPSTTM, stock.BookValuePerShare, stock.FCFPerShare)
+	fmt.Fprintf(w, "DCF: %.2f | 葛拉漢數字: %.2f | 股利折現: %.2f\n",
+		stock.DCFValue, stock.GrahamNumber, stock.DDMValue)
+	if stock.FairValue > 0 {
+		fmt.Fprintf(w, "合理價: %.2f | 現價: %.2f | 安全邊際: %+.1f%%\n",
+			stock.FairValue, stock.Price, stock.MarginOfSafety)
+	} else {
+		fmt.Fprintln(w, "合理價: 無法估算 (虧損、未配息或資料不足)")
+	}
+
+	// 技術指標
+	fmt.Fprintln(w, "\n【技術指標】")
+	fmt.Fprintf(w, "現價: %.2f | MA60: %.2f | 相對大盤: %+.1f%%\n", stock.Price, stock.MA60, stock.RelativeStrength)
+	fmt.Fprintf(w, "K值: %.2f | D值: %.2f | RSI(14): %.2f\n", stock.KValue, stock.DValue, stock.RSI)
+	fmt.Fprintf(w, "年化波動率: %.2f%% | 平均成交量: %d\n", stock.Volatility*100, stock.AvgVolume)
+	printAdjustment(w, stock)
+
+	// 風險指標
+	fmt.Fprintln(w, "\n【風險指標】")
+	printRisk(w, stock)
+
+	// 動能指標
+	fmt.Fprintln(w, "\n【動能指標】")
+	printMomentum(w, stock)
+
+	// 篩選階段判定
+	for _, stage := range ins.Stages {
+		fmt.Fprintf(w, "\n【第%d階段 %s】%s %d/%d\n", stage.Stage, stage.Name,
+			verdictIcon(stageStatus(stage)), stage.PassCount, stage.TotalChecks)
+		for _, rule := range stage.Rules {
+			line := fmt.Sprintf("  %s %s: %s", verdictIcon(rule.Status), rule.Rule, rule.Value)
+			if rule.Note != "" {
+				line += " (" + rule.Note + ")"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	fmt.Fprintln(w, "\n【綜合評估】")
+	if ins.Qualified {
+		fmt.Fprintf(w, "納入候選清單，評分: %.1f\n", stock.Score)
+		for _, c := range stock.ScoreBreakdown {
+			line := fmt.Sprintf("  %s: %.2f → %.2f × 權重 %.0f = %.1f分", c.Label, c.Value, c.Normalized, c.Weight, c.Points)
+			if c.Neutral {
+				line += " (比較基準不足，無法計算，以中性分數計)"
+			}
+			fmt.Fprintln(w, line)
+		}
+	} else {
+		for _, stage := range ins.Stages {
+			if stage.Required && !stage.Passed {
+				fmt.Fprintf(w, "未通過第%d階段 %s: %s\n", stage.Stage, stage.Name, strings.Join(stage.Reasons, ", "))
+			}
+		}
+	}
+}
+
+// stageStatus 階段整體判定 (非排除條件的階段未通過僅為警示)
+func stageStatus(stage StageResult) string {
+	switch {
+	case stage.Passed:
+		return verdictPass
+	case stage.Required:
+		return verdictFail
+	}
+	return verdictWarn
+}
+
+// printAdjustment 輸出技術指標是否使用還原權息價格及期間內的公司行動
+func printAdjustment(w io.Writer, stock *StockData) {
+	if stock.AdjustSource == "" {
+		fmt.Fprintln(w, "價格: 未還原權息")
+		return
+	}
+	fmt.Fprintf(w, "價格: 還原權息 (%s)\n", stock.AdjustSource)
+	for _, action := range stock.actions {
+		fmt.Fprintf(w, "  %s %s %.2f → %.2f", action.Date.Format("2006-01-02"), action.Kind, action.Before, action.After)
+		if action.Note != "" {
+			fmt.Fprintf(w, " (%s)", action.Note)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printValuationBand 輸出估值指標的近5年分位數
+func printValuationBand(w io.Writer, name string, band *ValuationBand) {
+	if band == nil {
+		return
+	}
+	period := fmt.Sprintf("近%d年", valuationHistoryYears)
+	if band.Since != "" {
+		period = band.Since + "起"
+	}
+	fmt.Fprintf(w, "  %s%s: P10 %.2f | P25 %.2f | P50 %.2f | P75 %.2f | P90 %.2f | 目前位於 %.0f%%\n",
+		name, period, band.P10, band.P25, band.P50, band.P75, band.P90, band.Percentile)
+}
+
+// printCompositeScore 輸出綜合分數及各組成項目
+func printCompositeScore(w io.Writer, name string, score *CompositeScore, format, note string) {
+	if score == nil {
+		fmt.Fprintf(w, "%s: 資料不足\n", name)
+		return
+	}
+	line := fmt.Sprintf("%s: "+format, name, score.Value)
+	if note != "" {
+		line += " (" + note + ")"
+	}
+	fmt.Fprintln(w, line)
+	for _, c := range score.Components {
+		item := fmt.Sprintf("  %s: %.3f", c.Name, c.Value)
+		if c.Detail != "" {
+			item += " (" + c.Detail + ")"
+		}
+		fmt.Fprintln(w, item)
+	}
+}
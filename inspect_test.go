@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"stock/finmind"
+)
+
+// rewriteTransport 將所有請求轉送至測試伺服器
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// finmindRows 組成 FinMind 回應內容
+func finmindRows(rows ...string) string {
+	body := `{"msg":"success","status":200,"data":[`
+	for i, row := range rows {
+		if i > 0 {
+			body += ","
+		}
+		body += row
+	}
+	return body + "]}"
+}
+
+// inspectScreener 建立以暫存快取及測試伺服器取得資料的篩選器
+func inspectScreener(t *testing.T) *StockScreener {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		row := func(date, typ string, value float64) string {
+			return fmt.Sprintf(`{"date":%q,"stock_id":"2330","type":%q,"value":%v}`, date, typ, value)
+		}
+		switch r.URL.Query().Get("dataset") {
+		case "TaiwanStockFinancialStatements":
+			fmt.Fprint(w, finmindRows(
+				row("2023-09-30", "EPS", 8.1), row("2023-09-30", "Revenue", 5.4e11),
+				row("2023-12-31", "EPS", 9.2), row("2023-12-31", "Revenue", 6.2e11), row("2023-12-31", "IncomeAfterTaxes", 2.4e11),
+				row("2024-03-31", "EPS", 8.7), row("2024-03-31", "Revenue", 5.9e11), row("2024-03-31", "IncomeAfterTaxes", 2.3e11),
+			))
+		case "TaiwanStockBalanceSheet":
+			fmt.Fprint(w, finmindRows(
+				row("2023-12-31", "Equity", 3.6e12), row("2023-12-31", "Liabilities_per", 34.5),
+				row("2024-03-31", "Equity", 3.8e12), row("2024-03-31", "Liabilities_per", 33.1),
+			))
+		default:
+			fmt.Fprint(w, finmindRows())
+		}
+	}))
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+
+	calendar := NewTradingCalendar()
+	year := taipeiNow().Year()
+	calendar.AddYear(year-1, nil)
+	calendar.AddYear(year, nil)
+
+	client := &http.Client{Transport: rewriteTransport{target: target}}
+	return &StockScreener{
+		logger:          slog.New(slog.DiscardHandler),
+		client:          client,
+		finmind:         finmind.NewClient(client, ""),
+		calendar:        calendar,
+		holidaysLoaded:  true,
+		benchmarkLoaded: true,
+		chart:           DefaultChartOptions(),
+		cache:           NewDataCache(t.TempDir()),
+		summary:         NewRunSummary(),
+		criteria:        DefaultCriteria(),
+	}
+}
+
+func TestFetchQuarterlyFinancials(t *testing.T) {
+	s := inspectScreener(t)
+	quarters, err := s.FetchQuarterlyFinancials(context.Background(), "2330", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []QuarterFinancials{
+		{Date: "2024-03-31", EPS: 8.7, Revenue: 5.9e11, NetIncome: 2.3e11, Equity: 3.8e12, DebtRatio: 33.1},
+		{Date: "2023-12-31", EPS: 9.2, Revenue: 6.2e11, NetIncome: 2.4e11, Equity: 3.6e12, DebtRatio: 34.5},
+	}
+	if len(quarters) != len(want) {
+		t.Fatalf("quarters = %+v, want %+v", quarters, want)
+	}
+	for i := range want {
+		if quarters[i] != want[i] {
+			t.Errorf("quarter %d = %+v, want %+v", i, quarters[i], want[i])
+		}
+	}
+}
+
+func TestROESource(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ROEStep
+		want  string
+	}{
+		{"精確計算", []ROEStep{{Method: roeMethodPrecise, ROE: 25}}, roeMethodPrecise},
+		{"精確計算失敗改用TWSE", []ROEStep{
+			{Method: roeMethodPrecise, Error: "無法獲取淨利數據"},
+			{Method: roeMethodTWSE, ROE: 22},
+		}, roeMethodTWSE},
+		{"皆失敗採行業估算", []ROEStep{
+			{Method: roeMethodPrecise, Error: "無法獲取淨利數據"},
+			{Method: roeMethodTWSE, Error: "查無資料"},
+			{Method: roeMethodDuPont, Error: "資料不足"},
+			{Method: roeMethodIndustry, ROE: 12},
+		}, roeMethodIndustry},
+		{"無紀錄為預設值", nil, roeMethodDefault},
+		{"全部失敗為預設值", []ROEStep{{Method: roeMethodPrecise, Error: "無法獲取淨利數據"}}, roeMethodDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roeSource(tt.steps); got != tt.want {
+				t.Errorf("roeSource = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectUsesCachedUniverse(t *testing.T) {
+	tests := []struct {
+		name         string
+		peers        []string
+		wantUniverse int
+	}{
+		{"無快取同業", nil, 1},
+		{"當日快取為比較基準", []string{"2303", "2454"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := inspectScreener(t)
+			tradingDay, err := s.latestTradingDate(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, code := range append([]string{"2330"}, tt.peers...) {
+				stock := &StockData{Code: code, Industry: "半導體業", ROE: float64(10 + 5*i), PE: float64(15 + i), Price: 100}
+				if err := s.cache.Store(stock, tradingDay, s.chart); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ins, err := s.Inspect(context.Background(), "2330")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ins.Universe != tt.wantUniverse {
+				t.Errorf("Universe = %d, want %d", ins.Universe, tt.wantUniverse)
+			}
+			if s.regime == nil {
+				t.Error("應與 screen 相同判定市場狀態")
+			}
+			if peers := ins.Stock.Peers; peers == nil || peers.Peers != tt.wantUniverse {
+				t.Errorf("Peers = %+v, want %d 檔同業", peers, tt.wantUniverse)
+			}
+			if tt.wantUniverse < 2 {
+				if ins.Stock.Ranks != nil {
+					t.Errorf("無比較基準時不應排名, Ranks = %+v", ins.Stock.Ranks)
+				}
+			} else if ins.Stock.Ranks == nil || ins.Stock.Ranks.Universe != tt.wantUniverse {
+				t.Errorf("Ranks = %+v, want 股票池 %d 檔", ins.Stock.Ranks, tt.wantUniverse)
+			}
+			if len(ins.Quarters) == 0 || ins.Quarters[0].Date != "2024-03-31" {
+				t.Errorf("Quarters = %+v", ins.Quarters)
+			}
+		})
+	}
+}
+
+func TestWriteInspectionJSON(t *testing.T) {
+	ins := &Inspection{
+		Stock:     &StockData{Code: "2330", Name: "台積電", ROE: 25},
+		Quarters:  []QuarterFinancials{{Date: "2024-03-31", EPS: 8.7}},
+		ROESteps:  []ROEStep{{Method: roeMethodPrecise, ROE: 25, Detail: "本期淨利 / 平均股東權益"}},
+		ROESource: roeMethodPrecise,
+		Stages:    []StageResult{{Stage: 1, Name: "基本財務健康度", Required: true, Passed: true}},
+		Qualified: true,
+		Universe:  3,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteInspectionJSON(&buf, ins); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Inspection
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("輸出不是有效的JSON: %v\n%s", err, buf.String())
+	}
+	if got.Stock.Code != "2330" || got.ROESource != roeMethodPrecise || got.Universe != 3 || !got.Qualified {
+		t.Errorf("got = %+v", got)
+	}
+	if len(got.Quarters) != 1 || got.Quarters[0] != ins.Quarters[0] {
+		t.Errorf("Quarters = %+v", got.Quarters)
+	}
+	if len(got.ROESteps) != 1 || got.ROESteps[0] != ins.ROESteps[0] {
+		t.Errorf("ROESteps = %+v", got.ROESteps)
+	}
+	if len(got.Stages) != 1 || got.Stages[0].Name != "基本財務健康度" {
+		t.Errorf("Stages = %+v", got.Stages)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("corporate_actions")) {
+		t.Error("無公司行動時應省略 corporate_actions")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 內在價值模型參數
+const (
+	dcfYears         = 5    // DCF高成長期年數，之後以永續成長率計算終值
+	dcfMaxGrowth     = 15.0 // 高成長期成長率上限 (%)，避免單季EPS暴增造成高估
+	grahamMultiplier = 22.5 // 葛拉漢數字: 本益比15倍 × 股價淨值比1.5倍
+	shareParValue    = 10.0 // 普通股每股面額 (元)
+)
+
+// trailingFourQuarters 最近四季數值加總 (如近四季EPS)，季度不足或不連續時 ok 為 false
+func trailingFourQuarters(quarterly map[string]float64) (float64, bool) {
+	return trailingQuartersAt(quarterly, 0)
+}
+
+// sharesOutstanding 以資產負債表的普通股股本換算流通股數
+func sharesOutstanding(balance map[string]float64) float64 {
+	capital := balance["OrdinaryShare"]
+	if capital <= 0 {
+		capital = balance["CapitalStock"]
+	}
+	return capital / shareParValue
+}
+
+// bookValuePerShare 以資產負債表的歸屬母公司權益及流通股數計算每股淨值
+func bookValuePerShare(balance map[string]float64) float64 {
+	equity := balance["EquityAttributableToOwnersOfParent"]
+	if equity <= 0 {
+		equity = balance["Equity"]
+	}
+	shares := sharesOutstanding(balance)
+	if equity <= 0 || shares <= 0 {
+		return 0
+	}
+	return equity / shares
+}
+
+// discountedCashFlow 兩階段DCF每股價值: 前 dcfYears 年以 growth 成長，之後以永續成長率計算終值
+// 各比率皆為小數，discount 必須大於 terminal
+func discountedCashFlow(cashFlow, growth, discount, terminal float64) float64 {
+	if cashFlow <= 0 || discount <= terminal {
+		return 0
+	}
+	value := 0.0
+	flow := cashFlow
+	for year := 1; year <= dcfYears; year++ {
+		flow *= 1 + growth
+		value += flow / math.Pow(1+discount, float64(year))
+	}
+	terminalValue := flow * (1 + terminal) / (discount - terminal)
+	return value + terminalValue/math.Pow(1+discount, dcfYears)
+}
+
+// grahamNumber 葛拉漢數字 √(22.5 × EPS × 每股淨值)，虧損或淨值為負時為 0
+func grahamNumber(eps, bookValue float64) float64 {
+	if eps <= 0 || bookValue <= 0 {
+		return 0
+	}
+	return math.Sqrt(grahamMultiplier * eps * bookValue)
+}
+
+// gordonGrowth 高登成長模型 D0 × (1+g) / (r-g)，未配息或 r 不大於 g 時為 0
+func gordonGrowth(dividend, discount, growth float64) float64 {
+	if dividend <= 0 || discount <= growth {
+		return 0
+	}
+	return dividend * (1 + growth) / (discount - growth)
+}
+
+// calculateIntrinsicValue 計算DCF、葛拉漢數字、股利折現價值，取可用模型的中位數為合理價，並計算自由現金流殖利率
+// 需在價格及估值資料取得後呼叫；折現率等參數來自篩選條件，快取資料載入後亦重新計算
+func (s *StockScreener) calculateIntrinsicValue(stock *StockData) {
+	c := s.criteria
+	discount, terminal := c.DiscountRate/100, c.TerminalGrowth/100
+
+	// 資產負債表無法計算時，以股價淨值比回推每股淨值
+	bookValue := stock.BookValuePerShare
+	if bookValue <= 0 && stock.PB > 0 && stock.Price > 0 {
+		bookValue = stock.Price / stock.PB
+	}
+
+	growth := math.Max(math.Min(stock.EPSGrowth, dcfMaxGrowth), 0) / 100
+	dividend := stock.Price * stock.DividendYield / 100
+
+	stock.DCFValue = discountedCashFlow(stock.FCFPerShare, growth, discount, terminal)
+	stock.GrahamNumber = grahamNumber(stock.EPSTTM, bookValue)
+	stock.DDMValue = gordonGrowth(dividend, discount, terminal)
+
+	var values []float64
+	for _, v := range []float64{stock.DCFValue, stock.GrahamNumber, stock.DDMValue} {
+		if v > 0 {
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+
+	stock.FairValue, stock.MarginOfSafety = 0, 0
+	if len(values) > 0 {
+		stock.FairValue = quantile(values, 0.5)
+	}
+	if stock.FairValue > 0 && stock.Price > 0 {
+		stock.MarginOfSafety = (stock.FairValue - stock.Price) / stock.FairValue * 100
+	}
+
+	// 自由現金流殖利率 (僅在取得現金流量表時計算)
+	stock.FCFYield = 0
+	if stock.OperatingCashFlow != 0 && stock.Price > 0 {
+		stock.FCFYield = stock.FCFPerShare / stock.Price * 100
+	}
+
+	s.logger.Debug("內在價值", logKeyStock, stock.Code, "dcf", stock.DCFValue, "graham", stock.GrahamNumber,
+		"ddm", stock.DDMValue, "fair_value", stock.FairValue, "margin_of_safety", stock.MarginOfSafety)
+}
+
+// evaluateMarginOfSafety 安全邊際規則 (第五階段)，低於下限即排除，無法估算合理價時不列入判定
+func (s *StockScreener) evaluateMarginOfSafety(stock *StockData) RuleVerdict {
+	if stock.FairValue <= 0 || stock.Price <= 0 {
+		return skipRule("安全邊際", "無法估算合理價")
+	}
+	minMargin := s.criteria.MinMarginOfSafety
+	return gradeRule("安全邊際", fmt.Sprintf("%+.1f%% (合理價 %.2f)", stock.MarginOfSafety, stock.FairValue),
+		stock.MarginOfSafety >= minMargin, false, "達標", "", "不足",
+		fmt.Sprintf("安全邊際不足 %.1f%% (<%.1f%%)", stock.MarginOfSafety, minMargin))
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestEvaluateMarginOfSafety(t *testing.T) {
+	s := testScreener(t, "default")
+	tests := []struct {
+		name  string
+		stock StockData
+		want  string
+	}{
+		{"低於合理價", StockData{Price: 80, FairValue: 100, MarginOfSafety: 20}, verdictPass},
+		{"略高於合理價即排除", StockData{Price: 110, FairValue: 100, MarginOfSafety: -10}, verdictFail},
+		{"高於合理價", StockData{Price: 150, FairValue: 100, MarginOfSafety: -50}, verdictFail},
+		{"無法估算合理價", StockData{Price: 100}, verdictSkip},
+		{"缺少股價", StockData{FairValue: 100}, verdictSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.evaluateMarginOfSafety(&tt.stock).Status; got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarginOfSafetyExcludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		price   float64
+		want    bool
+	}{
+		{"低於合理價", "default", 80, true},
+		{"高於合理價排除", "default", 110, false},
+		{"嚴格條件安全邊際達20%", "strict", 75, true},
+		{"嚴格條件安全邊際不足20%排除", "strict", 90, false},
+		{"寬鬆條件容許高於合理價20%", "relaxed", 115, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testScreener(t, tt.profile)
+			stock := &StockData{Code: "2330", ROE: 20, EPS: 5, Price: tt.price, MA60: 60, KValue: 50, DValue: 50,
+				FairValue: 100, MarginOfSafety: 100 - tt.price}
+			if got := s.meetsScreeningCriteria(stock); got != tt.want {
+				t.Errorf("安全邊際 %.1f%%: meetsScreeningCriteria = %v, want %v", stock.MarginOfSafety, got, tt.want)
+			}
+		})
+	}
+}
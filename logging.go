@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// logKeyStock 日誌中股票代碼的欄位名稱，個股追蹤依此判斷
+const logKeyStock = "stock"
+
+// LogOptions 日誌設定
+type LogOptions struct {
+	Level slog.Level
+	JSON  bool     // 以JSON格式輸出
+	Trace []string // 對這些股票代碼輸出除錯日誌，不受 Level 限制
+}
+
+// NewLogger 建立診斷日誌，報告內容另行輸出至標準輸出
+func NewLogger(w io.Writer, opts LogOptions) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	trace := make(map[string]bool, len(opts.Trace))
+	for _, code := range opts.Trace {
+		trace[code] = true
+	}
+
+	return slog.New(&traceHandler{inner: handler, level: opts.Level, trace: trace})
+}
+
+// ParseLogLevel 解析日誌等級 (debug, info, warn, error)
+func ParseLogLevel(text string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.ToUpper(text)))
+	return level, err
+}
+
+// traceHandler 依等級過濾日誌，追蹤中的股票一律輸出
+type traceHandler struct {
+	inner  slog.Handler
+	level  slog.Level
+	trace  map[string]bool
+	traced bool // 已透過 With 帶有追蹤中的股票代碼
+}
+
+func (h *traceHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level || h.traced || len(h.trace) > 0
+}
+
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level && !h.traced && !h.tracedRecord(r) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// tracedRecord 日誌記錄是否屬於追蹤中的股票
+func (h *traceHandler) tracedRecord(r slog.Record) bool {
+	traced := false
+	r.Attrs(func(a slog.Attr) bool {
+		traced = h.tracedAttr(a)
+		return !traced
+	})
+	return traced
+}
+
+func (h *traceHandler) tracedAttr(a slog.Attr) bool {
+	return a.Key == logKeyStock && h.trace[a.Value.String()]
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(attrs)
+	for _, a := range attrs {
+		if h.tracedAttr(a) {
+			clone.traced = true
+		}
+	}
+	return &clone
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
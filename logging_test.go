@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logMessages 解析日誌輸出中的訊息 (JSON 或 text 格式)
+func logMessages(t *testing.T, buf *bytes.Buffer, jsonFormat bool) []string {
+	t.Helper()
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		if jsonFormat {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("無效的JSON日誌 %q: %v", line, err)
+			}
+			messages = append(messages, record[slog.MessageKey].(string))
+			continue
+		}
+		start := strings.Index(line, "msg=")
+		if start < 0 {
+			t.Fatalf("text 日誌缺少 msg: %q", line)
+		}
+		msg := line[start+len("msg="):]
+		if end := strings.Index(msg, " "); end >= 0 {
+			msg = msg[:end]
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestTraceHandler(t *testing.T) {
+	for _, jsonFormat := range []bool{false, true} {
+		name := "text"
+		if jsonFormat {
+			name = "json"
+		}
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewLogger(&buf, LogOptions{Level: slog.LevelInfo, JSON: jsonFormat, Trace: []string{"2330"}})
+
+			logger.Debug("traced", logKeyStock, "2330")
+			logger.Debug("untraced", logKeyStock, "2454")
+			logger.Debug("no_stock")
+			logger.Info("info_other", logKeyStock, "2454")
+			logger.Debug("traced_second_attr", "step", "ROE", logKeyStock, "2330")
+
+			// With 帶入股票代碼的 logger 保留追蹤判斷
+			logger.With(logKeyStock, "2330").Debug("with_traced")
+			logger.With(logKeyStock, "2454").Debug("with_untraced")
+			logger.With(logKeyStock, "2330").With("step", "ROE").Debug("with_traced_nested")
+			logger.With(logKeyStock, "2454").Warn("with_untraced_warn")
+			logger.WithGroup("fetch").Debug("group_traced", logKeyStock, "2330")
+
+			got := logMessages(t, &buf, jsonFormat)
+			want := []string{"traced", "info_other", "traced_second_attr", "with_traced",
+				"with_traced_nested", "with_untraced_warn", "group_traced"}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("messages = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTraceHandlerWithoutTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogOptions{Level: slog.LevelWarn, JSON: true})
+
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("未追蹤任何股票時 Debug 應停用")
+	}
+	logger.Debug("debug", logKeyStock, "2330")
+	logger.Info("info", logKeyStock, "2330")
+	logger.Warn("warn", logKeyStock, "2330")
+
+	if got := logMessages(t, &buf, true); strings.Join(got, ",") != "warn" {
+		t.Errorf("messages = %v, want [warn]", got)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"loud", 0, true},
+	}
+	for _, tt := range tests {
+		level, err := ParseLogLevel(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLogLevel(%q) err = %v, want error %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && level != tt.want {
+			t.Errorf("ParseLogLevel(%q) = %v, want %v", tt.text, level, tt.want)
+		}
+	}
+}
@@ -1,54 +1,205 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+
+	"stock/finmind"
 )
 
 // StockData 股票資料結構
 type StockData struct {
-	Code          string  `json:"code"`
-	Name          string  `json:"name"`
-	Price         float64 `json:"price"`
-	Volume        int64   `json:"volume"`
-	ROE           float64 `json:"roe"`
-	RevenueGrowth float64 `json:"revenue_growth"`
-	DebtRatio     float64 `json:"debt_ratio"`
-	GrossMargin   float64 `json:"gross_margin"`
-	DividendYears int     `json:"dividend_years"`
-	YoYGrowth     float64 `json:"yoy_growth"` // 年增率 (Year-over-Year)
-	EPSGrowth     float64 `json:"eps_growth"` // EPS增長率
-	EPS           float64 `json:"eps"`        // 每股盈餘
-	MA60          float64 `json:"ma60"`
-	KValue        float64 `json:"k_value"`
-	DValue        float64 `json:"d_value"`
-	AvgVolume     int64   `json:"avg_volume"`
-	Score         float64 `json:"score"`
+	Code                string  `json:"code"`
+	Name                string  `json:"name"`
+	Industry            string  `json:"industry"`
+	Price               float64 `json:"price"`
+	Volume              int64   `json:"volume"`
+	ROE                 float64 `json:"roe"`
+	RevenueGrowth       float64 `json:"revenue_growth"`
+	DebtRatio           float64 `json:"debt_ratio"`
+	GrossMargin         float64 `json:"gross_margin"`
+	OperatingMargin     float64 `json:"operating_margin"` // 營業利益率 (%)，近四季
+	NetMargin           float64 `json:"net_margin"`       // 稅後淨利率 (%)，近四季
+	DividendYears       int     `json:"dividend_years"`
+	YoYGrowth           float64 `json:"yoy_growth"`            // 年增率 (Year-over-Year)
+	EPSGrowth           float64 `json:"eps_growth"`            // EPS增長率
+	EPS                 float64 `json:"eps"`                   // 每股盈餘
+	MonthlyRevenueYoY   float64 `json:"monthly_revenue_yoy"`   // 最新月營收年增率
+	MonthlyRevenueMonth string  `json:"monthly_revenue_month"` // 最新月營收所屬月份 (2006-01)
+	MA60                float64 `json:"ma60"`
+	KValue              float64 `json:"k_value"`
+	DValue              float64 `json:"d_value"`
+	RSI                 float64 `json:"rsi"`        // 14日RSI
+	Volatility          float64 `json:"volatility"` // 年化波動率
+	AvgVolume           int64   `json:"avg_volume"`
+
+	PE                float64        `json:"pe"`             // 本益比
+	PB                float64        `json:"pb"`             // 股價淨值比
+	DividendYield     float64        `json:"dividend_yield"` // 殖利率 (%)
+	PEG               float64        `json:"peg"`            // 本益比 ÷ EPS增長率
+	EarningsYield     float64        `json:"earnings_yield"` // 盈餘殖利率 (%)
+	PEBand            *ValuationBand `json:"pe_band,omitempty"`
+	PBBand            *ValuationBand `json:"pb_band,omitempty"`
+	YieldBand         *ValuationBand `json:"yield_band,omitempty"`
+	PEGBand           *ValuationBand `json:"peg_band,omitempty"` // 僅涵蓋可計算EPS年增率的期間
+	EarningsYieldBand *ValuationBand `json:"earnings_yield_band,omitempty"`
+
+	EPSTTM            float64 `json:"eps_ttm"`              // 近四季EPS
+	BookValuePerShare float64 `json:"book_value_per_share"` // 每股淨值
+	FCFPerShare       float64 `json:"fcf_per_share"`        // 每股自由現金流 (近四季，無現金流量表時以近四季EPS近似)
+	DCFValue          float64 `json:"dcf_value"`            // 現金流折現價值
+	GrahamNumber      float64 `json:"graham_number"`        // 葛拉漢數字
+	DDMValue          float64 `json:"ddm_value"`            // 股利折現價值
+	FairValue         float64 `json:"fair_value"`           // 合理價 (可用模型的中位數)
+	MarginOfSafety    float64 `json:"margin_of_safety"`     // 安全邊際 (%)，(合理價-現價)/合理價
+
+	OperatingCashFlow   float64 `json:"operating_cash_flow"`   // 近四季營業活動現金流量
+	CapitalExpenditure  float64 `json:"capital_expenditure"`   // 近四季資本支出
+	FreeCashFlow        float64 `json:"free_cash_flow"`        // 近四季自由現金流 (營業現金流-資本支出)
+	OCFToNetIncome      float64 `json:"ocf_to_net_income"`     // 營業現金流/淨利
+	AccrualsRatio       float64 `json:"accruals_ratio"`        // 應計比率 (%)，(淨利-營業現金流)/總資產
+	FCFYield            float64 `json:"fcf_yield"`             // 自由現金流殖利率 (%)
+	NegativeFCFQuarters int     `json:"negative_fcf_quarters"` // 最近連續自由現金流為負的季數
+
+	PiotroskiF *CompositeScore `json:"piotroski_f,omitempty"` // Piotroski F-Score (0-9)
+	AltmanZ    *CompositeScore `json:"altman_z,omitempty"`    // Altman Z-Score
+	AltmanZEM  *CompositeScore `json:"altman_z_em,omitempty"` // Altman Z''-Score 新興市場版
+	BeneishM   *CompositeScore `json:"beneish_m,omitempty"`   // Beneish M-Score
+
+	Beta             float64 `json:"beta"`              // 相對加權指數的Beta，無大盤資料時為 0
+	RelativeStrength float64 `json:"relative_strength"` // 價格區間內相對加權指數的超額報酬 (%)
+	SharpeRatio      float64 `json:"sharpe_ratio"`      // 年化夏普比率
+	SortinoRatio     float64 `json:"sortino_ratio"`     // 年化Sortino比率
+	MaxDrawdown      float64 `json:"max_drawdown"`      // 最大回撤 (%)
+	DrawdownDuration int     `json:"drawdown_duration"` // 最大回撤期間 (K棒數，自前高至收復，未收復時至最新)
+	VaR95            float64 `json:"var_95"`            // 歷史法單期95% VaR (%)
+	ParametricVaR95  float64 `json:"parametric_var_95"` // 參數法 (常態分布) 單期95% VaR (%)
+
+	Return1M         float64 `json:"return_1m"`       // 近1個月報酬率 (%)
+	Return3M         float64 `json:"return_3m"`       // 近3個月報酬率 (%)
+	Return6M         float64 `json:"return_6m"`       // 近6個月報酬率 (%)
+	Return12M        float64 `json:"return_12m"`      // 近12個月報酬率 (%)
+	Momentum12Minus1 float64 `json:"momentum_12_1"`   // 12-1月動能 (%)，12個月前至1個月前的報酬率
+	RSScore          float64 `json:"rs_score"`        // IBD加權報酬 (%)，近一季40%、前三季各20%
+	RSRating         int     `json:"rs_rating"`       // 相對強弱評等 (1-99)，股票池中 RSScore 的百分位，0 表示資料不足
+	MomentumMonths   int     `json:"momentum_months"` // 價格歷史可回溯的月數 (1, 3, 6, 9, 12)，決定可計算的報酬率
+	High52W          float64 `json:"high_52w"`        // 52週最高價
+	Low52W           float64 `json:"low_52w"`         // 52週最低價
+	FromHigh52W      float64 `json:"from_high_52w"`   // 距52週高點 (%)，≤ 0
+	FromLow52W       float64 `json:"from_low_52w"`    // 距52週低點 (%)，≥ 0
+
+	PriceSource  string  `json:"price_source,omitempty"`  // 採用的價格來源
+	AdjustSource string  `json:"adjust_source,omitempty"` // 還原權息價格的來源，空值表示未還原
+	Score        float64 `json:"score"`
+
+	ScoreBreakdown []FactorContribution `json:"score_breakdown,omitempty"` // 各評分因子的貢獻
+	Ranks          *FactorRanks         `json:"ranks,omitempty"`           // 股票池及同產業的橫向排名
+	Peers          *PeerComparison      `json:"peers,omitempty"`           // 與同產業中位數的比較
+
+	bars     []Bar             // 原始K棒序列 (由舊到新)
+	adjBars  []Bar             // 還原權息K棒序列
+	actions  []CorporateAction // 期間內的公司行動
+	closes   []float64         // 技術指標使用的收盤價序列，供波動率及共變異數計算
+	roeSteps []ROEStep         // ROE計算過程，依序記錄各方法結果
+
+	statements *statementHistory // 財報歷史，僅在取得財務資料時存在 (不寫入快取)
+
+	netIncomeTTM float64 // 近四季稅後淨利
+	shares       float64 // 流通股數 (普通股股本 ÷ 面額)
+	totalAssets  float64 // 最新一季資產總額
 }
 
 // ScreeningCriteria 篩選條件
+// 第二、三階段的分級規則以 Min/Max 為可接受門檻 (未達即未通過)，Good/Buy 為通過門檻，介於兩者之間為警示
 type ScreeningCriteria struct {
-	MinROE           float64
-	MinRevenueGrowth float64
-	MaxDebtRatio     float64
-	MinDividendYears int
-	MinYoYGrowth     float64 // 最小年增率要求
-	MinEPSGrowth     float64 // 最小EPS增長率要求 (三位數 = 100%)
-	MinEPS           float64 // 最小EPS要求
-	RequireMA60Above bool
-	MinKValue        float64
-	MaxKValue        float64
-	MinDValue        float64
-	MaxDValue        float64
+	MinROE           float64 `json:"min_roe"`
+	MinRevenueGrowth float64 `json:"min_revenue_growth"`
+	MaxDebtRatio     float64 `json:"max_debt_ratio"`
+	MinDividendYears int     `json:"min_dividend_years"`
+	MinYoYGrowth     float64 `json:"min_yoy_growth"` // 最小年增率要求
+	MinEPSGrowth     float64 `json:"min_eps_growth"` // 最小EPS增長率要求 (三位數 = 100%)
+	MinEPS           float64 `json:"min_eps"`        // 最小EPS要求
+	RequireMA60Above bool    `json:"require_ma60_above"`
+	MinKValue        float64 `json:"min_k_value"`
+	MaxKValue        float64 `json:"max_k_value"` // 不含上限
+	MinDValue        float64 `json:"min_d_value"`
+	MaxDValue        float64 `json:"max_d_value"` // 不含上限
+
+	GoodROE           float64 `json:"good_roe"`            // 第二階段ROE通過門檻 (%)
+	GoodRevenueGrowth float64 `json:"good_revenue_growth"` // 第二階段營收成長通過門檻 (%)
+	GoodDebtRatio     float64 `json:"good_debt_ratio"`     // 第二階段負債比通過上限 (%)
+	GoodDividendYears int     `json:"good_dividend_years"` // 第二階段配息年數通過門檻
+	MinKBuy           float64 `json:"min_k_buy"`           // 第三階段K值買進區間
+	MaxKBuy           float64 `json:"max_k_buy"`
+	MinDBuy           float64 `json:"min_d_buy"` // 第三階段D值買進區間
+	MaxDBuy           float64 `json:"max_d_buy"`
+
+	ExcludeDebtRatio     float64 `json:"exclude_debt_ratio"`     // 第一階段排除: 負債比上限 (%)
+	ExcludeRevenueGrowth float64 `json:"exclude_revenue_growth"` // 第一階段排除: 營收成長下限 (%)
+	ExcludeYoYGrowth     float64 `json:"exclude_yoy_growth"`     // 第一階段排除: 年增率下限 (%)
+	ExcludeEPSGrowth     float64 `json:"exclude_eps_growth"`     // 第一階段排除: EPS增長下限 (%)
+
+	MaxPE            float64 `json:"max_pe"`             // 本益比上限
+	MaxPB            float64 `json:"max_pb"`             // 股價淨值比上限
+	MaxPEG           float64 `json:"max_peg"`            // PEG上限，0 表示不檢查
+	MaxPEPercentile  float64 `json:"max_pe_percentile"`  // 本益比在近5年 (及PEG在有資料期間) 的百分位上限，0 表示不檢查
+	WarnPEPercentile float64 `json:"warn_pe_percentile"` // 百分位超過 MaxPEPercentile 但在此以內為警示，超過即未通過
+
+	DiscountRate      float64 `json:"discount_rate"`        // 內在價值折現率 (%)
+	TerminalGrowth    float64 `json:"terminal_growth"`      // 永續成長率 (%)，須小於折現率
+	MinMarginOfSafety float64 `json:"min_margin_of_safety"` // 安全邊際下限 (%)
+
+	MinOCFToNetIncome      float64 `json:"min_ocf_to_net_income"`     // 營業現金流/淨利下限
+	MaxAccrualsRatio       float64 `json:"max_accruals_ratio"`        // 應計比率上限 (%)
+	MinFCFYield            float64 `json:"min_fcf_yield"`             // 自由現金流殖利率下限 (%)
+	MaxNegativeFCFQuarters int     `json:"max_negative_fcf_quarters"` // 自由現金流連續為負的季數上限
+
+	MinPiotroskiF int     `json:"min_piotroski_f"` // Piotroski F-Score 下限 (0-9)
+	MinAltmanZ    float64 `json:"min_altman_z"`    // Altman Z 下限，低於為財務困境
+	MinAltmanZEM  float64 `json:"min_altman_z_em"` // Altman Z''(EM) 下限，0 表示不檢查
+	MaxBeneishM   float64 `json:"max_beneish_m"`   // Beneish M 上限，高於為財報操縱風險
+
+	Scoring       []ScoringFactor `json:"scoring"`        // 評分因子，未設定時使用 DefaultScoring
+	RankWinsorize float64         `json:"rank_winsorize"` // 橫向排名前兩端截尾的百分位，0 表示不截尾
+
+	ROEAboveIndustryMedian bool `json:"roe_above_industry_median"` // 第二階段ROE以產業中位數為門檻 (無同業資料時採絕對門檻)
+
+	MaxBeta     float64 `json:"max_beta"`     // Beta 上限，0 表示不檢查
+	MaxDrawdown float64 `json:"max_drawdown"` // 最大回撤上限 (%)，0 表示不檢查
+	MaxVaR95    float64 `json:"max_var_95"`   // 歷史法單期95% VaR 上限 (%)，0 表示不檢查
+
+	MinTechnicalPassRatio float64                    `json:"min_technical_pass_ratio"`   // 第三階段技術面通過比例門檻
+	RegimeOverrides       map[string]json.RawMessage `json:"regime_overrides,omitempty"` // 依市場狀態 (bull, neutral, bear) 覆寫的條件
+
+	MinRSRating             int     `json:"min_rs_rating"`             // 相對強弱評等下限 (1-99)，0 表示不檢查
+	MaxFromHigh52W          float64 `json:"max_from_high_52w"`         // 距52週高點最大跌幅 (%)，0 表示不檢查
+	RequirePositiveMomentum bool    `json:"require_positive_momentum"` // 是否須12-1月動能為正
+}
+
+// 篩選結果檔名格式
+// 中斷或逾時的部分結果另存為 screening_partial_*，不列入歷次結果，以免被當成完整執行比對新進榜
+const (
+	resultsFileFormat        = "screening_results_%s.json"
+	resultsFilePattern       = "screening_results_*.json"
+	partialResultsFileFormat = "screening_partial_%s.json"
+)
+
+// resultsFilename 依執行是否完整決定篩選結果檔名
+func resultsFilename(timestamp string, partial bool) string {
+	if partial {
+		return fmt.Sprintf(partialResultsFileFormat, timestamp)
+	}
+	return fmt.Sprintf(resultsFileFormat, timestamp)
 }
 
 // EPSData EPS數據結構
@@ -57,57 +208,191 @@ type EPSData struct {
 	Value float64
 }
 
+// ROE計算方法
+const (
+	roeMethodDefault  = "預設值"
+	roeMethodPrecise  = "精確計算"
+	roeMethodTWSE     = "TWSE本益比/淨值比"
+	roeMethodTWSEPE   = "TWSE本益比估算"
+	roeMethodDuPont   = "DuPont估算"
+	roeMethodIndustry = "行業估算"
+)
+
+// ROEStep ROE計算過程中的單一方法結果
+type ROEStep struct {
+	Method string  `json:"method"`
+	ROE    float64 `json:"roe,omitempty"`
+	Detail string  `json:"detail,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// addROEStep 記錄ROE計算方法的結果
+func (stock *StockData) addROEStep(method, detail string, err error) {
+	step := ROEStep{Method: method, Detail: detail}
+	if err != nil {
+		step.Error = err.Error()
+	} else {
+		step.ROE = stock.ROE
+	}
+	stock.roeSteps = append(stock.roeSteps, step)
+}
+
 // StockScreener 股票篩選器
 type StockScreener struct {
 	client   *http.Client
+	finmind  *finmind.Client
 	criteria ScreeningCriteria
+	calendar *TradingCalendar
+
+	notifiers  []Notifier
+	notifyTopN int
+
+	alerts      *AlertEngine
+	alertEvents []AlertEvent
+
+	chart          ChartOptions  // 價格歷史的資料區間及K棒週期
+	priceSources   []PriceSource // 依序嘗試的價格來源
+	priceTolerance float64       // 與備援來源收盤價的容許差異 (%)，0 表示不比對
+
+	cache   *DataCache
+	refresh bool // 忽略快取重新取得資料
+	summary *RunSummary
+	names   map[string]string // 股票代碼 -> 名稱
+
+	industries map[string]string // 股票代碼 -> 證交所產業別，nil 表示尚未取得
+
+	holidaysLoaded bool // 是否已嘗試取得證交所休市日程
+
+	benchmark       []Bar         // 加權指數日K棒
+	benchmarkLoaded bool          // 是否已嘗試取得加權指數
+	regime          *MarketRegime // 本次執行的市場狀態
+
+	logger *slog.Logger // 診斷日誌，報告內容輸出至標準輸出
 }
 
 // NewStockScreener 建立新的篩選器
 func NewStockScreener() *StockScreener {
+	logger := slog.Default()
+
+	calendar := NewTradingCalendar()
+	if err := calendar.LoadClosures(defaultClosuresFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("無法載入休市日設定", "file", defaultClosuresFile, "error", err)
+	}
+
+	alerts, err := LoadAlertEngine(defaultAlertRulesFile, defaultAlertStateFile)
+	if err != nil {
+		logger.Warn("無法載入警示規則", "file", defaultAlertRulesFile, "error", err)
+	}
+
+	summary := NewRunSummary()
+	client := newHTTPClient(summary)
+	priceSources, _ := NewPriceSources(client, priceSourceYahoo)
+
 	return &StockScreener{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		criteria: ScreeningCriteria{
-			MinROE:           8.0,   // 降低ROE要求到8%
-			MinRevenueGrowth: -5.0,  // 允許小幅衰退
-			MaxDebtRatio:     60.0,  // 提高負債比容忍度到60%
-			MinDividendYears: 2,     // 降低配息年數要求到2年
-			MinYoYGrowth:     10.0,  // 年增率至少10%
-			MinEPSGrowth:     100.0, // EPS增長至少100% (三位數增長)
-			MinEPS:           1.0,   // 最小EPS要求1元
-			RequireMA60Above: false, // 不強制要求站上MA60
-			MinKValue:        30.0,  // 擴大KD值範圍
-			MaxKValue:        85.0,
-			MinDValue:        30.0,
-			MaxDValue:        85.0,
-		},
+		logger:       logger,
+		client:       client,
+		finmind:      finmind.NewClient(client, finmind.TokenFromEnv()),
+		calendar:     calendar,
+		notifyTopN:   defaultNotifyTopN,
+		alerts:       alerts,
+		chart:        DefaultChartOptions(),
+		priceSources: priceSources,
+		cache:        NewDataCache(defaultCacheDir),
+		summary:      summary,
+		criteria:     DefaultCriteria(),
+	}
+}
+
+// DefaultCriteria 預設篩選條件
+func DefaultCriteria() ScreeningCriteria {
+	return ScreeningCriteria{
+		MinROE:           10.0,  // ROE 10%以上可接受，15%以上為優秀
+		MinRevenueGrowth: 0.0,   // 營收不衰退，10%以上為高成長
+		MaxDebtRatio:     50.0,  // 負債比50%以內可接受，30%以內為優秀
+		MinDividendYears: 3,     // 配息3年以上，5年以上為穩定
+		MinYoYGrowth:     10.0,  // 年增率至少10%
+		MinEPSGrowth:     100.0, // EPS增長至少100% (三位數增長)
+		MinEPS:           1.0,   // 最小EPS要求1元
+		RequireMA60Above: false, // 不強制要求站上MA60
+		MinKValue:        30.0,  // KD值30-90可觀察，50-80為買進區間
+		MaxKValue:        90.0,
+		MinDValue:        30.0,
+		MaxDValue:        90.0,
+
+		GoodROE:           15.0,
+		GoodRevenueGrowth: 10.0,
+		GoodDebtRatio:     30.0,
+		GoodDividendYears: 5,
+		MinKBuy:           50.0,
+		MaxKBuy:           80.0,
+		MinDBuy:           50.0,
+		MaxDBuy:           80.0,
+		MaxPE:             25.0, // 本益比25倍以內
+		MaxPB:             4.0,
+		WarnPEPercentile:  90.0, // PEG及位階預設不檢查，啟用時90%以內為警示
+
+		ExcludeDebtRatio:     80.0, // 負債比超過80%直接排除
+		ExcludeRevenueGrowth: -20.0,
+		ExcludeYoYGrowth:     -30.0,
+		ExcludeEPSGrowth:     -50.0,
+
+		DiscountRate:      10.0,
+		TerminalGrowth:    2.0,
+		MinMarginOfSafety: 0.0, // 現價不高於合理價
+
+		MinOCFToNetIncome:      0.8, // 營業現金流至少為淨利的8成
+		MaxAccrualsRatio:       10.0,
+		MinFCFYield:            2.0,
+		MaxNegativeFCFQuarters: 2,
+
+		MinPiotroskiF: 3,
+		MinAltmanZ:    altmanZDistress,
+		MinAltmanZEM:  0,
+		MaxBeneishM:   beneishMThreshold,
+
+		Scoring:       DefaultScoring(),
+		RankWinsorize: defaultRankWinsorize,
+
+		ROEAboveIndustryMedian: false,
+
+		MaxBeta:     0, // 風險上限預設不檢查，strict 條件組合啟用
+		MaxDrawdown: 0,
+		MaxVaR95:    0,
+
+		MinTechnicalPassRatio: 0.5,
+		RegimeOverrides:       defaultRegimeOverrides(),
+
+		MinRSRating:             0,
+		MaxFromHigh52W:          0,
+		RequirePositiveMomentum: false,
 	}
 }
 
 // FetchFinancialData 從FinMind API取得真實財務資料
-func (s *StockScreener) FetchFinancialData(stockCode string) (*StockData, error) {
+func (s *StockScreener) FetchFinancialData(ctx context.Context, stockCode string) (*StockData, error) {
 	stock := &StockData{
-		Code: stockCode,
+		Code:     stockCode,
+		Industry: s.industryFor(ctx, stockCode),
 		// 設定預設值
 		ROE:           10.0, // 預設ROE 10%
 		RevenueGrowth: 3.0,  // 預設營收成長3%
 		DebtRatio:     35.0, // 預設負債比35%
 		DividendYears: 3,    // 預設配息3年
-		GrossMargin:   25.0, // 預設毛利率25%
 		YoYGrowth:     0.0,  // 將從API獲取
 		EPSGrowth:     0.0,  // 將從API獲取
 		EPS:           0.0,  // 將從API獲取
 	}
 
 	// 先嘗試使用 FinMind API 獲取財務數據
-	if err := s.fetchFromFinMind(stock); err != nil {
-		log.Printf("FinMind API 失敗，使用預設值: %v", err)
+	if err := s.fetchFromFinMind(ctx, stock); err != nil {
+		s.logger.Warn("FinMind API 失敗，改用TWSE", logKeyStock, stockCode, "error", err)
+		s.summary.markDegraded(stockCode, "FinMind財務資料失敗")
 		// 如果 FinMind API 失敗，使用原有的 TWSE API 作為後備
-		if err := s.fetchFromTWSE(stock); err != nil {
-			log.Printf("TWSE API 也失敗: %v", err)
+		if err := s.fetchFromTWSE(ctx, stock); err != nil {
+			s.logger.Warn("TWSE API 也失敗，使用預設值", logKeyStock, stockCode, "error", err)
+			s.summary.markDegraded(stockCode, "財務資料使用預設值")
 			// 使用預設值
+			stock.addROEStep(roeMethodDefault, "財務資料皆無法取得", nil)
 			stock.YoYGrowth = 15.0
 			stock.EPSGrowth = 50.0
 			stock.EPS = 2.0
@@ -118,46 +403,34 @@ func (s *StockScreener) FetchFinancialData(stockCode string) (*StockData, error)
 }
 
 // fetchFromFinMind 從FinMind API獲取財務數據
-func (s *StockScreener) fetchFromFinMind(stock *StockData) error {
-	// 獲取過去2年的財務數據用於計算年增率
-	startDate := time.Now().AddDate(-2, 0, 0).Format("2006-01-02")
-	finmindURL := fmt.Sprintf("https://api.finmindtrade.com/api/v4/data?dataset=TaiwanStockFinancialStatements&data_id=%s&start_date=%s",
-		stock.Code, startDate)
-
-	resp, err := s.client.Get(finmindURL)
+func (s *StockScreener) fetchFromFinMind(ctx context.Context, stock *StockData) error {
+	// 獲取過去3年的財務數據用於計算年增率及跨年度比較
+	now := taipeiNow()
+	statements, err := s.finmind.FinancialStatements(ctx, stock.Code, now.AddDate(-3, 0, 0), now)
 	if err != nil {
-		return fmt.Errorf("finMind API request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var response struct {
-		Data []struct {
-			Date       string  `json:"date"`
-			StockID    string  `json:"stock_id"`
-			Type       string  `json:"type"`
-			Value      float64 `json:"value"`
-			OriginName string  `json:"origin_name"`
-		} `json:"data"`
-		Msg string `json:"msg"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("failed to decode FinMind response: %v", err)
+		return err
 	}
 
 	// 解析財務數據 - 改為分季度儲存
 
 	var epsData []EPSData
 	var revenueData []EPSData
+	quarterlyEPS := make(map[string]float64)
+	quarterlyNetIncome := make(map[string]float64)
 
-	for _, item := range response.Data {
-		// 調試：顯示所有數據項目 (限制輸出)
-		if stock.Code == "2330" && (strings.Contains(item.Date, "2024") || strings.Contains(item.Date, "2025")) {
-			fmt.Printf("  調試 - 日期:%s, 類型:%s, 名稱:%s, 數值:%.2f\n",
-				item.Date, item.Type, item.OriginName, item.Value)
-		}
+	stock.statements = newStatementHistory()
+	for _, item := range statements {
+		s.logger.Debug("財報資料", logKeyStock, stock.Code,
+			"date", item.Date, "type", item.Type, "name", item.OriginName, "value", item.Value)
+		record(stock.statements.income, item.Date, item.Type, item.Value)
 
 		// 收集所有 EPS 數據
+		if item.Type == "EPS" {
+			quarterlyEPS[item.Date] = item.Value
+		}
+		if item.Type == "IncomeAfterTaxes" {
+			quarterlyNetIncome[item.Date] = item.Value
+		}
 		if item.Type == "EPS" || strings.Contains(item.OriginName, "每股盈餘") {
 			epsData = append(epsData, EPSData{
 				Date:  item.Date,
@@ -174,18 +447,26 @@ func (s *StockScreener) fetchFromFinMind(stock *StockData) error {
 		}
 	}
 
+	// 計算近四季毛利率、營業利益率及淨利率
+	calculateMargins(stock)
+
 	// 計算 EPS 和 EPS 增長率 - 使用同季度比較
 	latestEPS, latestEPSDate := s.getLatestQuarterEPS(epsData)
 	sameQuarterLastYearEPS := s.getSameQuarterLastYearEPS(epsData, latestEPSDate)
 
-	// 設置最新EPS
+	// 設置最新EPS及近四季EPS
 	stock.EPS = latestEPS
+	if ttm, ok := trailingFourQuarters(quarterlyEPS); ok {
+		stock.EPSTTM = ttm
+		stock.FCFPerShare = ttm // 現金流量表取得前，以近四季EPS近似每股自由現金流
+	}
+	stock.netIncomeTTM, _ = trailingFourQuarters(quarterlyNetIncome)
 
 	// 計算同季度EPS增長率
 	if sameQuarterLastYearEPS > 0 && latestEPS > 0 {
 		stock.EPSGrowth = ((latestEPS - sameQuarterLastYearEPS) / sameQuarterLastYearEPS) * 100
-		fmt.Printf("EPS計算: 最新季(%s)=%.2f vs 去年同季=%.2f, 增長=%.1f%%\n",
-			latestEPSDate, latestEPS, sameQuarterLastYearEPS, stock.EPSGrowth)
+		s.logger.Debug("EPS計算", logKeyStock, stock.Code, "date", latestEPSDate,
+			"eps", latestEPS, "last_year_eps", sameQuarterLastYearEPS, "growth", stock.EPSGrowth)
 	}
 
 	// 計算營收年增率 - 使用相同邏輯
@@ -198,17 +479,29 @@ func (s *StockScreener) fetchFromFinMind(stock *StockData) error {
 	}
 
 	// 嘗試從其他來源獲取 ROE
-	if err := s.fetchROEData(stock); err != nil {
-		fmt.Printf("ROE獲取失敗，使用預設值: %v\n", err)
+	if err := s.fetchROEData(ctx, stock); err != nil {
+		s.logger.Warn("ROE獲取失敗，使用預設值", logKeyStock, stock.Code, "error", err)
 	}
 
 	// 獲取負債比數據
-	if err := s.fetchDebtRatioData(stock); err != nil {
-		fmt.Printf("負債比獲取失敗，使用預設值: %v\n", err)
+	if err := s.fetchDebtRatioData(ctx, stock); err != nil {
+		s.logger.Warn("負債比獲取失敗，使用預設值", logKeyStock, stock.Code, "error", err)
+		s.summary.markDegraded(stock.Code, "負債比使用預設值")
+	}
+
+	// 獲取現金流量 (需在負債比之後，使用資產負債表的股數及總資產)
+	if err := s.fetchCashFlow(ctx, stock); err != nil {
+		s.logger.Warn("現金流量表獲取失敗", logKeyStock, stock.Code, "error", err)
+		s.summary.markDegraded(stock.Code, "現金流量表失敗，自由現金流以EPS近似")
 	}
 
-	fmt.Printf("股票 %s FinMind 數據: EPS=%.2f, EPS增長=%.1f%%, 年增率=%.1f%%, ROE=%.1f%%, 負債比=%.1f%%\n",
-		stock.Code, stock.EPS, stock.EPSGrowth, stock.YoYGrowth, stock.ROE, stock.DebtRatio)
+	// 獲取月營收年增率
+	if err := s.fetchMonthlyRevenue(ctx, stock); err != nil {
+		s.logger.Warn("月營收獲取失敗", logKeyStock, stock.Code, "error", err)
+	}
+
+	s.logger.Info("FinMind財務資料", logKeyStock, stock.Code, "eps", stock.EPS, "eps_growth", stock.EPSGrowth,
+		"yoy_growth", stock.YoYGrowth, "roe", stock.ROE, "debt_ratio", stock.DebtRatio)
 
 	return nil
 }
@@ -300,38 +593,45 @@ func (s *StockScreener) getSameQuarterLastYearRevenue(revenueData []EPSData, lat
 }
 
 // fetchROEData 從FinMind API計算精確的ROE數據
-func (s *StockScreener) fetchROEData(stock *StockData) error {
+func (s *StockScreener) fetchROEData(ctx context.Context, stock *StockData) error {
 	// 使用精確的ROE計算方法：ROE = 本期淨利 / 平均股東權益 * 100%
-	if err := s.calculatePreciseROE(stock); err == nil {
+	err := s.calculatePreciseROE(ctx, stock)
+	if err == nil {
 		return nil
 	}
+	stock.addROEStep(roeMethodPrecise, "", err)
 
 	// 備用方法1: 嘗試從TWSE獲取財務比率數據
-	if err := s.fetchROEFromTWSE(stock); err == nil {
+	err = s.fetchROEFromTWSE(ctx, stock)
+	if err == nil {
 		return nil
 	}
+	stock.addROEStep(roeMethodTWSE, "", err)
 
 	// 備用方法2: 使用 DuPont 分析法估算 ROE
-	if err := s.estimateROEFromDuPont(stock); err == nil {
+	err = s.estimateROEFromDuPont(stock)
+	if err == nil {
 		return nil
 	}
+	stock.addROEStep(roeMethodDuPont, "", err)
 
 	// 備用方法3: 使用行業平均值或經驗公式
 	s.estimateROEFromIndustry(stock)
+	s.summary.markDegraded(stock.Code, "ROE使用行業估算值")
 
 	return nil
 }
 
 // calculatePreciseROE 使用FinMind API精確計算ROE
-func (s *StockScreener) calculatePreciseROE(stock *StockData) error {
+func (s *StockScreener) calculatePreciseROE(ctx context.Context, stock *StockData) error {
 	// 步驟1: 獲取最新本期淨利（分子）
-	netIncome, incomeDate, err := s.fetchNetIncome(stock.Code)
+	netIncome, incomeDate, err := s.fetchNetIncome(ctx, stock.Code)
 	if err != nil {
 		return fmt.Errorf("無法獲取淨利數據: %v", err)
 	}
 
 	// 步驟2: 獲取股東權益數據（分母）
-	avgEquity, err := s.fetchAverageEquity(stock.Code, incomeDate)
+	avgEquity, err := s.fetchAverageEquity(ctx, stock.Code, incomeDate)
 	if err != nil {
 		return fmt.Errorf("無法獲取權益數據: %v", err)
 	}
@@ -341,11 +641,10 @@ func (s *StockScreener) calculatePreciseROE(stock *StockData) error {
 		roe := (netIncome / avgEquity) * 100
 		stock.ROE = roe
 
-		fmt.Printf("📊 精確ROE計算 [%s]:\n", stock.Code)
-		fmt.Printf("   本期淨利: %.0f 元 (日期: %s)\n", netIncome, incomeDate)
-		fmt.Printf("   平均股東權益: %.0f 元\n", avgEquity)
-		fmt.Printf("   ROE = %.0f / %.0f × 100%% = %.2f%%\n",
-			netIncome, avgEquity, roe)
+		s.logger.Debug("精確ROE計算", logKeyStock, stock.Code, "net_income", netIncome,
+			"income_date", incomeDate, "avg_equity", avgEquity, "roe", roe)
+		stock.addROEStep(roeMethodPrecise, fmt.Sprintf("本期淨利 %.0f (%s) / 平均股東權益 %.0f",
+			netIncome, incomeDate, avgEquity), nil)
 
 		return nil
 	}
@@ -354,48 +653,27 @@ func (s *StockScreener) calculatePreciseROE(stock *StockData) error {
 }
 
 // fetchNetIncome 從FinMind獲取最新本期淨利
-func (s *StockScreener) fetchNetIncome(stockCode string) (float64, string, error) {
+func (s *StockScreener) fetchNetIncome(ctx context.Context, stockCode string) (float64, string, error) {
 	// 獲取今年的財務數據
-	startDate := time.Now().Format("2006") + "-01-01"
-	url := fmt.Sprintf("https://api.finmindtrade.com/api/v4/data?dataset=TaiwanStockFinancialStatements&data_id=%s&start_date=%s",
-		stockCode, startDate)
-
-	resp, err := s.client.Get(url)
+	now := taipeiNow()
+	startDate := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, taipeiLocation)
+	statements, err := s.finmind.FinancialStatements(ctx, stockCode, startDate, now)
 	if err != nil {
-		return 0, "", fmt.Errorf("API請求失敗: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var response struct {
-		Data []struct {
-			Date       string  `json:"date"`
-			StockID    string  `json:"stock_id"`
-			Type       string  `json:"type"`
-			Value      float64 `json:"value"`
-			OriginName string  `json:"origin_name"`
-		} `json:"data"`
-		Msg string `json:"msg"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return 0, "", fmt.Errorf("解析API回應失敗: %v", err)
+		return 0, "", err
 	}
 
 	// 尋找本期淨利（IncomeAfterTaxes）
 	var latestNetIncome float64
 	var latestDate string
 
-	for _, item := range response.Data {
+	for _, item := range statements {
 		// 只尋找確切的 IncomeAfterTaxes 類型（稅後本期淨利）
 		if item.Type == "IncomeAfterTaxes" {
 			if item.Date > latestDate {
 				latestDate = item.Date
 				latestNetIncome = item.Value
-				// 調試：顯示找到的淨利數據
-				if stockCode == "2328" {
-					fmt.Printf("     找到淨利數據: %s, Type: %s, OriginName: %s, Value: %.0f\n",
-						item.Date, item.Type, item.OriginName, item.Value)
-				}
+				s.logger.Debug("找到淨利數據", logKeyStock, stockCode,
+					"date", item.Date, "name", item.OriginName, "value", item.Value)
 			}
 		}
 	}
@@ -408,7 +686,7 @@ func (s *StockScreener) fetchNetIncome(stockCode string) (float64, string, error
 }
 
 // fetchAverageEquity 獲取平均股東權益
-func (s *StockScreener) fetchAverageEquity(stockCode, incomeDate string) (float64, error) {
+func (s *StockScreener) fetchAverageEquity(ctx context.Context, stockCode, incomeDate string) (float64, error) {
 	// 解析收入日期，判斷需要的權益日期
 	incomeTime, err := time.Parse("2006-01-02", incomeDate)
 	if err != nil {
@@ -444,42 +722,20 @@ func (s *StockScreener) fetchAverageEquity(stockCode, incomeDate string) (float6
 	}
 
 	// 獲取資產負債表數據
-	startDate := fmt.Sprintf("%d-01-01", incomeTime.Year()-1) // 獲取前一年的數據以確保完整
-	url := fmt.Sprintf("https://api.finmindtrade.com/api/v4/data?dataset=TaiwanStockBalanceSheet&data_id=%s&start_date=%s",
-		stockCode, startDate)
-
-	resp, err := s.client.Get(url)
+	startDate := time.Date(incomeTime.Year()-1, time.January, 1, 0, 0, 0, 0, taipeiLocation) // 獲取前一年的數據以確保完整
+	balance, err := s.finmind.BalanceSheet(ctx, stockCode, startDate, taipeiNow())
 	if err != nil {
-		return 0, fmt.Errorf("資產負債表API請求失敗: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var response struct {
-		Data []struct {
-			Date       string  `json:"date"`
-			StockID    string  `json:"stock_id"`
-			Type       string  `json:"type"`
-			Value      float64 `json:"value"`
-			OriginName string  `json:"origin_name"`
-		} `json:"data"`
-		Msg string `json:"msg"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return 0, fmt.Errorf("解析資產負債表回應失敗: %v", err)
+		return 0, err
 	}
 
 	// 尋找權益總額數據
 	equityData := make(map[string]float64)
 
-	for _, item := range response.Data {
+	for _, item := range balance {
 		// 尋找權益總額（Equity）- 確保使用正確的絕對值，不是百分比
 		if item.Type == "Equity" && !strings.Contains(item.OriginName, "_per") {
 			equityData[item.Date] = item.Value
-			// 調試：顯示找到的權益數據 (針對2328)
-			if stockCode == "2328" {
-				fmt.Printf("     找到權益數據: %s = %.0f 元\n", item.Date, item.Value)
-			}
+			s.logger.Debug("找到權益數據", logKeyStock, stockCode, "date", item.Date, "value", item.Value)
 		}
 	}
 
@@ -487,9 +743,9 @@ func (s *StockScreener) fetchAverageEquity(stockCode, incomeDate string) (float6
 	currentEquity, currentExists := equityData[currentQuarterDate]
 	previousEquity, previousExists := equityData[previousQuarterDate]
 
-	fmt.Printf("   權益數據查找:\n")
-	fmt.Printf("     當季日期 (%s): %.0f 元 [%t]\n", currentQuarterDate, currentEquity, currentExists)
-	fmt.Printf("     前季日期 (%s): %.0f 元 [%t]\n", previousQuarterDate, previousEquity, previousExists)
+	s.logger.Debug("權益數據查找", logKeyStock, stockCode,
+		"current_date", currentQuarterDate, "current_equity", currentEquity, "current_found", currentExists,
+		"previous_date", previousQuarterDate, "previous_equity", previousEquity, "previous_found", previousExists)
 
 	// 如果找不到精確日期，嘗試找最近的日期
 	if !currentExists || !previousExists {
@@ -509,9 +765,9 @@ func (s *StockScreener) fetchAverageEquity(stockCode, incomeDate string) (float6
 			currentEquity = equityData[latest]
 			previousEquity = equityData[secondLatest]
 
-			fmt.Printf("   使用最近的權益數據:\n")
-			fmt.Printf("     最新日期 (%s): %.0f 元\n", latest, currentEquity)
-			fmt.Printf("     次新日期 (%s): %.0f 元\n", secondLatest, previousEquity)
+			s.logger.Debug("使用最近的權益數據", logKeyStock, stockCode,
+				"current_date", latest, "current_equity", currentEquity,
+				"previous_date", secondLatest, "previous_equity", previousEquity)
 		} else {
 			return 0, fmt.Errorf("權益數據不足，僅找到 %d 筆記錄", len(availableDates))
 		}
@@ -527,45 +783,22 @@ func (s *StockScreener) fetchAverageEquity(stockCode, incomeDate string) (float6
 }
 
 // fetchROEFromTWSE 從台灣證交所API嘗試獲取ROE相關數據
-func (s *StockScreener) fetchROEFromTWSE(stock *StockData) error {
-	// 使用個股日成交資訊API
-	url := fmt.Sprintf("https://www.twse.com.tw/exchangeReport/BWIBBU_d?response=json&date=%s&stockNo=%s",
-		time.Now().Format("20060102"), stock.Code)
-
-	resp, err := s.client.Get(url)
+func (s *StockScreener) fetchROEFromTWSE(ctx context.Context, stock *StockData) error {
+	// 使用個股本益比、淨值比資料，日期取最近一個已公布資料的交易日
+	ratios, err := s.fetchTWSERatios(ctx, stock.Code)
 	if err != nil {
-		return fmt.Errorf("TWSE API request failed: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
+	stock.PE, stock.PB, stock.DividendYield = ratios.PE, ratios.PB, ratios.DividendYield
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return fmt.Errorf("failed to decode TWSE response: %v", err)
-	}
-
-	// 解析財務比率數據
-	if fields, ok := data["data"].([]interface{}); ok && len(fields) > 0 {
-		if row, ok := fields[0].([]interface{}); ok && len(row) >= 6 {
-			// 嘗試解析本益比 (P/E ratio)
-			if peStr, ok := row[4].(string); ok && peStr != "-" && peStr != "" {
-				if pe, err := strconv.ParseFloat(strings.TrimSpace(peStr), 64); err == nil && pe > 0 {
-					// 嘗試解析股價淨值比 (P/B ratio)
-					if len(row) > 5 {
-						if pbStr, ok := row[5].(string); ok && pbStr != "-" && pbStr != "" {
-							if pb, err := strconv.ParseFloat(strings.TrimSpace(pbStr), 64); err == nil && pb > 0 {
-								// 使用 ROE = (P/E) / (P/B) 的關係式
-								// 或 ROE ≈ (1/PE) * (PB) 的近似公式
-								estimatedROE := (pb / pe) * 100
-								if estimatedROE > 0 && estimatedROE < 100 { // 合理性檢查
-									stock.ROE = estimatedROE
-									fmt.Printf("從TWSE估算ROE: PE=%.2f, PB=%.2f, ROE=%.2f%%\n", pe, pb, estimatedROE)
-									return nil
-								}
-							}
-						}
-					}
-				}
-			}
+	if ratios.PE > 0 && ratios.PB > 0 {
+		// 使用 ROE = (P/B) / (P/E) 的關係式
+		estimatedROE := (ratios.PB / ratios.PE) * 100
+		if estimatedROE > 0 && estimatedROE < 100 { // 合理性檢查
+			stock.ROE = estimatedROE
+			s.logger.Debug("從TWSE估算ROE", logKeyStock, stock.Code, "pe", ratios.PE, "pb", ratios.PB, "roe", estimatedROE)
+			stock.addROEStep(roeMethodTWSE, fmt.Sprintf("PB %.2f / PE %.2f", ratios.PB, ratios.PE), nil)
+			return nil
 		}
 	}
 
@@ -610,31 +843,20 @@ func (s *StockScreener) estimateROEFromDuPont(stock *StockData) error {
 	}
 
 	stock.ROE = estimatedROE
-	fmt.Printf("DuPont估算ROE: 基於EPS=%.2f, YoY=%.1f%%, 估算ROE=%.2f%%\n",
-		stock.EPS, stock.YoYGrowth, estimatedROE)
+	s.logger.Debug("DuPont估算ROE", logKeyStock, stock.Code,
+		"eps", stock.EPS, "yoy_growth", stock.YoYGrowth, "roe", estimatedROE)
+	stock.addROEStep(roeMethodDuPont, fmt.Sprintf("EPS %.2f, 年增率 %.1f%%", stock.EPS, stock.YoYGrowth), nil)
 
 	return nil
 }
 
 // estimateROEFromIndustry 根據行業特性估算ROE
 func (s *StockScreener) estimateROEFromIndustry(stock *StockData) {
-	// 根據股票代碼判斷行業類型，設定合理的ROE預期
+	// 依證交所產業別設定合理的ROE預期
 	code := stock.Code
-	var industryROE float64
-
-	switch {
-	case code >= "2300" && code <= "2399": // 電子業
-		industryROE = 12.0
-	case code >= "2400" && code <= "2499": // 半導體
-		industryROE = 15.0
-	case code >= "2800" && code <= "2899": // 金融業
-		industryROE = 8.0
-	case code >= "2600" && code <= "2699": // 航運業
-		industryROE = 6.0
-	case code >= "1200" && code <= "1299": // 食品業
-		industryROE = 10.0
-	default:
-		industryROE = 10.0 // 預設值
+	industryROE, ok := industryBaselineROE[stock.Industry]
+	if !ok {
+		industryROE = defaultBaselineROE
 	}
 
 	// 根據公司表現調整
@@ -651,57 +873,35 @@ func (s *StockScreener) estimateROEFromIndustry(stock *StockData) {
 	}
 
 	stock.ROE = industryROE
-	fmt.Printf("行業估算ROE: 股票%s, 行業基準=%.1f%%, 調整後=%.2f%%\n",
-		code, industryROE/1.3, stock.ROE)
+	s.logger.Debug("行業估算ROE", logKeyStock, code, "industry", stock.Industry, "eps_growth", stock.EPSGrowth, "roe", stock.ROE)
+	stock.addROEStep(roeMethodIndustry, fmt.Sprintf("%s, EPS增長 %.1f%%", industryLabel(stock.Industry), stock.EPSGrowth), nil)
 }
 
 // fetchDebtRatioData 從FinMind API獲取負債比數據
-func (s *StockScreener) fetchDebtRatioData(stock *StockData) error {
+func (s *StockScreener) fetchDebtRatioData(ctx context.Context, stock *StockData) error {
 	// 使用FinMind資產負債表API
-	startDate := time.Now().AddDate(-1, 0, 0).Format("2006-01-02") // 獲取過去1年數據
-	balanceSheetURL := fmt.Sprintf("https://api.finmindtrade.com/api/v4/data?dataset=TaiwanStockBalanceSheet&data_id=%s&start_date=%s",
-		stock.Code, startDate)
-
-	resp, err := s.client.Get(balanceSheetURL)
+	now := taipeiNow()
+	balance, err := s.finmind.BalanceSheet(ctx, stock.Code, now.AddDate(-2, 0, 0), now) // 獲取過去2年數據 (財務評分需一年前同季)
 	if err != nil {
-		return fmt.Errorf("FinMind Balance Sheet API request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	var response struct {
-		Data []struct {
-			Date       string  `json:"date"`
-			StockID    string  `json:"stock_id"`
-			Type       string  `json:"type"`
-			Value      float64 `json:"value"`
-			OriginName string  `json:"origin_name"`
-		} `json:"data"`
-		Msg string `json:"msg"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("failed to decode FinMind Balance Sheet response: %v", err)
+		return err
 	}
 
 	// 尋找最新的總資產和總負債數據
 	var latestTotalAssets, latestTotalLiabilities float64
 	var latestDate string
 
-	// 調試：關閉詳細日誌
-	// if stock.Code == "2330" {
-	//     fmt.Printf("資產負債表調試:\n")
-	//     ...
-	// }
-
 	// 收集所有相關數據
 	dataMap := make(map[string]map[string]float64)
 
-	for _, item := range response.Data {
+	for _, item := range balance {
 		if dataMap[item.Date] == nil {
 			dataMap[item.Date] = make(map[string]float64)
 		}
 		dataMap[item.Date][item.Type] = item.Value
 	}
+	if stock.statements != nil {
+		stock.statements.balance = dataMap
+	}
 
 	// 找到最新日期
 	for date := range dataMap {
@@ -712,6 +912,10 @@ func (s *StockScreener) fetchDebtRatioData(stock *StockData) error {
 
 	// 獲取最新日期的資產負債數據
 	if latestData, ok := dataMap[latestDate]; ok {
+		stock.BookValuePerShare = bookValuePerShare(latestData)
+		stock.shares = sharesOutstanding(latestData)
+		stock.totalAssets = latestData["TotalAssets"]
+
 		// 尋找總資產
 		for key, value := range latestData {
 			if key == "TotalAssets" || strings.Contains(key, "Asset") {
@@ -723,7 +927,7 @@ func (s *StockScreener) fetchDebtRatioData(stock *StockData) error {
 		// 優先使用已計算好的負債比百分比
 		if liabilitiesPer, exists := latestData["Liabilities_per"]; exists {
 			stock.DebtRatio = liabilitiesPer
-			fmt.Printf("直接使用負債比: 日期=%s, 負債比=%.2f%%\n", latestDate, liabilitiesPer)
+			s.logger.Debug("直接使用負債比", logKeyStock, stock.Code, "date", latestDate, "debt_ratio", liabilitiesPer)
 			return nil
 		}
 
@@ -740,8 +944,8 @@ func (s *StockScreener) fetchDebtRatioData(stock *StockData) error {
 		// 合理性檢查 (負債比應該在0-100%之間)
 		if debtRatio >= 0 && debtRatio <= 100 {
 			stock.DebtRatio = debtRatio
-			fmt.Printf("負債比計算: 日期=%s, 總資產=%.0f, 總負債=%.0f, 負債比=%.2f%%\n",
-				latestDate, latestTotalAssets, latestTotalLiabilities, debtRatio)
+			s.logger.Debug("負債比計算", logKeyStock, stock.Code, "date", latestDate,
+				"total_assets", latestTotalAssets, "total_liabilities", latestTotalLiabilities, "debt_ratio", debtRatio)
 			return nil
 		}
 	}
@@ -750,186 +954,146 @@ func (s *StockScreener) fetchDebtRatioData(stock *StockData) error {
 		latestTotalAssets, latestTotalLiabilities)
 }
 
-// fetchFromTWSE 從TWSE API獲取基本數據作為後備
-func (s *StockScreener) fetchFromTWSE(stock *StockData) error {
-	fundamentalURL := fmt.Sprintf("https://www.twse.com.tw/exchangeReport/BWIBBU_d?response=json&date=%s&stockNo=%s",
-		time.Now().Format("20060102"), stock.Code)
-
-	resp, err := s.client.Get(fundamentalURL)
+// fetchMonthlyRevenue 從FinMind API獲取最新月營收並計算年增率
+func (s *StockScreener) fetchMonthlyRevenue(ctx context.Context, stock *StockData) error {
+	now := taipeiNow()
+	monthly, err := s.finmind.MonthRevenue(ctx, stock.Code, now.AddDate(-2, 0, 0), now)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
-	}
 
-	// 解析財務指標
-	if fields, ok := data["data"].([]interface{}); ok && len(fields) > 0 {
-		if row, ok := fields[0].([]interface{}); ok && len(row) >= 5 {
-			// 解析本益比、淨值比、殖利率等
-			if pe, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprintf("%v", row[4])), 64); err == nil {
-				stock.ROE = s.estimateROE(pe) // 簡化計算
-			}
+	// 以營收所屬年月建立索引
+	revenues := make(map[string]float64)
+	latestMonth := ""
+	for _, item := range monthly {
+		month := fmt.Sprintf("%d-%02d", item.RevenueYear, item.RevenueMonth)
+		revenues[month] = item.Revenue
+		if month > latestMonth {
+			latestMonth = month
 		}
 	}
 
-	return nil
-}
+	if latestMonth == "" {
+		return fmt.Errorf("未找到月營收數據")
+	}
 
-// FetchTechnicalData 取得技術面資料
-func (s *StockScreener) FetchTechnicalData(stock *StockData) error {
-	// 構建正確的 Yahoo Finance 股票代碼
-	symbol := s.buildYahooSymbol(stock.Code)
-	// 使用Yahoo Finance API取得技術指標
-	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?interval=1d&range=3mo", symbol)
-
-	// 建立請求並添加必要的 headers
-	req, err := http.NewRequest("GET", url, nil)
+	t, err := time.Parse("2006-01", latestMonth)
 	if err != nil {
 		return err
 	}
+	lastYear, ok := revenues[t.AddDate(-1, 0, 0).Format("2006-01")]
+	if !ok || lastYear <= 0 {
+		return fmt.Errorf("缺少去年同月營收: %s", latestMonth)
+	}
+
+	stock.MonthlyRevenueYoY = (revenues[latestMonth] - lastYear) / lastYear * 100
+	stock.MonthlyRevenueMonth = latestMonth
+	s.logger.Debug("月營收", logKeyStock, stock.Code, "month", latestMonth, "yoy", stock.MonthlyRevenueYoY)
 
-	// 添加 User-Agent 和其他 headers 來模擬瀏覽器請求
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Language", "zh-TW,zh;q=0.9,en;q=0.8")
+	return nil
+}
 
-	resp, err := s.client.Do(req)
+// fetchFromTWSE 從TWSE API獲取基本數據作為後備
+func (s *StockScreener) fetchFromTWSE(ctx context.Context, stock *StockData) error {
+	ratios, err := s.fetchTWSERatios(ctx, stock.Code)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	stock.PE, stock.PB, stock.DividendYield = ratios.PE, ratios.PB, ratios.DividendYield
 
-	// 檢查 HTTP 狀態碼
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("Yahoo Finance API 返回錯誤狀態碼: %d", resp.StatusCode)
+	// 以本益比估算ROE
+	if ratios.PE > 0 {
+		stock.ROE = s.estimateROE(ratios.PE) // 簡化計算
+		stock.addROEStep(roeMethodTWSEPE, fmt.Sprintf("PE %.2f", ratios.PE), nil)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return nil
+}
+
+// get 送出可取消的GET請求
+func (s *StockScreener) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.client.Do(req)
+}
 
-	// 可選：調試模式下打印響應內容
-	// bodyStr := string(body)
-	// if len(bodyStr) > 200 {
-	// 	log.Printf("股票 %s API 響應前200字符: %s", stock.Code, bodyStr[:200])
-	// } else {
-	// 	log.Printf("股票 %s API 完整響應: %s", stock.Code, bodyStr)
-	// }
-
-	// 解析技術指標
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		maxLen := len(body)
-		if maxLen > 500 {
-			maxLen = 500
-		}
-		return fmt.Errorf("JSON 解析錯誤: %v, 響應內容: %s", err, string(body[:maxLen]))
+// latestTradingDate 取得最近一個已公布盤後資料的交易日 (格式 20060102)
+// 交易日曆未涵蓋今年時先取得證交所休市日程，仍無法涵蓋時改為僅排除週末
+func (s *StockScreener) latestTradingDate(ctx context.Context) (string, error) {
+	s.loadHolidaySchedule(ctx)
+	day, err := s.calendar.LatestTradingDay(taipeiNow())
+	if err != nil {
+		return "", err
 	}
+	return day.Format("20060102"), nil
+}
 
-	// 檢查 Yahoo Finance API 是否返回錯誤
-	if chart, ok := data["chart"].(map[string]interface{}); ok {
-		if errorMsg, ok := chart["error"].(map[string]interface{}); ok {
-			if description, ok := errorMsg["description"].(string); ok {
-				return fmt.Errorf("Yahoo Finance API 錯誤: %s", description)
-			}
-		}
+// FetchTechnicalData 取得技術面資料
+func (s *StockScreener) FetchTechnicalData(ctx context.Context, stock *StockData) error {
+	chart, err := s.fetchChart(ctx, stock.Code)
+	if err != nil {
+		return err
 	}
 
-	// 計算移動平均線和KD值
-	if chart, ok := data["chart"].(map[string]interface{}); ok {
-		if result, ok := chart["result"].([]interface{}); ok && len(result) > 0 {
-			resultData := result[0].(map[string]interface{})
+	stock.Price = chart.Price
+	stock.PriceSource = chart.Source
+	stock.bars = chart.Bars
 
-			// 取得股票基本資訊
-			if meta, ok := resultData["meta"].(map[string]interface{}); ok {
-				if currentPrice, ok := meta["regularMarketPrice"].(float64); ok {
-					stock.Price = currentPrice
-				}
-			}
-
-			// 取得OHLC資料
-			if indicators, ok := resultData["indicators"].(map[string]interface{}); ok {
-				if quote, ok := indicators["quote"].([]interface{}); ok && len(quote) > 0 {
-					quoteData := quote[0].(map[string]interface{})
-
-					// 取得收盤價、最高價、最低價資料
-					var closes, highs, lows []float64
-
-					if closesRaw, ok := quoteData["close"].([]interface{}); ok {
-						for _, c := range closesRaw {
-							if price, ok := c.(float64); ok && price > 0 {
-								closes = append(closes, price)
-							}
-						}
-					}
-
-					if highsRaw, ok := quoteData["high"].([]interface{}); ok {
-						for _, h := range highsRaw {
-							if price, ok := h.(float64); ok && price > 0 {
-								highs = append(highs, price)
-							}
-						}
-					}
-
-					if lowsRaw, ok := quoteData["low"].([]interface{}); ok {
-						for _, l := range lowsRaw {
-							if price, ok := l.(float64); ok && price > 0 {
-								lows = append(lows, price)
-							}
-						}
-					}
-
-					// 計算技術指標並存入stock結構
-					s.calculateTechnicalIndicators(stock, closes, highs, lows)
-				}
-			}
-		}
+	// 除權息、減資造成的跳空會扭曲技術指標，依設定使用還原權息價格
+	if s.chart.Adjusted {
+		s.adjustPrices(ctx, stock)
 	}
 
+	// 計算技術指標並存入stock結構
+	s.calculateTechnicalIndicators(stock, s.indicatorBars(stock))
+	s.calculateRisk(ctx, stock)
+	s.calculateRelativeStrength(ctx, stock)
+	s.calculateMomentum(stock)
 	return nil
 }
 
 // calculateTechnicalIndicators 計算技術指標
-func (s *StockScreener) calculateTechnicalIndicators(stock *StockData, closes, highs, lows []float64) {
-	if len(closes) < 60 || len(highs) < 60 || len(lows) < 60 {
+func (s *StockScreener) calculateTechnicalIndicators(stock *StockData, bars []Bar) {
+	if len(bars) < 60 {
 		return
 	}
 
-	// 確保三個陣列長度一致
-	minLen := len(closes)
-	if len(highs) < minLen {
-		minLen = len(highs)
-	}
-	if len(lows) < minLen {
-		minLen = len(lows)
+	// 各序列取自同一組K棒，長度一致
+	closes := make([]float64, len(bars))
+	highs := make([]float64, len(bars))
+	lows := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.Close
+		highs[i] = bar.High
+		lows[i] = bar.Low
 	}
 
 	// 計算60日移動平均線
-	if minLen >= 60 {
-		var sum float64
-		for i := minLen - 60; i < minLen; i++ {
-			sum += closes[i]
-		}
-		stock.MA60 = sum / 60
+	var sum float64
+	for _, c := range closes[len(closes)-60:] {
+		sum += c
 	}
+	stock.MA60 = sum / 60
 
 	// 計算KD指標
-	if minLen >= 9 {
-		kd := s.calculateKDIndicator(closes, highs, lows)
-		stock.KValue = kd.K
-		stock.DValue = kd.D
-	}
+	kd := s.calculateKDIndicator(closes, highs, lows)
+	stock.KValue = kd.K
+	stock.DValue = kd.D
+
+	// 計算RSI指標
+	stock.RSI = s.calculateRSI(closes, 14)
 
-	// 計算平均成交量 (如果需要的話，這裡暫時設為0)
-	stock.AvgVolume = 0
+	// 保留計算用的收盤價序列並計算年化波動率
+	stock.closes = closes
+	stock.Volatility = CalculateVolatility(closes)
 
-	fmt.Printf("股票 %s - 現價: %.2f, MA60: %.2f, K: %.2f, D: %.2f\n",
-		stock.Code, stock.Price, stock.MA60, stock.KValue, stock.DValue)
+	// 計算平均成交量
+	stock.AvgVolume = averageVolume(bars, avgVolumeBars)
+
+	s.logger.Info("技術指標", logKeyStock, stock.Code, "price", stock.Price, "ma60", stock.MA60,
+		"k", stock.KValue, "d", stock.DValue, "rsi", stock.RSI)
 }
 
 // KDResult KD指標結果
@@ -993,6 +1157,45 @@ func (s *StockScreener) calculateKDIndicator(closes, highs, lows []float64) KDRe
 	return KDResult{K: k, D: d}
 }
 
+// calculateRSI 計算RSI指標 (Wilder平滑法)
+func (s *StockScreener) calculateRSI(closes []float64, period int) float64 {
+	if len(closes) <= period {
+		return 0
+	}
+
+	// 以前period日的平均漲跌幅作為初始值
+	var avgGain, avgLoss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		if change > 0 {
+			avgGain += change
+		} else {
+			avgLoss -= change
+		}
+	}
+	avgGain /= float64(period)
+	avgLoss /= float64(period)
+
+	// 之後以 Wilder 平滑更新
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		gain, loss := 0.0, 0.0
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+	}
+
+	if avgLoss == 0 {
+		return 100
+	}
+	rs := avgGain / avgLoss
+	return 100 - 100/(1+rs)
+}
+
 // estimateROE 簡化的ROE估算
 func (s *StockScreener) estimateROE(pe float64) float64 {
 	// 這是簡化的估算，實際應該從財報取得
@@ -1005,9 +1208,9 @@ func (s *StockScreener) estimateROE(pe float64) float64 {
 }
 
 // FetchStockList 取得股票清單
-func (s *StockScreener) FetchStockList() ([]string, error) {
+func (s *StockScreener) FetchStockList(ctx context.Context) ([]string, error) {
 	// 取得上市股票代碼
-	resp, err := s.client.Get("https://www.twse.com.tw/zh/api/codeQuery")
+	resp, err := s.get(ctx, "https://www.twse.com.tw/zh/api/codeQuery")
 	if err != nil {
 		return nil, err
 	}
@@ -1044,337 +1247,491 @@ func (s *StockScreener) FetchStockList() ([]string, error) {
 	return stockList, nil
 }
 
+// FetchListedStocks 從證交所OpenAPI取得所有上市股票代碼及名稱
+func (s *StockScreener) FetchListedStocks(ctx context.Context) ([]string, error) {
+	resp, err := s.get(ctx, "https://openapi.twse.com.tw/v1/exchangeReport/STOCK_DAY_ALL")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("TWSE OpenAPI 返回錯誤狀態碼: %d", resp.StatusCode)
+	}
+
+	var rows []struct {
+		Code string `json:"Code"`
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode TWSE OpenAPI response: %v", err)
+	}
+
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+
+	var codes []string
+	for _, row := range rows {
+		// 只保留4碼的普通股及ETF，排除權證等商品
+		if len(row.Code) != 4 {
+			continue
+		}
+		codes = append(codes, row.Code)
+		s.names[row.Code] = strings.TrimSpace(row.Name)
+	}
+
+	return codes, nil
+}
+
 // ScreenStocks 篩選股票
-func (s *StockScreener) ScreenStocks(stocks []string) ([]*StockData, error) {
+func (s *StockScreener) ScreenStocks(ctx context.Context, stocks []string) ([]*StockData, error) {
 	var qualifiedStocks []*StockData
+	var universe []*StockData // 取得資料的所有股票，作為同業比較、橫向排名及評分正規化的比較基準
 
-	for _, code := range stocks {
-		fmt.Printf("正在分析股票: %s\n", code)
+	for i, code := range stocks {
+		// 中斷或逾時時停止分析，保留已完成的結果
+		if ctx.Err() != nil {
+			s.summary.markInterrupted(ctx.Err(), i, len(stocks))
+			break
+		}
 
-		// 取得財務資料
-		stock, err := s.FetchFinancialData(code)
+		s.logger.Info("正在分析股票", logKeyStock, code)
+
+		// 取得財務及技術面資料
+		stock, fetched, err := s.LoadStock(ctx, code)
 		if err != nil {
-			log.Printf("無法取得 %s 的財務資料: %v\n", code, err)
+			s.logger.Error("無法取得資料", logKeyStock, code, "error", err)
 			continue
 		}
+		universe = append(universe, stock)
 
-		// 取得技術面資料
-		if err := s.FetchTechnicalData(stock); err != nil {
-			log.Printf("無法取得 %s 的技術資料: %v\n", code, err)
-			continue
+		// 評估自選股警示規則
+		if s.alerts != nil {
+			for _, event := range s.alerts.Evaluate(stock) {
+				s.logger.Info("警示觸發", logKeyStock, event.Code, "message", event.Message)
+				s.alertEvents = append(s.alertEvents, event)
+			}
 		}
 
-		// 檢查是否符合篩選條件
+		// 避免請求過於頻繁
+		if fetched {
+			select {
+			case <-time.After(1 * time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	// 市場狀態、同業比較、相對強弱評等及橫向排名需要完整的股票池，取得所有資料後再篩選
+	s.detectRegime(ctx, universe)
+	s.buildPeerGroups(universe)
+	s.rateRelativeStrength(universe)
+	s.rankUniverse(universe)
+	for _, stock := range universe {
 		if s.meetsScreeningCriteria(stock) {
-			s.calculateScore(stock)
 			qualifiedStocks = append(qualifiedStocks, stock)
 		}
-
-		// 避免請求過於頻繁
-		time.Sleep(1 * time.Second)
 	}
 
-	// 根據分數排序
+	// 評分並根據分數排序
+	s.scoreStocks(qualifiedStocks, universe)
 	sort.Slice(qualifiedStocks, func(i, j int) bool {
 		return qualifiedStocks[i].Score > qualifiedStocks[j].Score
 	})
 
-	return qualifiedStocks, nil
-}
-
-// meetsScreeningCriteria 檢查是否符合篩選條件 (分段篩選)
-func (s *StockScreener) meetsScreeningCriteria(stock *StockData) bool {
-	fmt.Printf("\n🔍 開始篩選股票: %s (%s)\n", stock.Code, stock.Name)
-
-	// 第一階段：基本財務健康度檢查 (必須條件)
-	stage1Passed, stage1Reasons := s.checkStage1Fundamentals(stock)
+	// 儲存警示評估狀態
+	if s.alerts != nil {
+		if err := s.alerts.Save(); err != nil {
+			s.logger.Warn("無法儲存警示狀態", "error", err)
+		}
+	}
 
-	if !stage1Passed {
-		fmt.Printf("❌ %s 第一階段未通過: %s\n", stock.Code, strings.Join(stage1Reasons, ", "))
-		return false
+	// 中斷時結果不完整，不發送通知
+	if err := ctx.Err(); err != nil {
+		return qualifiedStocks, err
 	}
 
-	fmt.Printf("✅ %s 通過第一階段 (基本財務健康度)\n", stock.Code)
+	// 發送篩選結果通知
+	s.notifyResults(qualifiedStocks)
 
-	// 第二階段：投資品質評估 (優先條件)
-	stage2Passed, stage2Reasons := s.checkStage2Quality(stock)
+	return qualifiedStocks, nil
+}
 
-	if !stage2Passed {
-		fmt.Printf("⚠️  %s 第二階段未完全通過: %s\n", stock.Code, strings.Join(stage2Reasons, ", "))
-		fmt.Printf("   但仍可列入候選清單\n")
-	} else {
-		fmt.Printf("✅ %s 通過第二階段 (投資品質)\n", stock.Code)
+// FetchPrices 取得股票最新價格
+func (s *StockScreener) FetchPrices(ctx context.Context, codes []string) map[string]float64 {
+	prices := make(map[string]float64, len(codes))
+	for _, code := range codes {
+		stock := &StockData{Code: code}
+		if err := s.FetchTechnicalData(ctx, stock); err != nil {
+			s.logger.Warn("無法取得價格", logKeyStock, code, "error", err)
+			continue
+		}
+		prices[code] = stock.Price
 	}
+	return prices
+}
 
-	// 第三階段：技術面時機判斷 (參考條件)
-	stage3Passed, stage3Reasons := s.checkStage3Technical(stock)
-
-	if !stage3Passed {
-		fmt.Printf("⚠️  %s 技術面時機: %s\n", stock.Code, strings.Join(stage3Reasons, ", "))
-	} else {
-		fmt.Printf("✅ %s 技術面時機良好\n", stock.Code)
+// WatchlistCodes 取得警示規則中的自選股代碼
+func (s *StockScreener) WatchlistCodes() []string {
+	if s.alerts == nil {
+		return nil
 	}
-
-	// 只要通過第一階段就納入候選
-	fmt.Printf("📈 %s 綜合評估: 納入候選清單\n", stock.Code)
-	return stage1Passed
+	return s.alerts.Codes()
 }
 
-// checkStage1Fundamentals 第一階段：基本財務健康度檢查
-func (s *StockScreener) checkStage1Fundamentals(stock *StockData) (bool, []string) {
-	reasons := []string{}
-
-	fmt.Printf("   📊 財務健康度檢查:\n")
-
-	// 極端負面條件 (絕對排除)
-	if stock.ROE <= 0 {
-		reasons = append(reasons, "ROE為負數或零")
+// LoadStock 取得個股財務及技術面資料，當日已有快取時直接使用
+// fetched 表示資料是否來自API (而非快取)
+func (s *StockScreener) LoadStock(ctx context.Context, code string) (stock *StockData, fetched bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
 	}
-	if stock.DebtRatio >= 80.0 {
-		reasons = append(reasons, fmt.Sprintf("負債比過高 %.1f%% (>80%%)", stock.DebtRatio))
+	s.summary.Attempted++
+	tradingDay, err := s.latestTradingDate(ctx)
+	if err != nil {
+		s.summary.markFailed(code, err)
+		return nil, false, err
+	}
+
+	if !s.refresh {
+		if cached, ok := s.cache.Load(code, tradingDay, s.chart); ok {
+			if cached.Industry == "" {
+				cached.Industry = s.industryFor(ctx, code)
+			}
+			s.calculateIntrinsicValue(cached)
+			s.summary.Succeeded++
+			return cached, false, nil
+		}
 	}
-	if stock.RevenueGrowth <= -20.0 {
-		reasons = append(reasons, fmt.Sprintf("營收大幅衰退 %.1f%% (<-20%%)", stock.RevenueGrowth))
+
+	// 取得財務資料
+	stock, err = s.FetchFinancialData(ctx, code)
+	if err != nil {
+		err = fmt.Errorf("財務資料: %v", err)
+		s.summary.markFailed(code, err)
+		return nil, true, err
 	}
-	if stock.YoYGrowth <= -30.0 {
-		reasons = append(reasons, fmt.Sprintf("年增率大幅衰退 %.1f%% (<-30%%)", stock.YoYGrowth))
+
+	// 取得估值資料
+	if err := s.fetchValuation(ctx, stock); err != nil {
+		s.logger.Warn("無法取得估值資料", logKeyStock, code, "error", err)
+		s.summary.markDegraded(code, "估值資料失敗")
 	}
-	if stock.EPSGrowth <= -50.0 {
-		reasons = append(reasons, fmt.Sprintf("EPS大幅衰退 %.1f%% (<-50%%)", stock.EPSGrowth))
+
+	// 財務評分 (Altman Z 以股價淨值比換算權益市值)
+	s.calculateFinancialScores(stock)
+
+	// 取得技術面資料
+	if err := s.FetchTechnicalData(ctx, stock); err != nil {
+		err = fmt.Errorf("技術資料: %v", err)
+		s.summary.markFailed(code, err)
+		return nil, true, err
 	}
-	if stock.EPS <= 0 {
-		reasons = append(reasons, "EPS為負數或零")
+
+	if stock.Name == "" {
+		stock.Name = s.names[code]
 	}
+	s.calculateIntrinsicValue(stock)
 
-	// 顯示數值
-	fmt.Printf("      ROE: %.1f%% %s\n", stock.ROE, s.getStatusIcon(stock.ROE > 0))
-	fmt.Printf("      負債比: %.1f%% %s\n", stock.DebtRatio, s.getStatusIcon(stock.DebtRatio < 80.0))
-	fmt.Printf("      營收成長: %.1f%% %s\n", stock.RevenueGrowth, s.getStatusIcon(stock.RevenueGrowth > -20.0))
-	fmt.Printf("      年增率: %.1f%% %s\n", stock.YoYGrowth, s.getStatusIcon(stock.YoYGrowth > -30.0))
-	fmt.Printf("      EPS增長: %.1f%% %s\n", stock.EPSGrowth, s.getStatusIcon(stock.EPSGrowth > -50.0))
-	fmt.Printf("      EPS: %.2f %s\n", stock.EPS, s.getStatusIcon(stock.EPS > 0))
+	s.summary.Succeeded++
+	if _, degraded := s.summary.Degraded[code]; !degraded {
+		if err := s.cache.Store(stock, tradingDay, s.chart); err != nil {
+			s.logger.Warn("無法寫入快取", logKeyStock, code, "error", err)
+		}
+	}
 
-	return len(reasons) == 0, reasons
+	return stock, true, nil
 }
 
-// checkStage2Quality 第二階段：投資品質評估
-func (s *StockScreener) checkStage2Quality(stock *StockData) (bool, []string) {
-	reasons := []string{}
-	passCount := 0
-	totalChecks := 7
-
-	fmt.Printf("   💎 投資品質評估:\n")
+// AddNotifier 新增篩選結果通知
+func (s *StockScreener) AddNotifier(n Notifier) {
+	s.notifiers = append(s.notifiers, n)
+}
 
-	// ROE品質檢查
-	if stock.ROE >= 15.0 {
-		fmt.Printf("      ROE: %.1f%% ✅ (優秀)\n", stock.ROE)
-		passCount++
-	} else if stock.ROE >= 10.0 {
-		fmt.Printf("      ROE: %.1f%% 🟡 (良好)\n", stock.ROE)
-		passCount++
-	} else {
-		fmt.Printf("      ROE: %.1f%% ❌ (偏低)\n", stock.ROE)
-		reasons = append(reasons, fmt.Sprintf("ROE偏低 %.1f%%", stock.ROE))
+// notifyResults 將篩選摘要送至所有通知
+func (s *StockScreener) notifyResults(stocks []*StockData) {
+	if len(s.notifiers) == 0 {
+		return
 	}
 
-	// 營收成長檢查
-	if stock.RevenueGrowth >= 10.0 {
-		fmt.Printf("      營收成長: %.1f%% ✅ (高成長)\n", stock.RevenueGrowth)
-		passCount++
-	} else if stock.RevenueGrowth >= 0 {
-		fmt.Printf("      營收成長: %.1f%% 🟡 (穩定)\n", stock.RevenueGrowth)
-		passCount++
-	} else {
-		fmt.Printf("      營收成長: %.1f%% ❌ (衰退)\n", stock.RevenueGrowth)
-		reasons = append(reasons, fmt.Sprintf("營收衰退 %.1f%%", stock.RevenueGrowth))
+	previous, err := loadPreviousQualifiers()
+	if err != nil {
+		s.logger.Warn("無法讀取前次篩選結果，全部視為新進榜", "error", err)
 	}
 
-	// 年增率檢查 (新增)
-	if stock.YoYGrowth >= s.criteria.MinYoYGrowth {
-		fmt.Printf("      年增率: %.1f%% ✅ (達標)\n", stock.YoYGrowth)
-		passCount++
-	} else if stock.YoYGrowth >= 0 {
-		fmt.Printf("      年增率: %.1f%% 🟡 (正成長)\n", stock.YoYGrowth)
-		passCount++
-	} else {
-		fmt.Printf("      年增率: %.1f%% ❌ (負成長)\n", stock.YoYGrowth)
-		reasons = append(reasons, fmt.Sprintf("年增率不足 %.1f%%", stock.YoYGrowth))
+	digest := BuildDigest(stocks, previous, s.notifyTopN)
+	digest.Alerts = s.alertEvents
+	for _, n := range s.notifiers {
+		if err := n.Notify(digest); err != nil {
+			s.logger.Warn("通知發送失敗", "notifier", n.Name(), "error", err)
+		}
 	}
+}
 
-	// EPS增長檢查 (新增)
-	if stock.EPSGrowth >= s.criteria.MinEPSGrowth {
-		fmt.Printf("      EPS增長: %.1f%% ✅ (三位數增長)\n", stock.EPSGrowth)
-		passCount++
-	} else if stock.EPSGrowth >= 50.0 {
-		fmt.Printf("      EPS增長: %.1f%% 🟡 (高成長)\n", stock.EPSGrowth)
-		passCount++
-	} else {
-		fmt.Printf("      EPS增長: %.1f%% ❌ (增長不足)\n", stock.EPSGrowth)
-		reasons = append(reasons, fmt.Sprintf("EPS增長不足 %.1f%%", stock.EPSGrowth))
-	}
+// meetsScreeningCriteria 檢查是否符合篩選條件 (分段篩選)
+// 排除條件階段 (Required) 任一未通過即不納入候選；其餘階段未通過仍可列入候選清單
+func (s *StockScreener) meetsScreeningCriteria(stock *StockData) bool {
+	s.logger.Debug("開始篩選股票", logKeyStock, stock.Code, "name", stock.Name)
 
-	// EPS絕對值檢查 (新增)
-	if stock.EPS >= s.criteria.MinEPS {
-		fmt.Printf("      EPS: %.2f ✅ (達標)\n", stock.EPS)
-		passCount++
-	} else {
-		fmt.Printf("      EPS: %.2f ❌ (偏低)\n", stock.EPS)
-		reasons = append(reasons, fmt.Sprintf("EPS偏低 %.2f", stock.EPS))
+	stages := s.EvaluateStages(stock)
+	for _, stage := range stages {
+		s.logStageResult(stock, stage)
 	}
 
-	// 負債比檢查
-	if stock.DebtRatio <= 30.0 {
-		fmt.Printf("      負債比: %.1f%% ✅ (優秀)\n", stock.DebtRatio)
-		passCount++
-	} else if stock.DebtRatio <= 50.0 {
-		fmt.Printf("      負債比: %.1f%% 🟡 (可接受)\n", stock.DebtRatio)
-		passCount++
-	} else {
-		fmt.Printf("      負債比: %.1f%% ❌ (偏高)\n", stock.DebtRatio)
-		reasons = append(reasons, fmt.Sprintf("負債比偏高 %.1f%%", stock.DebtRatio))
+	for _, stage := range stages {
+		if stage.Required && !stage.Passed {
+			s.logger.Info("未通過排除條件", logKeyStock, stock.Code, "stage", stage.Stage, "name", stage.Name,
+				"reasons", strings.Join(stage.Reasons, ", "))
+			return false
+		}
 	}
 
-	// 配息穩定性檢查
-	if stock.DividendYears >= 5 {
-		fmt.Printf("      配息年數: %d年 ✅ (穩定)\n", stock.DividendYears)
-		passCount++
-	} else if stock.DividendYears >= 3 {
-		fmt.Printf("      配息年數: %d年 🟡 (尚可)\n", stock.DividendYears)
-		passCount++
-	} else {
-		fmt.Printf("      配息年數: %d年 ❌ (不穩定)\n", stock.DividendYears)
-		reasons = append(reasons, fmt.Sprintf("配息不穩定 %d年", stock.DividendYears))
-	}
+	quality, technical := stages[1], stages[2]
+	s.logger.Info("納入候選清單", logKeyStock, stock.Code,
+		"quality_passed", quality.Passed, "quality_reasons", strings.Join(quality.Reasons, ", "),
+		"technical_passed", technical.Passed, "technical_reasons", strings.Join(technical.Reasons, ", "))
+	return true
+}
 
-	// 至少通過60%的品質檢查
-	qualityPassed := float64(passCount)/float64(totalChecks) >= 0.6
-	fmt.Printf("      品質評分: %d/%d (%.0f%%)\n", passCount, totalChecks, float64(passCount)/float64(totalChecks)*100)
+// 規則判定結果
+const (
+	verdictPass = "pass"
+	verdictWarn = "warn"
+	verdictFail = "fail"
+	verdictSkip = "skip" // 資料不足無法判定，不列入通過比例
+)
 
-	return qualityPassed, reasons
+// RuleVerdict 單一篩選規則的判定結果
+type RuleVerdict struct {
+	Rule   string `json:"rule"`
+	Value  string `json:"value"`
+	Status string `json:"status"`           // pass, warn, fail, skip
+	Note   string `json:"note,omitempty"`   // 判定說明 (如 優秀、偏低)
+	Reason string `json:"reason,omitempty"` // 未通過原因
 }
 
-// checkStage3Technical 第三階段：技術面時機判斷
-func (s *StockScreener) checkStage3Technical(stock *StockData) (bool, []string) {
-	reasons := []string{}
-	passCount := 0
-	totalChecks := 3
-
-	fmt.Printf("   📈 技術面時機評估:\n")
+// StageResult 篩選階段的判定結果
+type StageResult struct {
+	Stage       int           `json:"stage"`
+	Name        string        `json:"name"`
+	Passed      bool          `json:"passed"`
+	Required    bool          `json:"required"` // 排除條件階段，未通過即不列入候選
+	PassCount   int           `json:"pass_count"`
+	TotalChecks int           `json:"total_checks"`
+	Rules       []RuleVerdict `json:"rules"`
+	Reasons     []string      `json:"reasons,omitempty"`
+}
 
-	// MA60趨勢檢查
-	if stock.Price > 0 && stock.MA60 > 0 {
-		priceDiff := ((stock.Price - stock.MA60) / stock.MA60) * 100
-		if priceDiff >= 5.0 {
-			fmt.Printf("      股價vs MA60: %.2f vs %.2f (+%.1f%%) ✅ (強勢)\n", stock.Price, stock.MA60, priceDiff)
-			passCount++
-		} else if priceDiff >= 0 {
-			fmt.Printf("      股價vs MA60: %.2f vs %.2f (+%.1f%%) 🟡 (站穩)\n", stock.Price, stock.MA60, priceDiff)
-			passCount++
-		} else {
-			fmt.Printf("      股價vs MA60: %.2f vs %.2f (%.1f%%) ❌ (偏弱)\n", stock.Price, stock.MA60, priceDiff)
-			reasons = append(reasons, fmt.Sprintf("跌破MA60 %.1f%%", priceDiff))
+// newStageResult 依規則判定結果彙整階段結果，資料不足 (skip) 的規則不計入通過數及總數
+func newStageResult(stage int, name string, totalChecks int, rules []RuleVerdict) StageResult {
+	result := StageResult{Stage: stage, Name: name, TotalChecks: totalChecks, Rules: rules, Reasons: []string{}}
+	for _, rule := range rules {
+		switch rule.Status {
+		case verdictFail:
+			result.Reasons = append(result.Reasons, rule.Reason)
+		case verdictSkip:
+			result.TotalChecks--
+		default:
+			result.PassCount++
 		}
 	}
+	return result
+}
 
-	// KD指標檢查
-	if stock.KValue >= 50 && stock.KValue <= 80 {
-		fmt.Printf("      K值: %.1f ✅ (買進區間)\n", stock.KValue)
-		passCount++
-	} else if stock.KValue >= 30 && stock.KValue < 90 {
-		fmt.Printf("      K值: %.1f 🟡 (可觀察)\n", stock.KValue)
-		passCount++
-	} else {
-		fmt.Printf("      K值: %.1f ❌ (時機不佳)\n", stock.KValue)
-		reasons = append(reasons, fmt.Sprintf("K值不在理想區間 %.1f", stock.KValue))
+// passRatio 通過比例，沒有可判定的規則時為 0
+func (r StageResult) passRatio() float64 {
+	if r.TotalChecks <= 0 {
+		return 0
 	}
+	return float64(r.PassCount) / float64(r.TotalChecks)
+}
 
-	// D值檢查
-	if stock.DValue >= 50 && stock.DValue <= 80 {
-		fmt.Printf("      D值: %.1f ✅ (買進區間)\n", stock.DValue)
-		passCount++
-	} else if stock.DValue >= 30 && stock.DValue < 90 {
-		fmt.Printf("      D值: %.1f 🟡 (可觀察)\n", stock.DValue)
-		passCount++
-	} else {
-		fmt.Printf("      D值: %.1f ❌ (時機不佳)\n", stock.DValue)
-		reasons = append(reasons, fmt.Sprintf("D值不在理想區間 %.1f", stock.DValue))
+// gradeRule 依分級門檻判定規則 (達 pass 門檻為通過，達 warn 門檻為尚可)
+func gradeRule(rule, value string, pass, warn bool, passNote, warnNote, failNote, reason string) RuleVerdict {
+	switch {
+	case pass:
+		return RuleVerdict{Rule: rule, Value: value, Status: verdictPass, Note: passNote}
+	case warn:
+		return RuleVerdict{Rule: rule, Value: value, Status: verdictWarn, Note: warnNote}
 	}
+	return RuleVerdict{Rule: rule, Value: value, Status: verdictFail, Note: failNote, Reason: reason}
+}
 
-	// 技術面通過率
-	technicalPassed := float64(passCount)/float64(totalChecks) >= 0.5
-	fmt.Printf("      技術評分: %d/%d (%.0f%%)\n", passCount, totalChecks, float64(passCount)/float64(totalChecks)*100)
+// skipRule 資料不足無法判定的規則
+func skipRule(rule, note string) RuleVerdict {
+	return RuleVerdict{Rule: rule, Value: "-", Status: verdictSkip, Note: note}
+}
 
-	return technicalPassed, reasons
+// logStageResult 記錄各規則判定結果
+func (s *StockScreener) logStageResult(stock *StockData, result StageResult) {
+	for _, rule := range result.Rules {
+		s.logger.Debug("規則判定", logKeyStock, stock.Code, "stage", result.Stage,
+			"rule", rule.Rule, "value", rule.Value, "status", rule.Status, "note", rule.Note)
+	}
+	s.logger.Debug(result.Name, logKeyStock, stock.Code, "passed", result.Passed,
+		"pass_count", result.PassCount, "total_checks", result.TotalChecks)
 }
 
-// getStatusIcon 獲取狀態圖示
-func (s *StockScreener) getStatusIcon(passed bool) string {
-	if passed {
+// verdictIcon 判定結果圖示
+func verdictIcon(status string) string {
+	switch status {
+	case verdictPass:
 		return "✅"
+	case verdictWarn:
+		return "🟡"
+	case verdictSkip:
+		return "⚪"
 	}
 	return "❌"
 }
 
-// calculateScore 計算綜合評分
-func (s *StockScreener) calculateScore(stock *StockData) {
-	score := 0.0
-
-	// 基本面評分 (70% - 增加權重)
-	score += math.Min(stock.ROE/30.0, 1.0) * 15                   // ROE評分 (降低權重)
-	score += math.Min(stock.RevenueGrowth/20.0, 1.0) * 10         // 營收成長評分 (降低權重)
-	score += math.Min(stock.YoYGrowth/30.0, 1.0) * 15             // 年增率評分 (新增)
-	score += math.Min(stock.EPSGrowth/200.0, 1.0) * 20            // EPS增長評分 (新增，高權重)
-	score += math.Min(stock.EPS/5.0, 1.0) * 5                     // EPS絕對值評分 (新增)
-	score += (1.0 - stock.DebtRatio/100.0) * 10                   // 負債比評分 (降低權重)
-	score += math.Min(float64(stock.DividendYears)/10.0, 1.0) * 5 // 配息穩定性 (降低權重)
-
-	// 技術面評分 (30% - 降低權重)
-	if stock.Price > stock.MA60 {
-		score += 15 // 站上季線 (降低權重)
+// EvaluateStages 評估五個篩選階段 (不輸出)
+func (s *StockScreener) EvaluateStages(stock *StockData) []StageResult {
+	return []StageResult{
+		s.evaluateStage1(stock),
+		s.evaluateStage2(stock),
+		s.evaluateStage3(stock),
+		s.evaluateStage4(stock),
+		s.evaluateStage5(stock),
 	}
+}
 
-	// KD值在黃金交叉區間
-	if stock.KValue >= 50 && stock.KValue <= 80 {
-		score += 8 // 降低權重
-	}
-	if stock.DValue >= 50 && stock.DValue <= 80 {
-		score += 7 // 降低權重
+// qualifies 是否通過所有排除條件階段
+func qualifies(stages []StageResult) bool {
+	for _, stage := range stages {
+		if stage.Required && !stage.Passed {
+			return false
+		}
 	}
+	return true
+}
+
+// evaluateStage1 第一階段規則：極端負面條件 (絕對排除)
+func (s *StockScreener) evaluateStage1(stock *StockData) StageResult {
+	c := s.criteria
+	rule := func(name, value string, passed bool, reason string) RuleVerdict {
+		return gradeRule(name, value, passed, false, "", "", "", reason)
+	}
+
+	rules := []RuleVerdict{
+		rule("ROE", fmt.Sprintf("%.1f%%", stock.ROE), stock.ROE > 0, "ROE為負數或零"),
+		rule("負債比", fmt.Sprintf("%.1f%%", stock.DebtRatio), stock.DebtRatio < c.ExcludeDebtRatio,
+			fmt.Sprintf("負債比過高 %.1f%% (>%.0f%%)", stock.DebtRatio, c.ExcludeDebtRatio)),
+		rule("營收成長", fmt.Sprintf("%.1f%%", stock.RevenueGrowth), stock.RevenueGrowth > c.ExcludeRevenueGrowth,
+			fmt.Sprintf("營收大幅衰退 %.1f%% (<%.0f%%)", stock.RevenueGrowth, c.ExcludeRevenueGrowth)),
+		rule("年增率", fmt.Sprintf("%.1f%%", stock.YoYGrowth), stock.YoYGrowth > c.ExcludeYoYGrowth,
+			fmt.Sprintf("年增率大幅衰退 %.1f%% (<%.0f%%)", stock.YoYGrowth, c.ExcludeYoYGrowth)),
+		rule("EPS增長", fmt.Sprintf("%.1f%%", stock.EPSGrowth), stock.EPSGrowth > c.ExcludeEPSGrowth,
+			fmt.Sprintf("EPS大幅衰退 %.1f%% (<%.0f%%)", stock.EPSGrowth, c.ExcludeEPSGrowth)),
+		rule("EPS", fmt.Sprintf("%.2f", stock.EPS), stock.EPS > 0, "EPS為負數或零"),
+		rule("盈餘品質", fmt.Sprintf("EPS增長 %.1f%% / 營業現金流 %.0f", stock.EPSGrowth, stock.OperatingCashFlow),
+			stock.EPSGrowth < c.MinEPSGrowth || stock.OperatingCashFlow >= 0,
+			fmt.Sprintf("EPS高成長 %.1f%% 但營業現金流為負", stock.EPSGrowth)),
+	}
+	rules = append(rules, s.evaluateFinancialScores(stock)...)
+
+	result := newStageResult(1, "基本財務健康度", len(rules), rules)
+	result.Required = true
+	result.Passed = len(result.Reasons) == 0
+	return result
+}
+
+// evaluateStage2 第二階段規則：至少通過60%的品質檢查
+func (s *StockScreener) evaluateStage2(stock *StockData) StageResult {
+	c := s.criteria
+	rules := []RuleVerdict{
+		s.evaluateROE(stock),
+		gradeRule("營收成長", fmt.Sprintf("%.1f%%", stock.RevenueGrowth),
+			stock.RevenueGrowth >= c.GoodRevenueGrowth, stock.RevenueGrowth >= c.MinRevenueGrowth,
+			"高成長", "穩定", "衰退",
+			fmt.Sprintf("營收成長不足 %.1f%% (<%.1f%%)", stock.RevenueGrowth, c.MinRevenueGrowth)),
+		gradeRule("年增率", fmt.Sprintf("%.1f%%", stock.YoYGrowth),
+			stock.YoYGrowth >= c.MinYoYGrowth, stock.YoYGrowth >= 0, "達標", "正成長", "負成長",
+			fmt.Sprintf("年增率不足 %.1f%%", stock.YoYGrowth)),
+		gradeRule("EPS增長", fmt.Sprintf("%.1f%%", stock.EPSGrowth),
+			stock.EPSGrowth >= c.MinEPSGrowth, stock.EPSGrowth >= c.MinEPSGrowth/2, "達標", "高成長", "增長不足",
+			fmt.Sprintf("EPS增長不足 %.1f%%", stock.EPSGrowth)),
+		gradeRule("EPS", fmt.Sprintf("%.2f", stock.EPS),
+			stock.EPS >= c.MinEPS, false, "達標", "", "偏低",
+			fmt.Sprintf("EPS偏低 %.2f", stock.EPS)),
+		gradeRule("負債比", fmt.Sprintf("%.1f%%", stock.DebtRatio),
+			stock.DebtRatio <= c.GoodDebtRatio, stock.DebtRatio <= c.MaxDebtRatio, "優秀", "可接受", "偏高",
+			fmt.Sprintf("負債比偏高 %.1f%% (>%.0f%%)", stock.DebtRatio, c.MaxDebtRatio)),
+		gradeRule("配息年數", fmt.Sprintf("%d年", stock.DividendYears),
+			stock.DividendYears >= c.GoodDividendYears, stock.DividendYears >= c.MinDividendYears, "穩定", "尚可", "不穩定",
+			fmt.Sprintf("配息年數不足 %d年 (<%d年)", stock.DividendYears, c.MinDividendYears)),
+	}
+	rules = append(rules, s.evaluateCashFlowQuality(stock)...)
+
+	result := newStageResult(2, "投資品質", len(rules), rules)
+	result.Passed = result.passRatio() >= 0.6
+	return result
+}
+
+// evaluateStage3 第三階段規則：至少通過 MinTechnicalPassRatio (預設50%) 的技術面檢查
+// 設定 RequireMA60Above 時股價須站上MA60，否則不論通過比例皆未通過
+// 設定 RequireMA60Above 或市場狀態覆寫技術面條件 (如空頭) 時，本階段成為排除條件
+func (s *StockScreener) evaluateStage3(stock *StockData) StageResult {
+	var rules []RuleVerdict
+	belowMA60 := false
+
+	// MA60趨勢檢查 (缺少價格資料時略過，仍計入總數)
+	if stock.Price > 0 && stock.MA60 > 0 {
+		priceDiff := ((stock.Price - stock.MA60) / stock.MA60) * 100
+		rules = append(rules, gradeRule("股價vs MA60",
+			fmt.Sprintf("%.2f vs %.2f (%+.1f%%)", stock.Price, stock.MA60, priceDiff),
+			priceDiff >= 5.0, priceDiff >= 0, "強勢", "站穩", "偏弱",
+			fmt.Sprintf("跌破MA60 %.1f%%", priceDiff)))
+		belowMA60 = priceDiff < 0
+	}
+
+	c := s.criteria
+	rules = append(rules,
+		gradeRule("K值", fmt.Sprintf("%.1f", stock.KValue),
+			stock.KValue >= c.MinKBuy && stock.KValue <= c.MaxKBuy, stock.KValue >= c.MinKValue && stock.KValue < c.MaxKValue,
+			"買進區間", "可觀察", "時機不佳",
+			fmt.Sprintf("K值不在 %.0f-%.0f 區間 %.1f", c.MinKValue, c.MaxKValue, stock.KValue)),
+		gradeRule("D值", fmt.Sprintf("%.1f", stock.DValue),
+			stock.DValue >= c.MinDBuy && stock.DValue <= c.MaxDBuy, stock.DValue >= c.MinDValue && stock.DValue < c.MaxDValue,
+			"買進區間", "可觀察", "時機不佳",
+			fmt.Sprintf("D值不在 %.0f-%.0f 區間 %.1f", c.MinDValue, c.MaxDValue, stock.DValue)),
+	)
+
+	result := newStageResult(3, "技術面時機", 3, rules)
+	result.Required = c.RequireMA60Above || (s.regime != nil && s.regime.TechnicalRequired)
+	result.Passed = result.passRatio() >= c.MinTechnicalPassRatio
+	if c.RequireMA60Above && belowMA60 {
+		result.Passed = false
+		result.Reasons = append(result.Reasons, "要求站上MA60")
+	}
+	return result
+}
+
+// evaluateStage4 第四階段規則：風險上限及動能門檻 (絕對排除，以完整價格歷史計算)
+func (s *StockScreener) evaluateStage4(stock *StockData) StageResult {
+	rules := s.evaluateRisk(stock)
+	rules = append(rules, s.evaluateMomentum(stock)...)
 
-	stock.Score = score
+	result := newStageResult(4, "風險與動能", len(rules), rules)
+	result.Required = true
+	result.Passed = len(result.Reasons) == 0
+	return result
+}
+
+// evaluateStage5 第五階段規則：估值上限及安全邊際 (絕對排除，警示不排除)
+func (s *StockScreener) evaluateStage5(stock *StockData) StageResult {
+	rules := s.evaluateValuation(stock)
+	rules = append(rules, s.evaluateMarginOfSafety(stock))
+
+	result := newStageResult(5, "估值", len(rules), rules)
+	result.Required = true
+	result.Passed = len(result.Reasons) == 0
+	return result
 }
 
 // GenerateReport 產生篩選報告
 func (s *StockScreener) GenerateReport(stocks []*StockData) {
-	fmt.Println("\n========== 股票篩選報告 ==========")
-	fmt.Printf("篩選時間: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Println("\n【篩選條件】")
-	fmt.Printf("- ROE > %.1f%%\n", s.criteria.MinROE)
-	fmt.Printf("- 營收年增率 > %.1f%%\n", s.criteria.MinRevenueGrowth)
-	fmt.Printf("- 年增率 > %.1f%%\n", s.criteria.MinYoYGrowth)
-	fmt.Printf("- EPS增長 > %.1f%% (三位數增長)\n", s.criteria.MinEPSGrowth)
-	fmt.Printf("- EPS > %.1f元\n", s.criteria.MinEPS)
-	fmt.Printf("- 負債比 < %.1f%%\n", s.criteria.MaxDebtRatio)
-	fmt.Printf("- 近5年穩定配息\n")
-	fmt.Printf("- 股價在60日均線之上\n")
-	fmt.Printf("- KD值在 %.0f-%.0f 之間\n", s.criteria.MinKValue, s.criteria.MaxKValue)
-
-	fmt.Printf("\n【符合條件股票】共 %d 檔\n", len(stocks))
-	fmt.Println("=====================================")
-
-	for i, stock := range stocks {
-		fmt.Printf("\n%d. %s (%s)\n", i+1, stock.Name, stock.Code)
-		fmt.Printf("   綜合評分: %.1f\n", stock.Score)
-		fmt.Printf("   ROE: %.1f%%\n", stock.ROE)
-		fmt.Printf("   營收年增率: %.1f%%\n", stock.RevenueGrowth)
-		fmt.Printf("   年增率: %.1f%%\n", stock.YoYGrowth)
-		fmt.Printf("   EPS增長: %.1f%%\n", stock.EPSGrowth)
-		fmt.Printf("   EPS: %.2f元\n", stock.EPS)
-		fmt.Printf("   負債比: %.1f%%\n", stock.DebtRatio)
-		fmt.Printf("   現價: %.2f | MA60: %.2f\n", stock.Price, stock.MA60)
-		fmt.Printf("   K值: %.1f | D值: %.1f\n", stock.KValue, stock.DValue)
-		fmt.Println("   ---")
-	}
+	textRenderer{}.Render(os.Stdout, s.reportData(stocks))
 }
 
 // SaveResults 儲存篩選結果
@@ -1388,63 +1745,24 @@ func (s *StockScreener) SaveResults(stocks []*StockData, filename string) error
 }
 
 func main() {
-	fmt.Println("啟動台股篩選系統...")
-
-	// 建立篩選器
-	screener := NewStockScreener()
-
-	// 取得股票清單
-	stockList, err := screener.FetchStockList()
-	if err != nil {
-		log.Fatal("無法取得股票清單:", err)
-	}
-
-	fmt.Printf("準備篩選 %d 檔股票...\n", len(stockList))
-
-	// 執行篩選
-	qualifiedStocks, err := screener.ScreenStocks(stockList)
-	if err != nil {
-		log.Fatal("篩選過程發生錯誤:", err)
-	}
-
-	// 產生報告
-	screener.GenerateReport(qualifiedStocks)
+	os.Exit(runCLI(os.Args[1:]))
+}
 
-	// 儲存結果
-	filename := fmt.Sprintf("screening_results_%s.json",
-		time.Now().Format("20060102_150405"))
-	if err := screener.SaveResults(qualifiedStocks, filename); err != nil {
-		log.Printf("無法儲存結果: %v\n", err)
-	} else {
-		fmt.Printf("\n結果已儲存至: %s\n", filename)
-	}
+// 額外的輔助函數
 
-	// 產生買進建議
-	fmt.Println("\n========== 買進建議 ==========")
-	if len(qualifiedStocks) > 0 {
-		fmt.Println("【優先考慮】評分最高的前3檔:")
-		for i := 0; i < len(qualifiedStocks) && i < 3; i++ {
-			stock := qualifiedStocks[i]
-			fmt.Printf("%d. %s (%s) - 評分: %.1f\n",
-				i+1, stock.Name, stock.Code, stock.Score)
+// mergeCodes 合併股票代碼清單並去除重複
+func mergeCodes(codes []string, extra []string) []string {
+	seen := make(map[string]bool, len(codes))
+	merged := make([]string, 0, len(codes)+len(extra))
+	for _, code := range append(codes, extra...) {
+		if !seen[code] {
+			seen[code] = true
+			merged = append(merged, code)
 		}
-
-		fmt.Println("\n【進場策略】")
-		fmt.Println("1. 分3批進場，每批間隔1-2週")
-		fmt.Println("2. 設定停損點在買進價-10%")
-		fmt.Println("3. 獲利20-30%可先出場一半")
-		fmt.Println("4. 每週檢視技術指標變化")
-	} else {
-		fmt.Println("目前沒有符合所有條件的股票")
-		fmt.Println("建議：")
-		fmt.Println("1. 放寬部分篩選條件")
-		fmt.Println("2. 等待市場回檔再執行篩選")
-		fmt.Println("3. 考慮ETF作為替代選擇")
 	}
+	return merged
 }
 
-// 額外的輔助函數
-
 // CalculateVolatility 計算股價波動率
 func CalculateVolatility(prices []float64) float64 {
 	if len(prices) < 2 {
@@ -1499,21 +1817,27 @@ func CalculateSharpeRatio(returns []float64, riskFreeRate float64) float64 {
 	return (avgReturn - riskFreeRate) / stdDev
 }
 
+// otcStocks 已知的上櫃股票
+var otcStocks = map[string]bool{
+	"6000": true, // 鈊象電子
+	"6005": true, // 群益證
+	"3379": true,
+	// 可以根據需要添加更多上櫃股票
+}
+
 // buildYahooSymbol 構建正確的Yahoo Finance股票代碼
-func (s *StockScreener) buildYahooSymbol(code string) string {
+func buildYahooSymbol(code string) string {
 	// 台灣股票在 Yahoo Finance 的格式
 	// 上市股票: XXXX.TW (如 2330.TW)
 	// 上櫃股票: XXXX.TWO (但大多數也可用 .TW)
 	// ETF: XXXX.TW (如 0050.TW)
 
-	// 特殊處理某些已知的上櫃股票
-	otcStocks := map[string]bool{
-		"6000": true, // 鈊象電子
-		"6005": true, // 群益證
-		"3379": true,
-		// 可以根據需要添加更多上櫃股票
+	// 指數代碼 (如 ^TWII) 直接使用
+	if strings.HasPrefix(code, "^") {
+		return url.PathEscape(code)
 	}
 
+	// 特殊處理某些已知的上櫃股票
 	if otcStocks[code] {
 		return code + ".TWO"
 	}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// 動能指標設定
+const (
+	momentumMinRange  = "1y" // 價格歷史至少取得的資料區間 (12個月報酬率及52週高低點)
+	momentumTolerance = 10   // 回溯日期早於第一根K棒時容許的天數 (涵蓋春節等長假)
+	rsRatingMonths    = 12   // 計算相對強弱評等所需的月數
+)
+
+// momentumPeriods 計算報酬率的回溯月數
+var momentumPeriods = []int{1, 3, 6, 9, 12}
+
+// fetchOptions 取得價格歷史使用的設定: 資料區間為設定區間與 momentumMinRange 中較長者
+// 技術指標仍只使用設定區間內的K棒 (見 indicatorBars)，風險及動能指標使用完整價格歷史
+func (s *StockScreener) fetchOptions() ChartOptions {
+	opts := s.chart
+	opts.Range = longerRange(opts.Range, momentumMinRange)
+	return opts
+}
+
+// lookbackClose 最新一根K棒往前 months 個月當日 (含) 之前最近一根的收盤價
+// 回溯日期早於第一根K棒超過 momentumTolerance 天時 ok 為 false
+func lookbackClose(bars []Bar, months int) (float64, bool) {
+	if len(bars) < 2 {
+		return 0, false
+	}
+	target := bars[len(bars)-1].Time.AddDate(0, -months, 0)
+	cutoff := startOfDay(target).AddDate(0, 0, 1)
+	i := sort.Search(len(bars), func(i int) bool { return !bars[i].Time.Before(cutoff) })
+	if i == 0 {
+		if bars[0].Time.Sub(target) > momentumTolerance*24*time.Hour {
+			return 0, false
+		}
+		i = 1
+	}
+	if i == len(bars) {
+		return 0, false
+	}
+	return bars[i-1].Close, bars[i-1].Close > 0
+}
+
+// calculateMomentum 由完整價格歷史計算1/3/6/12個月報酬率、12-1月動能、IBD加權報酬及52週高低點距離
+// 價格歷史不足的期間不計算，MomentumMonths 記錄可計算的最長回溯月數
+func (s *StockScreener) calculateMomentum(stock *StockData) {
+	stock.Return1M, stock.Return3M, stock.Return6M, stock.Return12M = 0, 0, 0, 0
+	stock.Momentum12Minus1, stock.RSScore, stock.MomentumMonths = 0, 0, 0
+	bars := s.historyBars(stock)
+	if len(bars) < 2 {
+		return
+	}
+
+	last := bars[len(bars)-1]
+	returns := make(map[int]float64, len(momentumPeriods))
+	closes := make(map[int]float64, len(momentumPeriods))
+	for _, months := range momentumPeriods {
+		past, ok := lookbackClose(bars, months)
+		if !ok {
+			break
+		}
+		closes[months] = past
+		returns[months] = (last.Close/past - 1) * 100
+		stock.MomentumMonths = months
+	}
+	stock.Return1M, stock.Return3M = returns[1], returns[3]
+	stock.Return6M, stock.Return12M = returns[6], returns[12]
+
+	if stock.MomentumMonths >= rsRatingMonths {
+		stock.Momentum12Minus1 = (closes[1]/closes[12] - 1) * 100
+		// 近一季權重40%，前三季各20% (以累積報酬率表示)
+		stock.RSScore = 0.4*returns[3] + 0.2*returns[6] + 0.2*returns[9] + 0.2*returns[12]
+	}
+
+	// 52週高低點 (無最高/最低價時以收盤價代替)
+	stock.High52W, stock.Low52W, stock.FromHigh52W, stock.FromLow52W = 0, 0, 0, 0
+	since := last.Time.AddDate(-1, 0, 0)
+	for _, bar := range bars {
+		if bar.Time.Before(since) || bar.Close <= 0 {
+			continue
+		}
+		high, low := bar.High, bar.Low
+		if high <= 0 {
+			high = bar.Close
+		}
+		if low <= 0 {
+			low = bar.Close
+		}
+		stock.High52W = max(stock.High52W, high)
+		if stock.Low52W == 0 || low < stock.Low52W {
+			stock.Low52W = low
+		}
+	}
+	if stock.High52W > 0 && stock.Low52W > 0 {
+		stock.FromHigh52W = (last.Close/stock.High52W - 1) * 100
+		stock.FromLow52W = (last.Close/stock.Low52W - 1) * 100
+	}
+
+	s.logger.Debug("動能指標", logKeyStock, stock.Code, "months", stock.MomentumMonths,
+		"return_1m", stock.Return1M, "return_3m", stock.Return3M, "return_6m", stock.Return6M,
+		"return_12m", stock.Return12M, "momentum_12_1", stock.Momentum12Minus1, "rs_score", stock.RSScore,
+		"from_high_52w", stock.FromHigh52W, "from_low_52w", stock.FromLow52W)
+}
+
+// hasMomentum 價格歷史是否涵蓋 months 個月
+func hasMomentum(s *StockData, months int) bool {
+	return s.MomentumMonths >= months
+}
+
+// rateRelativeStrength 依股票池中IBD加權報酬的百分位給予相對強弱評等 (1-99)
+// 價格歷史不足12個月的股票評等為 0；有資料的股票不足2檔時無法比較
+func (s *StockScreener) rateRelativeStrength(universe []*StockData) {
+	var rated []*StockData
+	var scores []float64
+	for _, stock := range universe {
+		stock.RSRating = 0
+		if hasMomentum(stock, rsRatingMonths) {
+			rated = append(rated, stock)
+			scores = append(scores, stock.RSScore)
+		}
+	}
+	if len(rated) < 2 {
+		return
+	}
+
+	sort.Float64s(scores)
+	for _, stock := range rated {
+		pct := percentileRank(scores, stock.RSScore)
+		stock.RSRating = int(min(99, max(1, math.Round(pct))))
+	}
+	s.logger.Debug("相對強弱評等", "stocks", len(rated))
+}
+
+// rsRatingText 相對強弱評等的顯示文字，資料不足時為 "-"
+func rsRatingText(stock *StockData) string {
+	if stock.RSRating == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d", stock.RSRating)
+}
+
+// rsRatingCell 相對強弱評等的表格欄位值，資料不足時為 "-"
+func rsRatingCell(stock *StockData) interface{} {
+	if stock.RSRating == 0 {
+		return "-"
+	}
+	return float64(stock.RSRating)
+}
+
+// momentumCell 報酬率的表格欄位值，價格歷史不足時為 "-"
+func momentumCell(stock *StockData, months int, value float64) interface{} {
+	if !hasMomentum(stock, months) {
+		return "-"
+	}
+	return value
+}
+
+// returnText 報酬率文字，價格歷史不足時為 "-"
+func returnText(stock *StockData, months int, value float64) string {
+	if !hasMomentum(stock, months) {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", value)
+}
+
+// printMomentum 輸出動能指標
+func printMomentum(w io.Writer, stock *StockData) {
+	fmt.Fprintf(w, "報酬率: 1月 %s | 3月 %s | 6月 %s | 12月 %s\n",
+		returnText(stock, 1, stock.Return1M), returnText(stock, 3, stock.Return3M),
+		returnText(stock, 6, stock.Return6M), returnText(stock, 12, stock.Return12M))
+	fmt.Fprintf(w, "12-1動能: %s | RS評等: %s\n",
+		returnText(stock, rsRatingMonths, stock.Momentum12Minus1), rsRatingText(stock))
+	if stock.High52W > 0 {
+		fmt.Fprintf(w, "52週高低: %.2f / %.2f | 距高點 %+.1f%% | 距低點 %+.1f%%\n",
+			stock.High52W, stock.Low52W, stock.FromHigh52W, stock.FromLow52W)
+	}
+}
+
+// evaluateMomentum 動能門檻規則 (第四階段)，門檻為 0 或價格歷史不足時不排除
+func (s *StockScreener) evaluateMomentum(stock *StockData) []RuleVerdict {
+	c := s.criteria
+	rule := func(name, value string, passed bool, reason string) RuleVerdict {
+		return gradeRule(name, value, passed, false, "", "", "", reason)
+	}
+
+	var rules []RuleVerdict
+	if c.MinRSRating > 0 {
+		rules = append(rules, rule("RS評等", rsRatingText(stock),
+			stock.RSRating == 0 || stock.RSRating >= c.MinRSRating,
+			fmt.Sprintf("RS評等偏低 %d (<%d)", stock.RSRating, c.MinRSRating)))
+	}
+	if c.MaxFromHigh52W > 0 {
+		rules = append(rules, rule("距52週高點", fmt.Sprintf("%+.1f%%", stock.FromHigh52W),
+			stock.High52W == 0 || -stock.FromHigh52W <= c.MaxFromHigh52W,
+			fmt.Sprintf("距52週高點過遠 %.1f%% (>%.0f%%)", -stock.FromHigh52W, c.MaxFromHigh52W)))
+	}
+	if c.RequirePositiveMomentum {
+		rules = append(rules, rule("12-1動能", returnText(stock, rsRatingMonths, stock.Momentum12Minus1),
+			!hasMomentum(stock, rsRatingMonths) || stock.Momentum12Minus1 > 0,
+			fmt.Sprintf("12-1動能為負 %.1f%%", stock.Momentum12Minus1)))
+	}
+	return rules
+}
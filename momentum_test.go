@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// datedBars 依日期 (2006-01-02) 及收盤價建立K棒
+func datedBars(t *testing.T, days []string, closes []float64) []Bar {
+	t.Helper()
+	bars := make([]Bar, len(days))
+	for i, d := range days {
+		day, err := time.ParseInLocation(time.DateOnly, d, taipeiLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bars[i] = Bar{Time: day, Open: closes[i], High: closes[i], Low: closes[i], Close: closes[i]}
+	}
+	return bars
+}
+
+// monthlyStepBars 從 start 到 end 每個交易日的K棒，收盤價為 100 + 10 × (距2025年1月的月數)
+func monthlyStepBars(start, end time.Time) []Bar {
+	var bars []Bar
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		months := (day.Year()-2025)*12 + int(day.Month()) - 1
+		c := 100 + 10*float64(months)
+		bars = append(bars, Bar{Time: day, Open: c, High: c, Low: c, Close: c})
+	}
+	return bars
+}
+
+func TestLookbackClose(t *testing.T) {
+	bars := datedBars(t, []string{"2025-01-15", "2025-02-14", "2025-03-14", "2025-04-15"}, []float64{10, 11, 12, 13})
+
+	tests := []struct {
+		name   string
+		bars   []Bar
+		months int
+		want   float64
+		wantOK bool
+	}{
+		{"回溯日非交易日取前一根", bars, 1, 12, true},
+		{"回溯日前最近一根", bars, 2, 11, true},
+		{"回溯日當日", bars, 3, 10, true},
+		{"早於第一根K棒10天內", datedBars(t, []string{"2025-01-24", "2025-04-15"}, []float64{10, 13}), 3, 10, true},
+		{"早於第一根K棒超過10天", datedBars(t, []string{"2025-01-27", "2025-04-15"}, []float64{10, 13}), 3, 0, false},
+		{"不足2根", bars[:1], 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookbackClose(tt.bars, tt.months)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("lookbackClose(%d) = %v, %v, want %v, %v", tt.months, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCalculateMomentum(t *testing.T) {
+	s := testScreener(t, "default")
+	end := time.Date(2026, 1, 15, 0, 0, 0, 0, taipeiLocation)
+
+	// 2026/01/15 收盤 220，1/3/6/9/12 個月前分別為 210/190/160/130/100
+	bars := monthlyStepBars(time.Date(2025, 1, 2, 0, 0, 0, 0, taipeiLocation), end)
+	bars[0].Low = 50 // 52週以前的低點不列入
+	for i := range bars {
+		if bars[i].Time.Equal(time.Date(2025, 6, 10, 0, 0, 0, 0, taipeiLocation)) {
+			bars[i].High = 300
+		}
+	}
+	stock := &StockData{Code: "2330", bars: bars}
+	s.calculateMomentum(stock)
+
+	r3, r6, r9, r12 := (220.0/190-1)*100, (220.0/160-1)*100, (220.0/130-1)*100, 120.0
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"1月報酬", stock.Return1M, (220.0/210 - 1) * 100},
+		{"3月報酬", stock.Return3M, r3},
+		{"6月報酬", stock.Return6M, r6},
+		{"12月報酬", stock.Return12M, r12},
+		{"12-1動能", stock.Momentum12Minus1, 110},
+		{"IBD加權", stock.RSScore, 0.4*r3 + 0.2*r6 + 0.2*r9 + 0.2*r12},
+		{"52週高點", stock.High52W, 300},
+		{"52週低點", stock.Low52W, 100},
+		{"距52週高點", stock.FromHigh52W, (220.0/300 - 1) * 100},
+		{"距52週低點", stock.FromLow52W, 120},
+	}
+	for _, c := range checks {
+		if !approxEqual(c.got, c.want, 1e-9) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if stock.MomentumMonths != 12 {
+		t.Errorf("MomentumMonths = %d, want 12", stock.MomentumMonths)
+	}
+
+	// 價格歷史只有7個多月: 12個月相關欄位為 0
+	short := &StockData{Code: "2330", bars: monthlyStepBars(time.Date(2025, 6, 2, 0, 0, 0, 0, taipeiLocation), end),
+		Return12M: 99, Momentum12Minus1: 99, RSScore: 99}
+	s.calculateMomentum(short)
+	if short.MomentumMonths != 6 || !approxEqual(short.Return6M, r6, 1e-9) {
+		t.Errorf("MomentumMonths = %d, Return6M = %v, want 6, %v", short.MomentumMonths, short.Return6M, r6)
+	}
+	if short.Return12M != 0 || short.Momentum12Minus1 != 0 || short.RSScore != 0 {
+		t.Errorf("12個月欄位 = %v, %v, %v, want 0", short.Return12M, short.Momentum12Minus1, short.RSScore)
+	}
+	if returnText(short, 12, short.Return12M) != "-" {
+		t.Errorf("returnText = %s, want -", returnText(short, 12, short.Return12M))
+	}
+}
+
+func TestRateRelativeStrength(t *testing.T) {
+	s := testScreener(t, "default")
+	rated := func(score float64) *StockData {
+		return &StockData{MomentumMonths: 12, RSScore: score}
+	}
+
+	// 4檔: 百分位 12.5/37.5/62.5/87.5，同分相同評等，歷史不足12個月不評等
+	a, b, c, d, tie := rated(10), rated(20), rated(30), rated(40), rated(20)
+	short := &StockData{MomentumMonths: 6, RSScore: 100, RSRating: 50}
+	s.rateRelativeStrength([]*StockData{a, b, c, d, short})
+	for i, tt := range []struct {
+		stock *StockData
+		want  int
+	}{{a, 13}, {b, 38}, {c, 63}, {d, 88}, {short, 0}} {
+		if tt.stock.RSRating != tt.want {
+			t.Errorf("stock %d RSRating = %d, want %d", i, tt.stock.RSRating, tt.want)
+		}
+	}
+
+	s.rateRelativeStrength([]*StockData{b, tie, d})
+	if b.RSRating != tie.RSRating || b.RSRating != 33 || d.RSRating != 83 {
+		t.Errorf("同分 RSRating = %d, %d, 最高 %d, want 33, 33, 83", b.RSRating, tie.RSRating, d.RSRating)
+	}
+
+	// 百分位限制在 1-99
+	universe := make([]*StockData, 100)
+	for i := range universe {
+		universe[i] = rated(float64(i))
+	}
+	s.rateRelativeStrength(universe)
+	if universe[0].RSRating != 1 || universe[99].RSRating != 99 {
+		t.Errorf("RSRating 最低 %d、最高 %d, want 1, 99", universe[0].RSRating, universe[99].RSRating)
+	}
+
+	// 可評等不足2檔時清除評等
+	s.rateRelativeStrength([]*StockData{a, short})
+	if a.RSRating != 0 {
+		t.Errorf("RSRating = %d, want 0", a.RSRating)
+	}
+}
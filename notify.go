@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultNotifyTopN 通知中列出的評分前N名
+const defaultNotifyTopN = 5
+
+// notifyTimeout 單次通知請求的時限
+const notifyTimeout = 15 * time.Second
+
+// newNotifyClient 通知專用的HTTP客戶端，不共用資料來源的重試、斷路及請求時限，避免通知主機緩慢時互相影響
+func newNotifyClient() *http.Client {
+	return &http.Client{Timeout: notifyTimeout}
+}
+
+// defaultLineNotifyURL LINE Notify API 位址
+const defaultLineNotifyURL = "https://notify-api.line.me/api/notify"
+
+// defaultDigestTemplate 預設通知內容範本
+const defaultDigestTemplate = `台股篩選結果 {{.RunTime.Format "2006-01-02 15:04"}}
+符合條件: {{.TotalQualified}} 檔
+{{- if .NewQualifiers}}
+
+【新進榜】
+{{- range .NewQualifiers}}
+- {{.Code}} {{.Name}} 評分 {{printf "%.1f" .Score}}
+{{- end}}
+{{- end}}
+{{- if .TopStocks}}
+
+【評分前 {{len .TopStocks}} 名】
+{{- range $i, $s := .TopStocks}}
+{{inc $i}}. {{$s.Code}} {{$s.Name}} 評分 {{printf "%.1f" $s.Score}} | ROE {{printf "%.1f" $s.ROE}}% | EPS增長 {{printf "%.1f" $s.EPSGrowth}}% | 現價 {{printf "%.2f" $s.Price}}
+{{- end}}
+{{- end}}
+{{- if .Alerts}}
+
+【自選股警示】
+{{- range .Alerts}}
+- {{.Code}} {{.Name}}: {{.Message}}
+{{- end}}
+{{- end}}
+`
+
+// ScreeningDigest 篩選結果摘要
+type ScreeningDigest struct {
+	RunTime        time.Time    `json:"run_time"`
+	TotalQualified int          `json:"total_qualified"`
+	NewQualifiers  []*StockData `json:"new_qualifiers"`
+	TopStocks      []*StockData `json:"top_stocks"`
+	Alerts         []AlertEvent `json:"alerts"`
+}
+
+// Notifier 篩選結果通知介面
+type Notifier interface {
+	Name() string
+	Notify(digest *ScreeningDigest) error
+}
+
+// ParseDigestTemplate 解析通知內容範本
+func ParseDigestTemplate(text string) (*template.Template, error) {
+	return template.New("digest").Funcs(template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(text)
+}
+
+// renderDigest 以範本產生通知內容，未指定範本時使用預設範本
+func renderDigest(tmpl *template.Template, digest *ScreeningDigest) (string, error) {
+	if tmpl == nil {
+		var err error
+		if tmpl, err = ParseDigestTemplate(defaultDigestTemplate); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, digest); err != nil {
+		return "", fmt.Errorf("產生通知內容失敗: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// BuildDigest 產生篩選摘要，previous 為前次篩選通過的股票代碼
+func BuildDigest(stocks []*StockData, previous []string, topN int) *ScreeningDigest {
+	seen := make(map[string]bool, len(previous))
+	for _, code := range previous {
+		seen[code] = true
+	}
+
+	digest := &ScreeningDigest{
+		RunTime:        taipeiNow(),
+		TotalQualified: len(stocks),
+	}
+
+	for _, stock := range stocks {
+		if !seen[stock.Code] {
+			digest.NewQualifiers = append(digest.NewQualifiers, stock)
+		}
+	}
+
+	top := make([]*StockData, len(stocks))
+	copy(top, stocks)
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].Score > top[j].Score
+	})
+	if len(top) > topN {
+		top = top[:topN]
+	}
+	digest.TopStocks = top
+
+	return digest
+}
+
+// loadPreviousQualifiers 讀取最近一次儲存的篩選結果中的股票代碼
+func loadPreviousQualifiers() ([]string, error) {
+	files, err := ListRuns()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	record, err := LoadRun(files[len(files)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, len(record.Stocks))
+	for _, stock := range record.Stocks {
+		codes = append(codes, stock.Code)
+	}
+	return codes, nil
+}
+
+// postNotification 送出通知請求並檢查回應狀態
+func postNotification(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("通知服務返回錯誤狀態碼: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier SMTP 郵件通知
+type EmailNotifier struct {
+	Addr     string // SMTP 伺服器位址 host:port
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string
+	Template *template.Template
+}
+
+// Name 通知名稱
+func (n *EmailNotifier) Name() string { return "email" }
+
+// Notify 寄送篩選摘要郵件
+func (n *EmailNotifier) Notify(digest *ScreeningDigest) error {
+	body, err := renderDigest(n.Template, digest)
+	if err != nil {
+		return err
+	}
+
+	subject := n.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("台股篩選結果 %s", digest.RunTime.Format("2006-01-02"))
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", digest.RunTime.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		host := n.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	return smtp.SendMail(n.Addr, auth, n.From, n.To, msg.Bytes())
+}
+
+// WebhookNotifier 通用 Webhook 通知 (JSON POST)
+type WebhookNotifier struct {
+	URL      string
+	Client   *http.Client
+	Template *template.Template
+}
+
+// webhookPayload Webhook 送出的JSON內容
+type webhookPayload struct {
+	*ScreeningDigest
+	Message string `json:"message"`
+}
+
+// Name 通知名稱
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+// Notify 以JSON POST送出篩選摘要
+func (n *WebhookNotifier) Notify(digest *ScreeningDigest) error {
+	message, err := renderDigest(n.Template, digest)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(webhookPayload{ScreeningDigest: digest, Message: message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return postNotification(n.Client, req)
+}
+
+// LineNotifier LINE Notify 相容格式通知
+type LineNotifier struct {
+	URL      string // 預設為 LINE Notify API，可指向相容服務
+	Token    string
+	Client   *http.Client
+	Template *template.Template
+}
+
+// Name 通知名稱
+func (n *LineNotifier) Name() string { return "line" }
+
+// Notify 以表單格式送出篩選摘要
+func (n *LineNotifier) Notify(digest *ScreeningDigest) error {
+	message, err := renderDigest(n.Template, digest)
+	if err != nil {
+		return err
+	}
+
+	endpoint := n.URL
+	if endpoint == "" {
+		endpoint = defaultLineNotifyURL
+	}
+
+	form := url.Values{"message": {"\n" + message}}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+n.Token)
+
+	return postNotification(n.Client, req)
+}
+
+// SlackNotifier Slack Incoming Webhook 相容格式通知
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+	Template   *template.Template
+}
+
+// Name 通知名稱
+func (n *SlackNotifier) Name() string { return "slack" }
+
+// Notify 以 Slack 訊息格式送出篩選摘要
+func (n *SlackNotifier) Notify(digest *ScreeningDigest) error {
+	message, err := renderDigest(n.Template, digest)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return postNotification(n.Client, req)
+}
+
+// parseRecipients 解析以逗號分隔的收件者，略過空白項目，至少需要一位收件者
+func parseRecipients(list string) ([]string, error) {
+	var to []string
+	for _, addr := range strings.Split(list, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("NOTIFY_EMAIL_TO 收件者格式錯誤 %q: %v", addr, err)
+		}
+		to = append(to, parsed.Address)
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("已設定 NOTIFY_SMTP_ADDR 但 NOTIFY_EMAIL_TO 沒有收件者")
+	}
+	return to, nil
+}
+
+// NotifiersFromEnv 根據環境變數建立通知設定
+func NotifiersFromEnv(client *http.Client) ([]Notifier, error) {
+	var tmpl *template.Template
+	if path := os.Getenv("NOTIFY_TEMPLATE"); path != "" {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("無法讀取通知範本: %v", err)
+		}
+		if tmpl, err = ParseDigestTemplate(string(text)); err != nil {
+			return nil, fmt.Errorf("通知範本格式錯誤: %v", err)
+		}
+	}
+
+	var notifiers []Notifier
+
+	if addr := os.Getenv("NOTIFY_SMTP_ADDR"); addr != "" {
+		to, err := parseRecipients(os.Getenv("NOTIFY_EMAIL_TO"))
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, &EmailNotifier{
+			Addr:     addr,
+			Username: os.Getenv("NOTIFY_SMTP_USER"),
+			Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+			From:     os.Getenv("NOTIFY_EMAIL_FROM"),
+			To:       to,
+			Template: tmpl,
+		})
+	}
+
+	if webhookURL := os.Getenv("NOTIFY_WEBHOOK_URL"); webhookURL != "" {
+		notifiers = append(notifiers, &WebhookNotifier{URL: webhookURL, Client: client, Template: tmpl})
+	}
+
+	if token := os.Getenv("NOTIFY_LINE_TOKEN"); token != "" {
+		notifiers = append(notifiers, &LineNotifier{
+			URL:      os.Getenv("NOTIFY_LINE_URL"),
+			Token:    token,
+			Client:   client,
+			Template: tmpl,
+		})
+	}
+
+	if slackURL := os.Getenv("NOTIFY_SLACK_WEBHOOK_URL"); slackURL != "" {
+		notifiers = append(notifiers, &SlackNotifier{WebhookURL: slackURL, Client: client, Template: tmpl})
+	}
+
+	return notifiers, nil
+}
+
+// notifyTopNFromEnv 讀取通知列出名次數量
+func notifyTopNFromEnv() int {
+	if n, err := strconv.Atoi(os.Getenv("NOTIFY_TOP_N")); err == nil && n > 0 {
+		return n
+	}
+	return defaultNotifyTopN
+}
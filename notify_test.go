@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDigest 測試用篩選摘要
+func testDigest() *ScreeningDigest {
+	stock := &StockData{Code: "2330", Name: "台積電", Score: 88.5, ROE: 28.1, EPSGrowth: 120, Price: 1000}
+	return &ScreeningDigest{
+		RunTime:        time.Date(2026, 3, 4, 17, 0, 0, 0, taipeiLocation),
+		TotalQualified: 1,
+		NewQualifiers:  []*StockData{stock},
+		TopStocks:      []*StockData{stock},
+	}
+}
+
+// capturedRequest 本地 HTTP 替身收到的請求
+type capturedRequest struct {
+	header http.Header
+	body   string
+}
+
+// newHTTPStandIn 記錄收到的請求並回傳指定狀態碼的本地 HTTP 服務
+func newHTTPStandIn(t *testing.T, status int) (*httptest.Server, <-chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case requests <- capturedRequest{header: r.Header.Clone(), body: string(body)}:
+		default: // 只保留第一個請求
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	server, requests := newHTTPStandIn(t, http.StatusOK)
+	n := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+	if err := n.Notify(testDigest()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var payload struct {
+		TotalQualified int    `json:"total_qualified"`
+		Message        string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.TotalQualified != 1 || !strings.Contains(payload.Message, "2330 台積電 評分 88.5") {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestLineNotifier(t *testing.T) {
+	server, requests := newHTTPStandIn(t, http.StatusOK)
+	n := &LineNotifier{URL: server.URL, Token: "secret", Client: server.Client()}
+	if err := n.Notify(testDigest()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if auth := req.header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	form, err := url.ParseQuery(req.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := form.Get("message"); !strings.HasPrefix(msg, "\n台股篩選結果 2026-03-04 17:00") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server, requests := newHTTPStandIn(t, http.StatusOK)
+	tmpl, err := ParseDigestTemplate("{{.TotalQualified}} 檔")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &SlackNotifier{WebhookURL: server.URL, Client: server.Client(), Template: tmpl}
+	if err := n.Notify(testDigest()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if req.body != `{"text":"1 檔"}` {
+		t.Errorf("body = %s", req.body)
+	}
+}
+
+func TestHTTPNotifierErrorStatus(t *testing.T) {
+	server, _ := newHTTPStandIn(t, http.StatusInternalServerError)
+	notifiers := []Notifier{
+		&WebhookNotifier{URL: server.URL, Client: server.Client()},
+		&LineNotifier{URL: server.URL, Client: server.Client()},
+		&SlackNotifier{WebhookURL: server.URL, Client: server.Client()},
+	}
+	for _, n := range notifiers {
+		if err := n.Notify(testDigest()); err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("%s: err = %v, want 狀態碼 500", n.Name(), err)
+		}
+	}
+}
+
+// smtpMessage 本地 SMTP 替身收到的郵件
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// newSMTPStandIn 接受一封郵件的本地 SMTP 服務 (不支援 STARTTLS 及驗證)
+func newSMTPStandIn(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var msg smtpMessage
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.from = strings.Trim(strings.Fields(line[len("MAIL FROM:"):])[0], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				msg.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				messages <- msg
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestEmailNotifier(t *testing.T) {
+	addr, messages := newSMTPStandIn(t)
+	n := &EmailNotifier{Addr: addr, From: "screener@example.com", To: []string{"a@example.com", "b@example.com"}}
+	if err := n.Notify(testDigest()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.from != "screener@example.com" {
+			t.Errorf("from = %q", msg.from)
+		}
+		if strings.Join(msg.to, ",") != "a@example.com,b@example.com" {
+			t.Errorf("to = %v", msg.to)
+		}
+		for _, want := range []string{"To: a@example.com, b@example.com\r\n", "Subject: =?UTF-8?b?",
+			"Content-Type: text/plain; charset=UTF-8\r\n", "2330 台積電 評分 88.5"} {
+			if !strings.Contains(msg.data, want) {
+				t.Errorf("郵件內容缺少 %q:\n%s", want, msg.data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP 替身未收到郵件")
+	}
+}
+
+func TestNotifiersFromEnvRecipients(t *testing.T) {
+	t.Setenv("NOTIFY_SMTP_ADDR", "127.0.0.1:25")
+
+	tests := []struct {
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, true},
+		{" , ", nil, true},
+		{"not-an-address", nil, true},
+		{"a@example.com", []string{"a@example.com"}, false},
+		{" a@example.com, ,王小明 <b@example.com> ", []string{"a@example.com", "b@example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NOTIFY_EMAIL_TO", tt.to)
+		notifiers, err := NotifiersFromEnv(http.DefaultClient)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NOTIFY_EMAIL_TO=%q: 應回傳錯誤", tt.to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NOTIFY_EMAIL_TO=%q: %v", tt.to, err)
+			continue
+		}
+		email := notifiers[0].(*EmailNotifier)
+		if strings.Join(email.To, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("NOTIFY_EMAIL_TO=%q: To = %v, want %v", tt.to, email.To, tt.want)
+		}
+	}
+}
+
+func TestNotifyClientSeparateFromDataSources(t *testing.T) {
+	t.Setenv("NOTIFY_SMTP_ADDR", "")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/screen")
+	screener := NewStockScreener()
+
+	notifiers, err := NotifiersFromEnv(newNotifyClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook := notifiers[0].(*WebhookNotifier)
+	if webhook.Client == screener.client {
+		t.Error("通知不應共用資料來源的HTTP客戶端")
+	}
+	if _, ok := webhook.Client.Transport.(*RetryTransport); ok || webhook.Client.Timeout != notifyTimeout {
+		t.Errorf("Transport = %T, Timeout = %v, want 預設傳輸層及 %v", webhook.Client.Transport, webhook.Client.Timeout, notifyTimeout)
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 權重配置方式
+const (
+	WeightEqual      = "equal"       // 等權重
+	WeightScore      = "score"       // 依評分加權
+	WeightInverseVol = "inverse_vol" // 波動率倒數加權
+	WeightRiskParity = "risk_parity" // 風險平價
+)
+
+// boardLotShares 台股一張(整股)股數
+const boardLotShares = 1000
+
+// 預設投組設定
+const (
+	defaultPortfolioCapital   = 1000000.0
+	defaultMaxStockWeight     = 0.20
+	defaultMaxIndustryWeight  = 0.40
+	riskParityMaxIterations   = 500
+	riskParityTolerance       = 1e-8
+	constraintMaxIterations   = 50
+	constraintWeightTolerance = 1e-9
+)
+
+// PortfolioConstraints 投組限制條件
+type PortfolioConstraints struct {
+	MaxWeight         float64 // 單一個股權重上限 (0-1)
+	MaxIndustryWeight float64 // 單一產業權重上限 (0-1)
+	AllowOddLot       bool    // 整股之外允許以零股補足
+}
+
+// Position 建議持股部位
+type Position struct {
+	Code         string  `json:"code"`
+	Name         string  `json:"name"`
+	Industry     string  `json:"industry"`
+	Price        float64 `json:"price"`
+	TargetWeight float64 `json:"target_weight"`
+	Lots         int     `json:"lots"`       // 整股張數
+	OddShares    int     `json:"odd_shares"` // 零股股數
+	Shares       int     `json:"shares"`     // 總股數
+	Cost         float64 `json:"cost"`
+	Weight       float64 `json:"weight"` // 依實際股數計算之權重
+}
+
+// PortfolioPlan 投組建議
+type PortfolioPlan struct {
+	Scheme    string     `json:"scheme"`
+	Capital   float64    `json:"capital"`
+	Positions []Position `json:"positions"`
+	Invested  float64    `json:"invested"`
+	Cash      float64    `json:"cash"`
+}
+
+// PortfolioBuilder 投組建構器
+type PortfolioBuilder struct {
+	Capital     float64
+	Scheme      string
+	Constraints PortfolioConstraints
+}
+
+// NewPortfolioBuilder 建立預設條件的投組建構器
+func NewPortfolioBuilder(capital float64) *PortfolioBuilder {
+	return &PortfolioBuilder{
+		Capital: capital,
+		Scheme:  WeightEqual,
+		Constraints: PortfolioConstraints{
+			MaxWeight:         defaultMaxStockWeight,
+			MaxIndustryWeight: defaultMaxIndustryWeight,
+			AllowOddLot:       false,
+		},
+	}
+}
+
+// Build 根據篩選結果產生投組建議
+func (b *PortfolioBuilder) Build(stocks []*StockData) (*PortfolioPlan, error) {
+	if b.Capital <= 0 {
+		return nil, fmt.Errorf("投入資金必須大於零")
+	}
+
+	var candidates []*StockData
+	for _, stock := range stocks {
+		if stock.Price > 0 {
+			candidates = append(candidates, stock)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("沒有可配置的股票 (缺少股價)")
+	}
+
+	var weights []float64
+	switch b.Scheme {
+	case WeightEqual, "":
+		weights = equalWeights(len(candidates))
+	case WeightScore:
+		weights = scoreWeights(candidates)
+	case WeightInverseVol:
+		weights = inverseVolatilityWeights(candidates)
+	case WeightRiskParity:
+		weights = riskParityWeights(candidates)
+	default:
+		return nil, fmt.Errorf("未知的權重配置方式: %s", b.Scheme)
+	}
+
+	weights = b.applyConstraints(candidates, weights)
+
+	plan := &PortfolioPlan{Scheme: b.Scheme, Capital: b.Capital}
+	for i, stock := range candidates {
+		if weights[i] <= 0 {
+			continue
+		}
+
+		position := Position{
+			Code:         stock.Code,
+			Name:         stock.Name,
+			Industry:     industryOf(stock),
+			Price:        stock.Price,
+			TargetWeight: weights[i],
+		}
+
+		// 先買整股，剩餘金額視設定以零股補足
+		amount := b.Capital * weights[i]
+		position.Lots = int(amount / (stock.Price * boardLotShares))
+		if b.Constraints.AllowOddLot {
+			remaining := amount - float64(position.Lots*boardLotShares)*stock.Price
+			position.OddShares = int(remaining / stock.Price)
+		}
+		position.Shares = position.Lots*boardLotShares + position.OddShares
+		position.Cost = float64(position.Shares) * stock.Price
+		position.Weight = position.Cost / b.Capital
+
+		plan.Positions = append(plan.Positions, position)
+		plan.Invested += position.Cost
+	}
+	plan.Cash = b.Capital - plan.Invested
+
+	sort.SliceStable(plan.Positions, func(i, j int) bool {
+		return plan.Positions[i].TargetWeight > plan.Positions[j].TargetWeight
+	})
+
+	return plan, nil
+}
+
+// applyConstraints 套用個股及產業權重上限，超出部分重新分配給未達上限的股票
+func (b *PortfolioBuilder) applyConstraints(stocks []*StockData, weights []float64) []float64 {
+	w := make([]float64, len(weights))
+	copy(w, weights)
+
+	maxWeight := b.Constraints.MaxWeight
+	if maxWeight <= 0 {
+		maxWeight = 1
+	}
+	maxIndustry := b.Constraints.MaxIndustryWeight
+	if maxIndustry <= 0 {
+		maxIndustry = 1
+	}
+
+	industries := make([]string, len(stocks))
+	for i, stock := range stocks {
+		industries[i] = industryOf(stock)
+	}
+
+	capped := make([]bool, len(w))
+	for iter := 0; iter < constraintMaxIterations; iter++ {
+		excess := 0.0
+
+		// 個股上限
+		for i := range w {
+			if w[i] > maxWeight+constraintWeightTolerance {
+				excess += w[i] - maxWeight
+				w[i] = maxWeight
+				capped[i] = true
+			}
+		}
+
+		// 產業上限
+		industryWeight := make(map[string]float64)
+		for i := range w {
+			industryWeight[industries[i]] += w[i]
+		}
+		for industry, total := range industryWeight {
+			if total <= maxIndustry+constraintWeightTolerance {
+				continue
+			}
+			scale := maxIndustry / total
+			for i := range w {
+				if industries[i] == industry {
+					excess += w[i] * (1 - scale)
+					w[i] *= scale
+					capped[i] = true
+				}
+			}
+		}
+
+		if excess <= constraintWeightTolerance {
+			break
+		}
+
+		// 依原權重比例分配給尚未受限的股票，若全數受限則保留為現金
+		free := 0.0
+		for i := range w {
+			if !capped[i] {
+				free += w[i]
+			}
+		}
+		if free <= 0 {
+			break
+		}
+		for i := range w {
+			if !capped[i] {
+				w[i] += excess * w[i] / free
+			}
+		}
+	}
+
+	return w
+}
+
+// equalWeights 等權重
+func equalWeights(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1 / float64(n)
+	}
+	return w
+}
+
+// scoreWeights 依評分比例配置，評分皆為零時退回等權重
+func scoreWeights(stocks []*StockData) []float64 {
+	w := make([]float64, len(stocks))
+	total := 0.0
+	for i, stock := range stocks {
+		w[i] = math.Max(stock.Score, 0)
+		total += w[i]
+	}
+	if total == 0 {
+		return equalWeights(len(stocks))
+	}
+	for i := range w {
+		w[i] /= total
+	}
+	return w
+}
+
+// stockVolatility 取得年化波動率，缺資料時回傳0
+func stockVolatility(stock *StockData) float64 {
+	if stock.Volatility > 0 {
+		return stock.Volatility
+	}
+	return CalculateVolatility(stock.closes)
+}
+
+// inverseVolatilityWeights 依波動率倒數配置，缺少波動率者以其他股票平均值代替
+func inverseVolatilityWeights(stocks []*StockData) []float64 {
+	vols := make([]float64, len(stocks))
+	sum, count := 0.0, 0
+	for i, stock := range stocks {
+		vols[i] = stockVolatility(stock)
+		if vols[i] > 0 {
+			sum += vols[i]
+			count++
+		}
+	}
+	if count == 0 {
+		return equalWeights(len(stocks))
+	}
+
+	avgVol := sum / float64(count)
+	w := make([]float64, len(stocks))
+	total := 0.0
+	for i := range vols {
+		if vols[i] <= 0 {
+			vols[i] = avgVol
+		}
+		w[i] = 1 / vols[i]
+		total += w[i]
+	}
+	for i := range w {
+		w[i] /= total
+	}
+	return w
+}
+
+// riskParityWeights 風險平價: 使每檔股票對投組變異數的貢獻相等
+func riskParityWeights(stocks []*StockData) []float64 {
+	cov, ok := returnCovariance(stocks)
+	if !ok {
+		// 價格資料不足以估計共變異數時，等同假設零相關的波動率倒數
+		return inverseVolatilityWeights(stocks)
+	}
+
+	n := len(stocks)
+	w := inverseVolatilityWeights(stocks)
+	for iter := 0; iter < riskParityMaxIterations; iter++ {
+		// 邊際風險 (Σw)_i 與投組變異數
+		marginal := make([]float64, n)
+		variance := 0.0
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				marginal[i] += cov[i][j] * w[j]
+			}
+			variance += w[i] * marginal[i]
+		}
+		if variance <= 0 {
+			break
+		}
+
+		target := variance / float64(n)
+		maxDiff := 0.0
+		total := 0.0
+		for i := 0; i < n; i++ {
+			contribution := w[i] * marginal[i]
+			maxDiff = math.Max(maxDiff, math.Abs(contribution-target))
+			if contribution > 0 {
+				w[i] *= math.Sqrt(target / contribution)
+			}
+			total += w[i]
+		}
+		for i := range w {
+			w[i] /= total
+		}
+
+		if maxDiff < riskParityTolerance {
+			break
+		}
+	}
+	return w
+}
+
+// stockBars 計算共變異數使用的K棒，有還原權息價格時優先使用
+func stockBars(stock *StockData) []Bar {
+	if len(stock.adjBars) > 0 {
+		return stock.adjBars
+	}
+	return stock.bars
+}
+
+// returnCovariance 以日期對齊的報酬率計算共變異數矩陣，每對股票只使用兩者皆有價格的交易日
+// 任一對股票的共同報酬率期數不足 minRiskReturns 時 ok 為 false
+func returnCovariance(stocks []*StockData) ([][]float64, bool) {
+	if len(stocks) < 2 {
+		return nil, false
+	}
+
+	cov := make([][]float64, len(stocks))
+	for i := range cov {
+		cov[i] = make([]float64, len(stocks))
+	}
+	for i := range stocks {
+		for j := i; j < len(stocks); j++ {
+			ri, rj := alignedReturns(stockBars(stocks[i]), stockBars(stocks[j]))
+			if len(ri) < minRiskReturns {
+				return nil, false
+			}
+			cov[i][j] = covariance(ri, rj)
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return cov, true
+}
+
+// industryOf 取得股票產業，無產業分類的股票歸入 "未分類"
+func industryOf(stock *StockData) string {
+	return industryLabel(stock.Industry)
+}
+
+// PrintPortfolioPlan 輸出投組建議
+func PrintPortfolioPlan(plan *PortfolioPlan) {
+	fmt.Printf("\n【投組建構建議】資金 %.0f 元 | 權重方式: %s\n", plan.Capital, plan.Scheme)
+	for i, p := range plan.Positions {
+		fmt.Printf("%d. %s (%s) [%s] 目標 %.1f%% → %d 張", i+1, p.Name, p.Code, p.Industry, p.TargetWeight*100, p.Lots)
+		if p.OddShares > 0 {
+			fmt.Printf(" + %d 股零股", p.OddShares)
+		}
+		fmt.Printf(" @ %.2f = %.0f 元 (實際 %.1f%%)\n", p.Price, p.Cost, p.Weight*100)
+	}
+	fmt.Printf("投入金額: %.0f 元 | 剩餘現金: %.0f 元 (未含手續費及交易稅)\n", plan.Invested, plan.Cash)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// approxEqual 浮點數比較
+func approxEqual(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+// assertWeights 比較權重向量
+func assertWeights(t *testing.T, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("weights = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !approxEqual(got[i], want[i], 1e-6) {
+			t.Fatalf("weights = %v, want %v", got, want)
+		}
+	}
+}
+
+// dailyBars 自 2026-01-05 起逐個交易日 (週一至週五) 的K棒，收盤價依序為 closes
+func dailyBars(closes ...float64) []Bar {
+	bars := make([]Bar, 0, len(closes))
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, taipeiLocation)
+	for _, c := range closes {
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		bars = append(bars, Bar{Time: day, Open: c, High: c, Low: c, Close: c})
+		day = day.AddDate(0, 0, 1)
+	}
+	return bars
+}
+
+// zigzag 以固定幅度交替漲跌的收盤價序列，phase 決定起始方向
+func zigzag(n int, step float64, phase int) []float64 {
+	closes := make([]float64, n)
+	price := 100.0
+	for i := range closes {
+		if (i+phase)%2 == 0 {
+			price *= 1 + step
+		} else {
+			price /= 1 + step
+		}
+		closes[i] = price
+	}
+	return closes
+}
+
+func TestSimpleWeights(t *testing.T) {
+	assertWeights(t, equalWeights(4), []float64{0.25, 0.25, 0.25, 0.25})
+
+	stocks := []*StockData{{Score: 60}, {Score: 30}, {Score: -10}, {Score: 10}}
+	assertWeights(t, scoreWeights(stocks), []float64{0.6, 0.3, 0, 0.1})
+	assertWeights(t, scoreWeights([]*StockData{{}, {}}), []float64{0.5, 0.5})
+
+	// 缺少波動率者以平均值 (0.3) 代替：1/0.2 : 1/0.4 : 1/0.3 = 6 : 3 : 4
+	vols := []*StockData{{Volatility: 0.2}, {Volatility: 0.4}, {}}
+	assertWeights(t, inverseVolatilityWeights(vols), []float64{6.0 / 13, 3.0 / 13, 4.0 / 13})
+	assertWeights(t, inverseVolatilityWeights([]*StockData{{}, {}}), []float64{0.5, 0.5})
+}
+
+func TestApplyConstraints(t *testing.T) {
+	tests := []struct {
+		name        string
+		stocks      []*StockData
+		weights     []float64
+		maxWeight   float64
+		maxIndustry float64
+		want        []float64
+	}{
+		{
+			name:      "個股上限逐步重新分配",
+			stocks:    []*StockData{{Industry: "A"}, {Industry: "B"}, {Industry: "C"}},
+			weights:   []float64{0.6, 0.3, 0.1},
+			maxWeight: 0.4,
+			want:      []float64{0.4, 0.4, 0.2},
+		},
+		{
+			name:        "產業上限等比例縮減",
+			stocks:      []*StockData{{Industry: "半導體業"}, {Industry: "半導體業"}, {Industry: "航運業"}, {Industry: "金融保險業"}},
+			weights:     []float64{0.3, 0.3, 0.2, 0.2},
+			maxIndustry: 0.4,
+			want:        []float64{0.2, 0.2, 0.3, 0.3},
+		},
+		{
+			name:      "全數受限時保留現金",
+			stocks:    []*StockData{{Industry: "A"}, {Industry: "B"}},
+			weights:   []float64{0.5, 0.5},
+			maxWeight: 0.3,
+			want:      []float64{0.3, 0.3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &PortfolioBuilder{Constraints: PortfolioConstraints{MaxWeight: tt.maxWeight, MaxIndustryWeight: tt.maxIndustry}}
+			assertWeights(t, b.applyConstraints(tt.stocks, tt.weights), tt.want)
+		})
+	}
+}
+
+func TestBuildLots(t *testing.T) {
+	stocks := []*StockData{
+		{Code: "A", Industry: "A", Price: 100},
+		{Code: "B", Industry: "B", Price: 300},
+		{Code: "C", Industry: "C"}, // 無股價不配置
+	}
+	b := NewPortfolioBuilder(1000000)
+	b.Constraints.MaxWeight, b.Constraints.MaxIndustryWeight = 1, 1
+	b.Constraints.AllowOddLot = true
+	plan, err := b.Build(stocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Positions) != 2 {
+		t.Fatalf("positions = %+v", plan.Positions)
+	}
+	// 各 500000 元: A 5張；B 1張 (300000) + 666股零股 (199800)
+	a, bb := plan.Positions[0], plan.Positions[1]
+	if a.Lots != 5 || a.OddShares != 0 || a.Cost != 500000 {
+		t.Errorf("A = %+v", a)
+	}
+	if bb.Lots != 1 || bb.OddShares != 666 || bb.Shares != 1666 || bb.Cost != 499800 {
+		t.Errorf("B = %+v", bb)
+	}
+	if plan.Cash != 200 {
+		t.Errorf("cash = %v, want 200", plan.Cash)
+	}
+}
+
+func TestReturnCovarianceAlignsByDate(t *testing.T) {
+	a := dailyBars(zigzag(40, 0.02, 0)...)
+	b := dailyBars(zigzag(40, 0.01, 1)...)
+
+	// 停牌: B 缺少第10-14根K棒，應只以共同交易日計算，結果與對齊後的序列相同
+	suspended := append(append([]Bar{}, b[:10]...), b[15:]...)
+	ra, rb := alignedReturns(a, suspended)
+	if len(ra) != 34 {
+		t.Fatalf("aligned returns = %d, want 34", len(ra))
+	}
+	cov, ok := returnCovariance([]*StockData{{bars: a}, {bars: suspended}})
+	if !ok {
+		t.Fatal("共同期間足夠時應可計算")
+	}
+	if want := covariance(ra, rb); !approxEqual(cov[0][1], want, 1e-15) || cov[0][1] != cov[1][0] {
+		t.Errorf("cov = %v, want %v", cov, want)
+	}
+	if cov[0][1] >= 0 {
+		t.Errorf("反向漲跌的共變異數應為負: %v", cov[0][1])
+	}
+
+	// 上市較晚: 只使用重疊期間，不以尾端長度錯位對齊
+	late := dailyBars(zigzag(40, 0.01, 1)...)[15:]
+	cov, ok = returnCovariance([]*StockData{{bars: a}, {bars: late}})
+	if !ok || cov[0][1] >= 0 {
+		t.Errorf("cov = %v, ok = %v", cov, ok)
+	}
+
+	// 共同報酬率不足 minRiskReturns
+	if _, ok := returnCovariance([]*StockData{{bars: a}, {bars: b[:minRiskReturns]}}); ok {
+		t.Error("共同期間不足時應無法計算")
+	}
+}
+
+func TestRiskParityWeights(t *testing.T) {
+	// 日報酬波動 2% 與 1%，兩者相關性低
+	a := dailyBars(zigzag(41, 0.02, 0)...)
+	b := make([]Bar, len(a))
+	copy(b, a)
+	closes := zigzag(41, 0.01, 0)
+	for i := range b {
+		// 漲跌節奏為 ++-- 對 A 的 +-+-
+		c := closes[i]
+		if i%4 == 1 || i%4 == 2 {
+			c = closes[i] * 1.01 * 1.01
+		}
+		b[i].Close = c
+	}
+	stocks := []*StockData{{bars: a}, {bars: b}}
+	cov, ok := returnCovariance(stocks)
+	if !ok {
+		t.Fatal("應可計算共變異數")
+	}
+	w := riskParityWeights(stocks)
+	// 各股風險貢獻相等
+	rc := make([]float64, 2)
+	for i := range rc {
+		for j := range rc {
+			rc[i] += w[i] * cov[i][j] * w[j]
+		}
+	}
+	if !approxEqual(rc[0]/rc[1], 1, 1e-4) || !approxEqual(w[0]+w[1], 1, 1e-12) {
+		t.Errorf("weights = %v, risk contributions = %v", w, rc)
+	}
+	if w[0] >= w[1] {
+		t.Errorf("波動較大者權重應較低: %v", w)
+	}
+}
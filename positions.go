@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultLedgerFile 持股交易紀錄檔
+const defaultLedgerFile = "holdings.json"
+
+// 台股交易成本
+const (
+	brokerFeeRate      = 0.001425 // 券商手續費率
+	minBrokerFee       = 20.0     // 整股最低手續費
+	minOddLotBrokerFee = 1.0      // 零股最低手續費
+	stockTaxRate       = 0.003    // 股票證券交易稅 (賣出時課徵)
+	etfTaxRate         = 0.001    // ETF證券交易稅
+)
+
+// 交易方向
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// Transaction 買賣交易紀錄
+type Transaction struct {
+	Date   string   `json:"date"`
+	Code   string   `json:"code"`
+	Name   string   `json:"name,omitempty"`
+	Side   string   `json:"side"`
+	Shares int      `json:"shares"`
+	Price  float64  `json:"price"`
+	Fee    *float64 `json:"fee,omitempty"` // 未填寫時依費率計算，可填0表示免手續費
+	Tax    *float64 `json:"tax,omitempty"` // 未填寫時依稅率計算
+}
+
+// Ledger 持股帳本
+type Ledger struct {
+	FeeDiscount  float64            `json:"fee_discount,omitempty"` // 手續費折扣，如 0.6 表示六折
+	Transactions []Transaction      `json:"transactions"`
+	HighWater    map[string]float64 `json:"high_water"` // 持有期間最高價，以 代碼@建倉日 為鍵，供移動停利使用
+
+	path string
+}
+
+// Holding 目前持股
+type Holding struct {
+	Code        string  `json:"code"`
+	Name        string  `json:"name"`
+	Shares      int     `json:"shares"`
+	CostBasis   float64 `json:"cost_basis"` // 含買進手續費之持有成本
+	AvgCost     float64 `json:"avg_cost"`
+	RealizedPnL float64 `json:"realized_pnl"`
+	FirstBuy    string  `json:"first_buy"`
+}
+
+// LoadLedger 讀取持股帳本，檔案不存在時回傳空帳本
+func LoadLedger(filename string) (*Ledger, error) {
+	ledger := &Ledger{HighWater: make(map[string]float64), path: filename}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("解析持股帳本失敗: %v", err)
+	}
+	if ledger.HighWater == nil {
+		ledger.HighWater = make(map[string]float64)
+	}
+
+	for _, tx := range ledger.Transactions {
+		if tx.Side != SideBuy && tx.Side != SideSell {
+			return nil, fmt.Errorf("交易紀錄 %s %s 方向錯誤: %s", tx.Date, tx.Code, tx.Side)
+		}
+	}
+
+	return ledger, nil
+}
+
+// AddTransaction 新增交易紀錄，未填寫的手續費及交易稅於計算損益時依費率補算，不寫入帳本
+func (l *Ledger) AddTransaction(tx Transaction) error {
+	if tx.Side != SideBuy && tx.Side != SideSell {
+		return fmt.Errorf("交易方向錯誤: %s (應為 %s 或 %s)", tx.Side, SideBuy, SideSell)
+	}
+	if _, err := time.Parse("2006-01-02", tx.Date); err != nil {
+		return fmt.Errorf("交易日期格式錯誤 %q (應為 YYYY-MM-DD)", tx.Date)
+	}
+	if tx.Shares <= 0 || tx.Price <= 0 {
+		return fmt.Errorf("股數及價格必須大於零")
+	}
+	if (tx.Fee != nil && *tx.Fee < 0) || (tx.Tax != nil && *tx.Tax < 0) {
+		return fmt.Errorf("手續費及交易稅不可為負數")
+	}
+	if tx.Side == SideSell {
+		holdings, err := l.Holdings()
+		if err != nil {
+			return err
+		}
+		if held := holdings[tx.Code]; held == nil || held.Shares < tx.Shares {
+			return fmt.Errorf("賣出股數超過持有股數: %s", tx.Code)
+		}
+	}
+
+	l.Transactions = append(l.Transactions, tx)
+	return nil
+}
+
+// Holdings 依交易紀錄以平均成本法計算目前持股
+func (l *Ledger) Holdings() (map[string]*Holding, error) {
+	txs := make([]Transaction, len(l.Transactions))
+	copy(txs, l.Transactions)
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Date < txs[j].Date })
+
+	holdings := make(map[string]*Holding)
+	for _, tx := range txs {
+		h := holdings[tx.Code]
+		if h == nil {
+			h = &Holding{Code: tx.Code}
+			holdings[tx.Code] = h
+		}
+		if tx.Name != "" {
+			h.Name = tx.Name
+		}
+
+		amount := float64(tx.Shares) * tx.Price
+		switch tx.Side {
+		case SideBuy:
+			if h.Shares == 0 {
+				h.FirstBuy = tx.Date
+			}
+			h.Shares += tx.Shares
+			h.CostBasis += amount + l.transactionFee(tx)
+		case SideSell:
+			if tx.Shares > h.Shares {
+				return nil, fmt.Errorf("%s %s 賣出股數 %d 超過持有股數 %d", tx.Date, tx.Code, tx.Shares, h.Shares)
+			}
+			soldCost := h.CostBasis * float64(tx.Shares) / float64(h.Shares)
+			h.RealizedPnL += amount - l.transactionFee(tx) - transactionTaxPaid(tx) - soldCost
+			h.CostBasis -= soldCost
+			h.Shares -= tx.Shares
+		}
+
+		if h.Shares > 0 {
+			h.AvgCost = h.CostBasis / float64(h.Shares)
+		} else {
+			h.AvgCost = 0
+			h.CostBasis = 0
+		}
+	}
+
+	return holdings, nil
+}
+
+// Codes 取得目前持有的股票代碼
+func (l *Ledger) Codes() ([]string, error) {
+	holdings, err := l.Holdings()
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	for code, h := range holdings {
+		if h.Shares > 0 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// Save 儲存持股帳本
+func (l *Ledger) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, data, 0644)
+}
+
+// transactionFee 交易的手續費，未填寫時依費率計算
+func (l *Ledger) transactionFee(tx Transaction) float64 {
+	if tx.Fee != nil {
+		return *tx.Fee
+	}
+	return l.brokerFee(tx.Shares, tx.Price)
+}
+
+// transactionTaxPaid 交易的證券交易稅，未填寫時賣出依稅率計算，買進為零
+func transactionTaxPaid(tx Transaction) float64 {
+	if tx.Tax != nil {
+		return *tx.Tax
+	}
+	if tx.Side != SideSell {
+		return 0
+	}
+	return transactionTax(tx.Code, tx.Shares, tx.Price)
+}
+
+// brokerFee 計算券商手續費
+func (l *Ledger) brokerFee(shares int, price float64) float64 {
+	discount := l.FeeDiscount
+	if discount <= 0 {
+		discount = 1
+	}
+
+	fee := math.Floor(float64(shares) * price * brokerFeeRate * discount)
+	minimum := minBrokerFee
+	if shares < boardLotShares {
+		minimum = minOddLotBrokerFee
+	}
+	return math.Max(fee, minimum)
+}
+
+// transactionTax 計算賣出時的證券交易稅，ETF (00開頭) 適用較低稅率
+func transactionTax(code string, shares int, price float64) float64 {
+	rate := stockTaxRate
+	if strings.HasPrefix(code, "00") {
+		rate = etfTaxRate
+	}
+	return math.Floor(float64(shares) * price * rate)
+}
+
+// PositionRules 停損停利規則 (百分比)
+type PositionRules struct {
+	StopLossPct       float64 // 自平均成本下跌達此幅度停損
+	TrailingStopPct   float64 // 自持有期間高點回落達此幅度出場，0表示不啟用
+	TakeProfitPct     float64 // 自平均成本上漲達此幅度獲利了結
+	TakeProfitSellPct float64 // 獲利了結時賣出比例
+}
+
+// DefaultPositionRules 預設規則: 停損-10%，獲利20%先出場一半
+func DefaultPositionRules() PositionRules {
+	return PositionRules{
+		StopLossPct:       10.0,
+		TrailingStopPct:   15.0,
+		TakeProfitPct:     20.0,
+		TakeProfitSellPct: 50.0,
+	}
+}
+
+// PositionAction 觸發的操作建議
+type PositionAction struct {
+	Rule         string `json:"rule"`
+	SharesToSell int    `json:"shares_to_sell"`
+	Message      string `json:"message"`
+}
+
+// HoldingStatus 單一持股的評估結果
+type HoldingStatus struct {
+	Holding
+	Price            float64          `json:"price"`
+	HighWater        float64          `json:"high_water"`
+	MarketValue      float64          `json:"market_value"`
+	UnrealizedPnL    float64          `json:"unrealized_pnl"` // 已扣除預估賣出手續費及交易稅
+	UnrealizedPnLPct float64          `json:"unrealized_pnl_pct"`
+	Actions          []PositionAction `json:"actions"`
+}
+
+// PositionReport 持股監控報告
+type PositionReport struct {
+	Holdings      []HoldingStatus `json:"holdings"`
+	CostBasis     float64         `json:"cost_basis"`
+	MarketValue   float64         `json:"market_value"`
+	UnrealizedPnL float64         `json:"unrealized_pnl"`
+	RealizedPnL   float64         `json:"realized_pnl"`
+	MissingPrices []string        `json:"missing_prices"`
+}
+
+// MonitorPositions 以最新價格評估持股並更新持有期間高點
+func (l *Ledger) MonitorPositions(prices map[string]float64, rules PositionRules) (*PositionReport, error) {
+	holdings, err := l.Holdings()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PositionReport{}
+	codes := make([]string, 0, len(holdings))
+	for code := range holdings {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		h := holdings[code]
+		report.RealizedPnL += h.RealizedPnL
+
+		// 清除已出場部位的高點 (含舊版以代碼為鍵的紀錄)，出場後重新建倉時自成本重新起算
+		key := highWaterKey(code, h.FirstBuy)
+		for k := range l.HighWater {
+			if (k == code || strings.HasPrefix(k, code+"@")) && (h.Shares == 0 || k != key) {
+				delete(l.HighWater, k)
+			}
+		}
+		if h.Shares == 0 {
+			continue
+		}
+
+		price, ok := prices[code]
+		if !ok || price <= 0 {
+			report.MissingPrices = append(report.MissingPrices, code)
+			continue
+		}
+
+		highWater := math.Max(l.HighWater[key], math.Max(price, h.AvgCost))
+		l.HighWater[key] = highWater
+
+		status := HoldingStatus{
+			Holding:     *h,
+			Price:       price,
+			HighWater:   highWater,
+			MarketValue: float64(h.Shares) * price,
+		}
+		sellCost := l.brokerFee(h.Shares, price) + transactionTax(code, h.Shares, price)
+		status.UnrealizedPnL = status.MarketValue - sellCost - h.CostBasis
+		status.UnrealizedPnLPct = status.UnrealizedPnL / h.CostBasis * 100
+		status.Actions = evaluatePositionRules(h, price, highWater, rules)
+
+		report.Holdings = append(report.Holdings, status)
+		report.CostBasis += h.CostBasis
+		report.MarketValue += status.MarketValue
+		report.UnrealizedPnL += status.UnrealizedPnL
+	}
+
+	return report, nil
+}
+
+// highWaterKey 持有期間高點的鍵，同一股票出場後重新建倉視為不同部位
+func highWaterKey(code, firstBuy string) string {
+	return code + "@" + firstBuy
+}
+
+// evaluatePositionRules 檢查停損、移動停利及獲利了結條件
+func evaluatePositionRules(h *Holding, price, highWater float64, rules PositionRules) []PositionAction {
+	var actions []PositionAction
+	change := (price - h.AvgCost) / h.AvgCost * 100
+
+	if rules.StopLossPct > 0 && change <= -rules.StopLossPct {
+		actions = append(actions, PositionAction{
+			Rule:         "stop_loss",
+			SharesToSell: h.Shares,
+			Message:      fmt.Sprintf("跌幅 %.1f%% 達停損 -%.1f%%，建議全數出場", change, rules.StopLossPct),
+		})
+		return actions
+	}
+
+	if rules.TrailingStopPct > 0 && highWater > h.AvgCost {
+		drawdown := (highWater - price) / highWater * 100
+		if drawdown >= rules.TrailingStopPct {
+			actions = append(actions, PositionAction{
+				Rule:         "trailing_stop",
+				SharesToSell: h.Shares,
+				Message:      fmt.Sprintf("自高點 %.2f 回落 %.1f%% 達移動停利 %.1f%%，建議出場", highWater, drawdown, rules.TrailingStopPct),
+			})
+			return actions
+		}
+	}
+
+	if rules.TakeProfitPct > 0 && change >= rules.TakeProfitPct {
+		sellShares := int(float64(h.Shares) * rules.TakeProfitSellPct / 100)
+		// 賣出股數盡量以整張為單位
+		if sellShares >= boardLotShares {
+			sellShares -= sellShares % boardLotShares
+		}
+		actions = append(actions, PositionAction{
+			Rule:         "take_profit",
+			SharesToSell: sellShares,
+			Message:      fmt.Sprintf("漲幅 %.1f%% 達獲利目標 %.1f%%，建議賣出 %d 股", change, rules.TakeProfitPct, sellShares),
+		})
+	}
+
+	return actions
+}
+
+// PrintPositionReport 輸出持股監控報告
+func PrintPositionReport(report *PositionReport) {
+	fmt.Println("\n========== 持股監控 ==========")
+	if len(report.Holdings) == 0 && len(report.MissingPrices) == 0 {
+		fmt.Println("目前沒有持股")
+		return
+	}
+
+	for _, h := range report.Holdings {
+		fmt.Printf("%s %s: %d 股 | 均價 %.2f | 現價 %.2f | 未實現損益 %.0f 元 (%.1f%%)\n",
+			h.Code, h.Name, h.Shares, h.AvgCost, h.Price, h.UnrealizedPnL, h.UnrealizedPnLPct)
+		for _, action := range h.Actions {
+			fmt.Printf("   ⚠️  %s\n", action.Message)
+		}
+	}
+	for _, code := range report.MissingPrices {
+		fmt.Printf("%s: 無法取得現價，略過評估\n", code)
+	}
+
+	fmt.Printf("持有成本: %.0f 元 | 市值: %.0f 元 | 未實現損益: %.0f 元 | 已實現損益: %.0f 元\n",
+		report.CostBasis, report.MarketValue, report.UnrealizedPnL, report.RealizedPnL)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestTransactionCosts(t *testing.T) {
+	ledger := &Ledger{FeeDiscount: 0.6}
+	tests := []struct {
+		name    string
+		tx      Transaction
+		wantFee float64
+		wantTax float64
+	}{
+		{"整股買進依折扣計費", Transaction{Code: "2330", Side: SideBuy, Shares: 1000, Price: 1000}, 855, 0},
+		{"整股最低手續費", Transaction{Code: "2330", Side: SideBuy, Shares: 1000, Price: 10}, 20, 0},
+		{"零股最低手續費", Transaction{Code: "2330", Side: SideBuy, Shares: 10, Price: 50}, 1, 0},
+		{"股票賣出課稅0.3%", Transaction{Code: "2330", Side: SideSell, Shares: 1000, Price: 1000}, 855, 3000},
+		{"ETF賣出課稅0.1%", Transaction{Code: "0050", Side: SideSell, Shares: 1000, Price: 100}, 85, 100},
+		{"明確填寫零手續費", Transaction{Code: "2330", Side: SideBuy, Shares: 1000, Price: 1000, Fee: floatPtr(0)}, 0, 0},
+		{"明確填寫稅額", Transaction{Code: "2330", Side: SideSell, Shares: 1000, Price: 1000, Fee: floatPtr(100), Tax: floatPtr(1500)}, 100, 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ledger.transactionFee(tt.tx); got != tt.wantFee {
+				t.Errorf("fee = %v, want %v", got, tt.wantFee)
+			}
+			if got := transactionTaxPaid(tt.tx); got != tt.wantTax {
+				t.Errorf("tax = %v, want %v", got, tt.wantTax)
+			}
+		})
+	}
+}
+
+func TestLedgerSavePreservesExplicitZeroFee(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holdings.json")
+	ledger, err := LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ledger.AddTransaction(Transaction{Date: "2026-01-05", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100, Fee: floatPtr(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.AddTransaction(Transaction{Date: "2026-01-06", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"fee"`); n != 1 {
+		t.Errorf("holdings.json 應只記錄明確填寫的手續費，找到 %d 筆:\n%s", n, data)
+	}
+
+	loaded, err := LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := loaded.Transactions[0].Fee; fee == nil || *fee != 0 {
+		t.Errorf("第一筆手續費 = %v, want 0", fee)
+	}
+	if fee := loaded.Transactions[1].Fee; fee != nil {
+		t.Errorf("第二筆手續費 = %v, want nil (依費率計算)", *fee)
+	}
+
+	holdings, err := loaded.Holdings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100000 + 0 + 100000 + 142
+	if got, want := holdings["2330"].CostBasis, 200142.0; got != want {
+		t.Errorf("CostBasis = %v, want %v", got, want)
+	}
+}
+
+func TestAddTransactionValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   Transaction
+	}{
+		{"方向錯誤", Transaction{Date: "2026-01-05", Code: "2330", Side: "hold", Shares: 1000, Price: 100}},
+		{"日期格式錯誤", Transaction{Date: "2026/01/05", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100}},
+		{"股數為零", Transaction{Date: "2026-01-05", Code: "2330", Side: SideBuy, Shares: 0, Price: 100}},
+		{"負手續費", Transaction{Date: "2026-01-05", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100, Fee: floatPtr(-1)}},
+		{"賣出超過持股", Transaction{Date: "2026-01-05", Code: "2330", Side: SideSell, Shares: 1000, Price: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &Ledger{HighWater: make(map[string]float64)}
+			if err := ledger.AddTransaction(tt.tx); err == nil {
+				t.Error("預期錯誤")
+			}
+			if len(ledger.Transactions) != 0 {
+				t.Error("錯誤的交易不應寫入帳本")
+			}
+		})
+	}
+}
+
+func TestHoldingsAverageCost(t *testing.T) {
+	ledger := &Ledger{Transactions: []Transaction{
+		{Date: "2026-01-05", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100, Fee: floatPtr(100)},
+		{Date: "2026-01-06", Code: "2330", Side: SideBuy, Shares: 1000, Price: 120, Fee: floatPtr(100)},
+		{Date: "2026-01-07", Code: "2330", Side: SideSell, Shares: 1000, Price: 130, Fee: floatPtr(100), Tax: floatPtr(390)},
+	}}
+
+	holdings, err := ledger.Holdings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := holdings["2330"]
+	// 成本 (100100 + 120100) / 2000 = 110.1，賣出 1000 股: 130000 - 100 - 390 - 110100
+	if h.Shares != 1000 || h.AvgCost != 110.1 || h.CostBasis != 110100 || h.RealizedPnL != 19410 {
+		t.Errorf("holding = %+v", h)
+	}
+	if h.FirstBuy != "2026-01-05" {
+		t.Errorf("FirstBuy = %s", h.FirstBuy)
+	}
+}
+
+func TestMonitorPositionsResetsHighWaterOnReopen(t *testing.T) {
+	rules := DefaultPositionRules()
+	ledger := &Ledger{HighWater: make(map[string]float64)}
+	add := func(tx Transaction) {
+		t.Helper()
+		tx.Fee, tx.Tax = floatPtr(0), floatPtr(0)
+		if err := ledger.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add(Transaction{Date: "2026-01-05", Code: "2330", Side: SideBuy, Shares: 1000, Price: 100})
+	if _, err := ledger.MonitorPositions(map[string]float64{"2330": 200}, rules); err != nil {
+		t.Fatal(err)
+	}
+	if got := ledger.HighWater["2330@2026-01-05"]; got != 200 {
+		t.Fatalf("high water = %v, want 200", got)
+	}
+
+	// 監控之間全數出場後重新買進，不應沿用前一部位的高點觸發移動停利
+	add(Transaction{Date: "2026-02-02", Code: "2330", Side: SideSell, Shares: 1000, Price: 190})
+	add(Transaction{Date: "2026-02-03", Code: "2330", Side: SideBuy, Shares: 1000, Price: 150})
+	report, err := ledger.MonitorPositions(map[string]float64{"2330": 155}, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Holdings) != 1 {
+		t.Fatalf("holdings = %+v", report.Holdings)
+	}
+	if status := report.Holdings[0]; status.HighWater != 155 || len(status.Actions) != 0 {
+		t.Errorf("high water = %v, actions = %+v", status.HighWater, status.Actions)
+	}
+	if _, ok := ledger.HighWater["2330@2026-01-05"]; ok || len(ledger.HighWater) != 1 {
+		t.Errorf("舊部位高點未清除: %v", ledger.HighWater)
+	}
+
+	// 全數出場時清除高點 (含舊版以代碼為鍵的紀錄)
+	ledger.HighWater["2330"] = 300
+	add(Transaction{Date: "2026-03-02", Code: "2330", Side: SideSell, Shares: 1000, Price: 160})
+	if _, err := ledger.MonitorPositions(nil, rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.HighWater) != 0 {
+		t.Errorf("出場後高點未清除: %v", ledger.HighWater)
+	}
+}
+
+func TestEvaluatePositionRules(t *testing.T) {
+	rules := DefaultPositionRules()
+	h := &Holding{Code: "2330", Shares: 3000, AvgCost: 100}
+	tests := []struct {
+		name       string
+		price      float64
+		highWater  float64
+		wantRule   string
+		wantShares int
+	}{
+		{"持有", 105, 105, "", 0},
+		{"停損", 90, 100, "stop_loss", 3000},
+		{"移動停利", 110, 130, "trailing_stop", 3000},
+		{"獲利了結取整張", 125, 125, "take_profit", 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := evaluatePositionRules(h, tt.price, tt.highWater, rules)
+			if tt.wantRule == "" {
+				if len(actions) != 0 {
+					t.Errorf("actions = %+v, want none", actions)
+				}
+				return
+			}
+			if len(actions) != 1 || actions[0].Rule != tt.wantRule || actions[0].SharesToSell != tt.wantShares {
+				t.Errorf("actions = %+v, want %s %d", actions, tt.wantRule, tt.wantShares)
+			}
+		})
+	}
+}
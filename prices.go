@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+// 價格歷史預設設定
+const (
+	defaultChartRange    = "3mo"
+	defaultChartInterval = "1d"
+	avgVolumeBars        = 20 // 平均成交量計算的K棒數
+)
+
+// 價格來源名稱
+const (
+	priceSourceYahoo    = "yahoo"
+	priceSourceOfficial = "official" // 證交所及櫃買中心盤後行情
+)
+
+// 支援的資料區間、K棒週期及價格來源
+var (
+	chartRanges      = []string{"1mo", "3mo", "6mo", "1y", "2y", "5y", "10y", "ytd", "max"}
+	chartIntervals   = []string{"1d", "1wk", "1mo"}
+	priceSourceNames = []string{priceSourceYahoo, priceSourceOfficial}
+)
+
+// Bar 單根K棒，時間為台北時區
+type Bar struct {
+	Time     time.Time `json:"time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	AdjClose float64   `json:"adj_close"` // 還原權息收盤價，無資料時同收盤價
+	Volume   int64     `json:"volume"`    // 成交股數
+}
+
+// ChartOptions 價格歷史的資料區間、K棒週期及技術指標是否使用還原權息價格
+type ChartOptions struct {
+	Range    string `json:"range"`    // 1mo, 3mo, 6mo, 1y, 2y, 5y, 10y, ytd, max
+	Interval string `json:"interval"` // 1d (日線), 1wk (週線), 1mo (月線)
+	Adjusted bool   `json:"adjusted"`
+}
+
+// DefaultChartOptions 預設取得近3個月日線，技術指標使用還原權息價格
+func DefaultChartOptions() ChartOptions {
+	return ChartOptions{Range: defaultChartRange, Interval: defaultChartInterval, Adjusted: true}
+}
+
+// Validate 檢查資料區間及K棒週期
+func (o ChartOptions) Validate() error {
+	if !slices.Contains(chartRanges, o.Range) {
+		return fmt.Errorf("不支援的資料區間 %q (可用: %v)", o.Range, chartRanges)
+	}
+	if !slices.Contains(chartIntervals, o.Interval) {
+		return fmt.Errorf("不支援的K棒週期 %q (可用: %v)", o.Interval, chartIntervals)
+	}
+	return nil
+}
+
+// start 資料區間的起始日，max 以 maxYears 年為上限
+func (o ChartOptions) start(now time.Time, maxYears int) time.Time {
+	switch o.Range {
+	case "1mo":
+		return now.AddDate(0, -1, 0)
+	case "3mo":
+		return now.AddDate(0, -3, 0)
+	case "6mo":
+		return now.AddDate(0, -6, 0)
+	case "1y":
+		return now.AddDate(-1, 0, 0)
+	case "2y":
+		return now.AddDate(-2, 0, 0)
+	case "5y":
+		return now.AddDate(-5, 0, 0)
+	case "10y":
+		return now.AddDate(-10, 0, 0)
+	case "ytd":
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	}
+	return now.AddDate(-maxYears, 0, 0)
+}
+
+// longerRange 兩個資料區間中起始日較早者
+func longerRange(a, b string) string {
+	now := taipeiNow()
+	if (ChartOptions{Range: a}).start(now, defaultQuoteMaxYears).Before((ChartOptions{Range: b}).start(now, defaultQuoteMaxYears)) {
+		return a
+	}
+	return b
+}
+
+// chartWindow 資料區間內的K棒，range=max 時不截取
+func chartWindow(bars []Bar, opts ChartOptions) []Bar {
+	if opts.Range == "max" {
+		return bars
+	}
+	start := startOfDay(opts.start(taipeiNow(), defaultQuoteMaxYears))
+	i := sort.Search(len(bars), func(i int) bool { return !bars[i].Time.Before(start) })
+	return bars[i:]
+}
+
+// PriceChart 價格歷史
+type PriceChart struct {
+	Source string  // 價格來源名稱
+	Symbol string  // 來源使用的代碼
+	Price  float64 // 最新成交價
+	Bars   []Bar   // 由舊到新
+}
+
+// PriceSource 價格歷史來源
+type PriceSource interface {
+	Name() string
+	FetchChart(ctx context.Context, code string, opts ChartOptions) (*PriceChart, error)
+}
+
+// NewPriceSources 建立價格來源，primary 優先使用，其餘作為備援及交叉比對
+func NewPriceSources(client *http.Client, primary string) ([]PriceSource, error) {
+	sources := []PriceSource{NewYahooSource(client), NewOfficialSource(client)}
+	for i, source := range sources {
+		if source.Name() == primary {
+			sources[0], sources[i] = sources[i], sources[0]
+			return sources, nil
+		}
+	}
+	return nil, fmt.Errorf("不支援的價格來源 %q (可用: %v)", primary, priceSourceNames)
+}
+
+// PriceDiscrepancy 兩個價格來源收盤價不一致的交易日
+type PriceDiscrepancy struct {
+	Date      string  `json:"date"`
+	Primary   float64 `json:"primary"`
+	Secondary float64 `json:"secondary"`
+	DiffPct   float64 `json:"diff_pct"`
+}
+
+// fetchChart 依序嘗試各價格來源，並依設定與備援來源交叉比對收盤價
+// 資料區間至少為 momentumMinRange (見 fetchOptions)
+func (s *StockScreener) fetchChart(ctx context.Context, code string) (*PriceChart, error) {
+	var chart *PriceChart
+	var errs []error
+	next := 0
+	for next < len(s.priceSources) && chart == nil {
+		source := s.priceSources[next]
+		next++
+
+		c, err := source.FetchChart(ctx, code, s.fetchOptions())
+		if err == nil && len(c.Bars) == 0 {
+			err = fmt.Errorf("無價格資料")
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			s.logger.Warn("價格來源失敗", logKeyStock, code, "source", source.Name(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %v", source.Name(), err))
+			continue
+		}
+		chart = c
+	}
+	if chart == nil {
+		return nil, fmt.Errorf("所有價格來源皆失敗: %v", errs)
+	}
+	if len(errs) > 0 {
+		s.summary.markDegraded(code, fmt.Sprintf("價格改用%s", chart.Source))
+	}
+
+	// 交叉比對 (tolerance 為 0 時停用)
+	if s.priceTolerance > 0 && next < len(s.priceSources) {
+		s.crossCheckPrices(ctx, code, chart, s.priceSources[next])
+	}
+	return chart, nil
+}
+
+// crossCheckPrices 比對另一來源的收盤價，差異超過容許值時標示為資料不完整
+func (s *StockScreener) crossCheckPrices(ctx context.Context, code string, chart *PriceChart, source PriceSource) {
+	other, err := source.FetchChart(ctx, code, s.fetchOptions())
+	if err != nil {
+		s.logger.Warn("無法取得比對用價格", logKeyStock, code, "source", source.Name(), "error", err)
+		return
+	}
+
+	discrepancies := comparePrices(chart.Bars, other.Bars, s.priceTolerance)
+	for _, d := range discrepancies {
+		s.logger.Debug("價格差異", logKeyStock, code, "date", d.Date, chart.Source, d.Primary,
+			source.Name(), d.Secondary, "diff_pct", d.DiffPct)
+	}
+	if len(discrepancies) > 0 {
+		s.logger.Warn("價格來源不一致", logKeyStock, code, "sources", chart.Source+"/"+source.Name(),
+			"days", len(discrepancies), "tolerance_pct", s.priceTolerance)
+		s.summary.markDegraded(code, fmt.Sprintf("%s與%s收盤價差異超過%.1f%% (%d日)",
+			chart.Source, source.Name(), s.priceTolerance, len(discrepancies)))
+	}
+}
+
+// comparePrices 比對同一日期的收盤價，回傳差異百分比超過 tolerance 的交易日
+func comparePrices(primary, secondary []Bar, tolerance float64) []PriceDiscrepancy {
+	closes := make(map[string]float64, len(secondary))
+	for _, bar := range secondary {
+		closes[bar.Time.Format("2006-01-02")] = bar.Close
+	}
+
+	var discrepancies []PriceDiscrepancy
+	for _, bar := range primary {
+		date := bar.Time.Format("2006-01-02")
+		other, ok := closes[date]
+		if !ok || other <= 0 {
+			continue
+		}
+		diff := math.Abs(bar.Close-other) / other * 100
+		if diff > tolerance {
+			discrepancies = append(discrepancies, PriceDiscrepancy{
+				Date: date, Primary: bar.Close, Secondary: other, DiffPct: diff,
+			})
+		}
+	}
+	return discrepancies
+}
+
+// resampleBars 將日K棒合併為週K或月K
+func resampleBars(bars []Bar, interval string) []Bar {
+	var period func(t time.Time) int
+	switch interval {
+	case "1wk":
+		period = func(t time.Time) int {
+			year, week := t.ISOWeek()
+			return year*100 + week
+		}
+	case "1mo":
+		period = func(t time.Time) int {
+			return t.Year()*100 + int(t.Month())
+		}
+	default:
+		return bars
+	}
+
+	var result []Bar
+	for i, bar := range bars {
+		if i == 0 || period(bar.Time) != period(bars[i-1].Time) {
+			result = append(result, bar)
+			continue
+		}
+		last := &result[len(result)-1]
+		last.High = max(last.High, bar.High)
+		last.Low = min(last.Low, bar.Low)
+		last.Close = bar.Close
+		last.AdjClose = bar.AdjClose
+		last.Volume += bar.Volume
+	}
+	return result
+}
+
+// averageVolume 最近 n 根K棒的平均成交量
+func averageVolume(bars []Bar, n int) int64 {
+	if len(bars) == 0 {
+		return 0
+	}
+	if len(bars) > n {
+		bars = bars[len(bars)-n:]
+	}
+	var sum int64
+	for _, bar := range bars {
+		sum += bar.Volume
+	}
+	return sum / int64(len(bars))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// criteriaProfiles 內建篩選條件組合
+var criteriaProfiles = map[string]func() ScreeningCriteria{
+	"default": DefaultCriteria,
+	"strict": func() ScreeningCriteria {
+		c := DefaultCriteria()
+		c.MinROE, c.GoodROE = 15.0, 20.0
+		c.MinRevenueGrowth, c.GoodRevenueGrowth = 5.0, 15.0
+		c.MaxDebtRatio, c.GoodDebtRatio = 40.0, 25.0
+		c.MinDividendYears, c.GoodDividendYears = 5, 8
+		c.MinYoYGrowth = 20.0
+		c.MinEPS = 2.0
+		c.RequireMA60Above = true
+		c.MinKValue, c.MaxKValue, c.MinKBuy, c.MaxKBuy = 50.0, 85.0, 50.0, 80.0
+		c.MinDValue, c.MaxDValue, c.MinDBuy, c.MaxDBuy = 50.0, 85.0, 50.0, 80.0
+		c.MaxPE, c.MaxPB, c.MaxPEG = 20.0, 3.0, 1.0
+		c.MaxPEPercentile, c.WarnPEPercentile = 60.0, 80.0
+		c.DiscountRate = 12.0
+		c.MinMarginOfSafety = 20.0
+		c.MinOCFToNetIncome, c.MaxAccrualsRatio = 1.0, 5.0
+		c.MinFCFYield, c.MaxNegativeFCFQuarters = 4.0, 1
+		c.MinPiotroskiF, c.MinAltmanZEM = 5, altmanZEMDistress
+		c.MaxBeneishM = -2.22
+		c.MaxBeta, c.MaxDrawdown, c.MaxVaR95 = 1.3, 40.0, 4.0
+		c.MinRSRating, c.MaxFromHigh52W = 70, 25.0
+		return c
+	},
+	"relaxed": func() ScreeningCriteria {
+		c := DefaultCriteria()
+		c.MinROE, c.GoodROE = 5.0, 10.0
+		c.MinRevenueGrowth, c.GoodRevenueGrowth = -10.0, 5.0
+		c.MaxDebtRatio, c.GoodDebtRatio = 70.0, 40.0
+		c.MinDividendYears, c.GoodDividendYears = 1, 3
+		c.MinYoYGrowth = 0.0
+		c.MinEPSGrowth = 30.0
+		c.MinEPS = 0.5
+		c.MinKValue, c.MaxKValue, c.MinKBuy, c.MaxKBuy = 20.0, 95.0, 40.0, 85.0
+		c.MinDValue, c.MaxDValue, c.MinDBuy, c.MaxDBuy = 20.0, 95.0, 40.0, 85.0
+		c.MaxPE, c.MaxPB = 40.0, 8.0
+		c.MinMarginOfSafety = -20.0
+		c.MinOCFToNetIncome, c.MaxAccrualsRatio = 0.5, 15.0
+		c.MinFCFYield, c.MaxNegativeFCFQuarters = 0.0, 4
+		c.MinPiotroskiF, c.MinAltmanZ = 1, 1.0
+		c.MaxBeneishM = -1.0
+		return c
+	},
+}
+
+// profileNames 內建條件組合名稱
+func profileNames() []string {
+	names := make([]string, 0, len(criteriaProfiles))
+	for name := range criteriaProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadCriteriaProfile 取得內建條件組合，或從JSON檔載入 (未指定的欄位沿用預設值)
+func LoadCriteriaProfile(name string) (ScreeningCriteria, error) {
+	if profile, ok := criteriaProfiles[name]; ok {
+		return profile(), nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return ScreeningCriteria{}, fmt.Errorf("找不到條件組合 %q (內建: %v): %v", name, profileNames(), err)
+	}
+
+	criteria := DefaultCriteria()
+	if err := json.Unmarshal(data, &criteria); err != nil {
+		return ScreeningCriteria{}, fmt.Errorf("解析條件組合 %s 失敗: %v", name, err)
+	}
+	if err := validateScoring(criteria.Scoring); err != nil {
+		return ScreeningCriteria{}, fmt.Errorf("條件組合 %s: %v", name, err)
+	}
+	if err := validateRegimeOverrides(criteria.RegimeOverrides); err != nil {
+		return ScreeningCriteria{}, fmt.Errorf("條件組合 %s: %v", name, err)
+	}
+	if criteria.DiscountRate <= criteria.TerminalGrowth {
+		return ScreeningCriteria{}, fmt.Errorf("條件組合 %s: 折現率 %.1f%% 必須大於永續成長率 %.1f%%",
+			name, criteria.DiscountRate, criteria.TerminalGrowth)
+	}
+	return criteria, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 證交所及櫃買中心個股日成交資訊 (每次查詢一個月)
+const (
+	twseStockDayURL      = "https://www.twse.com.tw/exchangeReport/STOCK_DAY?response=json&date=%s&stockNo=%s"
+	tpexDailyQuoteURL    = "https://www.tpex.org.tw/web/stock/aftertrading/daily_trading_info/st43_result.php?l=zh-tw&d=%s&stkno=%s"
+	defaultQuoteDelay    = time.Second // 每月查詢間隔，避免證交所封鎖
+	defaultQuoteMaxYears = 10          // range=max 時最多回溯的年數
+)
+
+// 上市及上櫃市場
+const (
+	marketTWSE = "TWSE"
+	marketTPEx = "TPEx"
+
+	tpexVolumeUnit int64 = 1000 // 櫃買中心成交量單位為仟股
+)
+
+// twseStockDayResponse 證交所個股日成交資訊
+// 欄位: 日期, 成交股數, 成交金額, 開盤價, 最高價, 最低價, 收盤價, 漲跌價差, 成交筆數
+type twseStockDayResponse struct {
+	Stat string     `json:"stat"`
+	Data [][]string `json:"data"`
+}
+
+// tpexDailyQuoteResponse 櫃買中心個股日成交資訊
+// 欄位: 日期, 成交仟股, 成交仟元, 開盤, 最高, 最低, 收盤, 漲跌, 筆數
+type tpexDailyQuoteResponse struct {
+	StockNo string     `json:"stkNo"`
+	Data    [][]string `json:"aaData"`
+}
+
+// OfficialSource 證交所 (上市) 及櫃買中心 (上櫃) 盤後行情，逐月查詢後組成連續歷史
+// 官方行情為未還原權息的價格
+type OfficialSource struct {
+	client *http.Client
+	logger *slog.Logger
+
+	Delay    time.Duration // 每月查詢間隔
+	MaxYears int           // range=max 時最多回溯的年數
+}
+
+// NewOfficialSource 建立官方行情價格來源
+func NewOfficialSource(client *http.Client) *OfficialSource {
+	return &OfficialSource{
+		client:   client,
+		logger:   slog.Default(),
+		Delay:    defaultQuoteDelay,
+		MaxYears: defaultQuoteMaxYears,
+	}
+}
+
+// Name 價格來源名稱
+func (o *OfficialSource) Name() string {
+	return priceSourceOfficial
+}
+
+// FetchChart 逐月取得日成交資訊，週線及月線由日線合併
+func (o *OfficialSource) FetchChart(ctx context.Context, code string, opts ChartOptions) (*PriceChart, error) {
+	now := taipeiNow()
+	start := startOfDay(opts.start(now, o.MaxYears))
+
+	// 已知上櫃股票先查櫃買中心，其餘先查證交所
+	markets := []string{marketTWSE, marketTPEx}
+	if otcStocks[code] {
+		markets = []string{marketTPEx, marketTWSE}
+	}
+
+	var bars []Bar
+	market := ""
+	requests := 0
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, taipeiLocation); !month.After(now); month = month.AddDate(0, 1, 0) {
+		// 尚未確定市場時依序嘗試，之後只查詢該市場
+		candidates := markets
+		if market != "" {
+			candidates = []string{market}
+		}
+
+		for _, m := range candidates {
+			if requests > 0 {
+				select {
+				case <-time.After(o.Delay):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			requests++
+
+			monthBars, err := o.fetchMonth(ctx, m, code, month)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", m, month.Format("2006-01"), err)
+			}
+			if len(monthBars) > 0 {
+				market = m
+				bars = append(bars, monthBars...)
+				break
+			}
+		}
+	}
+
+	// 去除區間起始日之前的資料
+	for len(bars) > 0 && bars[0].Time.Before(start) {
+		bars = bars[1:]
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("證交所及櫃買中心皆無 %s 的成交資料", code)
+	}
+	o.logger.Debug("官方行情", logKeyStock, code, "market", market, "bars", len(bars), "requests", requests)
+
+	bars = resampleBars(bars, opts.Interval)
+	return &PriceChart{
+		Source: priceSourceOfficial,
+		Symbol: code + "." + market,
+		Price:  bars[len(bars)-1].Close,
+		Bars:   bars,
+	}, nil
+}
+
+// fetchMonth 取得單月日成交資訊，查無資料時回傳空值
+func (o *OfficialSource) fetchMonth(ctx context.Context, market, code string, month time.Time) ([]Bar, error) {
+	if market == marketTPEx {
+		rocMonth := fmt.Sprintf("%d/%02d", month.Year()-1911, month.Month())
+		var response tpexDailyQuoteResponse
+		if err := o.getJSON(ctx, fmt.Sprintf(tpexDailyQuoteURL, rocMonth, code), &response); err != nil {
+			return nil, err
+		}
+		return parseQuoteRows(response.Data, tpexVolumeUnit), nil
+	}
+
+	var response twseStockDayResponse
+	if err := o.getJSON(ctx, fmt.Sprintf(twseStockDayURL, month.Format("20060102"), code), &response); err != nil {
+		return nil, err
+	}
+	// 查無資料時 stat 為錯誤訊息 (如 "很抱歉，沒有符合條件的資料!")
+	if response.Stat != "OK" {
+		return nil, nil
+	}
+	return parseQuoteRows(response.Data, 1), nil
+}
+
+// getJSON 送出GET請求並解析JSON回應
+func (o *OfficialSource) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP 狀態碼 %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析回應失敗: %v", err)
+	}
+	return nil
+}
+
+// parseQuoteRows 解析日成交資訊，無成交 (價格為 "--") 的交易日略過
+func parseQuoteRows(rows [][]string, volumeUnit int64) []Bar {
+	bars := make([]Bar, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 7 {
+			continue
+		}
+		date, err := parseROCDate(row[0])
+		if err != nil {
+			continue
+		}
+
+		var prices [4]float64
+		ok := true
+		for i := range prices {
+			prices[i], err = parseQuoteNumber(row[3+i])
+			if err != nil || prices[i] <= 0 {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		volume, _ := parseQuoteNumber(row[1])
+
+		bars = append(bars, Bar{
+			Time:     date,
+			Open:     prices[0],
+			High:     prices[1],
+			Low:      prices[2],
+			Close:    prices[3],
+			AdjClose: prices[3],
+			Volume:   int64(volume) * volumeUnit,
+		})
+	}
+	return bars
+}
+
+// parseROCDate 解析民國日期 (如 113/01/02，可能帶有註記符號)
+func parseROCDate(text string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(strings.Trim(text, "＊* ")), "/")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("無效的民國日期: %q", text)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("無效的民國日期: %q", text)
+		}
+		nums[i] = n
+	}
+	t := time.Date(nums[0]+1911, time.Month(nums[1]), nums[2], 0, 0, 0, 0, taipeiLocation)
+	// time.Date 會將超出範圍的月日進位 (如 2/30 變為 3/1)，需另行檢查
+	if nums[0] <= 0 || int(t.Month()) != nums[1] || t.Day() != nums[2] {
+		return time.Time{}, fmt.Errorf("無效的民國日期: %q", text)
+	}
+	return t, nil
+}
+
+// parseQuoteNumber 解析帶千分位的數字
+func parseQuoteNumber(text string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", ""), 64)
+}
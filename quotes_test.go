@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseROCDate(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"113/01/02", time.Date(2024, 1, 2, 0, 0, 0, 0, taipeiLocation), false},
+		{" 99/12/31 ", time.Date(2010, 12, 31, 0, 0, 0, 0, taipeiLocation), false},
+		{"113/02/29＊", time.Date(2024, 2, 29, 0, 0, 0, 0, taipeiLocation), false},
+		{"*114/07/01", time.Date(2025, 7, 1, 0, 0, 0, 0, taipeiLocation), false},
+		{"114/02/29", time.Time{}, true},
+		{"113/13/01", time.Time{}, true},
+		{"113-01-02", time.Time{}, true},
+		{"113/1a/02", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseROCDate(tt.text)
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("parseROCDate(%q) = %v, %v, want %v (error %v)", tt.text, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseQuoteRows(t *testing.T) {
+	rows := [][]string{
+		// 日期, 成交股數, 成交金額, 開盤價, 最高價, 最低價, 收盤價, 漲跌價差, 成交筆數
+		{"113/01/02", "12,345,678", "7,000,000,000", "593.00", "593.00", "589.00", "593.00", "+0.00", "20,000"},
+		{"113/01/03", "0", "0", "--", "--", "--", "--", " 0.00", "0"},
+		{"113/01/04", "1,000", "580,000", "580.00", "582.00", "578.00", "580.00", "-13.00", "10"},
+		{"無效日期", "1,000", "580,000", "580.00", "582.00", "578.00", "580.00", "0.00", "10"},
+		{"113/01/05", "1,000"},
+	}
+
+	tests := []struct {
+		name        string
+		volumeUnit  int64
+		wantVolumes []int64
+	}{
+		{"上市以股計", 1, []int64{12345678, 1000}},
+		{"上櫃以千股計", 1000, []int64{12345678000, 1000000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bars := parseQuoteRows(rows, tt.volumeUnit)
+			if len(bars) != 2 {
+				t.Fatalf("bars = %+v, want 2", bars)
+			}
+			want := []Bar{
+				{Time: time.Date(2024, 1, 2, 0, 0, 0, 0, taipeiLocation), Open: 593, High: 593, Low: 589, Close: 593, AdjClose: 593},
+				{Time: time.Date(2024, 1, 4, 0, 0, 0, 0, taipeiLocation), Open: 580, High: 582, Low: 578, Close: 580, AdjClose: 580},
+			}
+			for i := range want {
+				want[i].Volume = tt.wantVolumes[i]
+				if got := bars[i]; !got.Time.Equal(want[i].Time) || got.Open != want[i].Open || got.High != want[i].High ||
+					got.Low != want[i].Low || got.Close != want[i].Close || got.AdjClose != want[i].AdjClose || got.Volume != want[i].Volume {
+					t.Errorf("bar %d = %+v, want %+v", i, got, want[i])
+				}
+			}
+		})
+	}
+}
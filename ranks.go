@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 綜合因子
+const (
+	factorValue    = "value"
+	factorQuality  = "quality"
+	factorGrowth   = "growth"
+	factorMomentum = "momentum"
+	factorLowVol   = "low_vol"
+)
+
+// compositeFactors 綜合因子及顯示名稱 (依輸出順序)
+var compositeFactors = []struct {
+	Name  string
+	Label string
+}{
+	{factorValue, "價值"},
+	{factorQuality, "品質"},
+	{factorGrowth, "成長"},
+	{factorMomentum, "動能"},
+	{factorLowVol, "低波動"},
+}
+
+// defaultRankWinsorize 排名前截尾的百分位 (兩端各5%)
+const defaultRankWinsorize = 5.0
+
+// rankMetric 橫向排名的指標，Value 的 ok 為 false 表示資料不足，不列入排名
+type rankMetric struct {
+	Field  string
+	Factor string // 所屬綜合因子
+	Invert bool   // 數值越低越好
+	Value  func(*StockData) (float64, bool)
+}
+
+// hasCashFlow 是否取得現金流量資料
+func hasCashFlow(s *StockData) bool {
+	return s.OperatingCashFlow != 0
+}
+
+// rankMetrics 排名指標
+var rankMetrics = []rankMetric{
+	{"earnings_yield", factorValue, false, func(s *StockData) (float64, bool) { return s.EarningsYield, s.PE > 0 }},
+	{"pb", factorValue, true, func(s *StockData) (float64, bool) { return s.PB, s.PB > 0 }},
+	{"fcf_yield", factorValue, false, func(s *StockData) (float64, bool) { return s.FCFYield, hasCashFlow(s) }},
+	{"dividend_yield", factorValue, false, func(s *StockData) (float64, bool) { return s.DividendYield, true }},
+	{"margin_of_safety", factorValue, false, func(s *StockData) (float64, bool) { return s.MarginOfSafety, s.FairValue > 0 }},
+
+	{"roe", factorQuality, false, func(s *StockData) (float64, bool) { return s.ROE, true }},
+	{"ocf_to_net_income", factorQuality, false, func(s *StockData) (float64, bool) { return s.OCFToNetIncome, hasCashFlow(s) }},
+	{"accruals_ratio", factorQuality, true, func(s *StockData) (float64, bool) { return s.AccrualsRatio, hasCashFlow(s) }},
+	{"piotroski_f", factorQuality, false, func(s *StockData) (float64, bool) {
+		return compositeValue(s.PiotroskiF), s.PiotroskiF != nil
+	}},
+	{"debt_ratio", factorQuality, true, func(s *StockData) (float64, bool) { return s.DebtRatio, true }},
+
+	{"revenue_growth", factorGrowth, false, func(s *StockData) (float64, bool) { return s.RevenueGrowth, true }},
+	{"yoy_growth", factorGrowth, false, func(s *StockData) (float64, bool) { return s.YoYGrowth, true }},
+	{"eps_growth", factorGrowth, false, func(s *StockData) (float64, bool) { return s.EPSGrowth, true }},
+
+	{"price_vs_ma60", factorMomentum, false, func(s *StockData) (float64, bool) { return priceVsMA60(s), s.MA60 > 0 }},
+	{"relative_strength", factorMomentum, false, func(s *StockData) (float64, bool) {
+		return s.RelativeStrength, s.RelativeStrength != 0
+	}},
+	{"momentum_12_1", factorMomentum, false, func(s *StockData) (float64, bool) {
+		return s.Momentum12Minus1, hasMomentum(s, rsRatingMonths)
+	}},
+	{"return_6m", factorMomentum, false, func(s *StockData) (float64, bool) { return s.Return6M, hasMomentum(s, 6) }},
+	{"from_high_52w", factorMomentum, false, func(s *StockData) (float64, bool) { return s.FromHigh52W, s.High52W > 0 }},
+
+	{"volatility", factorLowVol, true, func(s *StockData) (float64, bool) { return s.Volatility, s.Volatility > 0 }},
+	{"beta", factorLowVol, true, func(s *StockData) (float64, bool) { return s.Beta, s.Beta != 0 }},
+	{"max_drawdown", factorLowVol, true, func(s *StockData) (float64, bool) { return s.MaxDrawdown, s.MaxDrawdown > 0 }},
+}
+
+// priceVsMA60 股價相對60日均線的百分比
+func priceVsMA60(s *StockData) float64 {
+	if s.MA60 <= 0 {
+		return 0
+	}
+	return (s.Price - s.MA60) / s.MA60 * 100
+}
+
+// MetricRank 單一指標在股票池及同產業中的排名
+// 百分位及 z-score 皆已依方向調整 (越高越好)，z-score 以截尾後的分布計算
+type MetricRank struct {
+	Value              float64 `json:"value"`
+	Percentile         float64 `json:"percentile"`                    // 股票池百分位 (0-100)
+	Z                  float64 `json:"z"`                             // 股票池 z-score
+	IndustryPercentile float64 `json:"industry_percentile,omitempty"` // 同產業百分位 (同產業不足2檔時為 0)
+	IndustryZ          float64 `json:"industry_z,omitempty"`          // 同產業 z-score
+	IndustryPeers      int     `json:"industry_peers"`                // 同產業有資料的股票數 (含自身)
+}
+
+// FactorRanks 股票的橫向排名: 各指標排名及綜合因子百分位
+type FactorRanks struct {
+	Universe   int                   `json:"universe"`   // 股票池股票數
+	Composites map[string]float64    `json:"composites"` // 綜合因子百分位 (0-100)，資料不足時無此項
+	Metrics    map[string]MetricRank `json:"metrics"`
+}
+
+// composite 綜合因子百分位，無資料時 ok 為 false
+func (r *FactorRanks) composite(name string) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	v, ok := r.Composites[name]
+	return v, ok
+}
+
+// winsorize 將數值截斷在 pct 及 100-pct 百分位之間
+func winsorize(values []float64, pct float64) []float64 {
+	if len(values) == 0 || pct <= 0 {
+		return values
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	lo, hi := quantile(sorted, pct/100), quantile(sorted, 1-pct/100)
+
+	clipped := make([]float64, len(values))
+	for i, v := range values {
+		clipped[i] = max(lo, min(hi, v))
+	}
+	return clipped
+}
+
+// groupRanks 計算一組數值的百分位及截尾後 z-score (已依 invert 調整方向)
+func groupRanks(values []float64, invert bool, winsorizePct float64) (percentiles, zscores []float64) {
+	n := len(values)
+	percentiles, zscores = make([]float64, n), make([]float64, n)
+	if n < 2 {
+		return percentiles, zscores
+	}
+
+	sorted := make([]float64, n)
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	clipped := winsorize(values, winsorizePct)
+	mean, std := meanStd(clipped)
+	for i, v := range values {
+		percentiles[i] = percentileRank(sorted, v)
+		if std > 0 {
+			zscores[i] = (clipped[i] - mean) / std
+		}
+		if invert {
+			percentiles[i] = 100 - percentiles[i]
+			zscores[i] = -zscores[i]
+		}
+	}
+	return percentiles, zscores
+}
+
+// rankUniverse 計算股票池中各指標的排名 (全體及同產業) 與綜合因子百分位
+// 股票池不足2檔時無法比較，不設定排名
+func (s *StockScreener) rankUniverse(universe []*StockData) {
+	for _, stock := range universe {
+		stock.Ranks = nil
+	}
+	if len(universe) < 2 {
+		return
+	}
+
+	winsorizePct := s.criteria.RankWinsorize
+	for _, stock := range universe {
+		stock.Ranks = &FactorRanks{
+			Universe:   len(universe),
+			Composites: make(map[string]float64),
+			Metrics:    make(map[string]MetricRank),
+		}
+	}
+
+	for _, metric := range rankMetrics {
+		// 只比較有資料的股票
+		var members []*StockData
+		var values []float64
+		industries := make(map[string][]int)
+		for _, stock := range universe {
+			v, ok := metric.Value(stock)
+			if !ok {
+				continue
+			}
+			industry := industryOf(stock)
+			industries[industry] = append(industries[industry], len(members))
+			members = append(members, stock)
+			values = append(values, v)
+		}
+		if len(members) < 2 {
+			continue
+		}
+
+		percentiles, zscores := groupRanks(values, metric.Invert, winsorizePct)
+		for i, stock := range members {
+			stock.Ranks.Metrics[metric.Field] = MetricRank{Value: values[i], Percentile: percentiles[i], Z: zscores[i]}
+		}
+
+		for _, idx := range industries {
+			peerValues := make([]float64, len(idx))
+			for j, i := range idx {
+				peerValues[j] = values[i]
+			}
+			peerPercentiles, peerZ := groupRanks(peerValues, metric.Invert, winsorizePct)
+			for j, i := range idx {
+				rank := members[i].Ranks.Metrics[metric.Field]
+				rank.IndustryPercentile, rank.IndustryZ, rank.IndustryPeers = peerPercentiles[j], peerZ[j], len(idx)
+				members[i].Ranks.Metrics[metric.Field] = rank
+			}
+		}
+	}
+
+	// 綜合因子: 所屬指標 z-score 的平均，再換算為股票池百分位
+	for _, factor := range compositeFactors {
+		var members []*StockData
+		var averages []float64
+		for _, stock := range universe {
+			sum, count := 0.0, 0
+			for _, metric := range rankMetrics {
+				if rank, ok := stock.Ranks.Metrics[metric.Field]; ok && metric.Factor == factor.Name {
+					sum += rank.Z
+					count++
+				}
+			}
+			if count > 0 {
+				members = append(members, stock)
+				averages = append(averages, sum/float64(count))
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+		percentiles, _ := groupRanks(averages, false, 0)
+		for i, stock := range members {
+			stock.Ranks.Composites[factor.Name] = percentiles[i]
+		}
+	}
+
+	s.logger.Debug("橫向排名", "universe", len(universe), "metrics", len(rankMetrics))
+}
+
+// compositeCell 綜合因子百分位的表格欄位值，無資料時為 "-"
+func compositeCell(stock *StockData, factor string) interface{} {
+	if v, ok := stock.Ranks.composite(factor); ok {
+		return v
+	}
+	return "-"
+}
+
+// compositeText 綜合因子百分位文字 (如 價值 80 | 品質 65)
+func compositeText(stock *StockData) string {
+	text := ""
+	for _, factor := range compositeFactors {
+		if text != "" {
+			text += " | "
+		}
+		if v, ok := stock.Ranks.composite(factor.Name); ok {
+			text += fmt.Sprintf("%s %.0f", factor.Label, v)
+		} else {
+			text += factor.Label + " -"
+		}
+	}
+	return text
+}
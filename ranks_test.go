@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestWinsorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		pct    float64
+		want   []float64
+	}{
+		{"不截尾", []float64{1, 100, 3}, 0, []float64{1, 100, 3}},
+		{"兩端各10%", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 100}, 10, []float64{2, 2, 3, 4, 5, 6, 7, 8, 9, 10, 10}},
+		{"內插百分位", []float64{100, 1, 2, 3, 4}, 25, []float64{4, 2, 2, 3, 4}},
+		{"無資料", nil, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := slices.Clone(tt.values)
+			if got := winsorize(tt.values, tt.pct); !slices.Equal(got, tt.want) {
+				t.Errorf("winsorize = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(tt.values, input) {
+				t.Errorf("輸入被修改: %v", tt.values)
+			}
+		})
+	}
+}
+
+func TestGroupRanks(t *testing.T) {
+	sd := math.Sqrt(1.25)
+	tests := []struct {
+		name            string
+		values          []float64
+		invert          bool
+		winsorize       float64
+		wantPercentiles []float64
+		wantZ           []float64
+	}{
+		{"遞增", []float64{1, 2, 3, 4}, false, 0,
+			[]float64{12.5, 37.5, 62.5, 87.5}, []float64{-1.5 / sd, -0.5 / sd, 0.5 / sd, 1.5 / sd}},
+		{"越低越好", []float64{1, 2, 3, 4}, true, 0,
+			[]float64{87.5, 62.5, 37.5, 12.5}, []float64{1.5 / sd, 0.5 / sd, -0.5 / sd, -1.5 / sd}},
+		// 截尾後 [2 2 3 4 4]，平均 3、標準差 √0.8；百分位仍以原始數值排序
+		{"截尾後z-score", []float64{1, 2, 3, 4, 100}, false, 25,
+			[]float64{10, 30, 50, 70, 90}, []float64{-1 / math.Sqrt(0.8), -1 / math.Sqrt(0.8), 0, 1 / math.Sqrt(0.8), 1 / math.Sqrt(0.8)}},
+		{"數值相同", []float64{5, 5, 5}, false, 0, []float64{50, 50, 50}, []float64{0, 0, 0}},
+		{"不足2檔", []float64{5}, false, 0, []float64{0}, []float64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percentiles, zscores := groupRanks(tt.values, tt.invert, tt.winsorize)
+			for i := range tt.values {
+				if !approxEqual(percentiles[i], tt.wantPercentiles[i], 1e-9) || !approxEqual(zscores[i], tt.wantZ[i], 1e-9) {
+					t.Fatalf("groupRanks = %v, %v, want %v, %v", percentiles, zscores, tt.wantPercentiles, tt.wantZ)
+				}
+			}
+		})
+	}
+}
+
+func TestRankUniverse(t *testing.T) {
+	s := &StockScreener{criteria: DefaultCriteria(), logger: slog.New(slog.DiscardHandler)}
+	s.criteria.RankWinsorize = 0
+
+	a := &StockData{Code: "A", Industry: "半導體業", ROE: 20, PB: 1}
+	b := &StockData{Code: "B", Industry: "半導體業", ROE: 10, PB: 2}
+	c := &StockData{Code: "C", Industry: "金融保險業", ROE: 5}
+	s.rankUniverse([]*StockData{a, b, c})
+
+	tests := []struct {
+		stock          *StockData
+		field          string
+		wantPercentile float64
+		wantIndustry   float64
+		wantPeers      int
+	}{
+		{a, "roe", 100 * 2.5 / 3, 75, 2},
+		{b, "roe", 50, 25, 2},
+		{c, "roe", 100 * 0.5 / 3, 0, 1},
+		{a, "pb", 75, 75, 2},
+		{b, "pb", 25, 25, 2},
+	}
+	for _, tt := range tests {
+		rank, ok := tt.stock.Ranks.Metrics[tt.field]
+		if !ok {
+			t.Errorf("%s 缺少 %s 排名", tt.stock.Code, tt.field)
+			continue
+		}
+		if !approxEqual(rank.Percentile, tt.wantPercentile, 1e-9) || rank.IndustryPercentile != tt.wantIndustry ||
+			rank.IndustryPeers != tt.wantPeers {
+			t.Errorf("%s %s = %+v, want 百分位 %v、產業 %v (%d檔)", tt.stock.Code, tt.field, rank,
+				tt.wantPercentile, tt.wantIndustry, tt.wantPeers)
+		}
+	}
+
+	// 無股價淨值比的股票不列入該指標排名
+	if _, ok := c.Ranks.Metrics["pb"]; ok {
+		t.Error("C 不應有 pb 排名")
+	}
+	if a.Ranks.Universe != 3 {
+		t.Errorf("Universe = %d, want 3", a.Ranks.Universe)
+	}
+
+	qa, _ := a.Ranks.composite(factorQuality)
+	qb, _ := b.Ranks.composite(factorQuality)
+	qc, _ := c.Ranks.composite(factorQuality)
+	if !(qa > qb && qb > qc) {
+		t.Errorf("品質因子 A %v, B %v, C %v，應依ROE排序", qa, qb, qc)
+	}
+
+	// 股票池不足2檔時清除排名
+	s.rankUniverse([]*StockData{a})
+	if a.Ranks != nil {
+		t.Errorf("Ranks = %+v, want nil", a.Ranks)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 市場狀態
+const (
+	regimeBull    = "bull"
+	regimeNeutral = "neutral"
+	regimeBear    = "bear"
+	regimeUnknown = "unknown" // 加權指數資料不足
+)
+
+// 波動狀態
+const (
+	volatilityLow    = "low"
+	volatilityNormal = "normal"
+	volatilityHigh   = "high"
+)
+
+// 市場狀態判斷設定
+const (
+	benchmarkMinRange      = "1y" // 加權指數至少取得的資料區間 (200日均線及一年波動率分布)
+	regimeMAPeriod         = 200  // 加權指數長期均線天數
+	regimeVolatilityWindow = 20   // 波動率計算天數
+	regimeBreadthThreshold = 50.0 // 股票池站上MA60比例的多空分界 (%)
+	regimeMinBreadthStocks = 20   // 廣度納入判斷所需的最少股票數，不足時僅依加權指數判斷
+	regimeVolatilityHigh   = 80.0 // 波動率百分位高於此值為高波動
+	regimeVolatilityLow    = 20.0 // 波動率百分位低於此值為低波動
+)
+
+// regimes 可設定條件覆寫的市場狀態
+var regimes = []string{regimeBull, regimeNeutral, regimeBear}
+
+// regimeLabels 市場狀態顯示名稱
+var regimeLabels = map[string]string{
+	regimeBull:       "多頭",
+	regimeNeutral:    "盤整",
+	regimeBear:       "空頭",
+	regimeUnknown:    "未知",
+	volatilityLow:    "低波動",
+	volatilityNormal: "正常",
+	volatilityHigh:   "高波動",
+}
+
+// bearTechnicalPassRatio 空頭時技術面須通過的比例 (3項中至少2項)
+// 以 2.0/3 的完整精度寫入覆寫，避免 0.67 使 2/3 判定為未通過
+const bearTechnicalPassRatio = 2.0 / 3
+
+// defaultRegimeOverrides 預設的市場狀態條件覆寫: 空頭時要求站上MA60、提高技術面通過比例並限制Beta
+func defaultRegimeOverrides() map[string]json.RawMessage {
+	return map[string]json.RawMessage{
+		regimeBear: json.RawMessage(fmt.Sprintf(`{"require_ma60_above": true, "min_technical_pass_ratio": %s, "max_beta": 1.5}`,
+			strconv.FormatFloat(bearTechnicalPassRatio, 'g', -1, 64))),
+	}
+}
+
+// MarketRegime 本次執行的市場狀態: 加權指數趨勢、股票池廣度及波動狀態
+type MarketRegime struct {
+	Regime               string  `json:"regime"` // bull, neutral, bear, unknown
+	IndexClose           float64 `json:"index_close"`
+	IndexMA200           float64 `json:"index_ma200"`
+	AboveMA200           bool    `json:"above_ma200"`
+	Breadth              float64 `json:"breadth"`               // 股票池站上MA60的比例 (%)
+	BreadthStocks        int     `json:"breadth_stocks"`        // 有MA60資料的股票數
+	BreadthUsed          bool    `json:"breadth_used"`          // 股票數達 regimeMinBreadthStocks，廣度納入判斷
+	Volatility           float64 `json:"volatility"`            // 加權指數20日年化波動率 (%)
+	VolatilityPercentile float64 `json:"volatility_percentile"` // 在近一年20日波動率中的百分位
+	VolatilityRegime     string  `json:"volatility_regime"`     // low, normal, high
+	Overrides            bool    `json:"overrides"`             // 是否已套用該市場狀態的條件覆寫
+	TechnicalRequired    bool    `json:"technical_required"`    // 條件覆寫調整技術面條件，技術面成為排除條件
+}
+
+// String 市場狀態摘要 (如 空頭 | 加權指數 17000 < MA200 18000 | 廣度 35% (40檔) | 高波動 22.1% (P85))
+func (r *MarketRegime) String() string {
+	if r == nil {
+		return "-"
+	}
+	text := regimeLabels[r.Regime]
+	if r.IndexMA200 > 0 {
+		op := "<"
+		if r.AboveMA200 {
+			op = ">"
+		}
+		text += fmt.Sprintf(" | 加權指數 %.0f %s MA200 %.0f", r.IndexClose, op, r.IndexMA200)
+	}
+	text += fmt.Sprintf(" | 廣度 %.0f%% (%d檔)", r.Breadth, r.BreadthStocks)
+	if !r.BreadthUsed {
+		text += fmt.Sprintf(" 不足%d檔未納入判斷", regimeMinBreadthStocks)
+	}
+	if r.VolatilityRegime != "" {
+		text += fmt.Sprintf(" | %s %.1f%% (P%.0f)", regimeLabels[r.VolatilityRegime], r.Volatility, r.VolatilityPercentile)
+	}
+	if r.Overrides {
+		text += " | 已套用條件覆寫"
+	}
+	return text
+}
+
+// rollingVolatility 每個視窗結尾的年化波動率 (%)
+func rollingVolatility(closes []float64, window int) []float64 {
+	var vols []float64
+	for end := window; end < len(closes); end++ {
+		returns := make([]float64, window)
+		for i := range returns {
+			prev := closes[end-window+i]
+			returns[i] = closes[end-window+i+1]/prev - 1
+		}
+		_, std := meanStd(returns)
+		vols = append(vols, std*math.Sqrt(252)*100)
+	}
+	return vols
+}
+
+// computeRegime 依加權指數日K棒及股票池判斷市場狀態
+// 多頭: 指數站上200日均線且廣度過半；空頭: 指數跌破200日均線且廣度未過半或處於高波動；其餘為盤整
+// 有MA60資料的股票不足 regimeMinBreadthStocks 檔時廣度視為未知，僅依指數是否站上200日均線判斷多空
+func computeRegime(index []Bar, universe []*StockData) *MarketRegime {
+	r := &MarketRegime{Regime: regimeUnknown}
+
+	above := 0
+	for _, stock := range universe {
+		if stock.MA60 <= 0 || stock.Price <= 0 {
+			continue
+		}
+		r.BreadthStocks++
+		if stock.Price > stock.MA60 {
+			above++
+		}
+	}
+	if r.BreadthStocks > 0 {
+		r.Breadth = float64(above) / float64(r.BreadthStocks) * 100
+	}
+	r.BreadthUsed = r.BreadthStocks >= regimeMinBreadthStocks
+
+	closes := make([]float64, 0, len(index))
+	for _, bar := range index {
+		if bar.Close > 0 {
+			closes = append(closes, bar.Close)
+		}
+	}
+
+	// 近一年20日波動率分布
+	history := closes[max(0, len(closes)-252-regimeVolatilityWindow):]
+	if vols := rollingVolatility(history, regimeVolatilityWindow); len(vols) > 0 {
+		r.Volatility = vols[len(vols)-1]
+		sorted := slices.Clone(vols)
+		sort.Float64s(sorted)
+		r.VolatilityPercentile = percentileRank(sorted, r.Volatility)
+		switch {
+		case r.VolatilityPercentile >= regimeVolatilityHigh:
+			r.VolatilityRegime = volatilityHigh
+		case r.VolatilityPercentile <= regimeVolatilityLow:
+			r.VolatilityRegime = volatilityLow
+		default:
+			r.VolatilityRegime = volatilityNormal
+		}
+	}
+
+	if len(closes) < regimeMAPeriod {
+		return r
+	}
+	sum := 0.0
+	for _, c := range closes[len(closes)-regimeMAPeriod:] {
+		sum += c
+	}
+	r.IndexClose = closes[len(closes)-1]
+	r.IndexMA200 = sum / regimeMAPeriod
+	r.AboveMA200 = r.IndexClose > r.IndexMA200
+
+	breadthUp := r.Breadth >= regimeBreadthThreshold
+	switch {
+	case !r.BreadthUsed && r.AboveMA200:
+		r.Regime = regimeBull
+	case !r.BreadthUsed:
+		r.Regime = regimeBear
+	case r.AboveMA200 && breadthUp:
+		r.Regime = regimeBull
+	case !r.AboveMA200 && (!breadthUp || r.VolatilityRegime == volatilityHigh):
+		r.Regime = regimeBear
+	default:
+		r.Regime = regimeNeutral
+	}
+	return r
+}
+
+// technicalOverrideKeys 覆寫時使技術面成為排除條件的欄位
+var technicalOverrideKeys = []string{"require_ma60_above", "min_technical_pass_ratio"}
+
+// overridesTechnical 條件覆寫是否調整技術面條件
+func overridesTechnical(raw json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	for _, key := range technicalOverrideKeys {
+		if _, ok := fields[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRegimeOverrides 檢查條件覆寫的市場狀態名稱及內容
+func validateRegimeOverrides(overrides map[string]json.RawMessage) error {
+	for regime, raw := range overrides {
+		if !slices.Contains(regimes, regime) {
+			return fmt.Errorf("不支援的市場狀態 %q (可用: %v)", regime, regimes)
+		}
+		var c ScreeningCriteria
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return fmt.Errorf("市場狀態 %s 的條件覆寫: %v", regime, err)
+		}
+	}
+	return nil
+}
+
+// detectRegime 判斷本次執行的市場狀態並套用對應的條件覆寫，需在股票池資料取得後、篩選前呼叫
+func (s *StockScreener) detectRegime(ctx context.Context, universe []*StockData) {
+	s.applyRegime(computeRegime(s.loadBenchmark(ctx), universe))
+}
+
+// applyRegime 記錄市場狀態並套用對應的條件覆寫
+func (s *StockScreener) applyRegime(regime *MarketRegime) {
+	if raw, ok := s.criteria.RegimeOverrides[regime.Regime]; ok {
+		criteria := s.criteria
+		if err := json.Unmarshal(raw, &criteria); err != nil {
+			s.logger.Warn("無法套用市場狀態條件覆寫", "regime", regime.Regime, "error", err)
+		} else {
+			s.criteria = criteria
+			regime.Overrides = true
+			regime.TechnicalRequired = overridesTechnical(raw)
+		}
+	}
+
+	s.regime = regime
+	if s.summary != nil {
+		s.summary.Regime = regime
+	}
+	s.logger.Info("市場狀態", "regime", regime.Regime, "index", regime.IndexClose, "ma200", regime.IndexMA200,
+		"breadth", regime.Breadth, "volatility", regime.Volatility, "volatility_regime", regime.VolatilityRegime,
+		"overrides", regime.Overrides, "technical_required", regime.TechnicalRequired)
+}
+
+// indexCloseAt 指定日期當日 (含) 之前最近一根加權指數收盤價
+func indexCloseAt(index []Bar, t time.Time) (float64, bool) {
+	cutoff := startOfDay(t).AddDate(0, 0, 1)
+	i := sort.Search(len(index), func(i int) bool { return !index[i].Time.Before(cutoff) })
+	if i == 0 {
+		return 0, false
+	}
+	return index[i-1].Close, index[i-1].Close > 0
+}
+
+// calculateRelativeStrength 價格區間內相對加權指數的超額報酬 (%)，(1+個股報酬)/(1+指數報酬)-1
+func (s *StockScreener) calculateRelativeStrength(ctx context.Context, stock *StockData) {
+	stock.RelativeStrength = 0
+	bars := s.indicatorBars(stock)
+	index := s.loadBenchmark(ctx)
+	if len(bars) < 2 || len(index) == 0 {
+		return
+	}
+
+	first, last := bars[0], bars[len(bars)-1]
+	start, ok1 := indexCloseAt(index, first.Time)
+	end, ok2 := indexCloseAt(index, last.Time)
+	if !ok1 || !ok2 || first.Close <= 0 {
+		return
+	}
+	stock.RelativeStrength = ((last.Close/first.Close)/(end/start) - 1) * 100
+	s.logger.Debug("相對強弱", logKeyStock, stock.Code, "relative_strength", stock.RelativeStrength)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// indexBars 以固定漲跌幅產生加權指數日K棒
+func indexBars(n int, start, step float64) []Bar {
+	bars := make([]Bar, n)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := range bars {
+		bars[i] = Bar{Time: day.AddDate(0, 0, i), Close: start + step*float64(i)}
+	}
+	return bars
+}
+
+// breadthUniverse 產生 n 檔股票，其中 above 檔站上MA60
+func breadthUniverse(n, above int) []*StockData {
+	universe := make([]*StockData, n)
+	for i := range universe {
+		universe[i] = &StockData{Price: 90, MA60: 100}
+		if i < above {
+			universe[i].Price = 110
+		}
+	}
+	return universe
+}
+
+func TestComputeRegimeBreadth(t *testing.T) {
+	rising, falling := indexBars(260, 15000, 10), indexBars(260, 20000, -10)
+	tests := []struct {
+		name        string
+		index       []Bar
+		universe    []*StockData
+		want        string
+		breadthUsed bool
+	}{
+		{"單一股票跌破MA60不影響多頭", rising, breadthUniverse(1, 0), regimeBull, false},
+		{"股票數不足時僅依指數判斷空頭", falling, breadthUniverse(19, 19), regimeBear, false},
+		{"廣度不足過半為盤整", rising, breadthUniverse(20, 5), regimeNeutral, true},
+		{"指數及廣度皆強為多頭", rising, breadthUniverse(20, 15), regimeBull, true},
+		{"指數跌破均線且廣度不足為空頭", falling, breadthUniverse(20, 5), regimeBear, true},
+		{"指數資料不足", indexBars(100, 15000, 10), breadthUniverse(20, 15), regimeUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := computeRegime(tt.index, tt.universe)
+			if r.Regime != tt.want || r.BreadthUsed != tt.breadthUsed {
+				t.Errorf("Regime = %s, BreadthUsed = %v, want %s, %v (%s)", r.Regime, r.BreadthUsed, tt.want, tt.breadthUsed, r)
+			}
+		})
+	}
+}
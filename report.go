@@ -0,0 +1,601 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// 報告輸出格式
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatXLSX     = "xlsx"
+)
+
+// sparklinePoints 走勢圖顯示的交易日數
+const sparklinePoints = 120
+
+// ReportData 報告內容
+type ReportData struct {
+	GeneratedAt time.Time
+	Criteria    ScreeningCriteria
+	Stocks      []*StockData
+	Alerts      []AlertEvent
+	Regime      *MarketRegime // 本次執行的市場狀態，未判斷時為 nil
+}
+
+// ReportRenderer 報告輸出介面
+type ReportRenderer interface {
+	Render(w io.Writer, report *ReportData) error
+}
+
+// NewReportRenderer 依格式建立報告輸出
+func NewReportRenderer(format string) (ReportRenderer, error) {
+	switch format {
+	case FormatText:
+		return textRenderer{}, nil
+	case FormatJSON:
+		return jsonRenderer{}, nil
+	case FormatCSV:
+		return csvRenderer{}, nil
+	case FormatMarkdown, "md":
+		return markdownRenderer{}, nil
+	case FormatHTML:
+		return htmlRenderer{}, nil
+	case FormatXLSX:
+		return xlsxRenderer{}, nil
+	}
+	return nil, fmt.Errorf("不支援的報告格式: %s", format)
+}
+
+// reportExtension 報告格式對應的副檔名
+func reportExtension(format string) string {
+	switch format {
+	case FormatText:
+		return "txt"
+	case FormatMarkdown:
+		return "md"
+	}
+	return format
+}
+
+// reportColumn 表格類報告欄位
+type reportColumn struct {
+	Header string
+	Value  func(*StockData) interface{} // float64 為數值欄位，其餘為文字
+	Format string                       // 數值顯示格式
+}
+
+// reportColumns 表格類報告(CSV/Markdown/HTML/XLSX)共用欄位
+var reportColumns = []reportColumn{
+	{Header: "代碼", Value: func(s *StockData) interface{} { return s.Code }},
+	{Header: "名稱", Value: func(s *StockData) interface{} { return s.Name }},
+	{Header: "產業", Value: func(s *StockData) interface{} { return industryLabel(s.Industry) }},
+	{Header: "評分", Value: func(s *StockData) interface{} { return s.Score }, Format: "%.1f"},
+	{Header: "評分明細", Value: func(s *StockData) interface{} { return breakdownText(s.ScoreBreakdown) }},
+	{Header: "價值", Value: func(s *StockData) interface{} { return compositeCell(s, factorValue) }, Format: "%.0f"},
+	{Header: "品質", Value: func(s *StockData) interface{} { return compositeCell(s, factorQuality) }, Format: "%.0f"},
+	{Header: "成長", Value: func(s *StockData) interface{} { return compositeCell(s, factorGrowth) }, Format: "%.0f"},
+	{Header: "動能", Value: func(s *StockData) interface{} { return compositeCell(s, factorMomentum) }, Format: "%.0f"},
+	{Header: "低波動", Value: func(s *StockData) interface{} { return compositeCell(s, factorLowVol) }, Format: "%.0f"},
+	{Header: "ROE(%)", Value: func(s *StockData) interface{} { return s.ROE }, Format: "%.1f"},
+	{Header: "ROE-產業中位數", Value: func(s *StockData) interface{} { return peerDiffCell(s, "roe") }, Format: "%+.1f"},
+	{Header: "毛利率(%)", Value: func(s *StockData) interface{} { return s.GrossMargin }, Format: "%.1f"},
+	{Header: "營業利益率(%)", Value: func(s *StockData) interface{} { return s.OperatingMargin }, Format: "%.1f"},
+	{Header: "營收成長(%)", Value: func(s *StockData) interface{} { return s.RevenueGrowth }, Format: "%.1f"},
+	{Header: "年增率(%)", Value: func(s *StockData) interface{} { return s.YoYGrowth }, Format: "%.1f"},
+	{Header: "EPS增長(%)", Value: func(s *StockData) interface{} { return s.EPSGrowth }, Format: "%.1f"},
+	{Header: "EPS", Value: func(s *StockData) interface{} { return s.EPS }, Format: "%.2f"},
+	{Header: "負債比(%)", Value: func(s *StockData) interface{} { return s.DebtRatio }, Format: "%.1f"},
+	{Header: "本益比", Value: func(s *StockData) interface{} { return s.PE }, Format: "%.1f"},
+	{Header: "淨值比", Value: func(s *StockData) interface{} { return s.PB }, Format: "%.2f"},
+	{Header: "殖利率(%)", Value: func(s *StockData) interface{} { return s.DividendYield }, Format: "%.2f"},
+	{Header: "PEG", Value: func(s *StockData) interface{} { return s.PEG }, Format: "%.2f"},
+	{Header: "合理價", Value: func(s *StockData) interface{} { return s.FairValue }, Format: "%.2f"},
+	{Header: "安全邊際(%)", Value: func(s *StockData) interface{} { return s.MarginOfSafety }, Format: "%.1f"},
+	{Header: "現金流/淨利", Value: func(s *StockData) interface{} { return s.OCFToNetIncome }, Format: "%.2f"},
+	{Header: "FCF殖利率(%)", Value: func(s *StockData) interface{} { return s.FCFYield }, Format: "%.2f"},
+	{Header: "相對大盤(%)", Value: func(s *StockData) interface{} { return s.RelativeStrength }, Format: "%+.1f"},
+	{Header: "RS評等", Value: func(s *StockData) interface{} { return rsRatingCell(s) }, Format: "%.0f"},
+	{Header: "3月報酬(%)", Value: func(s *StockData) interface{} { return momentumCell(s, 3, s.Return3M) }, Format: "%+.1f"},
+	{Header: "12月報酬(%)", Value: func(s *StockData) interface{} { return momentumCell(s, 12, s.Return12M) }, Format: "%+.1f"},
+	{Header: "12-1動能(%)", Value: func(s *StockData) interface{} {
+		return momentumCell(s, rsRatingMonths, s.Momentum12Minus1)
+	}, Format: "%+.1f"},
+	{Header: "距52週高(%)", Value: func(s *StockData) interface{} { return s.FromHigh52W }, Format: "%+.1f"},
+	{Header: "Beta", Value: func(s *StockData) interface{} { return s.Beta }, Format: "%.2f"},
+	{Header: "最大回撤(%)", Value: func(s *StockData) interface{} { return s.MaxDrawdown }, Format: "%.1f"},
+	{Header: "Sortino", Value: func(s *StockData) interface{} { return s.SortinoRatio }, Format: "%.2f"},
+	{Header: "VaR95(%)", Value: func(s *StockData) interface{} { return s.VaR95 }, Format: "%.2f"},
+	{Header: "F分數", Value: func(s *StockData) interface{} { return scoreCell(s.PiotroskiF) }, Format: "%.0f"},
+	{Header: "Z分數", Value: func(s *StockData) interface{} { return scoreCell(s.AltmanZ) }, Format: "%.2f"},
+	{Header: "M分數", Value: func(s *StockData) interface{} { return scoreCell(s.BeneishM) }, Format: "%.2f"},
+	{Header: "現價", Value: func(s *StockData) interface{} { return s.Price }, Format: "%.2f"},
+	{Header: "MA60", Value: func(s *StockData) interface{} { return s.MA60 }, Format: "%.2f"},
+	{Header: "K值", Value: func(s *StockData) interface{} { return s.KValue }, Format: "%.1f"},
+	{Header: "D值", Value: func(s *StockData) interface{} { return s.DValue }, Format: "%.1f"},
+	{Header: "RSI", Value: func(s *StockData) interface{} { return s.RSI }, Format: "%.1f"},
+	{Header: "波動率", Value: func(s *StockData) interface{} { return s.Volatility }, Format: "%.3f"},
+}
+
+// cellText 取得欄位顯示文字
+func (c reportColumn) cellText(stock *StockData) string {
+	switch v := c.Value(stock).(type) {
+	case float64:
+		return fmt.Sprintf(c.Format, v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// textRenderer 終端機文字報告
+type textRenderer struct{}
+
+func (textRenderer) Render(w io.Writer, report *ReportData) error {
+	c := report.Criteria
+	fmt.Fprintln(w, "\n========== 股票篩選報告 ==========")
+	fmt.Fprintf(w, "篩選時間: %s\n", report.GeneratedAt.Format("2006-01-02 15:04:05"))
+	if report.Regime != nil {
+		fmt.Fprintf(w, "市場狀態: %s\n", report.Regime)
+	}
+	fmt.Fprintln(w, "\n【篩選條件】")
+	if c.ROEAboveIndustryMedian {
+		fmt.Fprintf(w, "- ROE > %.1f%% (第二階段以產業中位數為門檻)\n", c.MinROE)
+	} else {
+		fmt.Fprintf(w, "- ROE ≥ %.1f%% (≥ %.1f%% 為優秀)\n", c.MinROE, c.GoodROE)
+	}
+	fmt.Fprintf(w, "- 營收成長 ≥ %.1f%% (≥ %.1f%% 為高成長)\n", c.MinRevenueGrowth, c.GoodRevenueGrowth)
+	fmt.Fprintf(w, "- 年增率 > %.1f%%\n", c.MinYoYGrowth)
+	fmt.Fprintf(w, "- EPS增長 > %.1f%% (三位數增長)\n", c.MinEPSGrowth)
+	fmt.Fprintf(w, "- EPS > %.1f元\n", c.MinEPS)
+	fmt.Fprintf(w, "- 負債比 ≤ %.1f%% (≤ %.1f%% 為優秀)\n", c.MaxDebtRatio, c.GoodDebtRatio)
+	fmt.Fprintf(w, "- 配息年數 ≥ %d年 (≥ %d年 為穩定)\n", c.MinDividendYears, c.GoodDividendYears)
+	fmt.Fprintf(w, "- 排除: 負債比 ≥ %.0f%% | 營收成長 ≤ %.0f%% | 年增率 ≤ %.0f%% | EPS增長 ≤ %.0f%%\n",
+		c.ExcludeDebtRatio, c.ExcludeRevenueGrowth, c.ExcludeYoYGrowth, c.ExcludeEPSGrowth)
+	fmt.Fprintf(w, "- 股價在60日均線之上\n")
+	fmt.Fprintf(w, "- K值買進區間 %.0f-%.0f (可觀察 %.0f-%.0f) | D值買進區間 %.0f-%.0f (可觀察 %.0f-%.0f)\n",
+		c.MinKBuy, c.MaxKBuy, c.MinKValue, c.MaxKValue, c.MinDBuy, c.MaxDBuy, c.MinDValue, c.MaxDValue)
+	if c.RequireMA60Above {
+		fmt.Fprintln(w, "- 股價須站上MA60")
+	}
+	fmt.Fprintf(w, "- 技術面通過比例 ≥ %.0f%%\n", c.MinTechnicalPassRatio*100)
+	fmt.Fprintf(w, "- 本益比 < %.0f | 淨值比 < %.1f | PEG < %s | 本益比及PEG位階 < %s\n",
+		c.MaxPE, c.MaxPB, riskCapText(c.MaxPEG, "%.1f"), riskCapText(c.MaxPEPercentile, "%.0f%%"))
+	fmt.Fprintf(w, "- 安全邊際 > %.0f%% (折現率 %.1f%%, 永續成長 %.1f%%)\n",
+		c.MinMarginOfSafety, c.DiscountRate, c.TerminalGrowth)
+	fmt.Fprintf(w, "- 營業現金流/淨利 > %.1f | 應計比率 < %.0f%% | 自由現金流殖利率 > %.1f%% | 自由現金流連續為負 ≤ %d季\n",
+		c.MinOCFToNetIncome, c.MaxAccrualsRatio, c.MinFCFYield, c.MaxNegativeFCFQuarters)
+	fmt.Fprintf(w, "- Piotroski F ≥ %d | Altman Z ≥ %.2f | Altman Z''(EM) ≥ %.2f | Beneish M ≤ %.2f\n",
+		c.MinPiotroskiF, c.MinAltmanZ, c.MinAltmanZEM, c.MaxBeneishM)
+	fmt.Fprintf(w, "- Beta ≤ %s | 最大回撤 ≤ %s | VaR95 ≤ %s\n", riskCapText(c.MaxBeta, "%.2f"),
+		riskCapText(c.MaxDrawdown, "%.0f%%"), riskCapText(c.MaxVaR95, "%.2f%%"))
+	fmt.Fprintf(w, "- RS評等 ≥ %s | 距52週高點 ≤ %s\n", riskCapText(float64(c.MinRSRating), "%.0f"),
+		riskCapText(c.MaxFromHigh52W, "%.0f%%"))
+	if c.RequirePositiveMomentum {
+		fmt.Fprintln(w, "- 12-1月動能須為正")
+	}
+
+	fmt.Fprintf(w, "\n【符合條件股票】共 %d 檔\n", len(report.Stocks))
+	fmt.Fprintln(w, "=====================================")
+
+	for i, stock := range report.Stocks {
+		fmt.Fprintf(w, "\n%d. %s (%s)\n", i+1, stock.Name, stock.Code)
+		fmt.Fprintf(w, "   綜合評分: %.1f (%s)\n", stock.Score, breakdownText(stock.ScoreBreakdown))
+		fmt.Fprintf(w, "   因子百分位: %s\n", compositeText(stock))
+		fmt.Fprintf(w, "   ROE: %.1f%%\n", stock.ROE)
+		fmt.Fprintf(w, "   毛利率: %.1f%% | 營業利益率: %.1f%% | 淨利率: %.1f%%\n",
+			stock.GrossMargin, stock.OperatingMargin, stock.NetMargin)
+		fmt.Fprintf(w, "   同業比較: %s\n", peerText(stock))
+		fmt.Fprintf(w, "   營收年增率: %.1f%%\n", stock.RevenueGrowth)
+		fmt.Fprintf(w, "   年增率: %.1f%%\n", stock.YoYGrowth)
+		fmt.Fprintf(w, "   EPS增長: %.1f%%\n", stock.EPSGrowth)
+		fmt.Fprintf(w, "   EPS: %.2f元\n", stock.EPS)
+		fmt.Fprintf(w, "   負債比: %.1f%%\n", stock.DebtRatio)
+		fmt.Fprintf(w, "   本益比: %.1f | 淨值比: %.2f | 殖利率: %.2f%% | PEG: %.2f\n",
+			stock.PE, stock.PB, stock.DividendYield, stock.PEG)
+		fmt.Fprintf(w, "   合理價: %.2f | 安全邊際: %+.1f%%\n", stock.FairValue, stock.MarginOfSafety)
+		fmt.Fprintf(w, "   營業現金流/淨利: %.2f | 自由現金流殖利率: %.2f%%\n", stock.OCFToNetIncome, stock.FCFYield)
+		fmt.Fprintf(w, "   Piotroski F: %s | Altman Z: %s | Beneish M: %s\n", scoreText(stock.PiotroskiF, "%.0f"),
+			scoreText(stock.AltmanZ, "%.2f"), scoreText(stock.BeneishM, "%.2f"))
+		fmt.Fprintf(w, "   Beta: %.2f | 最大回撤: %.1f%% | Sortino: %.2f | VaR95: %.2f%%\n",
+			stock.Beta, stock.MaxDrawdown, stock.SortinoRatio, stock.VaR95)
+		fmt.Fprintf(w, "   現價: %.2f | MA60: %.2f | 相對大盤: %+.1f%%\n", stock.Price, stock.MA60, stock.RelativeStrength)
+		fmt.Fprintf(w, "   RS評等: %s | 12-1動能: %s | 距52週高點: %+.1f%%\n", rsRatingText(stock),
+			returnText(stock, rsRatingMonths, stock.Momentum12Minus1), stock.FromHigh52W)
+		fmt.Fprintf(w, "   K值: %.1f | D值: %.1f\n", stock.KValue, stock.DValue)
+		fmt.Fprintln(w, "   ---")
+	}
+
+	if len(report.Alerts) > 0 {
+		fmt.Fprintf(w, "\n【自選股警示】共 %d 則\n", len(report.Alerts))
+		for _, event := range report.Alerts {
+			fmt.Fprintf(w, "- %s %s: %s\n", event.Code, event.Name, event.Message)
+		}
+	}
+	return nil
+}
+
+// jsonRenderer JSON格式，與歷次篩選結果檔相同
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, report *ReportData) error {
+	data, err := json.MarshalIndent(report.Stocks, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// csvRenderer CSV格式，供試算表匯入
+type csvRenderer struct{}
+
+func (csvRenderer) Render(w io.Writer, report *ReportData) error {
+	// 加上 UTF-8 BOM，讓 Excel 正確辨識中文
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(reportColumns))
+	for i, col := range reportColumns {
+		header[i] = col.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, stock := range report.Stocks {
+		row := make([]string, len(reportColumns))
+		for i, col := range reportColumns {
+			row[i] = col.cellText(stock)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdownRenderer Markdown格式，供 wiki 使用
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, report *ReportData) error {
+	fmt.Fprintf(w, "# 股票篩選報告 %s\n\n", report.GeneratedAt.Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "符合條件股票共 %d 檔\n\n", len(report.Stocks))
+	if report.Regime != nil {
+		fmt.Fprintf(w, "市場狀態: %s\n\n", report.Regime)
+	}
+
+	header := make([]string, len(reportColumns))
+	align := make([]string, len(reportColumns))
+	for i, col := range reportColumns {
+		header[i] = col.Header
+		align[i] = "---"
+		if col.Format != "" {
+			align[i] = "---:"
+		}
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s|\n", strings.Join(align, "|"))
+
+	for _, stock := range report.Stocks {
+		row := make([]string, len(reportColumns))
+		for i, col := range reportColumns {
+			row[i] = strings.ReplaceAll(col.cellText(stock), "|", "\\|")
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+
+	if len(report.Alerts) > 0 {
+		fmt.Fprintf(w, "\n## 自選股警示\n\n")
+		for _, event := range report.Alerts {
+			fmt.Fprintf(w, "- **%s %s**: %s\n", event.Code, event.Name, event.Message)
+		}
+	}
+	return nil
+}
+
+// htmlRenderer 獨立HTML頁面，含可排序表格及股價/MA60走勢圖
+type htmlRenderer struct{}
+
+// htmlCell HTML表格儲存格
+type htmlCell struct {
+	Text    string
+	SortKey string
+	Numeric bool
+}
+
+// htmlRow HTML表格列
+type htmlRow struct {
+	Cells     []htmlCell
+	Sparkline template.HTML
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-Hant">
+<head>
+<meta charset="utf-8">
+<title>股票篩選報告 {{.GeneratedAt}}</title>
+<style>
+body { font-family: -apple-system, "Noto Sans TC", "Microsoft JhengHei", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; font-size: 14px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; }
+th { background: #f3f3f3; cursor: pointer; user-select: none; }
+th.asc::after { content: " ▲"; }
+th.desc::after { content: " ▼"; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr:nth-child(even) td { background: #fafafa; }
+.legend span { display: inline-block; margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>股票篩選報告</h1>
+<p>篩選時間: {{.GeneratedAt}} ｜ 符合條件股票共 {{len .Rows}} 檔</p>
+{{if .Regime}}<p>市場狀態: {{.Regime}}</p>{{end}}
+<p class="legend"><span style="color:#1f77b4">━ 收盤價</span><span style="color:#ff7f0e">━ MA60</span></p>
+<table id="report">
+<thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}<th>走勢</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .Cells}}<td{{if .Numeric}} class="num"{{end}} data-sort="{{.SortKey}}">{{.Text}}</td>{{end}}<td>{{.Sparkline}}</td></tr>
+{{end}}</tbody>
+</table>
+{{if .Alerts}}<h2>自選股警示</h2>
+<ul>{{range .Alerts}}<li><strong>{{.Code}} {{.Name}}</strong>: {{.Message}}</li>{{end}}</ul>
+{{end}}<script>
+document.querySelectorAll("#report th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#report tbody");
+    var rows = Array.prototype.slice.call(tbody.rows);
+    var asc = !th.classList.contains("asc");
+    document.querySelectorAll("#report th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(asc ? "asc" : "desc");
+    rows.sort(function (a, b) {
+      var x = a.cells[col].dataset.sort || "", y = b.cells[col].dataset.sort || "";
+      var nx = parseFloat(x), ny = parseFloat(y);
+      var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+      return asc ? cmp : -cmp;
+    });
+    rows.forEach(function (r) { tbody.appendChild(r); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+func (htmlRenderer) Render(w io.Writer, report *ReportData) error {
+	headers := make([]string, len(reportColumns))
+	for i, col := range reportColumns {
+		headers[i] = col.Header
+	}
+
+	rows := make([]htmlRow, 0, len(report.Stocks))
+	for _, stock := range report.Stocks {
+		row := htmlRow{Sparkline: sparklineSVG(stock.closes)}
+		for _, col := range reportColumns {
+			cell := htmlCell{Text: col.cellText(stock), SortKey: col.cellText(stock)}
+			if v, ok := col.Value(stock).(float64); ok {
+				cell.Numeric = true
+				cell.SortKey = fmt.Sprintf("%g", v)
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+
+	return htmlReportTemplate.Execute(w, map[string]interface{}{
+		"GeneratedAt": report.GeneratedAt.Format("2006-01-02 15:04"),
+		"Headers":     headers,
+		"Rows":        rows,
+		"Alerts":      report.Alerts,
+		"Regime":      report.Regime,
+	})
+}
+
+// movingAverage 計算移動平均序列，前 period-1 筆為 NaN
+func movingAverage(values []float64, period int) []float64 {
+	result := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			result[i] = sum / float64(period)
+		} else {
+			result[i] = math.NaN()
+		}
+	}
+	return result
+}
+
+// sparklineSVG 產生收盤價與MA60的內嵌SVG走勢圖
+func sparklineSVG(closes []float64) template.HTML {
+	const width, height = 160.0, 40.0
+	if len(closes) < 2 {
+		return ""
+	}
+
+	ma := movingAverage(closes, 60)
+	start := 0
+	if len(closes) > sparklinePoints {
+		start = len(closes) - sparklinePoints
+	}
+	closes, ma = closes[start:], ma[start:]
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range closes {
+		lo, hi = math.Min(lo, closes[i]), math.Max(hi, closes[i])
+		if !math.IsNaN(ma[i]) {
+			lo, hi = math.Min(lo, ma[i]), math.Max(hi, ma[i])
+		}
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	points := func(series []float64) string {
+		var b strings.Builder
+		for i, v := range series {
+			if math.IsNaN(v) {
+				continue
+			}
+			x := float64(i) / float64(len(series)-1) * width
+			y := height - (v-lo)/(hi-lo)*height
+			fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+		}
+		return strings.TrimSpace(b.String())
+	}
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+
+		`<polyline fill="none" stroke="#1f77b4" stroke-width="1.2" points="%s"/>`,
+		width, height, width, height, points(closes))
+	if maPoints := points(ma); maPoints != "" {
+		svg += fmt.Sprintf(`<polyline fill="none" stroke="#ff7f0e" stroke-width="1.2" points="%s"/>`, maPoints)
+	}
+	svg += `</svg>`
+
+	return template.HTML(svg)
+}
+
+// xlsxRenderer Excel活頁簿格式
+type xlsxRenderer struct{}
+
+func (xlsxRenderer) Render(w io.Writer, report *ReportData) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="篩選結果" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(report.Stocks)},
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// xlsxSheet 產生工作表XML，數值欄位以數字儲存
+func xlsxSheet(stocks []*StockData) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	writeText := func(ref, text string) {
+		fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>`, ref)
+		xml.EscapeText(&b, []byte(text))
+		b.WriteString(`</t></is></c>`)
+	}
+
+	b.WriteString(`<row r="1">`)
+	for i, col := range reportColumns {
+		writeText(xlsxCellRef(i, 1), col.Header)
+	}
+	b.WriteString(`</row>`)
+
+	for r, stock := range stocks {
+		rowNum := r + 2
+		fmt.Fprintf(&b, `<row r="%d">`, rowNum)
+		for i, col := range reportColumns {
+			ref := xlsxCellRef(i, rowNum)
+			if v, ok := col.Value(stock).(float64); ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+				fmt.Fprintf(&b, `<c r="%s"><v>%g</v></c>`, ref, v)
+			} else {
+				writeText(ref, col.cellText(stock))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxCellRef 將欄列索引轉為儲存格位置 (如 A1、AB3)
+func xlsxCellRef(col, row int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return fmt.Sprintf("%s%d", name, row)
+}
+
+// reportData 整理本次篩選的報告內容
+func (s *StockScreener) reportData(stocks []*StockData) *ReportData {
+	return &ReportData{
+		GeneratedAt: taipeiNow(),
+		Criteria:    s.criteria,
+		Stocks:      stocks,
+		Alerts:      s.alertEvents,
+		Regime:      s.regime,
+	}
+}
+
+// WriteReport 以指定格式輸出報告，filename 為 "-" 時輸出至標準輸出
+func (s *StockScreener) WriteReport(stocks []*StockData, format, filename string) error {
+	renderer, err := NewReportRenderer(format)
+	if err != nil {
+		return err
+	}
+
+	if filename == "-" {
+		return renderer.Render(os.Stdout, s.reportData(stocks))
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := renderer.Render(f, s.reportData(stocks)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestXLSXCellRef(t *testing.T) {
+	tests := []struct {
+		col, row int
+		want     string
+	}{
+		{0, 1, "A1"},
+		{25, 2, "Z2"},
+		{26, 3, "AA3"},
+		{27, 10, "AB10"},
+		{51, 1, "AZ1"},
+		{52, 1, "BA1"},
+		{701, 1, "ZZ1"},
+		{702, 1, "AAA1"},
+	}
+	for _, tt := range tests {
+		if got := xlsxCellRef(tt.col, tt.row); got != tt.want {
+			t.Errorf("xlsxCellRef(%d, %d) = %s, want %s", tt.col, tt.row, got, tt.want)
+		}
+	}
+}
+
+// testReport 含需跳脫字元及缺值欄位的報告
+func testReport() *ReportData {
+	return &ReportData{
+		GeneratedAt: taipeiNow(),
+		Criteria:    DefaultCriteria(),
+		Stocks: []*StockData{
+			{Code: "2330", Name: "台積電", Industry: "半導體業", ROE: 25.5, Price: 600},
+			{Code: "9999", Name: "A&B <測試>", ROE: 8},
+		},
+	}
+}
+
+func TestXLSXRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (xlsxRenderer{}).Render(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("無法開啟xlsx: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(data)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Errorf("缺少 %s", name)
+			continue
+		}
+		if err := xml.Unmarshal([]byte(content), new(struct{})); err != nil {
+			t.Errorf("%s 不是有效的XML: %v", name, err)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(sheet.Rows))
+	}
+	for i, row := range sheet.Rows {
+		if row.R != i+1 || len(row.Cells) != len(reportColumns) {
+			t.Errorf("row %d: r = %d, cells = %d, want %d", i, row.R, len(row.Cells), len(reportColumns))
+		}
+	}
+
+	header, first, second := sheet.Rows[0].Cells, sheet.Rows[1].Cells, sheet.Rows[2].Cells
+	if header[0].Inline != "代碼" || header[26].Ref != "AA1" || header[26].Inline != reportColumns[26].Header {
+		t.Errorf("標題列 = %+v, %+v", header[0], header[26])
+	}
+	if first[0].Type != "inlineStr" || first[0].Inline != "2330" || first[0].Ref != "A2" {
+		t.Errorf("代碼 = %+v, want 文字 2330", first[0])
+	}
+	if second[1].Inline != "A&B <測試>" {
+		t.Errorf("名稱 = %q, 應正確跳脫", second[1].Inline)
+	}
+	roe := slices.IndexFunc(reportColumns, func(c reportColumn) bool { return c.Header == "ROE(%)" })
+	if first[roe].Type != "" || first[roe].Value != "25.5" {
+		t.Errorf("ROE = %+v, want 數值 25.5", first[roe])
+	}
+	rs := slices.IndexFunc(reportColumns, func(c reportColumn) bool { return c.Header == "RS評等" })
+	if first[rs].Type != "inlineStr" || first[rs].Inline != "-" {
+		t.Errorf("RS評等 = %+v, want 文字 -", first[rs])
+	}
+}
+
+func TestCSVRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (csvRenderer{}).Render(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	content, ok := strings.CutPrefix(buf.String(), "\ufeff")
+	if !ok {
+		t.Fatalf("缺少UTF-8 BOM: %q", buf.String()[:min(10, buf.Len())])
+	}
+
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %d, want 3", len(records))
+	}
+	header := make([]string, len(reportColumns))
+	for i, col := range reportColumns {
+		header[i] = col.Header
+	}
+	if !slices.Equal(records[0], header) || records[0][0] != "代碼" {
+		t.Errorf("header = %v, want %v", records[0], header)
+	}
+	if records[1][0] != "2330" || records[1][1] != "台積電" || records[2][1] != "A&B <測試>" {
+		t.Errorf("rows = %v", records[1:])
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// 風險指標設定
+const (
+	benchmarkSymbol = "^TWII" // 加權指數 (Yahoo Finance 代碼)
+	riskFreeRate    = 1.5     // 無風險利率 (%/年)，約為一年期定存利率
+	minRiskReturns  = 20      // 計算風險指標所需的最少報酬率期數
+	varZ95          = 1.645   // 常態分布單尾95%的z值
+)
+
+// periodsPerYear 每年的K棒數，供年化使用
+func periodsPerYear(interval string) float64 {
+	switch interval {
+	case "1wk":
+		return 52
+	case "1mo":
+		return 12
+	}
+	return 252
+}
+
+// barReturns K棒收盤價的單期報酬率
+func barReturns(bars []Bar) []float64 {
+	if len(bars) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(bars)-1)
+	for i := 1; i < len(bars); i++ {
+		if bars[i-1].Close > 0 {
+			returns = append(returns, bars[i].Close/bars[i-1].Close-1)
+		}
+	}
+	return returns
+}
+
+// maxDrawdown 最大回撤 (%) 及其期間 (自前高至收復的K棒數，未收復時至最新一根)
+func maxDrawdown(closes []float64) (float64, int) {
+	peak, worst, worstPeak := 0, 0.0, -1
+	for i, c := range closes {
+		if c >= closes[peak] {
+			peak = i
+			continue
+		}
+		if dd := 1 - c/closes[peak]; dd > worst {
+			worst, worstPeak = dd, peak
+		}
+	}
+	if worstPeak < 0 {
+		return 0, 0
+	}
+
+	end := len(closes) - 1
+	for i := worstPeak + 1; i < len(closes); i++ {
+		if closes[i] >= closes[worstPeak] {
+			end = i
+			break
+		}
+	}
+	return worst * 100, end - worstPeak
+}
+
+// downsideDeviation 低於目標報酬的下方標準差
+func downsideDeviation(returns []float64, target float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, r := range returns {
+		if d := r - target; d < 0 {
+			sum += d * d
+		}
+	}
+	return math.Sqrt(sum / float64(len(returns)))
+}
+
+// alignedReturns 依日期對齊兩組K棒，回傳兩者皆有收盤價的相鄰交易日之間的報酬率
+// 停牌或上市較晚造成的缺漏日期不列入，跨越缺漏的報酬率以前後共同交易日計算
+func alignedReturns(a, b []Bar) ([]float64, []float64) {
+	closes := make(map[string]float64, len(b))
+	for _, bar := range b {
+		closes[bar.Time.Format("2006-01-02")] = bar.Close
+	}
+
+	var ra, rb []float64
+	prevA, prevB := 0.0, 0.0
+	for _, bar := range a {
+		other, ok := closes[bar.Time.Format("2006-01-02")]
+		if !ok || other <= 0 || bar.Close <= 0 {
+			continue
+		}
+		if prevA > 0 {
+			ra = append(ra, bar.Close/prevA-1)
+			rb = append(rb, other/prevB-1)
+		}
+		prevA, prevB = bar.Close, other
+	}
+	return ra, rb
+}
+
+// covariance 兩組等長報酬率的母體共變異數
+func covariance(a, b []float64) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	meanA, _ := meanStd(a)
+	meanB, _ := meanStd(b)
+	cov := 0.0
+	for i := range a {
+		cov += (a[i] - meanA) * (b[i] - meanB)
+	}
+	return cov / float64(len(a))
+}
+
+// calculateBeta 依日期對齊個股與大盤收盤價後計算Beta，對齊期數不足時 ok 為 false
+func calculateBeta(bars, benchmark []Bar) (float64, bool) {
+	stockReturns, marketReturns := alignedReturns(bars, benchmark)
+	if len(stockReturns) < minRiskReturns {
+		return 0, false
+	}
+
+	marketVar := covariance(marketReturns, marketReturns)
+	if marketVar == 0 {
+		return 0, false
+	}
+	return covariance(stockReturns, marketReturns) / marketVar, true
+}
+
+// loadBenchmark 取得加權指數日K棒 (至少一年，供市場狀態判斷)，每次執行只取得一次
+// 只有 Yahoo Finance 提供指數資料，失敗時回傳 nil
+func (s *StockScreener) loadBenchmark(ctx context.Context) []Bar {
+	if s.benchmarkLoaded {
+		return s.benchmark
+	}
+	s.benchmarkLoaded = true
+
+	for _, source := range s.priceSources {
+		if source.Name() != priceSourceYahoo {
+			continue
+		}
+		opts := ChartOptions{Range: longerRange(s.fetchOptions().Range, benchmarkMinRange), Interval: defaultChartInterval}
+		chart, err := source.FetchChart(ctx, benchmarkSymbol, opts)
+		if err != nil {
+			s.logger.Warn("無法取得加權指數", "symbol", benchmarkSymbol, "error", err)
+			return nil
+		}
+		s.benchmark = chart.Bars
+		s.logger.Debug("加權指數", "symbol", benchmarkSymbol, "bars", len(chart.Bars))
+		break
+	}
+	return s.benchmark
+}
+
+// calculateRisk 由完整價格歷史 (至少一年，見 fetchOptions) 計算Beta、夏普比率、Sortino比率、最大回撤及95% VaR
+// 報酬率期數不足 minRiskReturns 時不計算；無大盤資料時 Beta 為 0
+func (s *StockScreener) calculateRisk(ctx context.Context, stock *StockData) {
+	bars := s.historyBars(stock)
+	returns := barReturns(bars)
+	if len(returns) < minRiskReturns {
+		return
+	}
+
+	periods := periodsPerYear(s.chart.Interval)
+	rf := riskFreeRate / 100 / periods
+	mean, std := meanStd(returns)
+
+	stock.SharpeRatio = CalculateSharpeRatio(returns, rf) * math.Sqrt(periods)
+	stock.SortinoRatio = 0
+	if downside := downsideDeviation(returns, rf); downside > 0 {
+		stock.SortinoRatio = (mean - rf) / downside * math.Sqrt(periods)
+	}
+
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.Close
+	}
+	stock.MaxDrawdown, stock.DrawdownDuration = maxDrawdown(closes)
+
+	sorted := make([]float64, len(returns))
+	copy(sorted, returns)
+	sort.Float64s(sorted)
+	stock.VaR95 = max(0, -quantile(sorted, 0.05)*100)
+	stock.ParametricVaR95 = max(0, -(mean-varZ95*std)*100)
+
+	// 加權指數為日K棒，依個股K棒週期合併後對齊
+	stock.Beta = 0
+	if benchmark := s.loadBenchmark(ctx); len(benchmark) > 0 {
+		if beta, ok := calculateBeta(bars, resampleBars(benchmark, s.chart.Interval)); ok {
+			stock.Beta = beta
+		}
+	}
+
+	s.logger.Debug("風險指標", logKeyStock, stock.Code, "beta", stock.Beta, "sharpe", stock.SharpeRatio,
+		"sortino", stock.SortinoRatio, "max_drawdown", stock.MaxDrawdown, "drawdown_duration", stock.DrawdownDuration,
+		"var95", stock.VaR95, "parametric_var95", stock.ParametricVaR95)
+}
+
+// printRisk 輸出風險指標
+func printRisk(w io.Writer, stock *StockData) {
+	beta := "- (無加權指數資料)"
+	if stock.Beta != 0 {
+		beta = fmt.Sprintf("%.2f", stock.Beta)
+	}
+	fmt.Fprintf(w, "Beta: %s | 夏普比率: %.2f | Sortino: %.2f\n", beta, stock.SharpeRatio, stock.SortinoRatio)
+	fmt.Fprintf(w, "最大回撤: %.1f%% (%d根) | VaR95: 歷史 %.2f%% / 參數 %.2f%%\n",
+		stock.MaxDrawdown, stock.DrawdownDuration, stock.VaR95, stock.ParametricVaR95)
+}
+
+// riskCapText 風險上限的顯示文字，0 表示不檢查
+func riskCapText(limit float64, format string) string {
+	if limit <= 0 {
+		return "不檢查"
+	}
+	return fmt.Sprintf(format, limit)
+}
+
+// evaluateRisk 風險上限規則 (第四階段)，上限為 0 或無法計算時不排除
+func (s *StockScreener) evaluateRisk(stock *StockData) []RuleVerdict {
+	c := s.criteria
+	rule := func(name, value string, passed bool, reason string) RuleVerdict {
+		return gradeRule(name, value, passed, false, "", "", "", reason)
+	}
+
+	beta := "-"
+	if stock.Beta != 0 {
+		beta = fmt.Sprintf("%.2f", stock.Beta)
+	}
+	return []RuleVerdict{
+		rule("Beta", beta, c.MaxBeta <= 0 || stock.Beta == 0 || stock.Beta <= c.MaxBeta,
+			fmt.Sprintf("Beta過高 %.2f (>%.2f)", stock.Beta, c.MaxBeta)),
+		rule("最大回撤", fmt.Sprintf("%.1f%% (%d根)", stock.MaxDrawdown, stock.DrawdownDuration),
+			c.MaxDrawdown <= 0 || stock.MaxDrawdown <= c.MaxDrawdown,
+			fmt.Sprintf("最大回撤過大 %.1f%% (>%.0f%%)", stock.MaxDrawdown, c.MaxDrawdown)),
+		rule("VaR95", fmt.Sprintf("%.2f%%", stock.VaR95), c.MaxVaR95 <= 0 || stock.VaR95 <= c.MaxVaR95,
+			fmt.Sprintf("VaR95過高 %.2f%% (>%.2f%%)", stock.VaR95, c.MaxVaR95)),
+	}
+}
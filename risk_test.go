@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"testing"
+)
+
+func TestMaxDrawdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		closes       []float64
+		wantDrawdown float64
+		wantDuration int
+	}{
+		{"無資料", nil, 0, 0},
+		{"持續上漲", []float64{100, 110, 120}, 0, 0},
+		{"回撤後收復", []float64{100, 120, 90, 130}, 25, 2},
+		{"未收復至最新一根", []float64{100, 80, 90}, 20, 2},
+		{"取最大的一次回撤", []float64{100, 90, 100, 200, 100, 150, 210}, 50, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drawdown, duration := maxDrawdown(tt.closes)
+			if !approxEqual(drawdown, tt.wantDrawdown, 1e-9) || duration != tt.wantDuration {
+				t.Errorf("maxDrawdown = %v, %d, want %v, %d", drawdown, duration, tt.wantDrawdown, tt.wantDuration)
+			}
+		})
+	}
+}
+
+// scaledCloses 以大盤報酬率的固定倍數產生個股收盤價
+func scaledCloses(market []float64, factor float64) []float64 {
+	closes := make([]float64, len(market))
+	closes[0] = 50
+	for i := 1; i < len(market); i++ {
+		closes[i] = closes[i-1] * (1 + factor*(market[i]/market[i-1]-1))
+	}
+	return closes
+}
+
+func TestCalculateBeta(t *testing.T) {
+	market := zigzag(30, 0.01, 0)
+	tests := []struct {
+		name      string
+		stock     []Bar
+		benchmark []Bar
+		want      float64
+		wantOK    bool
+	}{
+		{"兩倍大盤報酬", dailyBars(scaledCloses(market, 2)...), dailyBars(market...), 2, true},
+		{"反向半倍", dailyBars(scaledCloses(market, -0.5)...), dailyBars(market...), -0.5, true},
+		{"與大盤相同", dailyBars(market...), dailyBars(market...), 1, true},
+		{"對齊期數不足", dailyBars(market[:minRiskReturns]...), dailyBars(market...), 0, false},
+		{"大盤無波動", dailyBars(market...), dailyBars(make([]float64, 30)...), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beta, ok := calculateBeta(tt.stock, tt.benchmark)
+			if ok != tt.wantOK || !approxEqual(beta, tt.want, 1e-9) {
+				t.Errorf("calculateBeta = %v, %v, want %v, %v", beta, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCalculateRiskUsesFullHistory(t *testing.T) {
+	// 近一年日K棒: 前半段腰斬後收復，近三個月穩定上漲
+	n := 250
+	bars := make([]Bar, n)
+	day := startOfDay(taipeiNow()).AddDate(0, 0, -n+1)
+	for i := range bars {
+		c := 100 + float64(i)*0.1
+		if i >= 40 && i < 80 {
+			c /= 2
+		}
+		bars[i] = Bar{Time: day.AddDate(0, 0, i), Close: c}
+	}
+
+	s := &StockScreener{
+		chart:           ChartOptions{Range: "3mo", Interval: "1d"},
+		logger:          slog.New(slog.DiscardHandler),
+		benchmarkLoaded: true,
+	}
+	stock := &StockData{Code: "2330", bars: bars}
+	if window := s.indicatorBars(stock); len(window) >= 100 {
+		t.Fatalf("3mo 區間不應包含回撤: %d 根", len(window))
+	}
+
+	s.calculateRisk(context.Background(), stock)
+	if want := (1 - (104.0/2)/103.9) * 100; math.Abs(stock.MaxDrawdown-want) > 1e-9 {
+		t.Errorf("MaxDrawdown = %v, want %v (完整價格歷史)", stock.MaxDrawdown, want)
+	}
+}
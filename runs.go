@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunRecord 歷次篩選結果檔
+type RunRecord struct {
+	File   string
+	Time   time.Time
+	Stocks []*StockData
+}
+
+// ListRuns 列出所有篩選結果檔 (由舊到新)
+func ListRuns() ([]string, error) {
+	files, err := filepath.Glob(resultsFilePattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// LoadRun 讀取篩選結果檔
+func LoadRun(filename string) (*RunRecord, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &RunRecord{File: filename}
+	if err := json.Unmarshal(data, &record.Stocks); err != nil {
+		return nil, fmt.Errorf("解析篩選結果 %s 失敗: %v", filename, err)
+	}
+
+	// 由檔名解析執行時間
+	base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(filename), "screening_results_"), ".json")
+	if t, err := time.ParseInLocation("20060102_150405", base, taipeiLocation); err == nil {
+		record.Time = t
+	}
+
+	return record, nil
+}
+
+// RunDiff 兩次篩選結果的差異
+type RunDiff struct {
+	Added   []*StockData
+	Removed []*StockData
+	Changed []ScoreChange
+}
+
+// ScoreChange 兩次皆入選股票的評分變化
+type ScoreChange struct {
+	Code     string
+	Name     string
+	OldScore float64
+	NewScore float64
+	OldRank  int
+	NewRank  int
+}
+
+// DiffRuns 比較兩次篩選結果
+func DiffRuns(older, newer *RunRecord) *RunDiff {
+	oldIndex := make(map[string]int, len(older.Stocks))
+	for i, stock := range older.Stocks {
+		oldIndex[stock.Code] = i
+	}
+	newIndex := make(map[string]int, len(newer.Stocks))
+	for i, stock := range newer.Stocks {
+		newIndex[stock.Code] = i
+	}
+
+	diff := &RunDiff{}
+	for i, stock := range newer.Stocks {
+		j, ok := oldIndex[stock.Code]
+		if !ok {
+			diff.Added = append(diff.Added, stock)
+			continue
+		}
+		diff.Changed = append(diff.Changed, ScoreChange{
+			Code:     stock.Code,
+			Name:     stock.Name,
+			OldScore: older.Stocks[j].Score,
+			NewScore: stock.Score,
+			OldRank:  j + 1,
+			NewRank:  i + 1,
+		})
+	}
+	for _, stock := range older.Stocks {
+		if _, ok := newIndex[stock.Code]; !ok {
+			diff.Removed = append(diff.Removed, stock)
+		}
+	}
+
+	return diff
+}
+
+// PrintRunDiff 輸出篩選結果差異
+func PrintRunDiff(older, newer *RunRecord, diff *RunDiff) {
+	fmt.Printf("比較 %s → %s\n", older.File, newer.File)
+
+	fmt.Printf("\n【新進榜】%d 檔\n", len(diff.Added))
+	for _, stock := range diff.Added {
+		fmt.Printf("+ %s %s 評分 %.1f\n", stock.Code, stock.Name, stock.Score)
+	}
+
+	fmt.Printf("\n【退出榜單】%d 檔\n", len(diff.Removed))
+	for _, stock := range diff.Removed {
+		fmt.Printf("- %s %s 評分 %.1f\n", stock.Code, stock.Name, stock.Score)
+	}
+
+	fmt.Printf("\n【評分變化】%d 檔\n", len(diff.Changed))
+	for _, c := range diff.Changed {
+		fmt.Printf("  %s %s 評分 %.1f → %.1f (%+.1f) | 排名 %d → %d\n",
+			c.Code, c.Name, c.OldScore, c.NewScore, c.NewScore-c.OldScore, c.OldRank, c.NewRank)
+	}
+}
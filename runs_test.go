@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingNotifier 記錄收到的通知
+type recordingNotifier struct {
+	digests []*ScreeningDigest
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(digest *ScreeningDigest) error {
+	n.digests = append(n.digests, digest)
+	return nil
+}
+
+func TestScreenStocksCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	notifier := &recordingNotifier{}
+	s := &StockScreener{
+		logger:          slog.New(slog.DiscardHandler),
+		summary:         NewRunSummary(),
+		criteria:        DefaultCriteria(),
+		benchmarkLoaded: true,
+	}
+	s.AddNotifier(notifier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stocks, err := s.ScreenStocks(ctx, []string{"2330", "2317"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(stocks) != 0 {
+		t.Errorf("stocks = %d, want 0", len(stocks))
+	}
+	if !strings.Contains(s.summary.Interrupted, "0/2") {
+		t.Errorf("Interrupted = %q, want 已完成 0/2", s.summary.Interrupted)
+	}
+	if len(notifier.digests) != 0 {
+		t.Errorf("中斷時不應發送通知，收到 %d 則", len(notifier.digests))
+	}
+}
+
+func TestPartialResultsNotListed(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	s := &StockScreener{}
+	full := resultsFilename("20240628_150000", false)
+	if err := s.SaveResults([]*StockData{{Code: "2330"}, {Code: "2317"}}, full); err != nil {
+		t.Fatal(err)
+	}
+	partial := resultsFilename("20240701_150000", true)
+	if err := s.SaveResults([]*StockData{{Code: "2330"}}, partial); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(partial); err != nil {
+		t.Fatalf("部分結果應寫入 %s: %v", partial, err)
+	}
+
+	files, err := ListRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{full}; !reflect.DeepEqual(files, want) {
+		t.Errorf("ListRuns = %v, want %v", files, want)
+	}
+
+	// 前次結果應為最近一次完整執行，而非較新的部分結果
+	codes, err := loadPreviousQualifiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2330", "2317"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("previous = %v, want %v", codes, want)
+	}
+}
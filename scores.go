@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Altman Z 及 Beneish M 常用門檻
+const (
+	altmanZDistress   = 1.81  // Z < 1.81 為困境區
+	altmanZSafe       = 2.99  // Z > 2.99 為安全區
+	altmanZEMDistress = 4.35  // 新興市場 Z''(EM) < 4.35 為困境區
+	altmanZEMSafe     = 5.85  // 新興市場 Z''(EM) > 5.85 為安全區
+	beneishMThreshold = -1.78 // M > -1.78 可能有財報操縱
+	beneishMinIndices = 5     // 至少需計算出的 Beneish 指標數
+
+	piotroskiSignals    = 9 // Piotroski F-Score 訊號數
+	piotroskiMinSignals = 6 // 判定門檻所需的最少有資料訊號數
+)
+
+// ScoreComponent 綜合分數的單一組成項目
+type ScoreComponent struct {
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// CompositeScore 由多個組成項目計算的綜合分數
+type CompositeScore struct {
+	Value      float64          `json:"value"`
+	Available  int              `json:"available,omitempty"` // 有資料的組成項目數 (Piotroski F)
+	Components []ScoreComponent `json:"components"`
+}
+
+// add 加入組成項目
+func (c *CompositeScore) add(name string, value float64, detail string) {
+	c.Components = append(c.Components, ScoreComponent{Name: name, Value: value, Detail: detail})
+}
+
+// statementHistory 財報歷史 (日期 → 科目 → 數值)，供跨年度比較的綜合分數使用
+type statementHistory struct {
+	income   map[string]map[string]float64 // 單季損益表
+	balance  map[string]map[string]float64 // 季底資產負債表
+	cashFlow map[string]map[string]float64 // 單季現金流量 (由累計數值還原)
+}
+
+// newStatementHistory 建立空的財報歷史
+func newStatementHistory() *statementHistory {
+	return &statementHistory{
+		income:   make(map[string]map[string]float64),
+		balance:  make(map[string]map[string]float64),
+		cashFlow: make(map[string]map[string]float64),
+	}
+}
+
+// record 記錄單一科目數值
+func record(section map[string]map[string]float64, date, itemType string, value float64) {
+	if section[date] == nil {
+		section[date] = make(map[string]float64)
+	}
+	section[date][itemType] = value
+}
+
+// series 取得科目的時間序列，依序採用第一個存在的 type
+func series(section map[string]map[string]float64, types ...string) map[string]float64 {
+	values := make(map[string]float64)
+	for date, items := range section {
+		for _, t := range types {
+			if v, ok := items[t]; ok {
+				values[date] = v
+				break
+			}
+		}
+	}
+	return values
+}
+
+// ttm 近四季加總，yearsAgo 為 1 時為前一年度的四季
+func (h *statementHistory) ttm(section map[string]map[string]float64, yearsAgo int, types ...string) (float64, bool) {
+	return trailingQuartersAt(series(section, types...), yearsAgo*4)
+}
+
+// balanceAt 最新一季 (yearsAgo 為 1 時為一年前同季) 的資產負債表科目
+func (h *statementHistory) balanceAt(yearsAgo int, types ...string) (float64, bool) {
+	latest := ""
+	for date := range h.balance {
+		if date > latest {
+			latest = date
+		}
+	}
+	t, err := time.Parse("2006-01-02", latest)
+	if err != nil {
+		return 0, false
+	}
+	v, ok := series(h.balance, types...)[t.AddDate(-yearsAgo, 0, 0).Format("2006-01-02")]
+	return v, ok
+}
+
+// trailingQuartersAt 略過最近 skip 季後的連續四季加總，季度不足或不連續時 ok 為 false
+func trailingQuartersAt(quarterly map[string]float64, skip int) (float64, bool) {
+	dates := make([]string, 0, len(quarterly))
+	for date := range quarterly {
+		dates = append(dates, date)
+	}
+	if len(dates) < skip+4 {
+		return 0, false
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	// 最新一季至最舊一季的間隔不得超過 skip+3 季 (允許月底日數差異)
+	latest, err1 := time.Parse("2006-01-02", dates[0])
+	oldest, err2 := time.Parse("2006-01-02", dates[skip+3])
+	maxSpan := time.Duration((skip+3)*92+25) * 24 * time.Hour
+	if err1 != nil || err2 != nil || latest.Sub(oldest) > maxSpan {
+		return 0, false
+	}
+
+	sum := 0.0
+	for _, date := range dates[skip : skip+4] {
+		sum += quarterly[date]
+	}
+	return sum, true
+}
+
+// safeRatio a/b，b 為 0 時為 0
+func safeRatio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// calculateFinancialScores 計算 Piotroski F、Altman Z (含新興市場版) 及 Beneish M
+// 需在財報及估值資料取得後呼叫 (Altman Z 以股價淨值比換算股東權益市值)
+func (s *StockScreener) calculateFinancialScores(stock *StockData) {
+	h := stock.statements
+	if h == nil {
+		return
+	}
+	stock.PiotroskiF = piotroskiFScore(h)
+	stock.AltmanZ, stock.AltmanZEM = altmanZScores(h, stock.PB)
+	stock.BeneishM = beneishMScore(h)
+
+	s.logger.Debug("財務評分", logKeyStock, stock.Code, "piotroski_f", scoreText(stock.PiotroskiF, "%.0f"),
+		"altman_z", scoreText(stock.AltmanZ, "%.2f"), "altman_z_em", scoreText(stock.AltmanZEM, "%.2f"),
+		"beneish_m", scoreText(stock.BeneishM, "%.2f"))
+}
+
+// piotroskiFScore Piotroski F-Score: 比較近四季與前一年度的9項訊號，每項符合得1分
+// 資產報酬率以期末總資產計算；資料不足的訊號以0分計並註明，有資料的訊號數記於 Available
+func piotroskiFScore(h *statementHistory) *CompositeScore {
+	ni0, okNI0 := h.ttm(h.income, 0, "IncomeAfterTaxes")
+	ta0, okTA0 := h.balanceAt(0, "TotalAssets")
+	if !okNI0 || !okTA0 || ta0 <= 0 {
+		return nil
+	}
+	ni1, okNI1 := h.ttm(h.income, 1, "IncomeAfterTaxes")
+	ta1, okTA1 := h.balanceAt(1, "TotalAssets")
+	cfo, okCFO := h.ttm(h.cashFlow, 0, operatingCashFlowTypes...)
+	ncl0, okNCL0 := h.balanceAt(0, "NoncurrentLiabilities")
+	ncl1, okNCL1 := h.balanceAt(1, "NoncurrentLiabilities")
+	ca0, okCA0 := h.balanceAt(0, "CurrentAssets")
+	ca1, okCA1 := h.balanceAt(1, "CurrentAssets")
+	cl0, okCL0 := h.balanceAt(0, "CurrentLiabilities")
+	cl1, okCL1 := h.balanceAt(1, "CurrentLiabilities")
+	sh0, okSH0 := h.balanceAt(0, "OrdinaryShare", "CapitalStock")
+	sh1, okSH1 := h.balanceAt(1, "OrdinaryShare", "CapitalStock")
+	rev0, okRev0 := h.ttm(h.income, 0, "Revenue")
+	rev1, okRev1 := h.ttm(h.income, 1, "Revenue")
+	gp0, okGP0 := h.ttm(h.income, 0, "GrossProfit")
+	gp1, okGP1 := h.ttm(h.income, 1, "GrossProfit")
+
+	score := &CompositeScore{}
+	signal := func(name string, available, passed bool, detail string) {
+		value := 0.0
+		if !available {
+			detail = "資料不足"
+		} else {
+			score.Available++
+			if passed {
+				value = 1
+			}
+		}
+		score.Value += value
+		score.add(name, value, detail)
+	}
+
+	roa0 := ni0 / ta0
+	roa1 := safeRatio(ni1, ta1)
+	signal("ROA為正", true, roa0 > 0, fmt.Sprintf("%.2f%%", roa0*100))
+	signal("營業現金流為正", okCFO, cfo > 0, fmt.Sprintf("%.0f", cfo))
+	signal("ROA提升", okNI1 && okTA1 && ta1 > 0, roa0 > roa1,
+		fmt.Sprintf("%.2f%% → %.2f%%", roa1*100, roa0*100))
+	signal("營業現金流大於淨利", okCFO, cfo > ni0, fmt.Sprintf("%.0f vs %.0f", cfo, ni0))
+
+	lev0, lev1 := ncl0/ta0, safeRatio(ncl1, ta1)
+	signal("長期負債比下降", okNCL0 && okNCL1 && okTA1, lev0 <= lev1,
+		fmt.Sprintf("%.2f%% → %.2f%%", lev1*100, lev0*100))
+
+	cr0, cr1 := safeRatio(ca0, cl0), safeRatio(ca1, cl1)
+	signal("流動比率提升", okCA0 && okCA1 && okCL0 && okCL1 && cl0 > 0 && cl1 > 0, cr0 > cr1,
+		fmt.Sprintf("%.2f → %.2f", cr1, cr0))
+	signal("未增發股票", okSH0 && okSH1, sh0 <= sh1, fmt.Sprintf("股本 %.0f → %.0f", sh1, sh0))
+
+	gm0, gm1 := safeRatio(gp0, rev0), safeRatio(gp1, rev1)
+	signal("毛利率提升", okGP0 && okGP1 && okRev0 && okRev1 && rev0 > 0 && rev1 > 0, gm0 > gm1,
+		fmt.Sprintf("%.2f%% → %.2f%%", gm1*100, gm0*100))
+
+	at0, at1 := rev0/ta0, safeRatio(rev1, ta1)
+	signal("資產周轉率提升", okRev0 && okRev1 && okTA1 && ta1 > 0, at0 > at1,
+		fmt.Sprintf("%.3f → %.3f", at1, at0))
+	return score
+}
+
+// altmanZScores Altman Z-Score (上市製造業) 及新興市場版 Z-EM
+// Z = 1.2X1 + 1.4X2 + 3.3X3 + 0.6X4 + X5，X4 為股東權益市值/總負債 (無股價淨值比時不計算)
+// Z-EM = 3.25 + 6.56X1 + 3.26X2 + 6.72X3 + 1.05X4'，X4' 為股東權益帳面值/總負債
+func altmanZScores(h *statementHistory, pb float64) (z, zem *CompositeScore) {
+	ta, okTA := h.balanceAt(0, "TotalAssets")
+	ca, okCA := h.balanceAt(0, "CurrentAssets")
+	cl, okCL := h.balanceAt(0, "CurrentLiabilities")
+	liabilities, okL := h.balanceAt(0, "Liabilities")
+	equity, okE := h.balanceAt(0, "Equity")
+	retained, okRE := h.balanceAt(0, "RetainedEarnings")
+	ebit, okEBIT := h.ttm(h.income, 0, "OperatingIncome", "PreTaxIncome")
+	sales, okSales := h.ttm(h.income, 0, "Revenue")
+	if !okTA || !okCA || !okCL || !okL || !okE || !okRE || !okEBIT || !okSales || ta <= 0 || liabilities <= 0 {
+		return nil, nil
+	}
+
+	x1 := (ca - cl) / ta
+	x2 := retained / ta
+	x3 := ebit / ta
+	x4Book := equity / liabilities
+	x5 := sales / ta
+
+	zem = &CompositeScore{Value: 3.25 + 6.56*x1 + 3.26*x2 + 6.72*x3 + 1.05*x4Book}
+	zem.add("X1 營運資金/總資產", x1, "")
+	zem.add("X2 保留盈餘/總資產", x2, "")
+	zem.add("X3 EBIT/總資產", x3, "")
+	zem.add("X4 權益帳面值/總負債", x4Book, "")
+
+	if pb > 0 {
+		x4 := pb * equity / liabilities
+		z = &CompositeScore{Value: 1.2*x1 + 1.4*x2 + 3.3*x3 + 0.6*x4 + x5}
+		z.add("X1 營運資金/總資產", x1, "")
+		z.add("X2 保留盈餘/總資產", x2, "")
+		z.add("X3 EBIT/總資產", x3, "")
+		z.add("X4 權益市值/總負債", x4, fmt.Sprintf("股價淨值比 %.2f", pb))
+		z.add("X5 營收/總資產", x5, "")
+	}
+	return z, zem
+}
+
+// beneishMScore Beneish M-Score (8變數)，比較近四季與前一年度
+// 無法計算的指標以中性值 (1，TATA 為 0) 代入並註明，可計算指標不足 beneishMinIndices 時不計算
+func beneishMScore(h *statementHistory) *CompositeScore {
+	sales0, ok0 := h.ttm(h.income, 0, "Revenue")
+	sales1, ok1 := h.ttm(h.income, 1, "Revenue")
+	ta0, okTA0 := h.balanceAt(0, "TotalAssets")
+	ta1, okTA1 := h.balanceAt(1, "TotalAssets")
+	if !ok0 || !ok1 || !okTA0 || !okTA1 || sales0 <= 0 || sales1 <= 0 || ta0 <= 0 || ta1 <= 0 {
+		return nil
+	}
+
+	score := &CompositeScore{Value: -4.84}
+	computed := 0
+	index := func(name string, weight float64, value float64, available bool, neutral float64) {
+		detail := ""
+		if available {
+			computed++
+		} else {
+			value, detail = neutral, "資料不足，以中性值計"
+		}
+		score.Value += weight * value
+		score.add(name, value, detail)
+	}
+
+	ar0, okAR0 := h.balanceAt(0, "AccountsReceivableNet")
+	ar1, okAR1 := h.balanceAt(1, "AccountsReceivableNet")
+	index("DSRI 應收帳款周轉", 0.92, safeRatio(ar0/sales0, ar1/sales1), okAR0 && okAR1 && ar1 > 0, 1)
+
+	gp0, okGP0 := h.ttm(h.income, 0, "GrossProfit")
+	gp1, okGP1 := h.ttm(h.income, 1, "GrossProfit")
+	index("GMI 毛利率變化", 0.528, safeRatio(gp1/sales1, gp0/sales0), okGP0 && okGP1 && gp0 > 0, 1)
+
+	ca0, okCA0 := h.balanceAt(0, "CurrentAssets")
+	ca1, okCA1 := h.balanceAt(1, "CurrentAssets")
+	ppe0, okPPE0 := h.balanceAt(0, "PropertyPlantAndEquipment")
+	ppe1, okPPE1 := h.balanceAt(1, "PropertyPlantAndEquipment")
+	soft0, soft1 := 1-(ca0+ppe0)/ta0, 1-(ca1+ppe1)/ta1
+	index("AQI 資產品質", 0.404, safeRatio(soft0, soft1), okCA0 && okCA1 && okPPE0 && okPPE1 && soft1 > 0, 1)
+
+	index("SGI 營收成長", 0.892, sales0/sales1, true, 1)
+
+	dep0, okDep0 := h.ttm(h.cashFlow, 0, depreciationTypes...)
+	dep1, okDep1 := h.ttm(h.cashFlow, 1, depreciationTypes...)
+	depRate0, depRate1 := safeRatio(dep0, dep0+ppe0), safeRatio(dep1, dep1+ppe1)
+	index("DEPI 折舊率", 0.115, safeRatio(depRate1, depRate0),
+		okDep0 && okDep1 && okPPE0 && okPPE1 && depRate0 > 0, 1)
+
+	sga0, okSGA0 := h.ttm(h.income, 0, "OperatingExpenses")
+	sga1, okSGA1 := h.ttm(h.income, 1, "OperatingExpenses")
+	index("SGAI 營業費用率", -0.172, safeRatio(sga0/sales0, sga1/sales1), okSGA0 && okSGA1 && sga1 > 0, 1)
+
+	ni0, okNI0 := h.ttm(h.income, 0, "IncomeAfterTaxes")
+	cfo0, okCFO0 := h.ttm(h.cashFlow, 0, operatingCashFlowTypes...)
+	index("TATA 總應計/總資產", 4.679, (ni0-cfo0)/ta0, okNI0 && okCFO0, 0)
+
+	l0, okL0 := h.balanceAt(0, "Liabilities")
+	l1, okL1 := h.balanceAt(1, "Liabilities")
+	index("LVGI 財務槓桿", -0.327, safeRatio(l0/ta0, l1/ta1), okL0 && okL1 && l1 > 0, 1)
+
+	if computed < beneishMinIndices {
+		return nil
+	}
+	return score
+}
+
+// scoreText 綜合分數的顯示文字，無法計算時為 "-"
+func scoreText(score *CompositeScore, format string) string {
+	if score == nil {
+		return "-"
+	}
+	return fmt.Sprintf(format, score.Value)
+}
+
+// scoreCell 表格欄位值，無法計算時為 "-"
+func scoreCell(score *CompositeScore) interface{} {
+	if score == nil {
+		return "-"
+	}
+	return score.Value
+}
+
+// altmanZone Altman Z 所在區間，無法計算時為空字串
+func altmanZone(score *CompositeScore, distress, safe float64) string {
+	switch {
+	case score == nil:
+		return ""
+	case score.Value > safe:
+		return "安全區"
+	case score.Value < distress:
+		return "困境區"
+	}
+	return "灰色區"
+}
+
+// piotroskiThreshold 依有資料的訊號數等比例調整的 F-Score 門檻 (無條件進位)
+func piotroskiThreshold(min, available int) int {
+	return (min*available + piotroskiSignals - 1) / piotroskiSignals
+}
+
+// evaluatePiotroski Piotroski F-Score 規則，門檻依有資料的訊號數調整，不足 piotroskiMinSignals 項時不判定
+func (s *StockScreener) evaluatePiotroski(f *CompositeScore) RuleVerdict {
+	if f == nil {
+		return skipRule("Piotroski F", "無法計算")
+	}
+	if f.Available < piotroskiMinSignals {
+		return skipRule("Piotroski F", fmt.Sprintf("僅%d/%d項有資料", f.Available, piotroskiSignals))
+	}
+	min := piotroskiThreshold(s.criteria.MinPiotroskiF, f.Available)
+	return gradeRule("Piotroski F", fmt.Sprintf("%.0f (%d/%d項)", f.Value, f.Available, piotroskiSignals),
+		f.Value >= float64(min), false, "", "", "",
+		fmt.Sprintf("Piotroski F-Score 過低 %.0f (<%d，%d/%d項有資料)", f.Value, min, f.Available, piotroskiSignals))
+}
+
+// evaluateFinancialScores 財務困境及財報操縱風險規則 (第一階段)，無法計算時不列入判定
+func (s *StockScreener) evaluateFinancialScores(stock *StockData) []RuleVerdict {
+	c := s.criteria
+	rule := func(name string, score *CompositeScore, format string, passed bool, reason string) RuleVerdict {
+		if score == nil {
+			return skipRule(name, "無法計算")
+		}
+		return gradeRule(name, scoreText(score, format), passed, false, "", "", "", reason)
+	}
+	z, zem, m := stock.AltmanZ, stock.AltmanZEM, stock.BeneishM
+	return []RuleVerdict{
+		s.evaluatePiotroski(stock.PiotroskiF),
+		rule("Altman Z", z, "%.2f", z != nil && z.Value >= c.MinAltmanZ,
+			fmt.Sprintf("Altman Z 財務困境 %s (<%.2f)", scoreText(z, "%.2f"), c.MinAltmanZ)),
+		rule("Altman Z''(EM)", zem, "%.2f", zem != nil && zem.Value >= c.MinAltmanZEM,
+			fmt.Sprintf("Altman Z''(EM) 財務困境 %s (<%.2f)", scoreText(zem, "%.2f"), c.MinAltmanZEM)),
+		rule("Beneish M", m, "%.2f", m != nil && m.Value <= c.MaxBeneishM,
+			fmt.Sprintf("Beneish M 財報操縱風險 %s (>%.2f)", scoreText(m, "%.2f"), c.MaxBeneishM)),
+	}
+}
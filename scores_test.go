@@ -0,0 +1,196 @@
+package main
+
+import "testing"
+
+// fiscalYear 單一年度財報: 資產負債表為年底數值，損益及現金流量為全年合計
+type fiscalYear struct {
+	balance  map[string]float64
+	income   map[string]float64
+	cashFlow map[string]float64
+}
+
+// testStatements 以前一年度 (2024) 及最近年度 (2025) 建立八季財報歷史，全年合計平均分配至各季
+func testStatements(prior, latest fiscalYear) *statementHistory {
+	h := newStatementHistory()
+	for _, year := range []struct {
+		prefix string
+		data   fiscalYear
+	}{{"2024", prior}, {"2025", latest}} {
+		for _, quarter := range []string{"-03-31", "-06-30", "-09-30", "-12-31"} {
+			date := year.prefix + quarter
+			for item, v := range year.data.balance {
+				record(h.balance, date, item, v)
+			}
+			for item, v := range year.data.income {
+				record(h.income, date, item, v/4)
+			}
+			for item, v := range year.data.cashFlow {
+				record(h.cashFlow, date, item, v/4)
+			}
+		}
+	}
+	return h
+}
+
+// improvingYears 各項 Piotroski 訊號皆改善的兩個年度
+func improvingYears() (prior, latest fiscalYear) {
+	prior = fiscalYear{
+		balance: map[string]float64{"TotalAssets": 1000, "NoncurrentLiabilities": 250, "CurrentAssets": 450,
+			"CurrentLiabilities": 300, "OrdinaryShare": 100},
+		income:   map[string]float64{"IncomeAfterTaxes": 60, "Revenue": 1600, "GrossProfit": 560},
+		cashFlow: map[string]float64{"CashFlowsFromOperatingActivities": 80},
+	}
+	latest = fiscalYear{
+		balance: map[string]float64{"TotalAssets": 1000, "NoncurrentLiabilities": 200, "CurrentAssets": 500,
+			"CurrentLiabilities": 250, "OrdinaryShare": 100},
+		income:   map[string]float64{"IncomeAfterTaxes": 100, "Revenue": 1800, "GrossProfit": 720},
+		cashFlow: map[string]float64{"CashFlowsFromOperatingActivities": 120},
+	}
+	return prior, latest
+}
+
+func TestPiotroskiFScore(t *testing.T) {
+	prior, latest := improvingYears()
+
+	// 年度互換且營業現金流為負、增發股票: 只剩 ROA為正
+	worse, better := latest, prior
+	better.cashFlow = map[string]float64{"CashFlowsFromOperatingActivities": -10}
+	better.balance = map[string]float64{"TotalAssets": 1000, "NoncurrentLiabilities": 250, "CurrentAssets": 450,
+		"CurrentLiabilities": 300, "OrdinaryShare": 110}
+
+	noPrior, noLatest := prior, latest
+	noPrior.cashFlow, noLatest.cashFlow = nil, nil
+
+	tests := []struct {
+		name      string
+		h         *statementHistory
+		want      float64
+		available int
+		check     map[string]float64
+	}{
+		{"九項皆符合", testStatements(prior, latest), 9, 9, nil},
+		{"全面惡化", testStatements(worse, better), 1, 9, map[string]float64{"ROA為正": 1, "未增發股票": 0}},
+		{"缺少現金流量", testStatements(noPrior, noLatest), 7, 7, map[string]float64{"營業現金流為正": 0, "營業現金流大於淨利": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := piotroskiFScore(tt.h)
+			if score == nil {
+				t.Fatal("score = nil")
+			}
+			if score.Value != tt.want || score.Available != tt.available || len(score.Components) != 9 {
+				t.Errorf("F = %v (%d/%d項), want %v (%d項有資料)", score.Value, score.Available, len(score.Components), tt.want, tt.available)
+			}
+			for _, c := range score.Components {
+				if want, ok := tt.check[c.Name]; ok && c.Value != want {
+					t.Errorf("%s = %v, want %v", c.Name, c.Value, want)
+				}
+			}
+		})
+	}
+
+	if score := piotroskiFScore(newStatementHistory()); score != nil {
+		t.Errorf("無財報 F = %v, want nil", score.Value)
+	}
+}
+
+func TestEvaluatePiotroski(t *testing.T) {
+	s := testScreener(t, "default") // MinPiotroskiF 3
+	tests := []struct {
+		name  string
+		score *CompositeScore
+		want  string
+	}{
+		{"無法計算不判定", nil, verdictSkip},
+		{"有資料訊號不足不判定", &CompositeScore{Value: 0, Available: 5}, verdictSkip},
+		{"九項有資料達門檻", &CompositeScore{Value: 3, Available: 9}, verdictPass},
+		{"九項有資料未達門檻", &CompositeScore{Value: 2, Available: 9}, verdictFail},
+		{"六項有資料門檻調整為2", &CompositeScore{Value: 2, Available: 6}, verdictPass},
+		{"六項有資料未達調整後門檻", &CompositeScore{Value: 1, Available: 6}, verdictFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.evaluatePiotroski(tt.score); got.Status != tt.want {
+				t.Errorf("Status = %s, want %s (%+v)", got.Status, tt.want, got)
+			}
+		})
+	}
+
+	// 其餘財務評分無法計算時亦不列入判定
+	for _, rule := range s.evaluateFinancialScores(&StockData{}) {
+		if rule.Status != verdictSkip {
+			t.Errorf("%s = %s, want %s", rule.Rule, rule.Status, verdictSkip)
+		}
+	}
+}
+
+func TestAltmanZScores(t *testing.T) {
+	// 教科書範例: 營運資金 200、保留盈餘 300、EBIT 150、權益市值 900、營收 1800、總資產 1000、總負債 600
+	// Z = 1.2×0.2 + 1.4×0.3 + 3.3×0.15 + 0.6×1.5 + 1.8 = 3.855
+	// Z''(EM) = 3.25 + 6.56×0.2 + 3.26×0.3 + 6.72×0.15 + 1.05×(400/600) = 7.248
+	year := fiscalYear{
+		balance: map[string]float64{"TotalAssets": 1000, "CurrentAssets": 500, "CurrentLiabilities": 300,
+			"Liabilities": 600, "Equity": 400, "RetainedEarnings": 300},
+		income: map[string]float64{"OperatingIncome": 150, "Revenue": 1800},
+	}
+	h := testStatements(year, year)
+
+	z, zem := altmanZScores(h, 900.0/400)
+	if z == nil || zem == nil {
+		t.Fatalf("z = %v, zem = %v", z, zem)
+	}
+	if !approxEqual(z.Value, 3.855, 1e-9) {
+		t.Errorf("Z = %v, want 3.855", z.Value)
+	}
+	if !approxEqual(zem.Value, 7.248, 1e-9) {
+		t.Errorf("Z''(EM) = %v, want 7.248", zem.Value)
+	}
+	if zone := altmanZone(z, altmanZDistress, altmanZSafe); zone != "安全區" {
+		t.Errorf("zone = %s, want 安全區", zone)
+	}
+
+	// 無股價淨值比時只計算帳面值版本
+	if z, zem := altmanZScores(h, 0); z != nil || zem == nil {
+		t.Errorf("pb 0: z = %v, zem = %v", z, zem)
+	}
+}
+
+func TestBeneishMScore(t *testing.T) {
+	year := fiscalYear{
+		balance: map[string]float64{"TotalAssets": 1000, "AccountsReceivableNet": 150, "CurrentAssets": 400,
+			"PropertyPlantAndEquipment": 300, "Liabilities": 500},
+		income: map[string]float64{"Revenue": 1200, "GrossProfit": 360, "OperatingExpenses": 120,
+			"IncomeAfterTaxes": 80},
+		cashFlow: map[string]float64{"Depreciation": 60, "CashFlowsFromOperatingActivities": 80},
+	}
+
+	// 兩年度相同時各指標為 1、TATA 為 0: M = -4.84 + 0.92 + 0.528 + 0.404 + 0.892 + 0.115 - 0.172 - 0.327 = -2.48
+	m := beneishMScore(testStatements(year, year))
+	if m == nil || !approxEqual(m.Value, -2.48, 1e-9) {
+		t.Fatalf("M = %v, want -2.48", scoreText(m, "%.4f"))
+	}
+
+	// 營收成長 50%: SGI 1.5 使 M 增加 0.892×0.5，DSRI、GMI 等比例科目同步放大則不變
+	grown := fiscalYear{balance: map[string]float64{}, income: map[string]float64{}, cashFlow: year.cashFlow}
+	for k, v := range year.balance {
+		grown.balance[k] = v
+	}
+	for k, v := range year.income {
+		grown.income[k] = v * 1.5
+	}
+	grown.balance["AccountsReceivableNet"] = 225
+	grown.cashFlow = map[string]float64{"Depreciation": 60, "CashFlowsFromOperatingActivities": 120}
+	m = beneishMScore(testStatements(year, grown))
+	if m == nil || !approxEqual(m.Value, -2.48+0.892*0.5, 1e-9) {
+		t.Errorf("M = %v, want %v", scoreText(m, "%.4f"), -2.48+0.892*0.5)
+	}
+
+	// 可計算指標不足 beneishMinIndices
+	sparse := fiscalYear{
+		balance: map[string]float64{"TotalAssets": 1000},
+		income:  map[string]float64{"Revenue": 1200},
+	}
+	if m := beneishMScore(testStatements(sparse, sparse)); m != nil {
+		t.Errorf("M = %v, want nil", m.Value)
+	}
+}
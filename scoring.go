@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 評分正規化方式
+const (
+	normalizeLinear     = "linear"     // 依 Min/Max 線性換算，超出範圍者截斷
+	normalizeMinMax     = "minmax"     // 依股票池最小值及最大值換算
+	normalizeZScore     = "zscore"     // 依股票池平均及標準差換算，以 ±Cap 個標準差截斷
+	normalizePercentile = "percentile" // 股票池中的百分位
+
+	normalizeIndustryZScore     = "industry_zscore"     // 同產業的 zscore
+	normalizeIndustryPercentile = "industry_percentile" // 同產業的百分位
+)
+
+// defaultZScoreCap z-score 正規化預設截斷的標準差倍數
+const defaultZScoreCap = 3.0
+
+// normalizeMethods 支援的正規化方式
+var normalizeMethods = []string{normalizeLinear, normalizeMinMax, normalizeZScore, normalizePercentile,
+	normalizeIndustryZScore, normalizeIndustryPercentile}
+
+// ScoringFactor 評分因子: 欄位、正規化方式及權重
+// 正規化後的分數介於 0-1，乘上權重後加總，再換算為 0-100 分
+type ScoringFactor struct {
+	Field     string  `json:"field"`               // 評分欄位 (見 scoreFields)
+	Weight    float64 `json:"weight"`              // 權重
+	Normalize string  `json:"normalize,omitempty"` // linear (預設), minmax, zscore, percentile, industry_zscore, industry_percentile
+	Min       float64 `json:"min,omitempty"`       // linear: 得 0 分的數值
+	Max       float64 `json:"max,omitempty"`       // linear: 得滿分的數值
+	Cap       float64 `json:"cap,omitempty"`       // zscore: 截斷的標準差倍數，預設 3
+	Invert    bool    `json:"invert,omitempty"`    // 數值越低越好
+}
+
+// FactorContribution 單一因子對綜合評分的貢獻
+type FactorContribution struct {
+	Field      string  `json:"field"`
+	Label      string  `json:"label"`
+	Value      float64 `json:"value"`      // 原始數值
+	Normalized float64 `json:"normalized"` // 正規化分數 (0-1)
+	Weight     float64 `json:"weight"`
+	Points     float64 `json:"points"`            // 對 0-100 分的貢獻
+	Neutral    bool    `json:"neutral,omitempty"` // 比較基準不足2檔，跨股票正規化無從比較而給予中性分數
+}
+
+// scoreField 可評分欄位
+type scoreField struct {
+	Label string
+	Value func(*StockData) float64
+}
+
+// boolScore 條件成立為 1，否則為 0
+func boolScore(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// scoreFields 可用於評分的欄位 (名稱同JSON欄位)
+var scoreFields = map[string]scoreField{
+	"roe":               {"ROE", func(s *StockData) float64 { return s.ROE }},
+	"revenue_growth":    {"營收成長", func(s *StockData) float64 { return s.RevenueGrowth }},
+	"yoy_growth":        {"年增率", func(s *StockData) float64 { return s.YoYGrowth }},
+	"eps_growth":        {"EPS增長", func(s *StockData) float64 { return s.EPSGrowth }},
+	"eps":               {"EPS", func(s *StockData) float64 { return s.EPS }},
+	"debt_ratio":        {"負債比", func(s *StockData) float64 { return s.DebtRatio }},
+	"dividend_years":    {"配息年數", func(s *StockData) float64 { return float64(s.DividendYears) }},
+	"pe":                {"本益比", func(s *StockData) float64 { return s.PE }},
+	"pb":                {"股價淨值比", func(s *StockData) float64 { return s.PB }},
+	"peg":               {"PEG", func(s *StockData) float64 { return s.PEG }},
+	"dividend_yield":    {"殖利率", func(s *StockData) float64 { return s.DividendYield }},
+	"earnings_yield":    {"盈餘殖利率", func(s *StockData) float64 { return s.EarningsYield }},
+	"margin_of_safety":  {"安全邊際", func(s *StockData) float64 { return s.MarginOfSafety }},
+	"fcf_yield":         {"自由現金流殖利率", func(s *StockData) float64 { return s.FCFYield }},
+	"ocf_to_net_income": {"營業現金流/淨利", func(s *StockData) float64 { return s.OCFToNetIncome }},
+	"accruals_ratio":    {"應計比率", func(s *StockData) float64 { return s.AccrualsRatio }},
+	"piotroski_f":       {"Piotroski F", func(s *StockData) float64 { return compositeValue(s.PiotroskiF) }},
+	"rsi":               {"RSI", func(s *StockData) float64 { return s.RSI }},
+	"price_vs_ma60":     {"股價相對MA60", priceVsMA60},
+	"beta":              {"Beta", func(s *StockData) float64 { return s.Beta }},
+	"relative_strength": {"相對大盤強弱", func(s *StockData) float64 { return s.RelativeStrength }},
+	"return_1m":         {"1個月報酬率", func(s *StockData) float64 { return s.Return1M }},
+	"return_3m":         {"3個月報酬率", func(s *StockData) float64 { return s.Return3M }},
+	"return_6m":         {"6個月報酬率", func(s *StockData) float64 { return s.Return6M }},
+	"return_12m":        {"12個月報酬率", func(s *StockData) float64 { return s.Return12M }},
+	"momentum_12_1":     {"12-1月動能", func(s *StockData) float64 { return s.Momentum12Minus1 }},
+	"rs_rating":         {"RS評等", func(s *StockData) float64 { return float64(s.RSRating) }},
+	"from_high_52w":     {"距52週高點", func(s *StockData) float64 { return s.FromHigh52W }},
+	"from_low_52w":      {"距52週低點", func(s *StockData) float64 { return s.FromLow52W }},
+	"sharpe":            {"夏普比率", func(s *StockData) float64 { return s.SharpeRatio }},
+	"sortino":           {"Sortino比率", func(s *StockData) float64 { return s.SortinoRatio }},
+	"max_drawdown":      {"最大回撤", func(s *StockData) float64 { return s.MaxDrawdown }},
+	"var_95":            {"VaR95", func(s *StockData) float64 { return s.VaR95 }},
+	"volatility":        {"波動率", func(s *StockData) float64 { return s.Volatility }},
+	"above_ma60": {"站上MA60", func(s *StockData) float64 {
+		return boolScore(s.Price > s.MA60)
+	}},
+	"k_buy_zone": {"K值買進區間", func(s *StockData) float64 {
+		return boolScore(s.KValue >= 50 && s.KValue <= 80)
+	}},
+	"d_buy_zone": {"D值買進區間", func(s *StockData) float64 {
+		return boolScore(s.DValue >= 50 && s.DValue <= 80)
+	}},
+	"value_rank":    {"價值因子", compositeField(factorValue)},
+	"quality_rank":  {"品質因子", compositeField(factorQuality)},
+	"growth_rank":   {"成長因子", compositeField(factorGrowth)},
+	"momentum_rank": {"動能因子", compositeField(factorMomentum)},
+	"low_vol_rank":  {"低波動因子", compositeField(factorLowVol)},
+}
+
+// compositeField 綜合因子百分位 (0-100)，未排名時為中性值 50
+func compositeField(factor string) func(*StockData) float64 {
+	return func(s *StockData) float64 {
+		if v, ok := s.Ranks.composite(factor); ok {
+			return v
+		}
+		return 50
+	}
+}
+
+// compositeValue 綜合分數的數值，無法計算時為 0
+func compositeValue(score *CompositeScore) float64 {
+	if score == nil {
+		return 0
+	}
+	return score.Value
+}
+
+// scoreFieldNames 可評分欄位名稱
+func scoreFieldNames() []string {
+	names := make([]string, 0, len(scoreFields))
+	for name := range scoreFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultScoring 預設評分因子: 基本面及估值 70 分、技術面 30 分
+func DefaultScoring() []ScoringFactor {
+	return []ScoringFactor{
+		{Field: "roe", Weight: 12, Min: 0, Max: 30},
+		{Field: "revenue_growth", Weight: 8, Min: 0, Max: 20},
+		{Field: "yoy_growth", Weight: 12, Min: 0, Max: 30},
+		{Field: "eps_growth", Weight: 16, Min: 0, Max: 200},
+		{Field: "eps", Weight: 4, Min: 0, Max: 5},
+		{Field: "debt_ratio", Weight: 8, Min: 0, Max: 100, Invert: true},
+		{Field: "dividend_years", Weight: 4, Min: 0, Max: 10},
+		{Field: "margin_of_safety", Weight: 6, Min: 0, Max: 50},
+		{Field: "above_ma60", Weight: 15, Min: 0, Max: 1},
+		{Field: "k_buy_zone", Weight: 8, Min: 0, Max: 1},
+		{Field: "d_buy_zone", Weight: 7, Min: 0, Max: 1},
+	}
+}
+
+// validateScoring 檢查評分因子設定
+func validateScoring(factors []ScoringFactor) error {
+	total := 0.0
+	for _, f := range factors {
+		if _, ok := scoreFields[f.Field]; !ok {
+			return fmt.Errorf("不支援的評分欄位 %q (可用: %v)", f.Field, scoreFieldNames())
+		}
+		method := f.method()
+		switch {
+		case !slices.Contains(normalizeMethods, method):
+			return fmt.Errorf("評分欄位 %s: 不支援的正規化方式 %q (可用: %v)", f.Field, method, normalizeMethods)
+		case method == normalizeLinear && f.Max == f.Min:
+			return fmt.Errorf("評分欄位 %s: linear 正規化須設定不同的 min 及 max", f.Field)
+		case f.Weight < 0:
+			return fmt.Errorf("評分欄位 %s: 權重不可為負數", f.Field)
+		}
+		total += f.Weight
+	}
+	if len(factors) > 0 && total <= 0 {
+		return fmt.Errorf("評分權重總和必須大於 0")
+	}
+	return nil
+}
+
+// method 正規化方式，未設定時為 linear
+func (f ScoringFactor) method() string {
+	if f.Normalize == "" {
+		return normalizeLinear
+	}
+	return f.Normalize
+}
+
+// factorNormalizer 依股票池建立的單一因子正規化函數
+type factorNormalizer func(v float64) float64
+
+// newNormalizer 建立因子的正規化函數，股票池不足以比較時回傳中性分數 0.5
+func newNormalizer(f ScoringFactor, universe []float64) factorNormalizer {
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1, v)) }
+
+	switch f.method() {
+	case normalizeMinMax:
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range universe {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		if len(universe) < 2 || hi == lo {
+			return func(float64) float64 { return 0.5 }
+		}
+		return func(v float64) float64 { return clamp((v - lo) / (hi - lo)) }
+
+	case normalizeZScore, normalizeIndustryZScore:
+		mean, std := meanStd(universe)
+		if len(universe) < 2 || std == 0 {
+			return func(float64) float64 { return 0.5 }
+		}
+		limit := f.Cap
+		if limit <= 0 {
+			limit = defaultZScoreCap
+		}
+		return func(v float64) float64 {
+			z := math.Max(-limit, math.Min(limit, (v-mean)/std))
+			return (z + limit) / (2 * limit)
+		}
+
+	case normalizePercentile, normalizeIndustryPercentile:
+		if len(universe) < 2 {
+			return func(float64) float64 { return 0.5 }
+		}
+		sorted := make([]float64, len(universe))
+		copy(sorted, universe)
+		sort.Float64s(sorted)
+		return func(v float64) float64 { return percentileRank(sorted, v) / 100 }
+	}
+
+	return func(v float64) float64 { return clamp((v - f.Min) / (f.Max - f.Min)) }
+}
+
+// meanStd 平均數及母體標準差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// industryScope 正規化是否以同產業為比較基準
+func (f ScoringFactor) industryScope() bool {
+	method := f.method()
+	return method == normalizeIndustryZScore || method == normalizeIndustryPercentile
+}
+
+// factorNormalizers 依股票池建立因子的正規化函數，同產業正規化時依產業分別建立 (鍵為產業，否則為空字串)
+// 另回傳各比較基準的股票數
+func factorNormalizers(f ScoringFactor, universe []*StockData) (map[string]factorNormalizer, map[string]int) {
+	field := scoreFields[f.Field]
+	groups := make(map[string][]float64)
+	for _, stock := range universe {
+		key := ""
+		if f.industryScope() {
+			key = industryOf(stock)
+		}
+		groups[key] = append(groups[key], field.Value(stock))
+	}
+
+	normalizers := make(map[string]factorNormalizer, len(groups))
+	sizes := make(map[string]int, len(groups))
+	for key, values := range groups {
+		normalizers[key] = newNormalizer(f, values)
+		sizes[key] = len(values)
+	}
+	return normalizers, sizes
+}
+
+// scoreStocks 依評分因子計算綜合評分 (0-100) 及各因子貢獻
+// 股票池 (universe) 用於 minmax、zscore、percentile 及同產業正規化，通常為本次取得資料的所有股票
+func (s *StockScreener) scoreStocks(stocks, universe []*StockData) {
+	factors := s.criteria.Scoring
+	if len(factors) == 0 {
+		factors = DefaultScoring()
+	}
+
+	totalWeight := 0.0
+	normalizers := make([]map[string]factorNormalizer, len(factors))
+	sizes := make([]map[string]int, len(factors))
+	for i, f := range factors {
+		totalWeight += f.Weight
+		normalizers[i], sizes[i] = factorNormalizers(f, universe)
+	}
+
+	for _, stock := range stocks {
+		stock.Score = 0
+		stock.ScoreBreakdown = make([]FactorContribution, len(factors))
+		for i, f := range factors {
+			field := scoreFields[f.Field]
+			value := field.Value(stock)
+			key := ""
+			if f.industryScope() {
+				key = industryOf(stock)
+			}
+			normalized := 0.5
+			if normalize, ok := normalizers[i][key]; ok {
+				normalized = normalize(value)
+			}
+			if f.Invert {
+				normalized = 1 - normalized
+			}
+			neutral := f.method() != normalizeLinear && sizes[i][key] < 2
+
+			points := 0.0
+			if totalWeight > 0 {
+				points = normalized * f.Weight / totalWeight * 100
+			}
+			stock.Score += points
+			stock.ScoreBreakdown[i] = FactorContribution{
+				Field: f.Field, Label: field.Label, Value: value,
+				Normalized: normalized, Weight: f.Weight, Points: points, Neutral: neutral,
+			}
+		}
+		s.logger.Debug("評分", logKeyStock, stock.Code, "score", stock.Score, "breakdown", breakdownText(stock.ScoreBreakdown))
+	}
+}
+
+// breakdownText 評分明細文字 (依貢獻由高至低，略過未得分的因子)
+func breakdownText(breakdown []FactorContribution) string {
+	sorted := make([]FactorContribution, len(breakdown))
+	copy(sorted, breakdown)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Points > sorted[j].Points })
+
+	var parts []string
+	for _, c := range sorted {
+		if c.Points > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.1f", c.Label, c.Points))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
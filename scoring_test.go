@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewNormalizer(t *testing.T) {
+	sd := math.Sqrt(2) // [1 2 3 4 5] 的母體標準差
+	tests := []struct {
+		name     string
+		factor   ScoringFactor
+		universe []float64
+		values   []float64
+		want     []float64
+	}{
+		{"linear截斷", ScoringFactor{Min: 0, Max: 30}, nil,
+			[]float64{-5, 0, 15, 30, 45}, []float64{0, 0, 0.5, 1, 1}},
+		{"minmax", ScoringFactor{Normalize: normalizeMinMax}, []float64{10, 20, 30},
+			[]float64{10, 25, 30, 40}, []float64{0, 0.75, 1, 1}},
+		{"minmax數值相同為中性", ScoringFactor{Normalize: normalizeMinMax}, []float64{5, 5},
+			[]float64{5, 100}, []float64{0.5, 0.5}},
+		{"zscore截斷2個標準差", ScoringFactor{Normalize: normalizeZScore, Cap: 2}, []float64{1, 2, 3, 4, 5},
+			[]float64{3, 3 + sd, 3 - 2*sd, 100, -100}, []float64{0.5, 0.75, 0, 1, 0}},
+		{"zscore預設3個標準差", ScoringFactor{Normalize: normalizeZScore}, []float64{1, 2, 3, 4, 5},
+			[]float64{3 + sd, 3 + 3*sd}, []float64{4.0 / 6, 1}},
+		{"zscore不足2檔為中性", ScoringFactor{Normalize: normalizeIndustryZScore}, []float64{3},
+			[]float64{3, 10}, []float64{0.5, 0.5}},
+		{"percentile", ScoringFactor{Normalize: normalizePercentile}, []float64{40, 10, 30, 20},
+			[]float64{10, 25, 40}, []float64{0.125, 0.5, 0.875}},
+		{"percentile不足2檔為中性", ScoringFactor{Normalize: normalizeIndustryPercentile}, []float64{10},
+			[]float64{10}, []float64{0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalize := newNormalizer(tt.factor, tt.universe)
+			for i, v := range tt.values {
+				if got := normalize(v); !approxEqual(got, tt.want[i], 1e-9) {
+					t.Errorf("normalize(%v) = %v, want %v", v, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScoreStocks(t *testing.T) {
+	a := &StockData{Code: "A", Industry: "半導體業", ROE: 10, DebtRatio: 20, PE: 10}
+	b := &StockData{Code: "B", Industry: "半導體業", ROE: 20, DebtRatio: 60, PE: 20}
+	c := &StockData{Code: "C", Industry: "金融保險業", ROE: 30, DebtRatio: 90, PE: 5}
+	universe := []*StockData{a, b, c}
+
+	// 權重 2:1:1 換算為 50、25、25 分
+	// A: ROE 0.5×50 + 負債比 (1-0.2)×25 + 本益比產業百分位 0.25×25 = 51.25
+	// B: 1×50 + 0.4×25 + 0.75×25 = 78.75
+	// C: ROE 截斷為 1×50 + 0.1×25 + 產業僅1檔為中性 0.5×25 = 65
+	want := map[string]float64{"A": 51.25, "B": 78.75, "C": 65}
+	for _, scale := range []float64{1, 10} {
+		s := testScreener(t, "default")
+		s.criteria.Scoring = []ScoringFactor{
+			{Field: "roe", Weight: 2 * scale, Min: 0, Max: 20},
+			{Field: "debt_ratio", Weight: 1 * scale, Min: 0, Max: 100, Invert: true},
+			{Field: "pe", Weight: 1 * scale, Normalize: normalizeIndustryPercentile},
+		}
+		s.scoreStocks(universe, universe)
+
+		for _, stock := range universe {
+			if !approxEqual(stock.Score, want[stock.Code], 1e-9) {
+				t.Errorf("權重×%v %s Score = %v, want %v", scale, stock.Code, stock.Score, want[stock.Code])
+			}
+			if len(stock.ScoreBreakdown) != 3 {
+				t.Fatalf("breakdown = %+v", stock.ScoreBreakdown)
+			}
+			sum := 0.0
+			for _, c := range stock.ScoreBreakdown {
+				sum += c.Points
+			}
+			if !approxEqual(sum, stock.Score, 1e-9) {
+				t.Errorf("%s 各因子分數合計 %v, Score %v", stock.Code, sum, stock.Score)
+			}
+		}
+		if debt := a.ScoreBreakdown[1]; debt.Label != "負債比" || debt.Value != 20 || !approxEqual(debt.Normalized, 0.8, 1e-9) {
+			t.Errorf("負債比 = %+v, want normalized 0.8", debt)
+		}
+	}
+
+	// 只評分部分股票仍以整個股票池正規化；股票池中沒有的產業為中性分數
+	s := testScreener(t, "default")
+	s.criteria.Scoring = []ScoringFactor{{Field: "pe", Weight: 1, Normalize: normalizeIndustryPercentile}}
+	other := &StockData{Code: "D", Industry: "航運業", PE: 8}
+	s.scoreStocks([]*StockData{a, other}, universe)
+	if !approxEqual(a.Score, 25, 1e-9) || !approxEqual(other.Score, 50, 1e-9) {
+		t.Errorf("A Score = %v, D Score = %v, want 25, 50", a.Score, other.Score)
+	}
+}
+
+func TestScoreStocksNeutral(t *testing.T) {
+	s := testScreener(t, "default")
+	s.criteria.Scoring = []ScoringFactor{
+		{Field: "roe", Weight: 1, Min: 0, Max: 20},
+		{Field: "pe", Weight: 1, Normalize: normalizePercentile},
+		{Field: "pb", Weight: 1, Normalize: normalizeIndustryZScore},
+	}
+	a := &StockData{Code: "A", Industry: "半導體業", ROE: 10, PE: 10, PB: 2}
+	b := &StockData{Code: "B", Industry: "航運業", ROE: 20, PE: 20, PB: 1}
+
+	// 股票池不足2檔時跨股票正規化的因子標示為中性分數，同產業正規化依產業判定
+	tests := []struct {
+		name     string
+		universe []*StockData
+		want     []bool
+	}{
+		{"單一股票", []*StockData{a}, []bool{false, true, true}},
+		{"不同產業", []*StockData{a, b}, []bool{false, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.scoreStocks([]*StockData{a}, tt.universe)
+			for i, c := range a.ScoreBreakdown {
+				if c.Neutral != tt.want[i] {
+					t.Errorf("%s Neutral = %v, want %v", c.Field, c.Neutral, tt.want[i])
+				}
+				if c.Neutral && c.Normalized != 0.5 {
+					t.Errorf("%s Normalized = %v, want 0.5", c.Field, c.Normalized)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreStocksRange(t *testing.T) {
+	s := testScreener(t, "default")
+	best := &StockData{Code: "A", ROE: 40, RevenueGrowth: 30, YoYGrowth: 50, EPSGrowth: 300, EPS: 10,
+		DebtRatio: 0, DividendYears: 15, MarginOfSafety: 60, Price: 110, MA60: 100, KValue: 60, DValue: 60}
+	worst := &StockData{Code: "B", ROE: -5, RevenueGrowth: -10, YoYGrowth: -10, EPSGrowth: -50, EPS: -1,
+		DebtRatio: 100, MarginOfSafety: -30, Price: 90, MA60: 100, KValue: 90, DValue: 20}
+	s.scoreStocks([]*StockData{best, worst}, []*StockData{best, worst})
+	if !approxEqual(best.Score, 100, 1e-9) || worst.Score != 0 {
+		t.Errorf("Score = %v, %v, want 100, 0", best.Score, worst.Score)
+	}
+}
+
+func TestValidateScoring(t *testing.T) {
+	tests := []struct {
+		name    string
+		factors []ScoringFactor
+		wantErr bool
+	}{
+		{"預設評分", DefaultScoring(), false},
+		{"未設定", nil, false},
+		{"不支援的欄位", []ScoringFactor{{Field: "market_cap", Weight: 1, Max: 1}}, true},
+		{"不支援的正規化方式", []ScoringFactor{{Field: "roe", Weight: 1, Normalize: "rank"}}, true},
+		{"linear未設定範圍", []ScoringFactor{{Field: "roe", Weight: 1}}, true},
+		{"minmax不需範圍", []ScoringFactor{{Field: "roe", Weight: 1, Normalize: normalizeMinMax}}, false},
+		{"權重為負", []ScoringFactor{{Field: "roe", Weight: -1, Max: 30}, {Field: "eps", Weight: 2, Max: 5}}, true},
+		{"權重總和為0", []ScoringFactor{{Field: "roe", Weight: 0, Max: 30}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateScoring(tt.factors); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultScoringSplit(t *testing.T) {
+	technical := map[string]bool{"above_ma60": true, "k_buy_zone": true, "d_buy_zone": true}
+	var fundamental, tech float64
+	for _, f := range DefaultScoring() {
+		if technical[f.Field] {
+			tech += f.Weight
+		} else {
+			fundamental += f.Weight
+		}
+	}
+	if fundamental != 70 || tech != 30 {
+		t.Errorf("基本面 %v、技術面 %v, want 70、30", fundamental, tech)
+	}
+}
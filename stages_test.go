@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// testScreener 以指定條件建立不連網的篩選器
+func testScreener(t *testing.T, profile string) *StockScreener {
+	t.Helper()
+	criteria, err := LoadCriteriaProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &StockScreener{criteria: criteria, logger: slog.New(slog.DiscardHandler)}
+}
+
+// ruleStatus 取得階段結果中指定規則的判定
+func ruleStatus(t *testing.T, result StageResult, name string) string {
+	t.Helper()
+	for _, rule := range result.Rules {
+		if rule.Rule == name {
+			return rule.Status
+		}
+	}
+	t.Fatalf("第%d階段沒有規則 %s", result.Stage, name)
+	return ""
+}
+
+func TestStageRulesFollowCriteria(t *testing.T) {
+	stock := &StockData{
+		Code: "2330", ROE: 12, DebtRatio: 55, RevenueGrowth: -3, DividendYears: 3,
+		EPS: 3, EPSGrowth: 10, YoYGrowth: 5, KValue: 85, DValue: 60,
+	}
+
+	tests := []struct {
+		profile string
+		stage   int
+		rule    string
+		want    string
+	}{
+		{"strict", 2, "ROE", verdictFail},
+		{"default", 2, "ROE", verdictWarn},
+		{"strict", 2, "負債比", verdictFail},
+		{"default", 2, "負債比", verdictFail},
+		{"relaxed", 2, "負債比", verdictWarn},
+		{"strict", 2, "配息年數", verdictFail},
+		{"relaxed", 2, "配息年數", verdictPass},
+		{"strict", 2, "營收成長", verdictFail},
+		{"default", 2, "營收成長", verdictFail},
+		{"relaxed", 2, "營收成長", verdictWarn},
+		{"strict", 3, "K值", verdictFail},
+		{"default", 3, "K值", verdictWarn},
+		{"strict", 3, "D值", verdictPass},
+		{"default", 1, "負債比", verdictPass},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile+"/"+tt.rule, func(t *testing.T) {
+			s := testScreener(t, tt.profile)
+			result := s.EvaluateStages(stock)[tt.stage-1]
+			if got := ruleStatus(t, result, tt.rule); got != tt.want {
+				t.Errorf("%s 第%d階段 %s = %s, want %s", tt.profile, tt.stage, tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+// 預設條件的分級門檻與原本固定門檻相同
+func TestDefaultStageGrading(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage int
+		rule  string
+		stock StockData
+		want  string
+	}{
+		{"ROE 15%優秀", 2, "ROE", StockData{ROE: 15}, verdictPass},
+		{"ROE 10%良好", 2, "ROE", StockData{ROE: 10}, verdictWarn},
+		{"ROE 9.9%偏低", 2, "ROE", StockData{ROE: 9.9}, verdictFail},
+		{"營收成長10%", 2, "營收成長", StockData{RevenueGrowth: 10}, verdictPass},
+		{"營收成長0%", 2, "營收成長", StockData{RevenueGrowth: 0}, verdictWarn},
+		{"營收衰退", 2, "營收成長", StockData{RevenueGrowth: -0.1}, verdictFail},
+		{"負債比30%", 2, "負債比", StockData{DebtRatio: 30}, verdictPass},
+		{"負債比50%", 2, "負債比", StockData{DebtRatio: 50}, verdictWarn},
+		{"負債比50.1%", 2, "負債比", StockData{DebtRatio: 50.1}, verdictFail},
+		{"配息5年", 2, "配息年數", StockData{DividendYears: 5}, verdictPass},
+		{"配息3年", 2, "配息年數", StockData{DividendYears: 3}, verdictWarn},
+		{"配息2年", 2, "配息年數", StockData{DividendYears: 2}, verdictFail},
+		{"EPS增長100%", 2, "EPS增長", StockData{EPSGrowth: 100}, verdictPass},
+		{"EPS增長50%", 2, "EPS增長", StockData{EPSGrowth: 50}, verdictWarn},
+		{"K值50", 3, "K值", StockData{KValue: 50}, verdictPass},
+		{"K值80", 3, "K值", StockData{KValue: 80}, verdictPass},
+		{"K值30", 3, "K值", StockData{KValue: 30}, verdictWarn},
+		{"K值89.9", 3, "K值", StockData{KValue: 89.9}, verdictWarn},
+		{"K值90", 3, "K值", StockData{KValue: 90}, verdictFail},
+		{"D值29.9", 3, "D值", StockData{DValue: 29.9}, verdictFail},
+	}
+	s := testScreener(t, "default")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.EvaluateStages(&tt.stock)[tt.stage-1]
+			if got := ruleStatus(t, result, tt.rule); got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStage1ExclusionThresholds(t *testing.T) {
+	s := testScreener(t, "default")
+	stock := &StockData{Code: "2330", ROE: 10, EPS: 1, DebtRatio: 70}
+	if got := ruleStatus(t, s.evaluateStage1(stock), "負債比"); got != verdictPass {
+		t.Errorf("負債比 70%% = %s, want pass", got)
+	}
+
+	s.criteria.ExcludeDebtRatio = 65
+	if got := ruleStatus(t, s.evaluateStage1(stock), "負債比"); got != verdictFail {
+		t.Errorf("exclude_debt_ratio 65 時負債比 70%% = %s, want fail", got)
+	}
+}
+
+// bearCriteria 套用預設空頭覆寫後的條件
+func bearCriteria(t *testing.T) ScreeningCriteria {
+	t.Helper()
+	criteria := DefaultCriteria()
+	if err := json.Unmarshal(criteria.RegimeOverrides[regimeBear], &criteria); err != nil {
+		t.Fatal(err)
+	}
+	return criteria
+}
+
+func TestStage3BearRegime(t *testing.T) {
+	bear := bearCriteria(t)
+	if !bear.RequireMA60Above || bear.MaxBeta != 1.5 {
+		t.Fatalf("空頭覆寫未套用: %+v", bear)
+	}
+
+	tests := []struct {
+		name     string
+		criteria ScreeningCriteria
+		stock    StockData
+		want     bool
+	}{
+		{"空頭3項中通過2項", bear, StockData{Price: 110, MA60: 100, KValue: 60, DValue: 95}, true},
+		{"空頭3項中通過1項", bear, StockData{Price: 110, MA60: 100, KValue: 95, DValue: 95}, false},
+		{"空頭KD皆通過但跌破MA60", bear, StockData{Price: 95, MA60: 100, KValue: 60, DValue: 60}, false},
+		{"空頭缺少MA60不強制", bear, StockData{KValue: 60, DValue: 60}, true},
+		{"預設跌破MA60仍依比例", DefaultCriteria(), StockData{Price: 95, MA60: 100, KValue: 60, DValue: 60}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StockScreener{criteria: tt.criteria, logger: slog.New(slog.DiscardHandler)}
+			if got := s.evaluateStage3(&tt.stock).Passed; got != tt.want {
+				t.Errorf("Passed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 空頭覆寫使技術面成為排除條件，未通過即不納入候選
+	below := StockData{Code: "2330", ROE: 10, EPS: 1, Price: 95, MA60: 100, KValue: 60, DValue: 60}
+	above := StockData{Code: "2330", ROE: 10, EPS: 1, Price: 110, MA60: 100, KValue: 60, DValue: 95}
+	weak := StockData{Code: "2330", ROE: 10, EPS: 1, Price: 110, MA60: 100, KValue: 95, DValue: 95}
+
+	screening := []struct {
+		name   string
+		regime string
+		stock  StockData
+		want   bool
+	}{
+		{"盤整跌破MA60仍納入", regimeNeutral, below, true},
+		{"空頭跌破MA60排除", regimeBear, below, false},
+		{"空頭站上MA60且3項中通過2項", regimeBear, above, true},
+		{"空頭3項中只通過1項排除", regimeBear, weak, false},
+	}
+	for _, tt := range screening {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testScreener(t, "default")
+			s.applyRegime(&MarketRegime{Regime: tt.regime})
+			if got := s.meetsScreeningCriteria(&tt.stock); got != tt.want {
+				t.Errorf("meetsScreeningCriteria = %v, want %v (regime %+v)", got, tt.want, s.regime)
+			}
+		})
+	}
+}
+
+func TestRequireMA60AboveNotInStage1(t *testing.T) {
+	s := &StockScreener{criteria: bearCriteria(t), logger: slog.New(slog.DiscardHandler)}
+	stock := &StockData{Code: "2330", ROE: 10, EPS: 1, Price: 90, MA60: 100}
+	for _, rule := range s.evaluateStage1(stock).Rules {
+		if rule.Rule == "站上MA60" {
+			t.Errorf("站上MA60 不應列為第一階段規則")
+		}
+	}
+}
+
+func TestRiskAndMomentumStage(t *testing.T) {
+	s := testScreener(t, "default")
+	stock := &StockData{Code: "2330", ROE: 10, EPS: 1, Beta: 3, MaxDrawdown: 30}
+
+	stages := s.EvaluateStages(stock)
+	if len(stages) != 5 {
+		t.Fatalf("stages = %d, want 5", len(stages))
+	}
+	for _, rule := range stages[0].Rules {
+		if rule.Rule == "Beta" || rule.Rule == "最大回撤" {
+			t.Errorf("%s 不應列為第一階段規則", rule.Rule)
+		}
+	}
+
+	// 預設不檢查Beta及最大回撤
+	if !qualifies(stages) || !s.meetsScreeningCriteria(stock) {
+		t.Error("預設條件不應因Beta排除")
+	}
+
+	s.criteria.MaxBeta = 2.0
+	stages = s.EvaluateStages(stock)
+	stage4 := stages[3]
+	if got := ruleStatus(t, stage4, "Beta"); got != verdictFail {
+		t.Errorf("Beta 3 = %s, want fail", got)
+	}
+	if !stages[0].Passed || stage4.Passed || !stage4.Required {
+		t.Errorf("stage1 passed = %v, stage4 passed = %v, required = %v", stages[0].Passed, stage4.Passed, stage4.Required)
+	}
+	if qualifies(stages) || s.meetsScreeningCriteria(stock) {
+		t.Error("第四階段未通過不應納入候選")
+	}
+
+	stock.Beta = 1.2
+	if stages := s.EvaluateStages(stock); !qualifies(stages) || !s.meetsScreeningCriteria(stock) {
+		t.Error("通過第一、四、五階段應納入候選")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 程式結束代碼
+const (
+	exitOK             = 0 // 全部成功
+	exitTotalFailure   = 1 // 全部失敗或無法執行
+	exitUsage          = 2 // 參數錯誤
+	exitPartialFailure = 3 // 部分股票資料取得失敗或執行中斷
+)
+
+// RunSummary 單次執行的資料取得統計
+type RunSummary struct {
+	Attempted int                 `json:"attempted"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    map[string]string   `json:"failed"`   // 代碼 -> 錯誤
+	Degraded  map[string][]string `json:"degraded"` // 代碼 -> 使用預設值或估算的項目
+
+	RunDegraded []string `json:"run_degraded,omitempty"` // 影響整次執行的資料降級 (如交易日曆僅排除週末)
+
+	Upstream    map[string]*UpstreamStats `json:"upstream"`              // 主機 -> 上游API請求統計
+	Interrupted string                    `json:"interrupted,omitempty"` // 中斷或逾時原因，結果只含已完成部分
+	Regime      *MarketRegime             `json:"regime,omitempty"`      // 本次執行的市場狀態
+	mu          sync.Mutex
+}
+
+// UpstreamStats 單一上游主機的請求統計
+type UpstreamStats struct {
+	Requests       int `json:"requests"`
+	Retries        int `json:"retries"`
+	Failures       int `json:"failures"`        // 重試後仍失敗
+	ShortCircuited int `json:"short_circuited"` // 斷路中未送出
+}
+
+// 上游請求事件
+type upstreamEvent int
+
+const (
+	upstreamRequest upstreamEvent = iota
+	upstreamRetry
+	upstreamFailure
+	upstreamShortCircuit
+)
+
+// NewRunSummary 建立執行統計
+func NewRunSummary() *RunSummary {
+	return &RunSummary{
+		Failed:   make(map[string]string),
+		Degraded: make(map[string][]string),
+		Upstream: make(map[string]*UpstreamStats),
+	}
+}
+
+// markFailed 記錄取得資料失敗的股票
+func (r *RunSummary) markFailed(code string, err error) {
+	if r == nil {
+		return
+	}
+	r.Failed[code] = err.Error()
+}
+
+// markDegraded 記錄資料不完整、改用預設值或估算的項目
+func (r *RunSummary) markDegraded(code, reason string) {
+	if r == nil {
+		return
+	}
+	r.Degraded[code] = append(r.Degraded[code], reason)
+}
+
+// markRunDegraded 記錄影響整次執行的資料降級
+func (r *RunSummary) markRunDegraded(reason string) {
+	if r == nil {
+		return
+	}
+	r.RunDegraded = append(r.RunDegraded, reason)
+}
+
+// markInterrupted 記錄執行因中斷或逾時而提前結束
+func (r *RunSummary) markInterrupted(err error, done, total int) {
+	if r == nil {
+		return
+	}
+	reason := "已中斷"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "已逾時"
+	}
+	r.Interrupted = fmt.Sprintf("%s，已完成 %d/%d 檔", reason, done, total)
+}
+
+// recordUpstream 記錄上游API請求事件
+func (r *RunSummary) recordUpstream(host string, event upstreamEvent) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.Upstream[host]
+	if !ok {
+		stats = &UpstreamStats{}
+		r.Upstream[host] = stats
+	}
+	switch event {
+	case upstreamRequest:
+		stats.Requests++
+	case upstreamRetry:
+		stats.Retries++
+	case upstreamFailure:
+		stats.Failures++
+	case upstreamShortCircuit:
+		stats.ShortCircuited++
+	}
+}
+
+// ExitCode 依統計結果決定結束代碼
+// 資料不完整 (改用預設值或估算) 只列於摘要，不影響結束代碼，以免與取得失敗混淆
+func (r *RunSummary) ExitCode() int {
+	if r.Attempted > 0 && r.Succeeded == 0 {
+		return exitTotalFailure
+	}
+	if len(r.Failed) > 0 || r.Interrupted != "" {
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// Print 輸出執行統計
+func (r *RunSummary) Print() {
+	fmt.Println("\n========== 執行摘要 ==========")
+	fmt.Printf("分析 %d 檔 | 成功 %d 檔 | 失敗 %d 檔 | 資料不完整 %d 檔\n",
+		r.Attempted, r.Succeeded, len(r.Failed), len(r.Degraded))
+	if r.Interrupted != "" {
+		fmt.Printf("⏹️  執行%s\n", r.Interrupted)
+	}
+	if r.Regime != nil {
+		fmt.Printf("📈 市場狀態: %s\n", r.Regime)
+	}
+	for _, reason := range r.RunDegraded {
+		fmt.Printf("⚠️  %s\n", reason)
+	}
+
+	for _, code := range sortedKeys(r.Failed) {
+		fmt.Printf("❌ %s: %s\n", code, r.Failed[code])
+	}
+	for _, code := range sortedKeys(r.Degraded) {
+		fmt.Printf("⚠️  %s: %s\n", code, strings.Join(r.Degraded[code], ", "))
+	}
+
+	// 只列出有重試、失敗或斷路的上游主機
+	for _, host := range sortedKeys(r.Upstream) {
+		stats := r.Upstream[host]
+		if stats.Retries == 0 && stats.Failures == 0 && stats.ShortCircuited == 0 {
+			continue
+		}
+		fmt.Printf("🌐 %s: 請求 %d 次 | 重試 %d 次 | 失敗 %d 次 | 斷路略過 %d 次\n",
+			host, stats.Requests, stats.Retries, stats.Failures, stats.ShortCircuited)
+	}
+}
+
+// sortedKeys 取得排序後的map鍵值
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 上游API重試及斷路設定
+const (
+	defaultMaxRetries       = 3
+	defaultRetryBaseDelay   = 500 * time.Millisecond
+	defaultRetryMaxDelay    = 10 * time.Second
+	defaultMaxRetryAfter    = time.Minute // Retry-After 超過此時間則不再等待
+	defaultAttemptTimeout   = 20 * time.Second
+	defaultBreakerThreshold = 5 // 連續失敗次數達此值即斷路
+	defaultBreakerCooldown  = time.Minute
+	defaultClientTimeout    = 2 * time.Minute // 含重試的單次請求總時限
+)
+
+// ErrCircuitOpen 上游主機斷路中，請求未送出
+var ErrCircuitOpen = errors.New("上游服務暫停使用 (斷路中)")
+
+// RetryTransport 共用的HTTP傳輸層，對暫時性錯誤重試，並依主機斷路
+type RetryTransport struct {
+	base    http.RoundTripper
+	summary *RunSummary
+	logger  *slog.Logger
+
+	MaxRetries       int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	MaxRetryAfter    time.Duration
+	AttemptTimeout   time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewRetryTransport 建立重試傳輸層，summary 可為 nil
+func NewRetryTransport(base http.RoundTripper, summary *RunSummary) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{
+		base:             base,
+		summary:          summary,
+		logger:           slog.Default(),
+		MaxRetries:       defaultMaxRetries,
+		BaseDelay:        defaultRetryBaseDelay,
+		MaxDelay:         defaultRetryMaxDelay,
+		MaxRetryAfter:    defaultMaxRetryAfter,
+		AttemptTimeout:   defaultAttemptTimeout,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+		breakers:         make(map[string]*circuitBreaker),
+	}
+}
+
+// newHTTPClient 建立使用重試傳輸層的HTTP客戶端
+func newHTTPClient(summary *RunSummary) *http.Client {
+	return &http.Client{
+		Transport: NewRetryTransport(nil, summary),
+		Timeout:   defaultClientTimeout,
+	}
+}
+
+// RoundTrip 送出請求，對 429、5xx 及逾時以指數退避重試
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	breaker := t.breaker(host)
+
+	allowed, probe := breaker.allow(time.Now())
+	if !allowed {
+		t.summary.recordUpstream(host, upstreamShortCircuit)
+		return nil, fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+
+	for attempt := 0; ; attempt++ {
+		t.summary.recordUpstream(host, upstreamRequest)
+
+		resp, err := t.attempt(req)
+		if err != nil && req.Context().Err() != nil {
+			// 呼叫端取消不代表上游成敗，只釋放試探名額
+			breaker.release(probe)
+			return nil, err
+		}
+		if !retryable(resp, err) {
+			breaker.record(true, time.Now())
+			return resp, err
+		}
+		if req.Context().Err() != nil {
+			breaker.release(probe)
+			discardBody(resp)
+			return nil, req.Context().Err()
+		}
+
+		// 每次失敗皆計入斷路器，斷路後不再重試，避免主機停擺時每個請求都耗盡重試次數
+		opened := breaker.record(false, time.Now())
+		if opened {
+			t.logger.Warn("上游服務連續失敗，暫停使用", "host", host, "cooldown", t.BreakerCooldown)
+		}
+		delay, ok := t.retryDelay(attempt, resp)
+		if opened || !ok || !canRetry(req) {
+			t.summary.recordUpstream(host, upstreamFailure)
+			return resp, err
+		}
+
+		discardBody(resp)
+		t.summary.recordUpstream(host, upstreamRetry)
+		t.logger.Debug("重試上游請求", "host", host, "attempt", attempt+1, "delay", delay, "error", err,
+			"status", statusCode(resp))
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			breaker.release(probe)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// attempt 以單次時限送出請求，回應內容關閉時才釋放時限
+func (t *RetryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.AttemptTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.AttemptTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryDelay 計算下次重試的等待時間，ok 為 false 表示不再重試
+func (t *RetryTransport) retryDelay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt >= t.MaxRetries {
+		return 0, false
+	}
+
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return after, after <= t.MaxRetryAfter
+		}
+	}
+
+	// 指數退避加上隨機抖動 (等待時間介於上限的一半至上限)
+	delay := t.BaseDelay << attempt
+	if delay <= 0 || delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1), true
+}
+
+// breaker 取得主機的斷路器
+func (t *RetryTransport) breaker(host string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &circuitBreaker{threshold: t.BreakerThreshold, cooldown: t.BreakerCooldown}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+// retryable 判斷是否為可重試的暫時性錯誤 (呼叫端取消須另行處理)
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// 單次逾時及連線錯誤皆重試
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// canRetry 只重送冪等請求，避免通知等 POST 重複送出
+func canRetry(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// parseRetryAfter 解析 Retry-After 標頭 (秒數或HTTP日期)
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// discardBody 讀完並關閉不再使用的回應內容，以便重用連線
+func discardBody(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// statusCode 回應狀態碼，無回應時為 0
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// cancelOnClose 回應內容關閉時釋放單次請求的時限
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// circuitBreaker 單一主機的斷路器
+// 連續失敗達門檻後斷路，冷卻期過後放行一次試探請求，成功即恢復
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow 是否允許送出請求，probe 表示此請求為冷卻期後的試探請求
+func (b *circuitBreaker) allow(now time.Time) (allowed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true, false
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// release 試探請求未取得結果 (呼叫端取消) 時釋放試探名額，不影響失敗計數
+func (b *circuitBreaker) release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// record 記錄請求結果，回傳是否因此進入斷路
+func (b *circuitBreaker) record(success bool, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+		return true
+	}
+	return false
+}
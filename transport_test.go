@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc 以函式實作的上游替身
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// statusResponse 指定狀態碼的回應
+func statusResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}
+}
+
+// testTransport 建立不等待的重試傳輸層
+func testTransport(base roundTripFunc) *RetryTransport {
+	t := NewRetryTransport(base, nil)
+	t.BaseDelay, t.MaxDelay = time.Millisecond, time.Millisecond
+	t.AttemptTimeout = 0
+	t.logger = slog.New(slog.DiscardHandler)
+	return t
+}
+
+func newTestRequest(t *testing.T, ctx context.Context, method string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, method, "http://upstream.test/data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	t0 := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	b := &circuitBreaker{threshold: 2, cooldown: time.Minute}
+
+	steps := []struct {
+		name        string
+		at          time.Duration
+		action      string // allow, fail, success, release
+		wantAllowed bool
+		wantProbe   bool
+		wantOpened  bool
+	}{
+		{"關閉時放行", 0, "allow", true, false, false},
+		{"第一次失敗", 0, "fail", false, false, false},
+		{"達門檻斷路", 0, "fail", false, false, true},
+		{"冷卻期內拒絕", 30 * time.Second, "allow", false, false, false},
+		{"冷卻期後放行試探", 61 * time.Second, "allow", true, true, false},
+		{"試探中拒絕其他請求", 61 * time.Second, "allow", false, false, false},
+		{"試探取消釋放名額", 61 * time.Second, "release", false, false, false},
+		{"釋放後可再試探", 62 * time.Second, "allow", true, true, false},
+		{"試探失敗重新斷路", 62 * time.Second, "fail", false, false, true},
+		{"重新冷卻", 90 * time.Second, "allow", false, false, false},
+		{"再次試探", 123 * time.Second, "allow", true, true, false},
+		{"試探成功恢復", 123 * time.Second, "success", false, false, false},
+		{"恢復後放行", 123 * time.Second, "allow", true, false, false},
+	}
+	probe := false
+	for _, step := range steps {
+		now := t0.Add(step.at)
+		switch step.action {
+		case "allow":
+			allowed, p := b.allow(now)
+			if allowed != step.wantAllowed || p != step.wantProbe {
+				t.Fatalf("%s: allow = %v, %v, want %v, %v", step.name, allowed, p, step.wantAllowed, step.wantProbe)
+			}
+			if allowed {
+				probe = p
+			}
+		case "fail", "success":
+			if opened := b.record(step.action == "success", now); opened != step.wantOpened {
+				t.Fatalf("%s: record opened = %v, want %v", step.name, opened, step.wantOpened)
+			}
+		case "release":
+			b.release(probe)
+			if b.failures != 2 {
+				t.Fatalf("%s: failures = %d, 取消不應改變失敗計數", step.name, b.failures)
+			}
+		}
+	}
+}
+
+func TestCircuitBreakerReleaseIgnoresNonProbe(t *testing.T) {
+	b := &circuitBreaker{threshold: 1, cooldown: time.Minute, failures: 1, probing: true}
+	b.release(false)
+	if !b.probing {
+		t.Error("非試探請求不應釋放試探名額")
+	}
+}
+
+func TestRetryTransportRetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		switch calls.Add(1) {
+		case 1:
+			return statusResponse(http.StatusServiceUnavailable), nil
+		case 2:
+			return nil, errors.New("connection reset")
+		}
+		return statusResponse(http.StatusOK), nil
+	})
+
+	resp, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("RoundTrip = %v, %v", statusCode(resp), err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestRetryTransportDoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return statusResponse(http.StatusBadGateway), nil
+	})
+
+	resp, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodPost))
+	if err != nil || resp.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Errorf("status %d, err %v, calls %d", statusCode(resp), err, calls.Load())
+	}
+}
+
+func TestRetryTransportOpensBreaker(t *testing.T) {
+	var calls atomic.Int32
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return statusResponse(http.StatusInternalServerError), nil
+	})
+	transport.MaxRetries, transport.BreakerThreshold = 0, 2
+
+	for i := 0; i < 2; i++ {
+		if _, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet))
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("斷路中不應送出請求，calls = %d", calls.Load())
+	}
+}
+
+func TestRetryTransportBreakerCountsAttempts(t *testing.T) {
+	var calls atomic.Int32
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return nil, errors.New("connection refused")
+	})
+	transport.MaxRetries, transport.BreakerThreshold = 5, 3
+
+	// 每次嘗試皆計入失敗，第一個請求在第3次嘗試後即斷路，不再用完重試次數
+	if _, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet)); err == nil {
+		t.Fatal("err = nil, want connection error")
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3 (斷路門檻)", calls.Load())
+	}
+
+	_, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet))
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("斷路中不應送出請求，calls = %d", calls.Load())
+	}
+}
+
+func TestRetryTransportSuccessResetsFailures(t *testing.T) {
+	var calls atomic.Int32
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1)%3 == 0 {
+			return statusResponse(http.StatusOK), nil
+		}
+		return statusResponse(http.StatusServiceUnavailable), nil
+	})
+	transport.BreakerThreshold = 3
+
+	// 每個請求失敗2次後成功，連續失敗未達門檻
+	for i := 0; i < 3; i++ {
+		resp, err := transport.RoundTrip(newTestRequest(t, context.Background(), http.MethodGet))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: %v, %v", i, statusCode(resp), err)
+		}
+	}
+	if b := transport.breaker("upstream.test"); b.failures != 0 {
+		t.Errorf("failures = %d, want 0", b.failures)
+	}
+}
+
+// openBreaker 讓傳輸層對 upstream.test 進入斷路且冷卻期已過
+func openBreaker(transport *RetryTransport) *circuitBreaker {
+	b := transport.breaker("upstream.test")
+	b.failures = transport.BreakerThreshold
+	b.openUntil = time.Now().Add(-time.Second)
+	return b
+}
+
+func TestRetryTransportCancelledProbeReleasesBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	b := openBreaker(transport)
+
+	if _, err := transport.RoundTrip(newTestRequest(t, ctx, http.MethodGet)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if b.probing || b.failures != transport.BreakerThreshold {
+		t.Errorf("probing = %v, failures = %d: 取消應釋放試探且不記為成功", b.probing, b.failures)
+	}
+	if allowed, probe := b.allow(time.Now()); !allowed || !probe {
+		t.Errorf("取消後應可再次試探, allow = %v, %v", allowed, probe)
+	}
+}
+
+func TestRetryTransportCancelDuringBackoffReleasesBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := testTransport(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		return statusResponse(http.StatusServiceUnavailable), nil
+	})
+	transport.BaseDelay, transport.MaxDelay = time.Hour, time.Hour
+	b := openBreaker(transport)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := transport.RoundTrip(newTestRequest(t, ctx, http.MethodGet))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消後未結束等待")
+	}
+	if b.probing || b.failures != transport.BreakerThreshold {
+		t.Errorf("probing = %v, failures = %d: 取消應釋放試探且不記為成功", b.probing, b.failures)
+	}
+}
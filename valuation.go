@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valuationHistoryYears 估值分位數使用的歷史年數
+const valuationHistoryYears = 5
+
+// twseRatiosURL 證交所個股本益比、殖利率及股價淨值比 (依日期，全部上市股票)
+// 欄位: 證券代號, 證券名稱, 殖利率(%), 股利年度, 本益比, 股價淨值比, 財報年/季
+const twseRatiosURL = "https://www.twse.com.tw/exchangeReport/BWIBBU_d?response=json&date=%s&stockNo=%s"
+
+// ValuationBand 估值指標的歷史分位數及目前所在位階
+type ValuationBand struct {
+	P10        float64 `json:"p10"`
+	P25        float64 `json:"p25"`
+	P50        float64 `json:"p50"`
+	P75        float64 `json:"p75"`
+	P90        float64 `json:"p90"`
+	Percentile float64 `json:"percentile"` // 目前值在歷史中的百分位 (0-100)
+	Samples    int     `json:"samples"`
+	Since      string  `json:"since,omitempty"` // 歷史資料少於 valuationHistoryYears 年時的起始日
+}
+
+// TWSERatios 證交所公布的估值比率
+type TWSERatios struct {
+	PE            float64
+	PB            float64
+	DividendYield float64
+}
+
+// fetchTWSERatios 取得個股最近交易日的本益比、股價淨值比及殖利率
+func (s *StockScreener) fetchTWSERatios(ctx context.Context, code string) (*TWSERatios, error) {
+	tradingDay, err := s.latestTradingDate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.get(ctx, fmt.Sprintf(twseRatiosURL, tradingDay, code))
+	if err != nil {
+		return nil, fmt.Errorf("TWSE API request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Stat string  `json:"stat"`
+		Data [][]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode TWSE response: %v", err)
+	}
+
+	// 回應可能包含全部上市股票，依代碼找出該股
+	for _, row := range data.Data {
+		if len(row) < 6 || (len(data.Data) > 1 && fmt.Sprint(row[0]) != code) {
+			continue
+		}
+		return &TWSERatios{
+			DividendYield: parseRatio(row[2]),
+			PE:            parseRatio(row[4]),
+			PB:            parseRatio(row[5]),
+		}, nil
+	}
+	return nil, fmt.Errorf("no valid financial ratios found")
+}
+
+// parseRatio 解析比率欄位，"-" 或無法解析時為 0
+func parseRatio(v any) float64 {
+	value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(fmt.Sprint(v)), ",", ""), 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+// fetchValuation 取得本益比、股價淨值比、殖利率，計算PEG與盈餘殖利率，以及各指標近5年分位數
+// 需在財務資料 (EPS增長) 取得後呼叫
+func (s *StockScreener) fetchValuation(ctx context.Context, stock *StockData) error {
+	now := taipeiNow()
+	history, err := s.finmind.PER(ctx, stock.Code, now.AddDate(-valuationHistoryYears, 0, 0), now)
+	if err == nil && len(history) == 0 {
+		err = fmt.Errorf("無本益比歷史資料")
+	}
+
+	if err == nil {
+		latest := history[len(history)-1]
+		stock.PE, stock.PB, stock.DividendYield = latest.PER, latest.PBR, latest.DividendYield
+		stock.updateValuationRatios()
+
+		growth := newEPSGrowthHistory(stock.statements)
+		var pes, pbs, yields, earningsYields, pegs []float64
+		pegSince := ""
+		for _, row := range history {
+			// 虧損期間本益比為 0，不列入分位數
+			if row.PER > 0 {
+				pes = append(pes, row.PER)
+				earningsYields = append(earningsYields, 100/row.PER)
+				if g, ok := growth.asOf(row.Date); ok && g > 0 {
+					pegs = append(pegs, row.PER/g)
+					if pegSince == "" {
+						pegSince = row.Date
+					}
+				}
+			}
+			if row.PBR > 0 {
+				pbs = append(pbs, row.PBR)
+			}
+			yields = append(yields, row.DividendYield)
+		}
+		stock.PEBand = newValuationBand(pes, stock.PE)
+		stock.PBBand = newValuationBand(pbs, stock.PB)
+		stock.YieldBand = newValuationBand(yields, stock.DividendYield)
+		if stock.PE > 0 {
+			stock.EarningsYieldBand = newValuationBand(earningsYields, stock.EarningsYield)
+		}
+		// 財報只取得近3年，PEG歷史僅涵蓋可計算EPS年增率的期間 (約近2年)
+		if stock.PEG > 0 {
+			if stock.PEGBand = newValuationBand(pegs, stock.PEG); stock.PEGBand != nil {
+				stock.PEGBand.Since = pegSince
+			}
+		}
+	} else {
+		// 改用證交所當日資料，無歷史分位數
+		s.logger.Warn("無法取得估值歷史，改用TWSE當日資料", logKeyStock, stock.Code, "error", err)
+		ratios, twseErr := s.fetchTWSERatios(ctx, stock.Code)
+		if twseErr != nil {
+			return fmt.Errorf("%v; TWSE: %v", err, twseErr)
+		}
+		s.summary.markDegraded(stock.Code, "估值歷史資料失敗")
+		stock.PE, stock.PB, stock.DividendYield = ratios.PE, ratios.PB, ratios.DividendYield
+		stock.updateValuationRatios()
+	}
+
+	s.logger.Debug("估值", logKeyStock, stock.Code, "pe", stock.PE, "pb", stock.PB,
+		"yield", stock.DividendYield, "peg", stock.PEG, "earnings_yield", stock.EarningsYield)
+	return nil
+}
+
+// updateValuationRatios 計算盈餘殖利率 (1/本益比) 及PEG (本益比 ÷ EPS增長率)
+// 本益比或EPS增長不為正時無法計算，設為 0
+func (stock *StockData) updateValuationRatios() {
+	stock.EarningsYield, stock.PEG = 0, 0
+	if stock.PE <= 0 {
+		return
+	}
+	stock.EarningsYield = 100 / stock.PE
+	if stock.EPSGrowth > 0 {
+		stock.PEG = stock.PE / stock.EPSGrowth
+	}
+}
+
+// epsGrowthHistory 各季底的單季EPS年增率 (%)，依季底日期排序
+type epsGrowthHistory struct {
+	dates  []string
+	growth []float64
+}
+
+// newEPSGrowthHistory 由財報歷史計算各季與去年同季相比的EPS增長率
+// 計算方式與 EPSGrowth 相同，去年同季EPS或本季EPS不為正的季度不列入
+func newEPSGrowthHistory(h *statementHistory) epsGrowthHistory {
+	var history epsGrowthHistory
+	if h == nil {
+		return history
+	}
+	eps := series(h.income, "EPS")
+	for date := range eps {
+		history.dates = append(history.dates, date)
+	}
+	sort.Strings(history.dates)
+
+	dates := history.dates[:0]
+	for _, date := range history.dates {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			continue
+		}
+		last, ok := eps[t.AddDate(-1, 0, 0).Format("2006-01-02")]
+		if !ok || last <= 0 || eps[date] <= 0 {
+			continue
+		}
+		dates = append(dates, date)
+		history.growth = append(history.growth, (eps[date]-last)/last*100)
+	}
+	history.dates = dates
+	return history
+}
+
+// asOf 指定日期前最近一季的EPS增長率，無資料時 ok 為 false
+// 以季底日期近似財報公布日，季底至公布前的估值會採用尚未公布的季度
+func (h epsGrowthHistory) asOf(date string) (float64, bool) {
+	i := sort.SearchStrings(h.dates, date)
+	if i < len(h.dates) && h.dates[i] == date {
+		return h.growth[i], true
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return h.growth[i-1], true
+}
+
+// newValuationBand 計算歷史分位數，資料不足時為 nil
+func newValuationBand(values []float64, current float64) *ValuationBand {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	return &ValuationBand{
+		P10:        quantile(sorted, 0.10),
+		P25:        quantile(sorted, 0.25),
+		P50:        quantile(sorted, 0.50),
+		P75:        quantile(sorted, 0.75),
+		P90:        quantile(sorted, 0.90),
+		Percentile: percentileRank(sorted, current),
+		Samples:    len(sorted),
+	}
+}
+
+// quantile 已排序資料的分位數 (線性內插)
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// percentileRank 數值在已排序資料中的百分位 (小於者加上相等者的一半)
+func percentileRank(sorted []float64, v float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	below := sort.SearchFloat64s(sorted, v)
+	equal := sort.SearchFloat64s(sorted, math.Nextafter(v, math.Inf(1))) - below
+	return (float64(below) + float64(equal)/2) / float64(len(sorted)) * 100
+}
+
+// evaluateValuation 估值上限規則 (第五階段)
+// 虧損 (本益比為負，或證交所以 0 表示且EPS為負) 及淨值為負列為未通過；無資料時不列入判定
+func (s *StockScreener) evaluateValuation(stock *StockData) []RuleVerdict {
+	c := s.criteria
+	var rules []RuleVerdict
+
+	loss := stock.PE < 0 || (stock.PE == 0 && stock.EPS < 0)
+	switch {
+	case loss:
+		rules = append(rules, gradeRule("本益比", fmt.Sprintf("%.1f", stock.PE), false, false, "", "", "虧損",
+			fmt.Sprintf("虧損 (EPS %.2f)，本益比無意義", stock.EPS)))
+	case stock.PE == 0:
+		rules = append(rules, skipRule("本益比", "無資料"))
+	default:
+		rules = append(rules, gradeRule("本益比", fmt.Sprintf("%.1f", stock.PE),
+			stock.PE <= c.MaxPE, stock.PE <= c.MaxPE*1.5, "合理", "偏高", "過高",
+			fmt.Sprintf("本益比過高 %.1f", stock.PE)))
+	}
+
+	switch {
+	case stock.PB < 0:
+		rules = append(rules, gradeRule("股價淨值比", fmt.Sprintf("%.2f", stock.PB), false, false, "", "", "淨值為負",
+			fmt.Sprintf("股價淨值比為負 %.2f", stock.PB)))
+	case stock.PB == 0:
+		rules = append(rules, skipRule("股價淨值比", "無資料"))
+	default:
+		rules = append(rules, gradeRule("股價淨值比", fmt.Sprintf("%.2f", stock.PB),
+			stock.PB <= c.MaxPB, stock.PB <= c.MaxPB*1.5, "合理", "偏高", "過高",
+			fmt.Sprintf("股價淨值比過高 %.2f", stock.PB)))
+	}
+
+	// PEG及位階上限為 0 時不檢查；本益比無法判定或EPS未成長時PEG無定義，不列入判定
+	switch {
+	case c.MaxPEG <= 0:
+		rules = append(rules, skipRule("PEG", "不檢查"))
+	case stock.PE <= 0:
+		rules = append(rules, skipRule("PEG", "無本益比"))
+	case stock.PEG <= 0:
+		rules = append(rules, skipRule("PEG", fmt.Sprintf("EPS未成長 (%.1f%%)，無法計算", stock.EPSGrowth)))
+	default:
+		rules = append(rules, gradeRule("PEG", fmt.Sprintf("%.2f", stock.PEG),
+			stock.PEG <= c.MaxPEG, stock.PEG <= c.MaxPEG*1.5, "成長合理", "偏高", "成長不足以支撐估值",
+			fmt.Sprintf("PEG過高 %.2f", stock.PEG)))
+	}
+
+	switch band := stock.PEBand; {
+	case c.MaxPEPercentile <= 0:
+		rules = append(rules, skipRule("本益比位階", "不檢查"))
+	case band == nil || stock.PE <= 0:
+		rules = append(rules, skipRule("本益比位階", "無歷史資料"))
+	default:
+		rules = append(rules, gradeRule("本益比位階", fmt.Sprintf("%.0f%% (%d年)", band.Percentile, valuationHistoryYears),
+			band.Percentile <= c.MaxPEPercentile, band.Percentile <= c.WarnPEPercentile, "歷史偏低", "歷史偏高", "歷史高檔",
+			fmt.Sprintf("本益比位於近%d年 %.0f%% 高檔", valuationHistoryYears, band.Percentile)))
+	}
+
+	// PEG位階沿用本益比位階的上限；盈餘殖利率為本益比的倒數，其位階與本益比位階互補，不另列規則以免重複計算
+	switch band := stock.PEGBand; {
+	case c.MaxPEPercentile <= 0:
+		rules = append(rules, skipRule("PEG位階", "不檢查"))
+	case band == nil || stock.PEG <= 0:
+		rules = append(rules, skipRule("PEG位階", "無歷史資料"))
+	default:
+		rules = append(rules, gradeRule("PEG位階", fmt.Sprintf("%.0f%% (%s起)", band.Percentile, band.Since),
+			band.Percentile <= c.MaxPEPercentile, band.Percentile <= c.WarnPEPercentile, "歷史偏低", "歷史偏高", "歷史高檔",
+			fmt.Sprintf("PEG位於%s以來 %.0f%% 高檔", band.Since, band.Percentile)))
+	}
+	return rules
+}
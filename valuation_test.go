@@ -0,0 +1,185 @@
+package main
+
+import "testing"
+
+func TestEvaluateValuation(t *testing.T) {
+	s := testScreener(t, "default")
+	s.criteria.MaxPEG, s.criteria.MaxPEPercentile = 1.5, 80
+	tests := []struct {
+		name  string
+		stock StockData
+		want  map[string]string
+	}{
+		{
+			name:  "合理估值",
+			stock: StockData{PE: 15, PB: 2, EPS: 5, EPSGrowth: 20, PEBand: &ValuationBand{Percentile: 40}},
+			want:  map[string]string{"本益比": verdictPass, "股價淨值比": verdictPass, "PEG": verdictPass, "本益比位階": verdictPass},
+		},
+		{
+			name:  "偏高為警示",
+			stock: StockData{PE: 30, PB: 5, EPS: 5, EPSGrowth: 15},
+			want:  map[string]string{"本益比": verdictWarn, "股價淨值比": verdictWarn, "PEG": verdictWarn, "本益比位階": verdictSkip},
+		},
+		{
+			name:  "證交所以0表示虧損",
+			stock: StockData{PE: 0, PB: 1.2, EPS: -1.5},
+			want:  map[string]string{"本益比": verdictFail, "股價淨值比": verdictPass, "PEG": verdictSkip, "本益比位階": verdictSkip},
+		},
+		{
+			name:  "本益比為負",
+			stock: StockData{PE: -8, PB: 1.2, EPS: 2},
+			want:  map[string]string{"本益比": verdictFail, "PEG": verdictSkip},
+		},
+		{
+			name:  "無資料",
+			stock: StockData{EPS: 3},
+			want:  map[string]string{"本益比": verdictSkip, "股價淨值比": verdictSkip, "PEG": verdictSkip, "本益比位階": verdictSkip},
+		},
+		{
+			name:  "EPS衰退",
+			stock: StockData{PE: 12, PB: 1.5, EPS: 3, EPSGrowth: -10},
+			want:  map[string]string{"PEG": verdictSkip, "PEG位階": verdictSkip},
+		},
+		{
+			name:  "PEG位於歷史高檔",
+			stock: StockData{PE: 15, PB: 2, EPS: 5, EPSGrowth: 20, PEGBand: &ValuationBand{Percentile: 95, Since: "2023-01-03"}},
+			want:  map[string]string{"PEG": verdictPass, "PEG位階": verdictFail},
+		},
+		{
+			name:  "PEG位階偏高為警示",
+			stock: StockData{PE: 15, PB: 2, EPS: 5, EPSGrowth: 20, PEGBand: &ValuationBand{Percentile: 85, Since: "2023-01-03"}},
+			want:  map[string]string{"PEG位階": verdictWarn},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.stock.updateValuationRatios()
+			result := newStageResult(5, "估值", 0, s.evaluateValuation(&tt.stock))
+			for rule, want := range tt.want {
+				if got := ruleStatus(t, result, rule); got != want {
+					t.Errorf("%s = %s, want %s", rule, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValuationDefaultSkipsPEG(t *testing.T) {
+	s := testScreener(t, "default")
+	stock := StockData{PE: 15, PB: 2, EPS: 5, EPSGrowth: -10,
+		PEBand: &ValuationBand{Percentile: 99}, PEGBand: &ValuationBand{Percentile: 99}}
+	stock.updateValuationRatios()
+
+	// 預設不檢查PEG及位階，EPS衰退或估值位於歷史高檔不排除
+	result := newStageResult(5, "估值", 0, s.evaluateValuation(&stock))
+	for _, rule := range []string{"PEG", "本益比位階", "PEG位階"} {
+		if got := ruleStatus(t, result, rule); got != verdictSkip {
+			t.Errorf("%s = %s, want %s", rule, got, verdictSkip)
+		}
+	}
+	if len(result.Reasons) != 0 {
+		t.Errorf("Reasons = %v, want none", result.Reasons)
+	}
+}
+
+func TestValuationExcludes(t *testing.T) {
+	tests := []struct {
+		name string
+		pe   float64
+		want bool
+	}{
+		{"本益比在上限內", 15, true},
+		{"本益比在1.5倍內為警示", 30, true},
+		{"本益比超過上限排除", 200, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testScreener(t, "default")
+			stock := &StockData{Code: "2330", ROE: 10, EPS: 5, EPSGrowth: 100, PB: 2, PE: tt.pe}
+			stock.updateValuationRatios()
+			if got := s.meetsScreeningCriteria(stock); got != tt.want {
+				t.Errorf("本益比 %v: meetsScreeningCriteria = %v, want %v (PEG %v)", tt.pe, got, tt.want, stock.PEG)
+			}
+		})
+	}
+}
+
+func TestNewStageResultExcludesSkipped(t *testing.T) {
+	rules := []RuleVerdict{
+		{Rule: "a", Status: verdictPass},
+		{Rule: "b", Status: verdictWarn},
+		{Rule: "c", Status: verdictFail, Reason: "c"},
+		skipRule("d", "無資料"),
+		skipRule("e", "無資料"),
+	}
+	result := newStageResult(2, "投資品質", len(rules), rules)
+	if result.PassCount != 2 || result.TotalChecks != 3 {
+		t.Errorf("pass %d / total %d, want 2 / 3", result.PassCount, result.TotalChecks)
+	}
+	if got := result.passRatio(); !approxEqual(got, 2.0/3, 1e-12) {
+		t.Errorf("passRatio = %v", got)
+	}
+	if empty := newStageResult(2, "投資品質", 1, []RuleVerdict{skipRule("d", "")}); empty.passRatio() != 0 {
+		t.Errorf("沒有可判定規則時 passRatio = %v, want 0", empty.passRatio())
+	}
+}
+
+func TestNewValuationBand(t *testing.T) {
+	values := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	band := newValuationBand(values, 30)
+	if !approxEqual(band.P50, 55, 1e-9) || !approxEqual(band.P10, 19, 1e-9) || !approxEqual(band.P90, 91, 1e-9) {
+		t.Errorf("band = %+v", band)
+	}
+	if band.Percentile != 25 || band.Samples != 10 {
+		t.Errorf("Percentile = %v, Samples = %d, want 25, 10", band.Percentile, band.Samples)
+	}
+
+	// 盈餘殖利率為本益比的倒數，位階與本益比互補
+	var pes, earningsYields []float64
+	for _, pe := range values {
+		pes = append(pes, pe)
+		earningsYields = append(earningsYields, 100/pe)
+	}
+	peBand, eyBand := newValuationBand(pes, 80), newValuationBand(earningsYields, 100.0/80)
+	if !approxEqual(peBand.Percentile+eyBand.Percentile, 100, 1e-9) {
+		t.Errorf("本益比位階 %v + 盈餘殖利率位階 %v, want 100", peBand.Percentile, eyBand.Percentile)
+	}
+
+	if newValuationBand(nil, 1) != nil {
+		t.Error("無歷史資料時應為 nil")
+	}
+}
+
+func TestEPSGrowthHistory(t *testing.T) {
+	h := newStatementHistory()
+	for date, eps := range map[string]float64{
+		"2022-03-31": 2, "2022-06-30": -1, "2022-09-30": 4,
+		"2023-03-31": 3, "2023-06-30": 2, "2023-09-30": 5, "2023-12-31": 6,
+	} {
+		record(h.income, date, "EPS", eps)
+	}
+	growth := newEPSGrowthHistory(h)
+
+	tests := []struct {
+		date string
+		want float64
+		ok   bool
+	}{
+		{"2023-03-30", 0, false}, // 尚無去年同季
+		{"2023-03-31", 50, true},
+		{"2023-05-15", 50, true},
+		{"2023-08-01", 50, true}, // 去年同季虧損，沿用前一季
+		{"2023-10-01", 25, true},
+		{"2024-02-01", 25, true}, // 無去年同季，沿用前一季
+	}
+	for _, tt := range tests {
+		got, ok := growth.asOf(tt.date)
+		if ok != tt.ok || !approxEqual(got, tt.want, 1e-9) {
+			t.Errorf("asOf(%s) = %v, %v, want %v, %v", tt.date, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if _, ok := newEPSGrowthHistory(nil).asOf("2024-01-01"); ok {
+		t.Error("無財報時不應有EPS增長率")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// yahooChartURL Yahoo Finance 圖表API
+const yahooChartURL = "https://query1.finance.yahoo.com/v8/finance/chart/%s?interval=%s&range=%s"
+
+// yahooChartResponse Yahoo Finance 圖表API響應結構，缺值以 nil 表示
+type yahooChartResponse struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol             string  `json:"symbol"`
+				Currency           string  `json:"currency"`
+				RegularMarketPrice float64 `json:"regularMarketPrice"`
+			} `json:"meta"`
+			Timestamp  []int64 `json:"timestamp"`
+			Indicators struct {
+				Quote []struct {
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*int64   `json:"volume"`
+				} `json:"quote"`
+				AdjClose []struct {
+					AdjClose []*float64 `json:"adjclose"`
+				} `json:"adjclose"`
+			} `json:"indicators"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// parseYahooChart 解析圖表回應，任一價格缺值的K棒整根略過，避免各序列錯位
+func parseYahooChart(body []byte) (*PriceChart, error) {
+	var response yahooChartResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		preview := body
+		if len(preview) > 500 {
+			preview = preview[:500]
+		}
+		return nil, fmt.Errorf("JSON 解析錯誤: %v, 響應內容: %s", err, string(preview))
+	}
+
+	if e := response.Chart.Error; e != nil {
+		return nil, fmt.Errorf("Yahoo Finance API 錯誤: %s", e.Description)
+	}
+	if len(response.Chart.Result) == 0 {
+		return nil, fmt.Errorf("Yahoo Finance API 未回傳資料")
+	}
+
+	result := response.Chart.Result[0]
+	chart := &PriceChart{Source: priceSourceYahoo, Symbol: result.Meta.Symbol, Price: result.Meta.RegularMarketPrice}
+	if len(result.Indicators.Quote) == 0 {
+		return chart, nil
+	}
+
+	quote := result.Indicators.Quote[0]
+	var adjClose []*float64
+	if len(result.Indicators.AdjClose) > 0 {
+		adjClose = result.Indicators.AdjClose[0].AdjClose
+	}
+
+	chart.Bars = make([]Bar, 0, len(result.Timestamp))
+	for i, ts := range result.Timestamp {
+		open, okOpen := positiveAt(quote.Open, i)
+		high, okHigh := positiveAt(quote.High, i)
+		low, okLow := positiveAt(quote.Low, i)
+		closePrice, okClose := positiveAt(quote.Close, i)
+		if !okOpen || !okHigh || !okLow || !okClose {
+			continue
+		}
+
+		bar := Bar{
+			Time:     time.Unix(ts, 0).In(taipeiLocation),
+			Open:     open,
+			High:     high,
+			Low:      low,
+			Close:    closePrice,
+			AdjClose: closePrice,
+		}
+		if adj, ok := positiveAt(adjClose, i); ok {
+			bar.AdjClose = adj
+		}
+		if i < len(quote.Volume) && quote.Volume[i] != nil {
+			bar.Volume = *quote.Volume[i]
+		}
+		chart.Bars = append(chart.Bars, bar)
+	}
+
+	// 最新價缺漏時以最後一根K棒收盤價代替
+	if chart.Price <= 0 && len(chart.Bars) > 0 {
+		chart.Price = chart.Bars[len(chart.Bars)-1].Close
+	}
+	return chart, nil
+}
+
+// positiveAt 取得序列中的正值，缺值或越界時 ok 為 false
+func positiveAt(values []*float64, i int) (float64, bool) {
+	if i >= len(values) || values[i] == nil || *values[i] <= 0 {
+		return 0, false
+	}
+	return *values[i], true
+}
+
+// YahooSource Yahoo Finance 價格來源 (非官方API)
+type YahooSource struct {
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewYahooSource 建立Yahoo Finance價格來源
+func NewYahooSource(client *http.Client) *YahooSource {
+	return &YahooSource{client: client, logger: slog.Default()}
+}
+
+// Name 價格來源名稱
+func (y *YahooSource) Name() string {
+	return priceSourceYahoo
+}
+
+// FetchChart 從Yahoo Finance取得價格歷史
+func (y *YahooSource) FetchChart(ctx context.Context, code string, opts ChartOptions) (*PriceChart, error) {
+	url := fmt.Sprintf(yahooChartURL, buildYahooSymbol(code), opts.Interval, opts.Range)
+
+	// 建立請求並添加必要的 headers
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 添加 User-Agent 和其他 headers 來模擬瀏覽器請求
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	req.Header.Set("Accept-Language", "zh-TW,zh;q=0.9,en;q=0.8")
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 檢查 HTTP 狀態碼
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Yahoo Finance API 返回錯誤狀態碼: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 除錯時記錄響應內容 (前200字元)
+	preview := body
+	if len(preview) > 200 {
+		preview = preview[:200]
+	}
+	y.logger.Debug("Yahoo Finance 響應", logKeyStock, code, "body", string(preview))
+
+	return parseYahooChart(body)
+}
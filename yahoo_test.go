@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// yahooChartBody 以三個交易日 (2024/01/02-01/04) 組成圖表回應，adjclose 為空字串時省略還原收盤價區塊
+func yahooChartBody(price float64, quote, adjclose string) []byte {
+	indicators := fmt.Sprintf(`"quote":[%s]`, quote)
+	if adjclose != "" {
+		indicators += fmt.Sprintf(`,"adjclose":[{"adjclose":%s}]`, adjclose)
+	}
+	return fmt.Appendf(nil, `{"chart":{"result":[{"meta":{"symbol":"2330.TW","currency":"TWD","regularMarketPrice":%v},`+
+		`"timestamp":[1704153600,1704240000,1704326400],"indicators":{%s}}],"error":null}}`, price, indicators)
+}
+
+func TestParseYahooChart(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 8, 0, 0, 0, taipeiLocation) }
+	full := `{"open":[99,100,101],"high":[101,102,103],"low":[98,99,100],"close":[100,101,102],"volume":[1000,2000,3000]}`
+
+	tests := []struct {
+		name      string
+		body      []byte
+		wantDays  []int
+		wantClose []float64
+		wantAdj   []float64
+		wantVol   []int64
+		wantPrice float64
+		wantErr   bool
+	}{
+		{"完整資料", yahooChartBody(102.5, full, "[95,96,97]"),
+			[]int{2, 3, 4}, []float64{100, 101, 102}, []float64{95, 96, 97}, []int64{1000, 2000, 3000}, 102.5, false},
+		{"中間收盤價缺值整根略過", yahooChartBody(102.5,
+			`{"open":[99,100,101],"high":[101,102,103],"low":[98,99,100],"close":[100,null,102],"volume":[1000,null,3000]}`,
+			"[95,96,97]"),
+			[]int{2, 4}, []float64{100, 102}, []float64{95, 97}, []int64{1000, 3000}, 102.5, false},
+		{"中間開盤價缺值整根略過", yahooChartBody(102.5,
+			`{"open":[99,null,101],"high":[101,102,103],"low":[98,99,100],"close":[100,101,102],"volume":[1000,2000,3000]}`,
+			"[95,96,97]"),
+			[]int{2, 4}, []float64{100, 102}, []float64{95, 97}, []int64{1000, 3000}, 102.5, false},
+		{"最後一根缺值且無最新價", yahooChartBody(0,
+			`{"open":[99,100,null],"high":[101,102,null],"low":[98,99,null],"close":[100,101,null],"volume":[1000,2000,null]}`,
+			"[95,96,null]"),
+			[]int{2, 3}, []float64{100, 101}, []float64{95, 96}, []int64{1000, 2000}, 101, false},
+		{"缺少還原收盤價區塊", yahooChartBody(102.5, full, ""),
+			[]int{2, 3, 4}, []float64{100, 101, 102}, []float64{100, 101, 102}, []int64{1000, 2000, 3000}, 102.5, false},
+		{"還原收盤價缺值以收盤價代替", yahooChartBody(102.5, full, "[95,null,97]"),
+			[]int{2, 3, 4}, []float64{100, 101, 102}, []float64{95, 101, 97}, []int64{1000, 2000, 3000}, 102.5, false},
+		{"序列長度不足", yahooChartBody(102.5,
+			`{"open":[99,100],"high":[101,102],"low":[98,99],"close":[100,101],"volume":[1000]}`, "[95]"),
+			[]int{2, 3}, []float64{100, 101}, []float64{95, 101}, []int64{1000, 0}, 102.5, false},
+		{"無報價序列", yahooChartBody(102.5, "", ""), nil, nil, nil, nil, 102.5, false},
+		{"result為空", []byte(`{"chart":{"result":[],"error":null}}`), nil, nil, nil, nil, 0, true},
+		{"API錯誤", []byte(`{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found"}}}`),
+			nil, nil, nil, nil, 0, true},
+		{"非JSON", []byte(`<html>`), nil, nil, nil, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart, err := parseYahooChart(tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("chart = %+v, want error", chart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chart.Source != priceSourceYahoo || chart.Symbol != "2330.TW" || chart.Price != tt.wantPrice {
+				t.Errorf("chart = %s %s %v, want price %v", chart.Source, chart.Symbol, chart.Price, tt.wantPrice)
+			}
+			if len(chart.Bars) != len(tt.wantDays) {
+				t.Fatalf("bars = %+v, want %d", chart.Bars, len(tt.wantDays))
+			}
+			for i, bar := range chart.Bars {
+				if !bar.Time.Equal(day(tt.wantDays[i])) || bar.Close != tt.wantClose[i] || bar.AdjClose != tt.wantAdj[i] ||
+					bar.Volume != tt.wantVol[i] {
+					t.Errorf("bar %d = %+v, want 1/%d close %v adj %v volume %d",
+						i, bar, tt.wantDays[i], tt.wantClose[i], tt.wantAdj[i], tt.wantVol[i])
+				}
+			}
+		})
+	}
+}